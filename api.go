@@ -10,8 +10,11 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/firestore"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/firebase"
 	"github.com/easterthebunny/spew-order/internal/queue"
 	"github.com/easterthebunny/spew-order/pkg/domain"
 	"github.com/easterthebunny/spew-order/pkg/handlers"
@@ -34,6 +37,13 @@ var (
 	orderTopic = getEnvVar(envOrderTopic)
 
 	GS       *domain.OrderBook
+	Holds    *domain.HoldAuditor
+	Snaps    *domain.BalanceSnapshotter
+	Withdraw *domain.WithdrawalProcessor
+	Deposits *domain.DepositProcessor
+	Recon    *domain.Reconciler
+	Proofs   *domain.LiabilityProver
+	Compact  persist.PostCompactor
 	Router   http.Handler
 	Webhooks http.Handler
 	Audit    http.Handler
@@ -45,10 +55,6 @@ func init() {
 
 	queue.OrderTopic = orderTopic
 
-	// add funds to new accounts
-	domain.FundNewAccounts = true
-	domain.NewAccountFunds = decimal.NewFromInt(5000)
-
 	pubKey := strings.NewReader(`-----BEGIN PUBLIC KEY-----
 MIICIjANBgkqhkiG9w0BAQEFAAOCAg8AMIICCgKCAgEA9MsJBuXzFGIh/xkAA9Cy
 QdZKRerV+apyOAWY7sEYV/AJg+AX/tW2SHeZj+3OilNYm5DlBi6ZzDboczmENrFn
@@ -83,6 +89,13 @@ version: 1`)
 	}
 
 	GS = handlers.NewGoogleOrderBook(client, f, air)
+	Holds = handlers.NewGoogleHoldAuditor(client)
+	Snaps = handlers.NewGoogleBalanceSnapshotter(client)
+	Withdraw = handlers.NewGoogleWithdrawalProcessor(client, f, air)
+	Deposits = handlers.NewGoogleDepositProcessor(client, f, air)
+	Recon = handlers.NewGoogleReconciler(client, f, air)
+	Proofs = handlers.NewGoogleLiabilityProver(client)
+	Compact = handlers.NewGooglePostCompactor(client)
 
 	jwt, err := handlers.NewJWTAuth(getEnvVar(envIdentityURI))
 	if err != nil {
@@ -97,9 +110,57 @@ version: 1`)
 	types.MakerFee = 0.0025
 	types.TakerFee = 0.0050
 
+	// new accounts are paid NewAccountFunds by the default sign up campaign; the budget
+	// covers a million accounts
+	err = domain.CreateDefaultCampaigns(context.Background(), firebase.NewCampaignRepository(client), domain.NewAccountFunds.Mul(decimal.NewFromInt(1000000)))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	// withdrawals over these limits are held for manual review
+	domain.WithdrawalLimits = map[domain.AccountTier]map[types.Symbol]domain.WithdrawalLimit{
+		domain.DefaultAccountTier: {
+			types.SymbolBitcoin: {
+				PerTransaction: decimal.NewFromFloat(0.5),
+				Daily:          decimal.NewFromInt(1),
+				Monthly:        decimal.NewFromInt(5),
+			},
+			types.SymbolEthereum: {
+				PerTransaction: decimal.NewFromInt(10),
+				Daily:          decimal.NewFromInt(20),
+				Monthly:        decimal.NewFromInt(100),
+			},
+		},
+	}
+
+	// network fees charged on withdrawals
+	domain.WithdrawalFees = map[types.Symbol]domain.WithdrawalFee{
+		types.SymbolBitcoin:     {Amount: decimal.NewFromFloat(0.0002), Dynamic: true},
+		types.SymbolBitcoinCash: {Amount: decimal.NewFromFloat(0.0001)},
+		types.SymbolDogecoin:    {Amount: decimal.NewFromInt(2), Deduct: true},
+		types.SymbolEthereum:    {Amount: decimal.NewFromFloat(0.003), Dynamic: true},
+	}
+
+	// network confirmations required before deposits are credited
+	domain.RequiredConfirmations = map[types.Symbol]int{
+		types.SymbolBitcoin:     3,
+		types.SymbolBitcoinCash: 6,
+		types.SymbolDogecoin:    20,
+		types.SymbolEthereum:    12,
+	}
+
+	// differences between customer balances and custodian holdings under these
+	// amounts are not reported
+	domain.ReconciliationThresholds = map[types.Symbol]decimal.Decimal{
+		types.SymbolBitcoin:     decimal.NewFromFloat(0.0001),
+		types.SymbolBitcoinCash: decimal.NewFromFloat(0.001),
+		types.SymbolDogecoin:    decimal.NewFromInt(10),
+		types.SymbolEthereum:    decimal.NewFromFloat(0.001),
+	}
+
 	Router = rh.Routes()
 	Webhooks = handlers.NewWebhookRouter(client, f, air).Routes()
-	Audit = handlers.NewAuditRouter(client).Routes()
+	Audit = handlers.NewAuditRouter(client, f, air).Routes()
 }
 
 // RestAPI forwards all rest requests to the main API handler.
@@ -129,12 +190,140 @@ func OrderPubSub(ctx context.Context, m domain.PubSubMessage) error {
 	if msg.Action == domain.CancelOrderMessageType {
 		return GS.CancelOrder(ctx, msg.Order)
 	} else if msg.Action == domain.OpenOrderMessageType {
-		return GS.ExecuteOrInsertOrder(ctx, msg.Order)
+		return GS.ExecuteOrReject(ctx, msg.Order)
 	}
 
 	return nil
 }
 
+// OrphanedHoldsPubSub consumes a scheduled Pub/Sub message and reports balance
+// holds not referenced by an open order along with orders flagged for review. Holds are only released when the message
+// sets release to true.
+func OrphanedHoldsPubSub(ctx context.Context, m domain.PubSubMessage) error {
+
+	var msg struct {
+		Release bool `json:"release"`
+	}
+	if len(m.Data) > 0 {
+		if err := json.Unmarshal(m.Data, &msg); err != nil {
+			return err
+		}
+	}
+
+	orphans, err := Holds.FindOrphanedHolds(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range orphans {
+		log.Printf("orphaned hold %s on account %s: %s %s", o.Hold.ID, o.Account, o.Hold.Amount.StringFixedBank(o.Symbol.RoundingPlace()), o.Symbol)
+	}
+
+	flagged, err := Holds.FindFlaggedOrders(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range flagged {
+		log.Printf("order %s on account %s flagged for review: %s", o.Base.ID, o.Base.Account, o.Reason)
+	}
+
+	if !msg.Release {
+		return nil
+	}
+
+	released, err := Holds.ReleaseOrphanedHolds(ctx, orphans)
+	log.Printf("released %d of %d orphaned holds", len(released), len(orphans))
+
+	return err
+}
+
+// BalanceSnapshotPubSub consumes a scheduled Pub/Sub message and saves the current
+// balances of every account as the snapshot for the day.
+func BalanceSnapshotPubSub(ctx context.Context, m domain.PubSubMessage) error {
+
+	cnt, err := Snaps.SnapshotAll(ctx, time.Now())
+	log.Printf("saved balance snapshots for %d accounts", cnt)
+
+	return err
+}
+
+// WithdrawalPubSub consumes a scheduled Pub/Sub message and moves requested
+// withdrawals through approval, broadcast and confirmation.
+func WithdrawalPubSub(ctx context.Context, m domain.PubSubMessage) error {
+
+	run, err := Withdraw.ProcessAll(ctx)
+	if run != nil {
+		for _, w := range run.Stuck {
+			log.Printf("withdrawal %s on account %s stuck broadcasting since %s", w.ID, w.Account, time.Time(w.Updated).UTC().Format(time.RFC3339))
+		}
+
+		log.Printf("withdrawals: %d completed, %d pending, %d in review, %d stuck", len(run.Completed), len(run.Pending), len(run.Review), len(run.Stuck))
+	}
+
+	return err
+}
+
+// DepositPubSub consumes a scheduled Pub/Sub message and credits pending deposits
+// that have reached the required network confirmations.
+func DepositPubSub(ctx context.Context, m domain.PubSubMessage) error {
+
+	run, err := Deposits.ProcessAll(ctx)
+	if run != nil {
+		log.Printf("deposits: %d credited, %d pending", len(run.Credited), len(run.Pending))
+	}
+
+	return err
+}
+
+// ReconciliationPubSub consumes a scheduled Pub/Sub message and compares customer
+// balances with the holdings reported by the funding sources.
+func ReconciliationPubSub(ctx context.Context, m domain.PubSubMessage) error {
+
+	run, err := Recon.Reconcile(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, e := range run.Errors {
+		log.Printf("reconciliation %s: source error: %s", run.ID, e)
+	}
+
+	for _, i := range run.Discrepant() {
+		place := i.Symbol.RoundingPlace()
+		log.Printf("reconciliation %s: %s holdings %s do not match liabilities %s or payable %s",
+			run.ID, i.Symbol, i.Total.StringFixedBank(place), i.Liabilities.StringFixedBank(place), i.Payable.StringFixedBank(place))
+	}
+
+	log.Printf("reconciliation %s: %d symbols, %d discrepancies", run.ID, len(run.Items), len(run.Discrepant()))
+
+	return nil
+}
+
+// LiabilityProofPubSub consumes a scheduled Pub/Sub message and publishes a new proof of
+// liabilities tree over all account balances.
+func LiabilityProofPubSub(ctx context.Context, m domain.PubSubMessage) error {
+
+	t, err := Proofs.Generate(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	log.Printf("liabilities %s: %d accounts, root %s", t.ID, t.Accounts, t.Root.Hash)
+
+	return nil
+}
+
+// BalanceCompactionPubSub consumes a scheduled Pub/Sub message and rolls pending balance
+// posts up into the balance shards so balance reads stay short.
+func BalanceCompactionPubSub(ctx context.Context, m domain.PubSubMessage) error {
+
+	cnt, err := Compact.CompactPosts(ctx)
+	log.Printf("compacted %d balance posts", cnt)
+
+	return err
+}
+
 func getEnvVar(key string) string {
 	keyEnv, ok := os.LookupEnv(key)
 	if !ok {
@@ -60,7 +60,7 @@ func main() {
 	}
 
 	wh := handlers.NewWebhookRouter(client, f, air)
-	ah := handlers.NewAuditRouter(client)
+	ah := handlers.NewAuditRouter(client, f, air)
 
 	wg := new(sync.WaitGroup)
 
@@ -108,8 +108,8 @@ func main() {
 					panic(err)
 				}
 			} else if om.Action == domain.OpenOrderMessageType {
-				if err := book.ExecuteOrInsertOrder(context.Background(), om.Order); err != nil {
-					log.Printf("ExecuteOrInsertOrder::%s", err)
+				if err := book.ExecuteOrReject(context.Background(), om.Order); err != nil {
+					log.Printf("ExecuteOrReject::%s", err)
 					panic(err)
 				}
 			}
@@ -60,7 +60,7 @@ func printOpenOrders(ctx context.Context, repo persist.AccountRepository) {
 func printClosedOrders(ctx context.Context, repo persist.AccountRepository) {
 	a := &persist.Account{ID: *account}
 
-	orders, err := repo.Orders(a).GetOrdersByStatus(ctx, persist.StatusCanceled, persist.StatusFilled)
+	orders, err := repo.Orders(a).GetOrdersByStatus(ctx, persist.StatusCanceled, persist.StatusFilled, persist.StatusPartialCanceled)
 	if err != nil {
 		log.Println(err)
 	}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/firebase"
+	"github.com/easterthebunny/spew-order/pkg/domain"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	projectID = flag.String("project", "", "Google project id.")
+	account   = flag.String("account", "", "account id")
+	from      = flag.String("from", "", "first day of the statement; YYYY-MM-DD")
+	to        = flag.String("to", "", "last day of the statement; YYYY-MM-DD")
+	format    = flag.String("format", "csv", "statement format; csv or ofx")
+	out       = flag.String("out", "", "output file; defaults to stdout")
+)
+
+func main() {
+	flag.Parse()
+
+	ctx := context.Background()
+
+	id, err := uuid.FromString(*account)
+	if err != nil {
+		log.Fatalf("invalid account id: %s", err)
+	}
+
+	start, err := time.Parse(persist.SnapshotDateFormat, *from)
+	if err != nil {
+		log.Fatalf("invalid from date: %s", err)
+	}
+
+	end, err := time.Parse(persist.SnapshotDateFormat, *to)
+	if err != nil {
+		log.Fatalf("invalid to date: %s", err)
+	}
+
+	client, err := firestore.NewClient(ctx, *projectID)
+	if err != nil {
+		panic(err)
+	}
+
+	bm := domain.NewBalanceManager(firebase.NewAccountRepository(client), firebase.NewLedgerRepository(client))
+
+	st, err := bm.GetStatement(ctx, &domain.Account{ID: id}, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := st.Write(w, domain.StatementFormat(*format)); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/firebase"
+	"github.com/easterthebunny/spew-order/pkg/domain"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/olekukonko/tablewriter"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	projectID = flag.String("project", "", "Google project id.")
+	create    = flag.Bool("create", false, "create a new campaign from the campaign flags")
+	grants    = flag.String("grants", "", "campaign uuid to list the grants of")
+	name      = flag.String("name", "", "campaign name")
+	rule      = flag.String("rule", "", "campaign rule: sign_up_bonus, first_deposit_match, first_trade_reward or referral_reward")
+	symbol    = flag.String("symbol", types.SymbolCipherMtn.String(), "symbol granted by the campaign")
+	amount    = flag.String("amount", "", "fixed amount of each grant")
+	matchRate = flag.String("match-rate", "", "share of a first deposit matched by the grant")
+	budget    = flag.String("budget", "", "total amount the campaign can grant")
+	limit     = flag.Int("limit", 1, "grants allowed for each account owner")
+	start     = flag.String("start", "", "RFC3339 time the campaign starts; defaults to now")
+	end       = flag.String("end", "", "RFC3339 time the campaign ends")
+)
+
+func main() {
+	flag.Parse()
+
+	ctx := context.Background()
+
+	client, err := firestore.NewClient(ctx, *projectID)
+	if err != nil {
+		panic(err)
+	}
+
+	repo := firebase.NewCampaignRepository(client)
+
+	if *create {
+		createCampaign(ctx, repo)
+		return
+	}
+
+	if *grants != "" {
+		listGrants(ctx, repo)
+		return
+	}
+
+	list, err := repo.GetCampaigns(ctx)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	printCampaigns("campaigns", list)
+}
+
+// createCampaign never replaces a stored campaign so the amount already granted
+// cannot be reset
+func createCampaign(ctx context.Context, repo persist.CampaignRepository) {
+	c, err := campaign()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if err = domain.ValidateCampaign(c); err != nil {
+		log.Println(err)
+		return
+	}
+
+	err = repo.CreateCampaign(ctx, c)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	printCampaigns("created campaign", []*persist.Campaign{c})
+}
+
+func campaign() (*persist.Campaign, error) {
+	sym, err := types.FromString(*symbol)
+	if err != nil {
+		return nil, fmt.Errorf("invalid symbol: %w", err)
+	}
+
+	from := time.Now()
+	if *start != "" {
+		from, err = time.Parse(time.RFC3339, *start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start: %w", err)
+		}
+	}
+
+	to, err := time.Parse(time.RFC3339, *end)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end: %w", err)
+	}
+
+	c := persist.NewCampaign(*name, persist.CampaignRule(*rule), sym, from, to)
+	c.PerAccountLimit = *limit
+
+	for _, f := range []struct {
+		name string
+		in   string
+		out  *decimal.Decimal
+	}{
+		{name: "amount", in: *amount, out: &c.Amount},
+		{name: "match-rate", in: *matchRate, out: &c.MatchRate},
+		{name: "budget", in: *budget, out: &c.Budget},
+	} {
+		if f.in == "" {
+			continue
+		}
+
+		d, err := decimal.NewFromString(f.in)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", f.name, err)
+		}
+		*f.out = d
+	}
+
+	return c, nil
+}
+
+func listGrants(ctx context.Context, repo persist.CampaignRepository) {
+	id, err := uuid.FromString(*grants)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	_, err = repo.GetCampaign(ctx, id)
+	if err != nil {
+		if errors.Is(err, persist.ErrObjectNotExist) {
+			log.Printf("campaign %s not found", id)
+			return
+		}
+		log.Println(err)
+		return
+	}
+
+	list, err := repo.GetGrants(ctx, id)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	printGrants(fmt.Sprintf("grants for campaign %s", id), list)
+}
+
+func printCampaigns(title string, campaigns []*persist.Campaign) {
+
+	fmt.Println("")
+	fmt.Printf("----------- %s -----------", title)
+	fmt.Println("")
+
+	now := time.Now()
+	data := [][]string{}
+	for _, c := range campaigns {
+		place := c.Symbol.RoundingPlace()
+		data = append(data, []string{
+			c.ID,
+			c.Name,
+			string(c.Rule),
+			c.Symbol.String(),
+			c.Amount.StringFixedBank(place),
+			c.MatchRate.String(),
+			c.Budget.StringFixedBank(place),
+			c.Granted.StringFixedBank(place),
+			strconv.Itoa(c.PerAccountLimit),
+			time.Time(c.Start).UTC().Format(time.RFC3339),
+			time.Time(c.End).UTC().Format(time.RFC3339),
+			strconv.FormatBool(c.Active(now)),
+		})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeader([]string{"ID", "Name", "Rule", "Symbol", "Amount", "Match Rate", "Budget", "Granted", "Limit", "Start", "End", "Active"})
+	table.SetBorder(false)
+	table.AppendBulk(data)
+	table.Render()
+
+	fmt.Println("")
+}
+
+func printGrants(title string, grants []*persist.Grant) {
+
+	fmt.Println("")
+	fmt.Printf("----------- %s -----------", title)
+	fmt.Println("")
+
+	data := [][]string{}
+	for _, g := range grants {
+		data = append(data, []string{
+			g.ID,
+			g.Owner,
+			g.Account,
+			g.Event,
+			g.Amount.StringFixedBank(g.Symbol.RoundingPlace()),
+			g.Symbol.String(),
+			string(g.Status),
+			time.Time(g.Timestamp).UTC().Format(time.RFC3339),
+		})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeader([]string{"ID", "Owner", "Account", "Event", "Amount", "Symbol", "Status", "Created"})
+	table.SetBorder(false)
+	table.AppendBulk(data)
+	table.Render()
+
+	fmt.Println("")
+}
@@ -29,7 +29,7 @@ func main() {
 
 	router := chi.NewRouter()
 	router.Use(h.AccountCtx(bm, paramFunc))
-	router.Get(fmt.Sprintf("/{%s}", api.AccountPathParamName), h.GetAccount())
+	router.Get(fmt.Sprintf("/{%s}", api.AccountPathParamName), h.GetAccount(nil))
 
 	start := time.Now()
 	wg := new(sync.WaitGroup)
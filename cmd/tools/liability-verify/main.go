@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/easterthebunny/spew-order/internal/merkle"
+	"github.com/easterthebunny/spew-order/pkg/api"
+	"github.com/olekukonko/tablewriter"
+)
+
+var (
+	proofFile = flag.String("proof", "", "response from /accounts/{accountID}/liabilities/proof saved to a file")
+	rootFile  = flag.String("root", "", "response from the public /liabilities endpoint saved to a file")
+	account   = flag.String("account", "", "account uuid the proof was requested for")
+)
+
+func main() {
+	flag.Parse()
+
+	if *proofFile == "" || *rootFile == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var proof api.LiabilityProof
+	if err := readData(*proofFile, &proof); err != nil {
+		log.Fatal(err)
+	}
+
+	var root api.LiabilityRoot
+	if err := readData(*rootFile, &root); err != nil {
+		log.Fatal(err)
+	}
+
+	if proof.TreeId != root.Id {
+		log.Fatalf("proof is for tree %s but the published tree is %s", proof.TreeId, root.Id)
+	}
+
+	// the account hash is rebuilt from the account id so the leaf is known to be ours
+	accountHash := proof.AccountHash
+	if *account != "" {
+		accountHash = merkle.AccountHash(*account, proof.Nonce)
+		if accountHash != proof.AccountHash {
+			log.Fatalf("account hash does not match account %s", *account)
+		}
+	}
+
+	leaf, err := api.MerkleFromLiabilityNode(proof.Leaf)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	p := merkle.Proof{Leaf: merkle.NewLeaf(accountHash, leaf.Sums)}
+	if p.Leaf.Hash != proof.Leaf.Hash {
+		log.Fatal("leaf hash does not match the account hash and balances")
+	}
+
+	for _, s := range proof.Path {
+		n, err := api.MerkleFromLiabilityNode(api.LiabilityNode{Hash: s.Hash, Sums: s.Sums})
+		if err != nil {
+			log.Fatal(err)
+		}
+		p.Path = append(p.Path, merkle.Step{Node: n, Left: s.Left})
+	}
+
+	published, err := api.MerkleFromLiabilityNode(root.Root)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := p.Verify(published); err != nil {
+		log.Fatalf("verification failed: %s", err)
+	}
+
+	fmt.Printf("tree %s published %s with %d accounts\n", root.Id, root.Timestamp, root.Accounts)
+	fmt.Printf("root %s\n\n", root.Root.Hash)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Symbol", "Your Balance", "Total Liabilities"})
+	for _, s := range root.Root.Sums {
+		table.Append([]string{
+			string(s.Symbol),
+			leaf.Sums[string(s.Symbol)].String(),
+			string(s.Quantity),
+		})
+	}
+	table.Render()
+
+	fmt.Println("\nbalances are included in the published liabilities")
+}
+
+// readData reads an api response from a file. Both the full response and the data
+// object alone are accepted.
+func readData(name string, v interface{}) error {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	var res struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(b, &res); err == nil && len(res.Data) > 0 {
+		b = res.Data
+	}
+
+	return json.Unmarshal(b, v)
+}
@@ -62,7 +62,7 @@ func main() {
 				repo := firebase.NewAccountRepository(client).Orders(a)
 
 				var orders []*persist.Order
-				orders, err = repo.GetOrdersByStatus(ctx, persist.StatusCanceled, persist.StatusFilled, persist.StatusOpen, persist.StatusPartial)
+				orders, err = repo.GetOrdersByStatus(ctx, persist.StatusCanceled, persist.StatusFilled, persist.StatusOpen, persist.StatusPartial, persist.StatusPartialCanceled)
 				if err != nil {
 					log.Println(err)
 					return
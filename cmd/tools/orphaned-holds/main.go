@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/domain"
+	"github.com/easterthebunny/spew-order/pkg/handlers"
+	"github.com/olekukonko/tablewriter"
+)
+
+var (
+	projectID = flag.String("project", "", "Google project id.")
+	release   = flag.Bool("release", false, "release orphaned holds and record each in the audit log")
+	grace     = flag.Duration("grace", domain.HoldGracePeriod, "minimum hold age before it is considered orphaned")
+)
+
+func main() {
+	flag.Parse()
+
+	ctx := context.Background()
+
+	client, err := firestore.NewClient(ctx, *projectID)
+	if err != nil {
+		panic(err)
+	}
+
+	domain.HoldGracePeriod = *grace
+	auditor := handlers.NewGoogleHoldAuditor(client)
+
+	orphans, err := auditor.FindOrphanedHolds(ctx)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	printHolds("orphaned holds", orphans)
+
+	flagged, err := auditor.FindFlaggedOrders(ctx)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	printOrders("orders flagged for review", flagged)
+
+	if !*release || len(orphans) == 0 {
+		return
+	}
+
+	released, err := auditor.ReleaseOrphanedHolds(ctx, orphans)
+	printHolds("released holds", released)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func printHolds(title string, holds []domain.OrphanedHold) {
+
+	fmt.Println("")
+	fmt.Printf("----------- %s -----------", title)
+	fmt.Println("")
+
+	data := [][]string{}
+	for _, o := range holds {
+		data = append(data, []string{
+			o.Account,
+			o.Symbol.String(),
+			o.Hold.ID,
+			o.Hold.Amount.StringFixedBank(o.Symbol.RoundingPlace()),
+			time.Time(o.Hold.Timestamp).UTC().Format(time.RFC3339),
+		})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeader([]string{"Account", "Symbol", "Hold", "Amount", "Created"})
+	table.SetBorder(false)
+	table.AppendBulk(data)
+	table.Render()
+
+	fmt.Println("")
+}
+
+func printOrders(title string, orders []*persist.Order) {
+
+	fmt.Println("")
+	fmt.Printf("----------- %s -----------", title)
+	fmt.Println("")
+
+	data := [][]string{}
+	for _, o := range orders {
+		data = append(data, []string{
+			o.Base.Account.String(),
+			o.Base.ID.String(),
+			o.Status.String(),
+			string(o.Reason),
+			o.Base.HoldID,
+			o.Base.FeeHoldID,
+		})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeader([]string{"Account", "Order", "Status", "Reason", "Hold", "Fee Hold"})
+	table.SetBorder(false)
+	table.AppendBulk(data)
+	table.Render()
+
+	fmt.Println("")
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/firebase"
+	"github.com/easterthebunny/spew-order/pkg/domain"
+	"github.com/easterthebunny/spew-order/pkg/handlers"
+	"github.com/olekukonko/tablewriter"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	projectID  = flag.String("project", "", "Google project id.")
+	account    = flag.String("account", "", "account uuid of the withdrawal to review")
+	withdrawal = flag.String("withdrawal", "", "withdrawal uuid to review")
+	approve    = flag.Bool("approve", false, "approve the withdrawal so it is sent")
+	reject     = flag.Bool("reject", false, "reject the withdrawal and refund the held funds")
+	note       = flag.String("note", "", "reason recorded on a rejected withdrawal")
+	tier       = flag.String("tier", "", "assign the account to a withdrawal limit tier")
+)
+
+func main() {
+	flag.Parse()
+
+	ctx := context.Background()
+
+	client, err := firestore.NewClient(ctx, *projectID)
+	if err != nil {
+		panic(err)
+	}
+
+	ar := firebase.NewAccountRepository(client)
+	bm := domain.NewBalanceManager(ar, firebase.NewLedgerRepository(client))
+
+	if *tier != "" {
+		setTier(ctx, ar, bm)
+		return
+	}
+
+	if *approve || *reject {
+		review(ctx, bm)
+		return
+	}
+
+	list, err := handlers.NewGoogleWithdrawalProcessor(client).FindReview(ctx)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	printWithdrawals("withdrawals in review", list)
+}
+
+// setTier only assigns a tier to an existing account so a mistyped id cannot create
+// a new one
+func setTier(ctx context.Context, ar persist.AccountRepository, bm *domain.BalanceManager) {
+	id, err := uuid.FromString(*account)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	_, err = ar.Find(ctx, id)
+	if err != nil {
+		if errors.Is(err, persist.ErrObjectNotExist) {
+			log.Printf("account %s not found", id)
+			return
+		}
+		log.Println(err)
+		return
+	}
+
+	a := &domain.Account{ID: id}
+	err = bm.SetAccountTier(ctx, a, domain.AccountTier(*tier))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	fmt.Printf("account %s assigned to tier %s\n", a.ID, a.Tier)
+}
+
+func review(ctx context.Context, bm *domain.BalanceManager) {
+	if *approve == *reject {
+		log.Println("use one of -approve or -reject")
+		return
+	}
+
+	id, err := uuid.FromString(*account)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	wid, err := uuid.FromString(*withdrawal)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	w, err := bm.GetWithdrawal(ctx, &domain.Account{ID: id}, wid)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	err = bm.ReviewWithdrawal(ctx, w, *approve, *note)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	printWithdrawals("reviewed withdrawal", []*persist.Withdrawal{w})
+}
+
+func printWithdrawals(title string, list []*persist.Withdrawal) {
+
+	fmt.Println("")
+	fmt.Printf("----------- %s -----------", title)
+	fmt.Println("")
+
+	data := [][]string{}
+	for _, w := range list {
+		data = append(data, []string{
+			w.Account,
+			w.ID,
+			w.Symbol.String(),
+			w.Amount.StringFixedBank(w.Symbol.RoundingPlace()),
+			w.Address,
+			string(w.Status),
+			w.Reason,
+			time.Time(w.Created).UTC().Format(time.RFC3339),
+		})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeader([]string{"Account", "Withdrawal", "Symbol", "Amount", "Address", "Status", "Reason", "Created"})
+	table.SetBorder(false)
+	table.AppendBulk(data)
+	table.Render()
+
+	fmt.Println("")
+}
@@ -83,6 +83,7 @@ func (s *airdropSource) Callback() func(http.Handler) http.Handler {
 					TransactionHash: posting.TransactionHash,
 					Address:         posting.Address,
 					Amount:          decimal.NewFromInt(int64(amt)),
+					Confirmed:       true,
 				}
 
 				ctx = attachToContext(ctx, tr, nil)
@@ -113,6 +114,12 @@ func (s *airdropSource) Withdraw(tr *Transaction) (string, error) {
 	return "", ErrNotImplemented
 }
 
+// Balances returns no holdings; airdropped funds are issued by the exchange and are not
+// held by a custodian
+func (s *airdropSource) Balances() (map[types.Symbol]decimal.Decimal, error) {
+	return map[types.Symbol]decimal.Decimal{}, nil
+}
+
 func (s *airdropSource) OKResponse() int {
 	return http.StatusOK
 }
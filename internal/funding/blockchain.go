@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
 )
 
 func NewBlockchainSource() Source {
@@ -35,7 +36,11 @@ func (b *blockchainSource) Callback() func(http.Handler) http.Handler {
 }
 
 func (b *blockchainSource) Withdraw(*Transaction) (string, error) {
-	return "", errors.New("not implemented")
+	return "", ErrNotImplemented
+}
+
+func (b *blockchainSource) Balances() (map[types.Symbol]decimal.Decimal, error) {
+	return nil, ErrNotImplemented
 }
 
 func (b *blockchainSource) OKResponse() int {
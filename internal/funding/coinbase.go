@@ -170,7 +170,7 @@ func (s *coinbaseSource) Withdraw(t *Transaction) (trhash string, err error) {
 	if !ok {
 		err = s.getAccounts()
 		if err != nil {
-			err = fmt.Errorf("Withdraw::%w", err)
+			err = fmt.Errorf("Withdraw: %w: %s", ErrWithdrawalNotSent, err)
 			return
 		}
 
@@ -180,7 +180,7 @@ func (s *coinbaseSource) Withdraw(t *Transaction) (trhash string, err error) {
 			for k, _ := range s.accounts {
 				keys = append(keys, k)
 			}
-			err = fmt.Errorf("Withdraw: account not found '%s' -> %v", t.Symbol.String(), keys)
+			err = fmt.Errorf("Withdraw: %w: account not found '%s' -> %v", ErrWithdrawalNotSent, t.Symbol.String(), keys)
 			return
 		}
 	}
@@ -212,6 +212,12 @@ func (s *coinbaseSource) Withdraw(t *Transaction) (trhash string, err error) {
 	}
 	defer resp.Body.Close()
 
+	// a client error means the send was refused; any other failure may have sent funds
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		err = fmt.Errorf("Withdraw: %w: unexpected response code '%d'", ErrWithdrawalNotSent, resp.StatusCode)
+		return
+	}
+
 	if resp.StatusCode != http.StatusCreated {
 		err = fmt.Errorf("Withdraw: unexpected response code '%d'", resp.StatusCode)
 		return
@@ -233,6 +239,89 @@ func (s *coinbaseSource) Withdraw(t *Transaction) (trhash string, err error) {
 	return obj.Network.Hash, nil
 }
 
+// DepositConfirmations looks up the network status of a received payment by the
+// transaction resource path sent with the payment notification
+func (s *coinbaseSource) DepositConfirmations(t *Transaction) (confirmations int, confirmed bool, err error) {
+
+	if t.Reference == "" {
+		err = fmt.Errorf("DepositConfirmations: %w: missing transaction reference", ErrInvalidTransaction)
+		return
+	}
+
+	resp, err := s.request("GET", t.Reference, nil)
+	if err != nil {
+		err = fmt.Errorf("DepositConfirmations::%w", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("DepositConfirmations: unexpected response code '%d'", resp.StatusCode)
+		return
+	}
+
+	data, err := s.extractResponsePayload(resp.Body)
+	if err != nil {
+		err = fmt.Errorf("DepositConfirmations::%w", err)
+		return
+	}
+
+	var obj coinbaseTransactionResourceFullV2
+	err = json.Unmarshal(data.Data, &obj)
+	if err != nil {
+		err = fmt.Errorf("DepositConfirmations (unmarshal transaction resource): %w", err)
+		return
+	}
+
+	return obj.Network.Confirmations, obj.Network.Status == "confirmed", nil
+}
+
+// Balances totals the wallet account balances for each supported symbol
+func (s *coinbaseSource) Balances() (map[types.Symbol]decimal.Decimal, error) {
+	path := "/v2/accounts?limit=100"
+
+	bals := make(map[types.Symbol]decimal.Decimal)
+	for path != "" {
+		resp, err := s.request("GET", path, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Balances::%w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("Balances: unexpected response code '%d'", resp.StatusCode)
+		}
+
+		payload, err := s.extractResponsePayload(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Balances::%w", err)
+		}
+
+		var accts []coinbaseAccountResourceV2
+		err = json.Unmarshal(payload.Data, &accts)
+		if err != nil {
+			return nil, fmt.Errorf("Balances (unmarshal error): %w", err)
+		}
+
+		for _, acct := range accts {
+			sym, err := types.FromString(string(acct.Balance.Currency))
+			if err != nil || !s.Supports(sym) {
+				continue
+			}
+
+			bals[sym] = bals[sym].Add(acct.Balance.Amount)
+		}
+
+		path = ""
+		if payload.Pagination != nil {
+			path = payload.Pagination.NextURI
+		}
+	}
+
+	return bals, nil
+}
+
 func (s *coinbaseSource) OKResponse() int {
 	return http.StatusOK
 }
@@ -397,6 +486,7 @@ func (s *coinbaseSource) transactionFromBody(body io.Reader) (Transaction, error
 			TransactionHash: pmt.Hash,
 			Address:         adr.Address,
 			Amount:          pmt.Amount.Amount,
+			Reference:       pmt.Transaction.Path,
 		}
 	}
 
@@ -523,13 +613,19 @@ type coinbaseTransactionResourceFullV2 struct {
 }
 
 type coinbaseNetworkDetailV2 struct {
-	Status string `json:"status"`
-	Hash   string `json:"hash"`
-	Name   string `json:"name"`
+	Status        string `json:"status"`
+	Hash          string `json:"hash"`
+	Name          string `json:"name"`
+	Confirmations int    `json:"confirmations"`
 }
 
 type coinbaseResponsePayloadV2 struct {
-	Data json.RawMessage `json:"data"`
+	Pagination *coinbasePaginationV2 `json:"pagination,omitempty"`
+	Data       json.RawMessage       `json:"data"`
+}
+
+type coinbasePaginationV2 struct {
+	NextURI string `json:"next_uri"`
 }
 
 type coinbaseNotificationPayloadV2 struct {
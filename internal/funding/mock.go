@@ -14,6 +14,7 @@ import (
 
 	"github.com/easterthebunny/spew-order/pkg/types"
 	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
 )
 
 func NewMockSource() Source {
@@ -96,6 +97,19 @@ func (s *mockSource) Withdraw(*Transaction) (string, error) {
 	return encoded, nil
 }
 
+// Balances returns no holdings; the mock source does not hold funds
+func (s *mockSource) Balances() (map[types.Symbol]decimal.Decimal, error) {
+	return map[types.Symbol]decimal.Decimal{}, nil
+}
+
 func (s *mockSource) OKResponse() int {
 	return http.StatusOK
 }
+
+func (s *mockSource) WithdrawalConfirmed(*Transaction) (bool, error) {
+	return true, nil
+}
+
+func (s *mockSource) DepositConfirmations(t *Transaction) (int, bool, error) {
+	return t.Confirmations, true, nil
+}
@@ -13,6 +13,9 @@ import (
 var (
 	ErrInvalidTransaction    = errors.New("transaction detail is invalid")
 	ErrRequestBodyParseError = errors.New("request body parse error")
+	// ErrWithdrawalNotSent is wrapped by withdrawal errors that are known to have
+	// happened before any funds were sent
+	ErrWithdrawalNotSent = errors.New("withdrawal not sent")
 )
 
 type contextKey int
@@ -32,6 +35,16 @@ type Transaction struct {
 	TransactionHash string
 	Address         string
 	Amount          decimal.Decimal
+	// OutputIndex identifies the output paid to the address when a transaction pays
+	// more than one deposit address
+	OutputIndex int
+	// Reference is a source specific identifier used to look up the transaction later
+	Reference string
+	// Confirmations is the number of network confirmations reported for a deposit
+	Confirmations int
+	// Confirmed is set when the source considers the deposit final regardless of the
+	// number of confirmations
+	Confirmed bool
 }
 
 type Address struct {
@@ -47,9 +60,32 @@ type Source interface {
 	Callback() func(http.Handler) http.Handler
 	CreateAddress(types.Symbol) (*Address, error)
 	Withdraw(*Transaction) (string, error)
+	// Balances returns the funds held by the source for each symbol it custodies.
+	// Sources that do not hold funds return no balances.
+	Balances() (map[types.Symbol]decimal.Decimal, error)
 	OKResponse() int
 }
 
+// WithdrawalTracker is implemented by sources that can report whether a sent
+// withdrawal has been confirmed by the network. Withdrawals sent through sources
+// without tracking are confirmed once the source accepts them.
+type WithdrawalTracker interface {
+	WithdrawalConfirmed(*Transaction) (bool, error)
+}
+
+// DepositTracker is implemented by sources that can report confirmation updates for a
+// received deposit. Deposits from sources without tracking are only updated when the
+// source sends another notification.
+type DepositTracker interface {
+	DepositConfirmations(*Transaction) (confirmations int, confirmed bool, err error)
+}
+
+// FeeEstimator is implemented by sources that can quote the current network fee for
+// sending a withdrawal
+type FeeEstimator interface {
+	EstimateWithdrawalFee(*Transaction) (decimal.Decimal, error)
+}
+
 type SourceConfig struct {
 	CallbackAudit io.Writer
 	PublicKey     io.Reader
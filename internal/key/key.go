@@ -1,5 +1,10 @@
 package key
 
+import (
+	"fmt"
+	"strconv"
+)
+
 // Key ...
 type Key []byte
 
@@ -7,3 +12,31 @@ type Key []byte
 func (k Key) String() string {
 	return Printable(k)
 }
+
+// Parse returns the key described by a string produced by String.
+func Parse(s string) (Key, error) {
+	k := make(Key, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			k = append(k, s[i])
+			continue
+		}
+
+		switch {
+		case i+1 < len(s) && s[i+1] == '\\':
+			k = append(k, '\\')
+			i++
+		case i+3 < len(s) && s[i+1] == 'x':
+			b, err := strconv.ParseUint(s[i+2:i+4], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid escape in key %q: %w", s, err)
+			}
+			k = append(k, byte(b))
+			i += 3
+		default:
+			return nil, fmt.Errorf("invalid escape in key %q", s)
+		}
+	}
+
+	return k, nil
+}
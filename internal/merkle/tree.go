@@ -0,0 +1,200 @@
+package merkle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrNegativeBalance = errors.New("negative balance")
+	ErrEmptyTree       = errors.New("tree has no leaves")
+	ErrLeafIndex       = errors.New("leaf index out of range")
+	ErrProofMismatch   = errors.New("proof does not match root")
+)
+
+// Sums are amounts by symbol name
+type Sums map[string]decimal.Decimal
+
+// Add returns the total of both sums
+func (s Sums) Add(o Sums) Sums {
+	out := make(Sums)
+	for k, v := range s {
+		out[k] = v
+	}
+	for k, v := range o {
+		out[k] = out[k].Add(v)
+	}
+	return out
+}
+
+// Equal reports whether both sums have the same amount for every symbol
+func (s Sums) Equal(o Sums) bool {
+	return s.encode() == o.encode()
+}
+
+// encode writes the sums in symbol order. Zero amounts are left out so the encoding
+// does not depend on which symbols are present.
+func (s Sums) encode() string {
+	var names []string
+	for k, v := range s {
+		if !v.IsZero() {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, k := range names {
+		parts[i] = fmt.Sprintf("%s=%s", k, s[k].String())
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// Node is a hash and the total of all balances below it
+type Node struct {
+	Hash string `json:"hash"`
+	Sums Sums   `json:"sums"`
+}
+
+// AccountHash hides an account id behind a nonce that only the account holder is given
+func AccountHash(id, nonce string) string {
+	return hash("account", id, nonce)
+}
+
+// NewLeaf creates the leaf for an account hash and its balances
+func NewLeaf(accountHash string, s Sums) Node {
+	return Node{Hash: hash("leaf", accountHash, s.encode()), Sums: s}
+}
+
+func parent(l, r Node) Node {
+	return Node{
+		Hash: hash("node", l.Hash, l.Sums.encode(), r.Hash, r.Sums.encode()),
+		Sums: l.Sums.Add(r.Sums),
+	}
+}
+
+// empty pads levels with an odd number of nodes. It adds nothing to the sums so no
+// balance is counted twice.
+func empty() Node {
+	return Node{Hash: hash("empty"), Sums: Sums{}}
+}
+
+func hash(parts ...string) string {
+	h := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(h[:])
+}
+
+// Tree is a Merkle sum tree. Every node commits to the hashes and balance totals of its
+// children so the root commits to the total of every leaf.
+type Tree struct {
+	levels [][]Node
+	leaves int
+}
+
+// New builds a tree from the leaves. Negative balances are rejected because they would
+// let an operator hide liabilities in the totals.
+func New(leaves []Node) (*Tree, error) {
+	if len(leaves) == 0 {
+		return nil, ErrEmptyTree
+	}
+
+	for i, l := range leaves {
+		for k, v := range l.Sums {
+			if v.IsNegative() {
+				return nil, fmt.Errorf("%w: leaf %d %s", ErrNegativeBalance, i, k)
+			}
+		}
+	}
+
+	level := append([]Node(nil), leaves...)
+	t := &Tree{levels: [][]Node{level}, leaves: len(leaves)}
+	for len(level) > 1 {
+		if len(level)%2 == 1 {
+			level = append(level, empty())
+			t.levels[len(t.levels)-1] = level
+		}
+
+		next := make([]Node, len(level)/2)
+		for i := range next {
+			next[i] = parent(level[2*i], level[2*i+1])
+		}
+
+		t.levels = append(t.levels, next)
+		level = next
+	}
+
+	return t, nil
+}
+
+// Root returns the top node of the tree
+func (t *Tree) Root() Node {
+	return t.levels[len(t.levels)-1][0]
+}
+
+// Proof returns the path from a leaf to the root
+func (t *Tree) Proof(i int) (*Proof, error) {
+	if i < 0 || i >= t.leaves {
+		return nil, ErrLeafIndex
+	}
+
+	p := &Proof{Leaf: t.levels[0][i], Path: []Step{}}
+	for _, level := range t.levels[:len(t.levels)-1] {
+		sibling := i ^ 1
+		p.Path = append(p.Path, Step{Node: level[sibling], Left: sibling < i})
+		i /= 2
+	}
+
+	return p, nil
+}
+
+// Step is a sibling on the path from a leaf to the root
+type Step struct {
+	Node
+	// Left is true when the sibling is the left child
+	Left bool `json:"left"`
+}
+
+// Proof shows that a leaf is included in a tree
+type Proof struct {
+	Leaf Node   `json:"leaf"`
+	Path []Step `json:"path"`
+}
+
+// Verify rebuilds the root from the proof and checks it against the published root.
+// Any step with a negative sum fails because it would reduce the published totals.
+func (p Proof) Verify(root Node) error {
+	nodes := []Node{p.Leaf}
+	for _, s := range p.Path {
+		nodes = append(nodes, s.Node)
+	}
+
+	for _, n := range nodes {
+		for k, v := range n.Sums {
+			if v.IsNegative() {
+				return fmt.Errorf("%w: %s", ErrNegativeBalance, k)
+			}
+		}
+	}
+
+	n := p.Leaf
+	for _, s := range p.Path {
+		if s.Left {
+			n = parent(s.Node, n)
+		} else {
+			n = parent(n, s.Node)
+		}
+	}
+
+	if n.Hash != root.Hash || !n.Sums.Equal(root.Sums) {
+		return ErrProofMismatch
+	}
+
+	return nil
+}
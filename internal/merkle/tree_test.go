@@ -0,0 +1,71 @@
+package merkle
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreeProofs(t *testing.T) {
+	for n := 1; n <= 7; n++ {
+		t.Run(fmt.Sprintf("%d leaves", n), func(t *testing.T) {
+			var leaves []Node
+			total := decimal.Zero
+			for i := 0; i < n; i++ {
+				amt := decimal.NewFromInt(int64(i + 1))
+				total = total.Add(amt)
+				leaves = append(leaves, NewLeaf(AccountHash(fmt.Sprint(i), "nonce"), Sums{"BTC": amt}))
+			}
+
+			tree, err := New(leaves)
+			assert.NoError(t, err)
+
+			root := tree.Root()
+			assert.True(t, root.Sums["BTC"].Equal(total), root.Sums["BTC"].String())
+
+			for i := range leaves {
+				p, err := tree.Proof(i)
+				assert.NoError(t, err)
+				assert.NoError(t, p.Verify(root))
+			}
+
+			_, err = tree.Proof(n)
+			assert.ErrorIs(t, err, ErrLeafIndex)
+		})
+	}
+}
+
+func TestProofTampering(t *testing.T) {
+	leaves := []Node{
+		NewLeaf(AccountHash("a", "1"), Sums{"BTC": decimal.NewFromInt(1)}),
+		NewLeaf(AccountHash("b", "2"), Sums{"BTC": decimal.NewFromInt(2), "ETH": decimal.NewFromInt(3)}),
+		NewLeaf(AccountHash("c", "3"), Sums{"ETH": decimal.NewFromInt(4)}),
+	}
+
+	tree, err := New(leaves)
+	assert.NoError(t, err)
+	root := tree.Root()
+
+	// a smaller balance for the account does not match the root
+	p, _ := tree.Proof(1)
+	p.Leaf = NewLeaf(AccountHash("b", "2"), Sums{"BTC": decimal.NewFromInt(1), "ETH": decimal.NewFromInt(3)})
+	assert.ErrorIs(t, p.Verify(root), ErrProofMismatch)
+
+	// sibling sums cannot be negative to offset a balance
+	p, _ = tree.Proof(0)
+	p.Path[0].Sums = Sums{"BTC": decimal.NewFromInt(-1)}
+	assert.ErrorIs(t, p.Verify(root), ErrNegativeBalance)
+
+	// understating the published totals fails
+	p, _ = tree.Proof(2)
+	lower := Node{Hash: root.Hash, Sums: Sums{"BTC": decimal.NewFromInt(3), "ETH": decimal.NewFromInt(6)}}
+	assert.ErrorIs(t, p.Verify(lower), ErrProofMismatch)
+
+	_, err = New([]Node{NewLeaf("x", Sums{"BTC": decimal.NewFromInt(-1)})})
+	assert.ErrorIs(t, err, ErrNegativeBalance)
+
+	_, err = New(nil)
+	assert.ErrorIs(t, err, ErrEmptyTree)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"github.com/easterthebunny/spew-order/internal/persist"
@@ -102,6 +103,34 @@ func (r *AccountRepository) Orders(a *persist.Account) persist.OrderRepository {
 	return NewOrderRepository(r.client, a)
 }
 
+func (r *AccountRepository) Idempotency(a *persist.Account) persist.IdempotencyRepository {
+	return NewIdempotencyRepository(r.client, a)
+}
+
+func (r *AccountRepository) Snapshots(a *persist.Account) persist.BalanceSnapshotRepository {
+	return NewBalanceSnapshotRepository(r.client, a)
+}
+
+func (r *AccountRepository) Withdrawals(a *persist.Account) persist.WithdrawalRepository {
+	return NewWithdrawalRepository(r.client, a)
+}
+
+func (r *AccountRepository) Allowlist(a *persist.Account) persist.AllowlistRepository {
+	return NewAllowlistRepository(r.client, a)
+}
+
+func (r *AccountRepository) Deposits(a *persist.Account) persist.DepositRepository {
+	return NewDepositRepository(r.client, a)
+}
+
+func (r *AccountRepository) DepositRegistry() persist.DepositRegistry {
+	return NewDepositRegistry(r.client)
+}
+
+func (r *AccountRepository) Campaigns() persist.CampaignRepository {
+	return NewCampaignRepository(r.client)
+}
+
 type ky string
 
 func (k ky) String() string {
@@ -110,7 +139,23 @@ func (k ky) String() string {
 
 func accountToDocument(a *persist.Account) map[string]interface{} {
 	m := map[string]interface{}{
-		"id": a.ID,
+		"id":        a.ID,
+		"label":     a.Label,
+		"archived":  a.Archived,
+		"tier":      a.Tier,
+		"allowlist": a.Allowlist,
+	}
+
+	if a.Owner != "" {
+		m["owner"] = a.Owner
+	}
+
+	if a.FirstTrade != "" {
+		m["first_trade"] = a.FirstTrade
+	}
+
+	if a.AllowlistDisableAt != nil {
+		m["allowlist_disable_at"] = a.AllowlistDisableAt.Value()
 	}
 
 	addr := []interface{}{}
@@ -133,6 +178,35 @@ func documentToAccount(m map[string]interface{}) *persist.Account {
 		acct.ID = v.(string)
 	}
 
+	if v, ok := m["label"]; ok {
+		acct.Label = v.(string)
+	}
+
+	if v, ok := m["archived"]; ok {
+		acct.Archived = v.(bool)
+	}
+
+	if v, ok := m["tier"]; ok {
+		acct.Tier = v.(string)
+	}
+
+	if v, ok := m["allowlist"]; ok {
+		acct.Allowlist = v.(bool)
+	}
+
+	if v, ok := m["allowlist_disable_at"]; ok {
+		t := persist.NanoTime(time.Unix(0, v.(int64)))
+		acct.AllowlistDisableAt = &t
+	}
+
+	if v, ok := m["owner"]; ok {
+		acct.Owner = v.(string)
+	}
+
+	if v, ok := m["first_trade"]; ok {
+		acct.FirstTrade = v.(string)
+	}
+
 	if v, ok := m["addresses"]; ok {
 		addrs := []persist.FundingAddress{}
 
@@ -0,0 +1,155 @@
+package firebase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// /root/account/{accountid}/allowlist/{entryid}
+type AllowlistRepository struct {
+	client  *firestore.Client
+	account *persist.Account
+}
+
+func NewAllowlistRepository(client *firestore.Client, account *persist.Account) *AllowlistRepository {
+	return &AllowlistRepository{client: client, account: account}
+}
+
+func (ar *AllowlistRepository) SetAllowlistEntry(ctx context.Context, e *persist.AllowlistEntry) error {
+	if e == nil {
+		return fmt.Errorf("%w for allowlist entry", persist.ErrCannotSaveNilValue)
+	}
+
+	_, err := ar.collection(ctx).Doc(e.ID).Set(ctx, allowlistEntryToDocument(e))
+	if err != nil {
+		return fmt.Errorf("SetAllowlistEntry: %w", err)
+	}
+
+	return nil
+}
+
+func (ar *AllowlistRepository) GetAllowlistEntry(ctx context.Context, k persist.Key) (*persist.AllowlistEntry, error) {
+
+	dsnap, err := ar.collection(ctx).Doc(k.String()).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, persist.ErrObjectNotExist
+		}
+
+		return nil, fmt.Errorf("GetAllowlistEntry: %w", err)
+	}
+
+	e, err := documentToAllowlistEntry(dsnap.Data())
+	if err != nil {
+		return nil, fmt.Errorf("GetAllowlistEntry: %w", err)
+	}
+
+	return e, nil
+}
+
+func (ar *AllowlistRepository) GetAllowlist(ctx context.Context) (list []*persist.AllowlistEntry, err error) {
+	iter := ar.collection(ctx).OrderBy("created", firestore.Asc).Documents(ctx)
+
+	var doc *firestore.DocumentSnapshot
+	for {
+		doc, err = iter.Next()
+		if err != nil {
+			if errors.Is(err, iterator.Done) {
+				err = nil
+			} else {
+				err = fmt.Errorf("GetAllowlist: %w", err)
+			}
+
+			break
+		}
+
+		var e *persist.AllowlistEntry
+		e, err = documentToAllowlistEntry(doc.Data())
+		if err != nil {
+			err = fmt.Errorf("GetAllowlist: %w", err)
+			return
+		}
+
+		list = append(list, e)
+	}
+
+	return
+}
+
+func (ar *AllowlistRepository) DeleteAllowlistEntry(ctx context.Context, k persist.Key) error {
+	_, err := ar.collection(ctx).Doc(k.String()).Delete(ctx)
+	if err != nil {
+		return fmt.Errorf("DeleteAllowlistEntry: %w", err)
+	}
+
+	return nil
+}
+
+func (ar *AllowlistRepository) collection(ctx context.Context) *firestore.CollectionRef {
+	return ar.getClient(ctx).Collection(fmt.Sprintf("accounts/%s/allowlist", ar.account.ID))
+}
+
+func (ar *AllowlistRepository) getClient(ctx context.Context) *firestore.Client {
+
+	var client *firestore.Client
+	if ar.client == nil {
+		client = clientFromContext(ctx)
+	} else {
+		client = ar.client
+	}
+	return client
+}
+
+func allowlistEntryToDocument(e *persist.AllowlistEntry) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        e.ID,
+		"symbol":    e.Symbol.String(),
+		"address":   e.Address,
+		"label":     e.Label,
+		"created":   e.Created.Value(),
+		"active_at": e.ActiveAt.Value(),
+	}
+}
+
+func documentToAllowlistEntry(m map[string]interface{}) (*persist.AllowlistEntry, error) {
+	e := &persist.AllowlistEntry{}
+
+	if v, ok := m["id"]; ok {
+		e.ID = v.(string)
+	}
+
+	if v, ok := m["symbol"]; ok {
+		sym, err := types.FromString(v.(string))
+		if err != nil {
+			return nil, err
+		}
+		e.Symbol = sym
+	}
+
+	if v, ok := m["address"]; ok {
+		e.Address = v.(string)
+	}
+
+	if v, ok := m["label"]; ok {
+		e.Label = v.(string)
+	}
+
+	if v, ok := m["created"]; ok {
+		e.Created = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	if v, ok := m["active_at"]; ok {
+		e.ActiveAt = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	return e, nil
+}
@@ -0,0 +1,142 @@
+package firebase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"google.golang.org/api/iterator"
+)
+
+// /audit/{entryid}
+type AuditRepository struct {
+	client *firestore.Client
+}
+
+func NewAuditRepository(client *firestore.Client) *AuditRepository {
+	return &AuditRepository{client: client}
+}
+
+func (ar *AuditRepository) SetAuditEntry(ctx context.Context, e *persist.AuditEntry) error {
+	if e == nil {
+		return fmt.Errorf("%w for audit entry", persist.ErrCannotSaveNilValue)
+	}
+
+	_, err := ar.getClient(ctx).Collection("audit").Doc(e.ID).Set(ctx, auditEntryToDocument(e))
+	if err != nil {
+		return fmt.Errorf("SetAuditEntry: %w", err)
+	}
+
+	return nil
+}
+
+func (ar *AuditRepository) GetAuditEntries(ctx context.Context) (entries []*persist.AuditEntry, err error) {
+	iter := ar.getClient(ctx).Collection("audit").OrderBy("timestamp", firestore.Asc).Documents(ctx)
+	var doc *firestore.DocumentSnapshot
+	for {
+		doc, err = iter.Next()
+		if err != nil {
+			if errors.Is(err, iterator.Done) {
+				err = nil
+			} else {
+				err = fmt.Errorf("GetAuditEntries: %w", err)
+			}
+
+			break
+		}
+
+		entries = append(entries, documentToAuditEntry(doc.Data()))
+	}
+
+	return
+}
+
+func (ar *AuditRepository) GetAccountAuditEntries(ctx context.Context, account string) (entries []*persist.AuditEntry, err error) {
+	iter := ar.getClient(ctx).Collection("audit").
+		Where("account", "==", account).
+		OrderBy("timestamp", firestore.Asc).
+		Documents(ctx)
+
+	var doc *firestore.DocumentSnapshot
+	for {
+		doc, err = iter.Next()
+		if err != nil {
+			if errors.Is(err, iterator.Done) {
+				err = nil
+			} else {
+				err = fmt.Errorf("GetAccountAuditEntries: %w", err)
+			}
+
+			break
+		}
+
+		entries = append(entries, documentToAuditEntry(doc.Data()))
+	}
+
+	return
+}
+
+func (ar *AuditRepository) getClient(ctx context.Context) *firestore.Client {
+
+	var client *firestore.Client
+	if ar.client == nil {
+		client = clientFromContext(ctx)
+	} else {
+		client = ar.client
+	}
+	return client
+}
+
+func auditEntryToDocument(e *persist.AuditEntry) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        e.ID,
+		"type":      string(e.Type),
+		"account":   e.Account,
+		"symbol":    e.Symbol,
+		"reference": e.Reference,
+		"amount":    e.Amount,
+		"detail":    e.Detail,
+		"timestamp": e.Timestamp.Value(),
+	}
+}
+
+func documentToAuditEntry(m map[string]interface{}) *persist.AuditEntry {
+	e := &persist.AuditEntry{}
+
+	if v, ok := m["id"]; ok {
+		e.ID = v.(string)
+	}
+
+	if v, ok := m["type"]; ok {
+		e.Type = persist.AuditEntryType(v.(string))
+	}
+
+	if v, ok := m["account"]; ok {
+		e.Account = v.(string)
+	}
+
+	if v, ok := m["symbol"]; ok {
+		e.Symbol = v.(string)
+	}
+
+	if v, ok := m["reference"]; ok {
+		e.Reference = v.(string)
+	}
+
+	if v, ok := m["amount"]; ok {
+		e.Amount = v.(string)
+	}
+
+	if v, ok := m["detail"]; ok {
+		e.Detail = v.(string)
+	}
+
+	if v, ok := m["timestamp"]; ok {
+		e.Timestamp = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	return e
+}
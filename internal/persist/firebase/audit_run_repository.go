@@ -0,0 +1,161 @@
+package firebase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// /audit_runs/{runid}
+type AuditRunRepository struct {
+	client *firestore.Client
+}
+
+func NewAuditRunRepository(client *firestore.Client) *AuditRunRepository {
+	return &AuditRunRepository{client: client}
+}
+
+func (ar *AuditRunRepository) SetAuditRun(ctx context.Context, r *persist.AuditRun) error {
+	if r == nil {
+		return fmt.Errorf("%w for audit run", persist.ErrCannotSaveNilValue)
+	}
+
+	_, err := ar.getClient(ctx).Collection("audit_runs").Doc(r.ID).Set(ctx, auditRunToDocument(r))
+	if err != nil {
+		return fmt.Errorf("SetAuditRun: %w", err)
+	}
+
+	return nil
+}
+
+func (ar *AuditRunRepository) GetAuditRun(ctx context.Context, k persist.Key) (*persist.AuditRun, error) {
+
+	dsnap, err := ar.getClient(ctx).Collection("audit_runs").Doc(k.String()).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, persist.ErrObjectNotExist
+		}
+
+		return nil, fmt.Errorf("GetAuditRun: %w", err)
+	}
+
+	r, err := documentToAuditRun(dsnap.Data())
+	if err != nil {
+		return nil, fmt.Errorf("GetAuditRun: %w", err)
+	}
+
+	return r, nil
+}
+
+func (ar *AuditRunRepository) GetAuditRuns(ctx context.Context) (runs []*persist.AuditRun, err error) {
+	iter := ar.getClient(ctx).Collection("audit_runs").OrderBy("timestamp", firestore.Asc).Documents(ctx)
+	var doc *firestore.DocumentSnapshot
+	for {
+		doc, err = iter.Next()
+		if err != nil {
+			if errors.Is(err, iterator.Done) {
+				err = nil
+			} else {
+				err = fmt.Errorf("GetAuditRuns: %w", err)
+			}
+
+			break
+		}
+
+		var r *persist.AuditRun
+		r, err = documentToAuditRun(doc.Data())
+		if err != nil {
+			err = fmt.Errorf("GetAuditRuns: %w", err)
+			break
+		}
+
+		runs = append(runs, r)
+	}
+
+	return
+}
+
+func (ar *AuditRunRepository) getClient(ctx context.Context) *firestore.Client {
+
+	var client *firestore.Client
+	if ar.client == nil {
+		client = clientFromContext(ctx)
+	} else {
+		client = ar.client
+	}
+	return client
+}
+
+func auditRunToDocument(r *persist.AuditRun) map[string]interface{} {
+	values := make(map[string]interface{})
+	for s, vals := range r.Values {
+		m := make(map[string]interface{})
+		for name, amt := range vals {
+			m[name] = amt.String()
+		}
+		values[s.String()] = m
+	}
+
+	errs := make([]interface{}, len(r.Errors))
+	for i, e := range r.Errors {
+		errs[i] = e
+	}
+
+	return map[string]interface{}{
+		"id":        r.ID,
+		"timestamp": r.Timestamp.Value(),
+		"as_of":     r.AsOf.Value(),
+		"values":    values,
+		"errors":    errs,
+	}
+}
+
+func documentToAuditRun(m map[string]interface{}) (*persist.AuditRun, error) {
+	r := &persist.AuditRun{}
+
+	if v, ok := m["id"]; ok {
+		r.ID = v.(string)
+	}
+
+	if v, ok := m["timestamp"]; ok {
+		r.Timestamp = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	if v, ok := m["as_of"]; ok {
+		r.AsOf = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	if v, ok := m["values"]; ok {
+		for name, x := range v.(map[string]interface{}) {
+			sym, err := types.FromString(name)
+			if err != nil {
+				return nil, err
+			}
+
+			for field, y := range x.(map[string]interface{}) {
+				amt, err := decimal.NewFromString(y.(string))
+				if err != nil {
+					return nil, err
+				}
+				r.Set(sym, field, amt)
+			}
+		}
+	}
+
+	if v, ok := m["errors"]; ok {
+		for _, x := range v.([]interface{}) {
+			r.Errors = append(r.Errors, x.(string))
+		}
+	}
+
+	return r, nil
+}
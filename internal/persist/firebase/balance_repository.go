@@ -40,6 +40,12 @@ type balanceDocument struct {
 	Created time.Time `firestore:"created"`
 }
 
+// holdGuardDocument is written by every transaction that checks the available balance
+// before creating a hold
+type holdGuardDocument struct {
+	Updated time.Time `firestore:"updated"`
+}
+
 type balanceItemDocument struct {
 	Version   int64     `firestore:"version"`
 	ID        string    `firestore:"id"`
@@ -48,102 +54,77 @@ type balanceItemDocument struct {
 	Amount    string    `firestore:"amount"`
 }
 
-// GetBalance gets the balance record for the defined repository parameters. If the record does
-// not exist, it is created. This method continually rolls up all balance postings into the
-// balance document and avoids the firestore write contention by checking the last update time
-// on the balance document and the create date on the post document being rolled up. All updates
-// are completed as a batch operation.
+// GetBalance reads the balance document, the balance shards and any posts that have not
+// been compacted in a single read only transaction. Reads never write so busy accounts
+// do not contend with themselves; posts are rolled up by the PostCompactor.
 func (b *BalanceRepository) GetBalance(ctx context.Context) (balance decimal.Decimal, err error) {
 
-	balance = decimal.NewFromInt(0)
-	storeBalance := decimal.NewFromInt(0)
-
-	balRef := b.getSymbolDocumentRef(ctx)
 	err = b.getClient(ctx).RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
 		var txErr error
-		execUpdate := false
+		balance, txErr = b.readBalance(ctx, tx)
+		return txErr
+	}, firestore.ReadOnly)
 
-		// get the balance document from storage
-		// if the document is not found, attempt to create it
-		balDoc, txErr := tx.Get(balRef)
-		if txErr != nil {
-			if status.Code(txErr) != codes.NotFound {
-				return txErr
-			}
+	return balance, err
+}
 
-			newDoc := &balanceDocument{
-				Balance: "0",
-				Created: time.Now(),
-				Updated: time.Now(),
-			}
+// readBalance totals the balance document, all shards and all posts in the transaction.
+// A missing balance document is a zero balance.
+func (b *BalanceRepository) readBalance(ctx context.Context, tx *firestore.Transaction) (balance decimal.Decimal, err error) {
+	balance = decimal.NewFromInt(0)
 
-			return tx.Set(balRef, newDoc)
+	balDoc, err := tx.Get(b.getSymbolDocumentRef(ctx))
+	if err != nil {
+		if status.Code(err) != codes.NotFound {
+			return
 		}
-
-		docBalString, txErr := balDoc.DataAt("balance")
-		if txErr != nil {
-			return txErr
+		err = nil
+	} else {
+		var doc balanceDocument
+		if err = balDoc.DataTo(&doc); err != nil {
+			return
 		}
 
-		balance, txErr = decimal.NewFromString(docBalString.(string))
-		if txErr != nil {
-			return txErr
+		if doc.Balance != "" {
+			balance, err = decimal.NewFromString(doc.Balance)
+			if err != nil {
+				return
+			}
 		}
-		storeBalance = balance
-
-		// collect all post items with their document refs
-		iter := tx.Documents(b.getPostCollection(ctx))
-		var doc *firestore.DocumentSnapshot
-		for {
-			doc, txErr = iter.Next()
-			if txErr != nil {
-				if errors.Is(txErr, iterator.Done) {
-					txErr = nil
-				}
+	}
 
-				break
-			}
-			execUpdate = true
+	shards, err := sumDocuments(tx, b.getShardCollection(ctx), "balance")
+	if err != nil {
+		return
+	}
 
-			amtStr, txErr := doc.DataAt("amount")
-			if txErr != nil {
-				return txErr
-			}
+	posts, err := sumDocuments(tx, b.getPostCollection(ctx), "amount")
+	if err != nil {
+		return
+	}
 
-			amt, txErr := decimal.NewFromString(amtStr.(string))
-			if txErr != nil {
-				return txErr
-			}
+	balance = balance.Add(shards).Add(posts)
+	return
+}
 
-			// the returned balance amount should have all posts applied to it
-			balance = balance.Add(amt)
+// sumDocuments totals a decimal string field over every document in a collection
+func sumDocuments(tx *firestore.Transaction, col *firestore.CollectionRef, field string) (decimal.Decimal, error) {
+	total := decimal.NewFromInt(0)
 
-			// the post is allowed to be deleted only if the balance document AND
-			// the post document are last updated more than 1 second ago
-			// if they can both be edited, add the post amount to the change
-			// amount and delete the post
-			if canChange(doc.UpdateTime) && canChange(balDoc.UpdateTime) {
-				storeBalance = storeBalance.Add(amt)
-				txErr = tx.Delete(doc.Ref)
-				if txErr != nil {
-					return txErr
-				}
-			}
-		}
+	docs, err := tx.Documents(col).GetAll()
+	if err != nil {
+		return total, err
+	}
 
-		// if the balance document can be updated and it needs to be updated
-		// execute the update
-		if execUpdate && canChange(balDoc.UpdateTime) {
-			return tx.Set(balRef, map[string]interface{}{
-				"balance": storeBalance.StringFixedBank(b.symbol.RoundingPlace()),
-				"updated": time.Now(),
-			}, firestore.MergeAll)
-		} else {
-			return nil
+	for _, doc := range docs {
+		amt, err := decimalAt(doc, field)
+		if err != nil {
+			return total, err
 		}
-	})
+		total = total.Add(amt)
+	}
 
-	return balance, err
+	return total, nil
 }
 
 func (b *BalanceRepository) AddToBalance(ctx context.Context, amt decimal.Decimal) error {
@@ -166,10 +147,18 @@ func (b *BalanceRepository) getPostCollection(ctx context.Context) *firestore.Co
 	return b.getSymbolDocumentRef(ctx).Collection("posts")
 }
 
+func (b *BalanceRepository) getShardCollection(ctx context.Context) *firestore.CollectionRef {
+	return b.getSymbolDocumentRef(ctx).Collection("shards")
+}
+
 func (b *BalanceRepository) getHoldCollection(ctx context.Context) *firestore.CollectionRef {
 	return b.getSymbolDocumentRef(ctx).Collection("holds")
 }
 
+func (b *BalanceRepository) getHoldGuardRef(ctx context.Context) *firestore.DocumentRef {
+	return b.getSymbolDocumentRef(ctx).Collection("guards").Doc("holds")
+}
+
 func (b *BalanceRepository) getSymbolDocumentRef(ctx context.Context) *firestore.DocumentRef {
 	return b.getClient(ctx).Collection("accounts").Doc(b.account.ID).Collection("symbols").Doc(b.symbol.String())
 }
@@ -291,6 +280,84 @@ func (b *BalanceRepository) CreateHold(ctx context.Context, hold *persist.Balanc
 	return err
 }
 
+// CreateHoldIfAvailable reads the balance document, shards, unapplied posts and current
+// holds and creates the hold in the same transaction. A hold guard document is read and
+// written in every hold transaction so that concurrent holds on the same balance conflict
+// and are retried without contending with reads and writes of the balance document.
+func (b *BalanceRepository) CreateHoldIfAvailable(ctx context.Context, hold *persist.BalanceItem) error {
+	if hold == nil {
+		return fmt.Errorf("%w for hold", persist.ErrCannotSaveNilValue)
+	}
+
+	guardRef := b.getHoldGuardRef(ctx)
+	holdRef := b.getHoldCollection(ctx).NewDoc()
+	err := b.getClient(ctx).RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		// a new hold is not a change to any document read by a concurrent transaction
+		// so the guard is what makes them conflict
+		_, txErr := tx.Get(guardRef)
+		if txErr != nil && status.Code(txErr) != codes.NotFound {
+			return txErr
+		}
+
+		available, txErr := b.readBalance(ctx, tx)
+		if txErr != nil {
+			return txErr
+		}
+
+		holds, txErr := tx.Documents(b.getHoldCollection(ctx)).GetAll()
+		if txErr != nil {
+			return txErr
+		}
+
+		// only the latest version of each hold counts against the balance
+		latest := make(map[string]balanceItemDocument)
+		for _, h := range holds {
+			var item balanceItemDocument
+			if txErr = h.DataTo(&item); txErr != nil {
+				return txErr
+			}
+
+			if v, ok := latest[item.ID]; !ok || item.Version > v.Version {
+				latest[item.ID] = item
+			}
+		}
+
+		for _, item := range latest {
+			amt, txErr := decimal.NewFromString(item.Amount)
+			if txErr != nil {
+				return txErr
+			}
+			available = available.Sub(amt)
+		}
+
+		if available.LessThan(hold.Amount) {
+			return persist.ErrInsufficientBalance
+		}
+
+		txErr = tx.Set(guardRef, &holdGuardDocument{Updated: time.Now()})
+		if txErr != nil {
+			return txErr
+		}
+
+		return tx.Create(holdRef, &balanceItemDocument{
+			Version:   0,
+			ID:        hold.ID,
+			Timestamp: time.Time(hold.Timestamp),
+			Created:   time.Now(),
+			Amount:    hold.Amount.StringFixedBank(b.symbol.RoundingPlace()),
+		})
+	})
+
+	if err != nil {
+		if errors.Is(err, persist.ErrInsufficientBalance) {
+			return err
+		}
+		return fmt.Errorf("CreateHoldIfAvailable: %w", err)
+	}
+
+	return nil
+}
+
 func (b *BalanceRepository) UpdateHold(ctx context.Context, id persist.Key, amt decimal.Decimal) error {
 
 	_, docs, err := b.getBalanceItemDocuments(ctx, b.getHoldCollection(ctx))
@@ -357,6 +424,21 @@ func (b *BalanceRepository) DeleteHold(ctx context.Context, id persist.Key) erro
 	return nil
 }
 
+// decimalAt reads a decimal stored as a string field of a document
+func decimalAt(doc *firestore.DocumentSnapshot, field string) (decimal.Decimal, error) {
+	v, err := doc.DataAt(field)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	str, ok := v.(string)
+	if !ok {
+		return decimal.Zero, fmt.Errorf("%s of %s is not a string", field, doc.Ref.Path)
+	}
+
+	return decimal.NewFromString(str)
+}
+
 func (b *BalanceRepository) getClient(ctx context.Context) *firestore.Client {
 
 	var client *firestore.Client
@@ -0,0 +1,46 @@
+package firebase
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCreateHoldIfAvailable_Concurrent(t *testing.T) {
+	client := emulatorClient(t)
+	defer client.Close()
+	ctx := context.Background()
+
+	r := newTestBalance(client)
+	assert.NoError(t, r.AddToBalance(ctx, decimal.NewFromInt(5)))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var created int
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := r.CreateHoldIfAvailable(ctx, persist.NewBalanceItem(decimal.NewFromInt(1)))
+			// transactions that keep conflicting give up, so only overdrawing is an error
+			if err == nil {
+				mu.Lock()
+				created++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.True(t, created > 0 && created <= 5, "created %d holds", created)
+
+	// holds do not write the balance document
+	_, err := r.getSymbolDocumentRef(ctx).Get(ctx)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,477 @@
+package firebase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// /campaigns/{campaignid}
+// /campaigns/{campaignid}/grants/{grantid}
+// /referrals/{accountid}
+type CampaignRepository struct {
+	client *firestore.Client
+}
+
+func NewCampaignRepository(client *firestore.Client) *CampaignRepository {
+	return &CampaignRepository{client: client}
+}
+
+func (cr *CampaignRepository) SetCampaign(ctx context.Context, c *persist.Campaign) error {
+	if c == nil {
+		return fmt.Errorf("%w for campaign", persist.ErrCannotSaveNilValue)
+	}
+
+	_, err := cr.campaigns(ctx).Doc(c.ID).Set(ctx, campaignToDocument(c))
+	if err != nil {
+		return fmt.Errorf("SetCampaign: %w", err)
+	}
+
+	return nil
+}
+
+func (cr *CampaignRepository) CreateCampaign(ctx context.Context, c *persist.Campaign) error {
+	if c == nil {
+		return fmt.Errorf("%w for campaign", persist.ErrCannotSaveNilValue)
+	}
+
+	// create fails when the document exists which makes the check and write atomic
+	_, err := cr.campaigns(ctx).Doc(c.ID).Create(ctx, campaignToDocument(c))
+	if err != nil {
+		if status.Code(err) == codes.AlreadyExists {
+			return persist.ErrObjectExists
+		}
+
+		return fmt.Errorf("CreateCampaign: %w", err)
+	}
+
+	return nil
+}
+
+func (cr *CampaignRepository) GetCampaign(ctx context.Context, id persist.Key) (*persist.Campaign, error) {
+
+	dsnap, err := cr.campaigns(ctx).Doc(id.String()).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, persist.ErrObjectNotExist
+		}
+
+		return nil, fmt.Errorf("GetCampaign: %w", err)
+	}
+
+	c, err := documentToCampaign(dsnap.Data())
+	if err != nil {
+		return nil, fmt.Errorf("GetCampaign: %w", err)
+	}
+
+	return c, nil
+}
+
+func (cr *CampaignRepository) GetCampaigns(ctx context.Context) ([]*persist.Campaign, error) {
+	var campaigns []*persist.Campaign
+
+	iter := cr.campaigns(ctx).OrderBy("created", firestore.Asc).Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("GetCampaigns: %w", err)
+		}
+
+		c, err := documentToCampaign(doc.Data())
+		if err != nil {
+			return nil, fmt.Errorf("GetCampaigns: %w", err)
+		}
+
+		campaigns = append(campaigns, c)
+	}
+
+	return campaigns, nil
+}
+
+// CreateGrant reads the campaign and the grants already made to the owner in the same
+// transaction the grant is written so concurrent grants cannot exceed the budget or the
+// owner limit
+func (cr *CampaignRepository) CreateGrant(ctx context.Context, g *persist.Grant) error {
+	if g == nil {
+		return fmt.Errorf("%w for grant", persist.ErrCannotSaveNilValue)
+	}
+
+	campaignRef := cr.campaigns(ctx).Doc(g.Campaign)
+	grantRef := campaignRef.Collection("grants").Doc(g.ID)
+
+	return cr.getClient(ctx).RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		dsnap, err := tx.Get(campaignRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return persist.ErrObjectNotExist
+			}
+			return err
+		}
+
+		c, err := documentToCampaign(dsnap.Data())
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Get(grantRef)
+		if err == nil {
+			return persist.ErrObjectExists
+		}
+		if status.Code(err) != codes.NotFound {
+			return err
+		}
+
+		if c.Remaining().LessThan(g.Amount) {
+			return persist.ErrCampaignBudget
+		}
+
+		existing, err := tx.Documents(campaignRef.Collection("grants").Where("owner", "==", g.Owner)).GetAll()
+		if err != nil {
+			return err
+		}
+
+		if len(existing) >= c.PerAccountLimit {
+			return persist.ErrGrantLimit
+		}
+
+		if err = tx.Create(grantRef, grantToDocument(g)); err != nil {
+			return err
+		}
+
+		return tx.Update(campaignRef, []firestore.Update{
+			{Path: "granted", Value: c.Granted.Add(g.Amount).String()},
+		})
+	})
+}
+
+func (cr *CampaignRepository) GetGrant(ctx context.Context, campaign persist.Key, id persist.Key) (*persist.Grant, error) {
+
+	dsnap, err := cr.campaigns(ctx).Doc(campaign.String()).Collection("grants").Doc(id.String()).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, persist.ErrObjectNotExist
+		}
+
+		return nil, fmt.Errorf("GetGrant: %w", err)
+	}
+
+	g, err := documentToGrant(dsnap.Data())
+	if err != nil {
+		return nil, fmt.Errorf("GetGrant: %w", err)
+	}
+
+	return g, nil
+}
+
+// UpdateGrant saves the grant in a transaction that reads the stored grant with the
+// expected status
+func (cr *CampaignRepository) UpdateGrant(ctx context.Context, g *persist.Grant, from persist.GrantStatus) error {
+	if g == nil {
+		return fmt.Errorf("%w for grant", persist.ErrCannotSaveNilValue)
+	}
+
+	grantRef := cr.campaigns(ctx).Doc(g.Campaign).Collection("grants").Doc(g.ID)
+	err := cr.getClient(ctx).RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		dsnap, err := tx.Get(grantRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return persist.ErrObjectNotExist
+			}
+			return err
+		}
+
+		current, err := documentToGrant(dsnap.Data())
+		if err != nil {
+			return err
+		}
+
+		if current.Status != from {
+			return fmt.Errorf("%w: grant is %s", persist.ErrPreconditionFailed, current.Status)
+		}
+
+		return tx.Set(grantRef, grantToDocument(g))
+	})
+	if err != nil {
+		return fmt.Errorf("UpdateGrant: %w", err)
+	}
+
+	return nil
+}
+
+func (cr *CampaignRepository) DeleteGrant(ctx context.Context, g *persist.Grant) error {
+	if g == nil {
+		return nil
+	}
+
+	campaignRef := cr.campaigns(ctx).Doc(g.Campaign)
+	grantRef := campaignRef.Collection("grants").Doc(g.ID)
+
+	return cr.getClient(ctx).RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		dsnap, err := tx.Get(campaignRef)
+		if err != nil {
+			return err
+		}
+
+		granted, err := decimalAt(dsnap, "granted")
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.Get(grantRef); err != nil {
+			if status.Code(err) == codes.NotFound {
+				return nil
+			}
+			return err
+		}
+
+		if err = tx.Delete(grantRef); err != nil {
+			return err
+		}
+
+		return tx.Update(campaignRef, []firestore.Update{
+			{Path: "granted", Value: granted.Sub(g.Amount).String()},
+		})
+	})
+}
+
+func (cr *CampaignRepository) GetGrants(ctx context.Context, campaign persist.Key) ([]*persist.Grant, error) {
+	var grants []*persist.Grant
+
+	iter := cr.campaigns(ctx).Doc(campaign.String()).Collection("grants").
+		OrderBy("timestamp", firestore.Asc).
+		Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("GetGrants: %w", err)
+		}
+
+		g, err := documentToGrant(doc.Data())
+		if err != nil {
+			return nil, fmt.Errorf("GetGrants: %w", err)
+		}
+
+		grants = append(grants, g)
+	}
+
+	return grants, nil
+}
+
+func (cr *CampaignRepository) SetReferral(ctx context.Context, r *persist.Referral) error {
+	if r == nil {
+		return fmt.Errorf("%w for referral", persist.ErrCannotSaveNilValue)
+	}
+
+	// create fails when the document exists which makes the check and write atomic
+	_, err := cr.getClient(ctx).Collection("referrals").Doc(r.Account).Create(ctx, map[string]interface{}{
+		"account":   r.Account,
+		"referrer":  r.Referrer,
+		"timestamp": r.Timestamp.Value(),
+	})
+	if err != nil {
+		if status.Code(err) == codes.AlreadyExists {
+			return persist.ErrObjectExists
+		}
+
+		return fmt.Errorf("SetReferral: %w", err)
+	}
+
+	return nil
+}
+
+func (cr *CampaignRepository) GetReferral(ctx context.Context, acct persist.Key) (*persist.Referral, error) {
+
+	dsnap, err := cr.getClient(ctx).Collection("referrals").Doc(acct.String()).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, persist.ErrObjectNotExist
+		}
+
+		return nil, fmt.Errorf("GetReferral: %w", err)
+	}
+
+	r := &persist.Referral{}
+	m := dsnap.Data()
+
+	if v, ok := m["account"]; ok {
+		r.Account = v.(string)
+	}
+
+	if v, ok := m["referrer"]; ok {
+		r.Referrer = v.(string)
+	}
+
+	if v, ok := m["timestamp"]; ok {
+		r.Timestamp = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	return r, nil
+}
+
+func (cr *CampaignRepository) campaigns(ctx context.Context) *firestore.CollectionRef {
+	return cr.getClient(ctx).Collection("campaigns")
+}
+
+func (cr *CampaignRepository) getClient(ctx context.Context) *firestore.Client {
+
+	var client *firestore.Client
+	if cr.client == nil {
+		client = clientFromContext(ctx)
+	} else {
+		client = cr.client
+	}
+	return client
+}
+
+func campaignToDocument(c *persist.Campaign) map[string]interface{} {
+	return map[string]interface{}{
+		"id":              c.ID,
+		"name":            c.Name,
+		"rule":            string(c.Rule),
+		"symbol":          c.Symbol.String(),
+		"amount":          c.Amount.String(),
+		"matchRate":       c.MatchRate.String(),
+		"budget":          c.Budget.String(),
+		"granted":         c.Granted.String(),
+		"perAccountLimit": int64(c.PerAccountLimit),
+		"start":           c.Start.Value(),
+		"end":             c.End.Value(),
+		"created":         c.Created.Value(),
+	}
+}
+
+func documentToCampaign(m map[string]interface{}) (*persist.Campaign, error) {
+	c := &persist.Campaign{}
+
+	if v, ok := m["id"]; ok {
+		c.ID = v.(string)
+	}
+
+	if v, ok := m["name"]; ok {
+		c.Name = v.(string)
+	}
+
+	if v, ok := m["rule"]; ok {
+		c.Rule = persist.CampaignRule(v.(string))
+	}
+
+	if v, ok := m["symbol"]; ok {
+		sym, err := types.FromString(v.(string))
+		if err != nil {
+			return nil, err
+		}
+		c.Symbol = sym
+	}
+
+	for field, dst := range map[string]*decimal.Decimal{
+		"amount":    &c.Amount,
+		"matchRate": &c.MatchRate,
+		"budget":    &c.Budget,
+		"granted":   &c.Granted,
+	} {
+		*dst = decimal.Zero
+		if v, ok := m[field]; ok {
+			d, err := decimal.NewFromString(v.(string))
+			if err != nil {
+				return nil, err
+			}
+			*dst = d
+		}
+	}
+
+	if v, ok := m["perAccountLimit"]; ok {
+		c.PerAccountLimit = int(v.(int64))
+	}
+
+	if v, ok := m["start"]; ok {
+		c.Start = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	if v, ok := m["end"]; ok {
+		c.End = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	if v, ok := m["created"]; ok {
+		c.Created = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	return c, nil
+}
+
+func grantToDocument(g *persist.Grant) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        g.ID,
+		"campaign":  g.Campaign,
+		"owner":     g.Owner,
+		"account":   g.Account,
+		"event":     g.Event,
+		"symbol":    g.Symbol.String(),
+		"amount":    g.Amount.String(),
+		"status":    string(g.Status),
+		"timestamp": g.Timestamp.Value(),
+	}
+}
+
+func documentToGrant(m map[string]interface{}) (*persist.Grant, error) {
+	g := &persist.Grant{Amount: decimal.Zero}
+
+	if v, ok := m["id"]; ok {
+		g.ID = v.(string)
+	}
+
+	if v, ok := m["campaign"]; ok {
+		g.Campaign = v.(string)
+	}
+
+	if v, ok := m["owner"]; ok {
+		g.Owner = v.(string)
+	}
+
+	if v, ok := m["account"]; ok {
+		g.Account = v.(string)
+	}
+
+	if v, ok := m["event"]; ok {
+		g.Event = v.(string)
+	}
+
+	if v, ok := m["symbol"]; ok {
+		sym, err := types.FromString(v.(string))
+		if err != nil {
+			return nil, err
+		}
+		g.Symbol = sym
+	}
+
+	if v, ok := m["amount"]; ok {
+		amt, err := decimal.NewFromString(v.(string))
+		if err != nil {
+			return nil, err
+		}
+		g.Amount = amt
+	}
+
+	if v, ok := m["status"]; ok {
+		g.Status = persist.GrantStatus(v.(string))
+	}
+
+	if v, ok := m["timestamp"]; ok {
+		g.Timestamp = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	return g, nil
+}
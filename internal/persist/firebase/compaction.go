@@ -0,0 +1,156 @@
+package firebase
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// BalanceShards is the number of counters a balance is spread over. Compactions
+	// for the same balance pick a shard at random so they rarely write the same document.
+	BalanceShards = 8
+	// CompactionBatchSize is the most posts read in a single compaction run
+	CompactionBatchSize = 2000
+	// compactionTxSize keeps each transaction under the firestore write limit
+	compactionTxSize = 400
+)
+
+type shardDocument struct {
+	Balance string    `firestore:"balance"`
+	Updated time.Time `firestore:"updated"`
+}
+
+// PostCompactor folds balance posts into the balance shards of each symbol
+type PostCompactor struct {
+	client *firestore.Client
+}
+
+func NewPostCompactor(client *firestore.Client) *PostCompactor {
+	return &PostCompactor{client: client}
+}
+
+// CompactPosts reads a batch of posts across all accounts and moves the amounts into a
+// balance shard of the symbol they were posted to. The posts are deleted in the same
+// transaction the shard is updated so the total balance never changes during a run.
+func (c *PostCompactor) CompactPosts(ctx context.Context) (int, error) {
+
+	docs, err := c.getClient(ctx).CollectionGroup("posts").Limit(CompactionBatchSize).Documents(ctx).GetAll()
+	if err != nil {
+		return 0, fmt.Errorf("CompactPosts: %w", err)
+	}
+
+	// posts are grouped by the symbol document they belong to
+	groups := make(map[string][]*firestore.DocumentRef)
+	parents := make(map[string]*firestore.DocumentRef)
+	for _, doc := range docs {
+		sym := doc.Ref.Parent.Parent
+		if sym == nil {
+			continue
+		}
+
+		groups[sym.Path] = append(groups[sym.Path], doc.Ref)
+		parents[sym.Path] = sym
+	}
+
+	total := 0
+	for path, refs := range groups {
+		symRef := parents[path]
+		sym, err := types.FromString(symRef.ID)
+		if err != nil {
+			continue
+		}
+
+		for start := 0; start < len(refs); start += compactionTxSize {
+			end := start + compactionTxSize
+			if end > len(refs) {
+				end = len(refs)
+			}
+
+			cnt, err := c.compact(ctx, symRef, sym, refs[start:end])
+			if err != nil {
+				return total, fmt.Errorf("CompactPosts: %s: %w", path, err)
+			}
+			total += cnt
+		}
+	}
+
+	return total, nil
+}
+
+// compact moves the amounts of the posts into a random shard of the symbol document.
+// Posts already removed by another run are skipped.
+func (c *PostCompactor) compact(ctx context.Context, symRef *firestore.DocumentRef, sym types.Symbol, refs []*firestore.DocumentRef) (int, error) {
+	shardRef := symRef.Collection("shards").Doc(strconv.Itoa(rand.Intn(BalanceShards)))
+
+	var cnt int
+	err := c.getClient(ctx).RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		// the transaction may be retried so the count starts over each attempt
+		cnt = 0
+
+		balance := decimal.NewFromInt(0)
+		shard, txErr := tx.Get(shardRef)
+		if txErr != nil {
+			if status.Code(txErr) != codes.NotFound {
+				return txErr
+			}
+		} else {
+			balance, txErr = decimalAt(shard, "balance")
+			if txErr != nil {
+				return txErr
+			}
+		}
+
+		posts, txErr := tx.GetAll(refs)
+		if txErr != nil {
+			return txErr
+		}
+
+		for _, post := range posts {
+			if !post.Exists() {
+				continue
+			}
+
+			amt, txErr := decimalAt(post, "amount")
+			if txErr != nil {
+				return txErr
+			}
+
+			balance = balance.Add(amt)
+			if txErr = tx.Delete(post.Ref); txErr != nil {
+				return txErr
+			}
+			cnt++
+		}
+
+		if cnt == 0 {
+			return nil
+		}
+
+		return tx.Set(shardRef, &shardDocument{
+			Balance: balance.StringFixedBank(sym.RoundingPlace()),
+			Updated: time.Now(),
+		})
+	})
+
+	return cnt, err
+}
+
+func (c *PostCompactor) getClient(ctx context.Context) *firestore.Client {
+
+	var client *firestore.Client
+	if c.client == nil {
+		client = clientFromContext(ctx)
+	} else {
+		client = c.client
+	}
+	return client
+}
@@ -0,0 +1,134 @@
+package firebase
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/firestore"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// emulatorClient connects to the firestore emulator. Tests that need a firestore
+// backend are skipped when FIRESTORE_EMULATOR_HOST is not set.
+func emulatorClient(t *testing.T) *firestore.Client {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST is not set")
+	}
+
+	client, err := firestore.NewClient(context.Background(), "spew-order-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+func newTestBalance(client *firestore.Client) *BalanceRepository {
+	return NewBalanceRepository(client, &persist.Account{ID: uuid.NewV4().String()}, types.SymbolBitcoin)
+}
+
+// compactAll runs the compactor until no posts are left and checks the balance after
+// every run
+func compactAll(t *testing.T, c *PostCompactor, r *BalanceRepository, expected decimal.Decimal) {
+	ctx := context.Background()
+	for {
+		n, err := c.CompactPosts(ctx)
+		assert.NoError(t, err)
+
+		bal, err := r.GetBalance(ctx)
+		assert.NoError(t, err)
+		assert.True(t, bal.Equal(expected), "balance %s after compacting, expected %s", bal, expected)
+
+		if err != nil || n == 0 {
+			return
+		}
+	}
+}
+
+func TestCompactPosts_PreservesBalance(t *testing.T) {
+	client := emulatorClient(t)
+	defer client.Close()
+	ctx := context.Background()
+
+	r := newTestBalance(client)
+	for _, amt := range []float64{1.5, 2.25, -0.75, 0.00000001} {
+		assert.NoError(t, r.AddToBalance(ctx, decimal.NewFromFloat(amt)))
+	}
+
+	before, err := r.GetBalance(ctx)
+	assert.NoError(t, err)
+	assert.True(t, before.Equal(decimal.NewFromFloat(3.00000001)), before.String())
+
+	compactAll(t, NewPostCompactor(client), r, before)
+
+	posts, err := r.getPostCollection(ctx).Documents(ctx).GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, posts, 0)
+
+	shards, err := r.getShardCollection(ctx).Documents(ctx).GetAll()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, shards)
+
+	// holds are checked against the compacted balance
+	assert.ErrorIs(t, r.CreateHoldIfAvailable(ctx, persist.NewBalanceItem(decimal.NewFromInt(4))), persist.ErrInsufficientBalance)
+	assert.NoError(t, r.CreateHoldIfAvailable(ctx, persist.NewBalanceItem(decimal.NewFromInt(3))))
+}
+
+func TestCompactPosts_SmallBatches(t *testing.T) {
+	client := emulatorClient(t)
+	defer client.Close()
+	ctx := context.Background()
+
+	defer func(b, tx int) {
+		CompactionBatchSize = b
+		compactionTxSize = tx
+	}(CompactionBatchSize, compactionTxSize)
+	CompactionBatchSize = 3
+	compactionTxSize = 2
+
+	r := newTestBalance(client)
+	expected := decimal.NewFromInt(0)
+	for i := 1; i <= 7; i++ {
+		amt := decimal.NewFromInt(int64(i))
+		expected = expected.Add(amt)
+		assert.NoError(t, r.AddToBalance(ctx, amt))
+	}
+
+	compactAll(t, NewPostCompactor(client), r, expected)
+}
+
+func TestCompactPosts_ConcurrentPosts(t *testing.T) {
+	client := emulatorClient(t)
+	defer client.Close()
+	ctx := context.Background()
+
+	r := newTestBalance(client)
+	c := NewPostCompactor(client)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, r.AddToBalance(ctx, decimal.NewFromInt(1)))
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 3; i++ {
+			_, err := c.CompactPosts(ctx)
+			assert.NoError(t, err)
+		}
+	}()
+	wg.Wait()
+
+	compactAll(t, c, r, decimal.NewFromInt(10))
+}
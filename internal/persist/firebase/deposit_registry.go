@@ -0,0 +1,128 @@
+package firebase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// /deposits/{depositid}
+type DepositRegistry struct {
+	client *firestore.Client
+}
+
+func NewDepositRegistry(client *firestore.Client) *DepositRegistry {
+	return &DepositRegistry{client: client}
+}
+
+func (dr *DepositRegistry) Register(ctx context.Context, r *persist.DepositRegistration) error {
+	if r == nil {
+		return fmt.Errorf("%w for deposit registration", persist.ErrCannotSaveNilValue)
+	}
+
+	// create fails when the document exists which makes the check and write atomic
+	_, err := dr.doc(ctx, r.Symbol, r.TransactionHash, r.OutputIndex).Create(ctx, registrationToDocument(r))
+	if err != nil {
+		if status.Code(err) == codes.AlreadyExists {
+			return persist.ErrObjectExists
+		}
+
+		return fmt.Errorf("Register: %w", err)
+	}
+
+	return nil
+}
+
+func (dr *DepositRegistry) GetRegistration(ctx context.Context, s types.Symbol, hash string, index int) (*persist.DepositRegistration, error) {
+
+	dsnap, err := dr.doc(ctx, s, hash, index).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, persist.ErrObjectNotExist
+		}
+
+		return nil, fmt.Errorf("GetRegistration: %w", err)
+	}
+
+	r, err := documentToRegistration(dsnap.Data())
+	if err != nil {
+		return nil, fmt.Errorf("GetRegistration: %w", err)
+	}
+
+	return r, nil
+}
+
+func (dr *DepositRegistry) DeleteRegistration(ctx context.Context, s types.Symbol, hash string, index int) error {
+	_, err := dr.doc(ctx, s, hash, index).Delete(ctx)
+	if err != nil {
+		return fmt.Errorf("DeleteRegistration: %w", err)
+	}
+
+	return nil
+}
+
+func (dr *DepositRegistry) doc(ctx context.Context, s types.Symbol, hash string, index int) *firestore.DocumentRef {
+	return dr.getClient(ctx).Collection("deposits").Doc(persist.DepositID(s, hash, index))
+}
+
+func (dr *DepositRegistry) getClient(ctx context.Context) *firestore.Client {
+
+	var client *firestore.Client
+	if dr.client == nil {
+		client = clientFromContext(ctx)
+	} else {
+		client = dr.client
+	}
+	return client
+}
+
+func registrationToDocument(r *persist.DepositRegistration) map[string]interface{} {
+	return map[string]interface{}{
+		"symbol":           r.Symbol.String(),
+		"transaction_hash": r.TransactionHash,
+		"output_index":     int64(r.OutputIndex),
+		"account":          r.Account,
+		"deposit":          r.Deposit,
+		"timestamp":        r.Timestamp.Value(),
+	}
+}
+
+func documentToRegistration(m map[string]interface{}) (*persist.DepositRegistration, error) {
+	r := &persist.DepositRegistration{}
+
+	if v, ok := m["symbol"]; ok {
+		sym, err := types.FromString(v.(string))
+		if err != nil {
+			return nil, err
+		}
+		r.Symbol = sym
+	}
+
+	if v, ok := m["transaction_hash"]; ok {
+		r.TransactionHash = v.(string)
+	}
+
+	if v, ok := m["output_index"]; ok {
+		r.OutputIndex = int(v.(int64))
+	}
+
+	if v, ok := m["account"]; ok {
+		r.Account = v.(string)
+	}
+
+	if v, ok := m["deposit"]; ok {
+		r.Deposit = v.(string)
+	}
+
+	if v, ok := m["timestamp"]; ok {
+		r.Timestamp = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	return r, nil
+}
@@ -0,0 +1,190 @@
+package firebase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// /root/account/{accountid}/deposits/{depositid}
+type DepositRepository struct {
+	client  *firestore.Client
+	account *persist.Account
+}
+
+func NewDepositRepository(client *firestore.Client, account *persist.Account) *DepositRepository {
+	return &DepositRepository{client: client, account: account}
+}
+
+func (dr *DepositRepository) SetDeposit(ctx context.Context, d *persist.Deposit) error {
+	if d == nil {
+		return fmt.Errorf("%w for deposit", persist.ErrCannotSaveNilValue)
+	}
+
+	_, err := dr.collection(ctx).Doc(d.ID).Set(ctx, depositToDocument(d))
+	if err != nil {
+		return fmt.Errorf("SetDeposit: %w", err)
+	}
+
+	return nil
+}
+
+func (dr *DepositRepository) GetDeposit(ctx context.Context, k persist.Key) (*persist.Deposit, error) {
+
+	dsnap, err := dr.collection(ctx).Doc(k.String()).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, persist.ErrObjectNotExist
+		}
+
+		return nil, fmt.Errorf("GetDeposit: %w", err)
+	}
+
+	d, err := documentToDeposit(dsnap.Data())
+	if err != nil {
+		return nil, fmt.Errorf("GetDeposit: %w", err)
+	}
+
+	return d, nil
+}
+
+func (dr *DepositRepository) GetDeposits(ctx context.Context, st ...persist.DepositStatus) (list []*persist.Deposit, err error) {
+
+	q := dr.collection(ctx).Query
+	if len(st) > 0 {
+		in := []string{}
+		for _, s := range st {
+			in = append(in, string(s))
+		}
+		q = q.Where("status", "in", in)
+	}
+
+	iter := q.OrderBy("created", firestore.Asc).Documents(ctx)
+	var doc *firestore.DocumentSnapshot
+	for {
+		doc, err = iter.Next()
+		if err != nil {
+			if errors.Is(err, iterator.Done) {
+				err = nil
+			} else {
+				err = fmt.Errorf("GetDeposits: %w", err)
+			}
+
+			break
+		}
+
+		var d *persist.Deposit
+		d, err = documentToDeposit(doc.Data())
+		if err != nil {
+			err = fmt.Errorf("GetDeposits: %w", err)
+			return
+		}
+
+		list = append(list, d)
+	}
+
+	return
+}
+
+func (dr *DepositRepository) collection(ctx context.Context) *firestore.CollectionRef {
+	return dr.getClient(ctx).Collection(fmt.Sprintf("accounts/%s/deposits", dr.account.ID))
+}
+
+func (dr *DepositRepository) getClient(ctx context.Context) *firestore.Client {
+
+	var client *firestore.Client
+	if dr.client == nil {
+		client = clientFromContext(ctx)
+	} else {
+		client = dr.client
+	}
+	return client
+}
+
+func depositToDocument(d *persist.Deposit) map[string]interface{} {
+	return map[string]interface{}{
+		"id":               d.ID,
+		"account":          d.Account,
+		"symbol":           d.Symbol.String(),
+		"amount":           d.Amount.StringFixedBank(d.Symbol.RoundingPlace()),
+		"address":          d.Address,
+		"transaction_hash": d.TransactionHash,
+		"output_index":     int64(d.OutputIndex),
+		"reference":        d.Reference,
+		"confirmations":    int64(d.Confirmations),
+		"status":           string(d.Status),
+		"created":          d.Created.Value(),
+		"updated":          d.Updated.Value(),
+	}
+}
+
+func documentToDeposit(m map[string]interface{}) (*persist.Deposit, error) {
+	d := &persist.Deposit{}
+
+	if v, ok := m["id"]; ok {
+		d.ID = v.(string)
+	}
+
+	if v, ok := m["account"]; ok {
+		d.Account = v.(string)
+	}
+
+	if v, ok := m["symbol"]; ok {
+		sym, err := types.FromString(v.(string))
+		if err != nil {
+			return nil, err
+		}
+		d.Symbol = sym
+	}
+
+	if v, ok := m["amount"]; ok {
+		amt, err := decimal.NewFromString(v.(string))
+		if err != nil {
+			return nil, err
+		}
+		d.Amount = amt
+	}
+
+	if v, ok := m["address"]; ok {
+		d.Address = v.(string)
+	}
+
+	if v, ok := m["transaction_hash"]; ok {
+		d.TransactionHash = v.(string)
+	}
+
+	if v, ok := m["output_index"]; ok {
+		d.OutputIndex = int(v.(int64))
+	}
+
+	if v, ok := m["reference"]; ok {
+		d.Reference = v.(string)
+	}
+
+	if v, ok := m["confirmations"]; ok {
+		d.Confirmations = int(v.(int64))
+	}
+
+	if v, ok := m["status"]; ok {
+		d.Status = persist.DepositStatus(v.(string))
+	}
+
+	if v, ok := m["created"]; ok {
+		d.Created = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	if v, ok := m["updated"]; ok {
+		d.Updated = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	return d, nil
+}
@@ -0,0 +1,154 @@
+package firebase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// /root/account/{accountid}/idempotency/{keyhash}
+type IdempotencyRepository struct {
+	client  *firestore.Client
+	account *persist.Account
+}
+
+func NewIdempotencyRepository(client *firestore.Client, account *persist.Account) *IdempotencyRepository {
+	return &IdempotencyRepository{client: client, account: account}
+}
+
+func (ir *IdempotencyRepository) GetRecord(ctx context.Context, k persist.Key) (*persist.IdempotencyRecord, error) {
+
+	dsnap, err := ir.doc(ctx, k).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, persist.ErrObjectNotExist
+		}
+
+		return nil, fmt.Errorf("GetRecord: %w", err)
+	}
+
+	return documentToIdempotencyRecord(dsnap.Data()), nil
+}
+
+func (ir *IdempotencyRepository) SetRecord(ctx context.Context, r *persist.IdempotencyRecord) error {
+	if r == nil {
+		return fmt.Errorf("%w for idempotency record", persist.ErrCannotSaveNilValue)
+	}
+
+	_, err := ir.doc(ctx, ky(r.Key)).Set(ctx, idempotencyRecordToDocument(r))
+	if err != nil {
+		return fmt.Errorf("SetRecord: %w", err)
+	}
+
+	return nil
+}
+
+// CreateRecord creates the record document if the key is free. A stored record that
+// can be replaced is overwritten in a transaction so that only one of several
+// concurrent writers claims the key.
+func (ir *IdempotencyRepository) CreateRecord(ctx context.Context, r *persist.IdempotencyRecord, replace func(*persist.IdempotencyRecord) bool) error {
+	if r == nil {
+		return fmt.Errorf("%w for idempotency record", persist.ErrCannotSaveNilValue)
+	}
+
+	ref := ir.doc(ctx, ky(r.Key))
+	_, err := ref.Create(ctx, idempotencyRecordToDocument(r))
+	if err == nil {
+		return nil
+	}
+
+	if status.Code(err) != codes.AlreadyExists {
+		return fmt.Errorf("CreateRecord: %w", err)
+	}
+
+	err = ir.getClient(ctx).RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		dsnap, err := tx.Get(ref)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return tx.Create(ref, idempotencyRecordToDocument(r))
+			}
+			return err
+		}
+
+		if !replace(documentToIdempotencyRecord(dsnap.Data())) {
+			return persist.ErrObjectExists
+		}
+
+		return tx.Set(ref, idempotencyRecordToDocument(r))
+	})
+	if err != nil {
+		if errors.Is(err, persist.ErrObjectExists) {
+			return err
+		}
+		return fmt.Errorf("CreateRecord: %w", err)
+	}
+
+	return nil
+}
+
+func (ir *IdempotencyRepository) DeleteRecord(ctx context.Context, k persist.Key) error {
+	_, err := ir.doc(ctx, k).Delete(ctx)
+	if err != nil {
+		return fmt.Errorf("DeleteRecord: %w", err)
+	}
+
+	return nil
+}
+
+// doc returns the document reference for a key; client keys are hashed because
+// they may contain characters that are not valid in a document id
+func (ir *IdempotencyRepository) doc(ctx context.Context, k persist.Key) *firestore.DocumentRef {
+	sum := sha256.Sum256([]byte(k.String()))
+	col := fmt.Sprintf("accounts/%s/idempotency", ir.account.ID)
+	return ir.getClient(ctx).Collection(col).Doc(hex.EncodeToString(sum[:]))
+}
+
+func (ir *IdempotencyRepository) getClient(ctx context.Context) *firestore.Client {
+
+	var client *firestore.Client
+	if ir.client == nil {
+		client = clientFromContext(ctx)
+	} else {
+		client = ir.client
+	}
+	return client
+}
+
+func idempotencyRecordToDocument(r *persist.IdempotencyRecord) map[string]interface{} {
+	return map[string]interface{}{
+		"key":       r.Key,
+		"type":      string(r.Type),
+		"reference": r.Reference,
+		"timestamp": r.Timestamp.Value(),
+	}
+}
+
+func documentToIdempotencyRecord(m map[string]interface{}) *persist.IdempotencyRecord {
+	r := &persist.IdempotencyRecord{}
+
+	if v, ok := m["key"]; ok {
+		r.Key = v.(string)
+	}
+
+	if v, ok := m["type"]; ok {
+		r.Type = persist.IdempotencyType(v.(string))
+	}
+
+	if v, ok := m["reference"]; ok {
+		r.Reference = v.(string)
+	}
+
+	if v, ok := m["timestamp"]; ok {
+		r.Timestamp = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	return r
+}
@@ -47,6 +47,36 @@ func (r *LedgerRepository) RecordDeposit(ctx context.Context, s types.Symbol, am
 	return nil
 }
 
+// RecordDepositCredit makes the same entries as RecordDeposit at the registration time
+// in one batch. Document IDs are derived from the deposit so a repeated write replaces them.
+func (r *LedgerRepository) RecordDepositCredit(ctx context.Context, reg *persist.DepositRegistration, amt decimal.Decimal) error {
+	entries := []persist.LedgerEntry{
+		{Account: persist.Transfers, Entry: persist.Debit},
+		{Account: persist.TransfersPayable, Entry: persist.Credit},
+	}
+
+	batch := r.getClient(ctx).Batch()
+	for i, entry := range entries {
+		record := map[string]interface{}{
+			"entry":     entry.Entry.String(),
+			"account":   entry.Account.String(),
+			"symbol":    reg.Symbol.String(),
+			"amount":    amt.StringFixedBank(reg.Symbol.RoundingPlace()),
+			"timestamp": reg.Timestamp.Value(),
+		}
+
+		doc := r.getClient(ctx).Collection(r.ledgerAccountSubspace(entry.Account)).Doc(fmt.Sprintf("deposit-%s-%d", reg.Deposit, i))
+		batch.Set(doc, record)
+	}
+
+	_, err := batch.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("RecordDepositCredit: %w", err)
+	}
+
+	return nil
+}
+
 func (r *LedgerRepository) RecordTransfer(ctx context.Context, s types.Symbol, amt decimal.Decimal) error {
 	record := map[string]interface{}{
 		"entry":     persist.Credit.String(),
@@ -179,6 +209,239 @@ func (r *LedgerRepository) RecordFee(ctx context.Context, s types.Symbol, amt de
 	return nil
 }
 
+// RecordWithdrawal writes the transfer and fee entries of a settled withdrawal in one
+// batch. Document IDs are derived from the withdrawal so a repeated write replaces them.
+func (r *LedgerRepository) RecordWithdrawal(ctx context.Context, w *persist.Withdrawal) error {
+	if w.Settled == nil {
+		return fmt.Errorf("RecordWithdrawal: withdrawal %s is not settled", w.ID)
+	}
+
+	entries := []persist.LedgerEntry{
+		{Account: persist.Transfers, Entry: persist.Credit, Amount: w.Amount},
+		{Account: persist.TransfersPayable, Entry: persist.Debit, Amount: w.Amount},
+	}
+
+	if w.Fee.IsPositive() {
+		entries = append(entries,
+			persist.LedgerEntry{Account: persist.Transfers, Entry: persist.Credit, Amount: w.Fee},
+			persist.LedgerEntry{Account: persist.TransfersPayable, Entry: persist.Debit, Amount: w.Fee},
+			persist.LedgerEntry{Account: persist.Cash, Entry: persist.Debit, Amount: w.Fee},
+			persist.LedgerEntry{Account: persist.Sales, Entry: persist.Credit, Amount: w.Fee},
+		)
+	}
+
+	batch := r.getClient(ctx).Batch()
+	for i, entry := range entries {
+		record := map[string]interface{}{
+			"entry":     entry.Entry.String(),
+			"account":   entry.Account.String(),
+			"symbol":    w.Symbol.String(),
+			"amount":    entry.Amount.StringFixedBank(w.Symbol.RoundingPlace()),
+			"timestamp": w.Settled.Value(),
+		}
+
+		doc := r.getClient(ctx).Collection(r.ledgerAccountSubspace(entry.Account)).Doc(fmt.Sprintf("withdrawal-%s-%d", w.ID, i))
+		batch.Set(doc, record)
+	}
+
+	_, err := batch.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("RecordWithdrawal: %w", err)
+	}
+
+	return nil
+}
+
+// RecordTrade saves the journal entries for one side of a settled trade in one batch. The
+// customer liability sub-account is offset by the Trade Clearing account for each symbol.
+// Document IDs are derived from the leg so a repeated write replaces them.
+func (r *LedgerRepository) RecordTrade(ctx context.Context, leg persist.TradeLeg) error {
+	ts := time.Now().UnixNano()
+
+	entries := []persist.LedgerEntry{
+		{Account: persist.CustomerLiabilities, Entry: persist.Credit, Symbol: leg.AddSymbol, Amount: leg.AddAmount},
+		{Account: persist.TradeClearing, Entry: persist.Debit, Symbol: leg.AddSymbol, Amount: leg.AddAmount},
+		{Account: persist.CustomerLiabilities, Entry: persist.Debit, Symbol: leg.SubSymbol, Amount: leg.SubAmount},
+		{Account: persist.TradeClearing, Entry: persist.Credit, Symbol: leg.SubSymbol, Amount: leg.SubAmount},
+	}
+
+	batch := r.getClient(ctx).Batch()
+	for i, entry := range entries {
+		record := map[string]interface{}{
+			"entry":      entry.Entry.String(),
+			"account":    entry.Account.String(),
+			"subAccount": leg.Account,
+			"symbol":     entry.Symbol.String(),
+			"amount":     entry.Amount.StringFixedBank(entry.Symbol.RoundingPlace()),
+			"timestamp":  ts,
+		}
+
+		doc := r.getClient(ctx).Collection(r.ledgerAccountSubspace(entry.Account)).Doc(fmt.Sprintf("trade-%s-%d", leg.ID, i))
+		batch.Set(doc, record)
+	}
+
+	_, err := batch.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("RecordTrade: %w", err)
+	}
+
+	return nil
+}
+
+// RecordInternalTransfer moves a customer liability from one customer sub-account to another
+// in one batch. Document IDs are derived from the transfer so a repeated write replaces them.
+func (r *LedgerRepository) RecordInternalTransfer(ctx context.Context, id, from, to string, s types.Symbol, amt decimal.Decimal) error {
+	ts := time.Now().UnixNano()
+
+	entries := []persist.LedgerEntry{
+		{Account: persist.CustomerLiabilities, SubAccount: from, Entry: persist.Debit, Symbol: s, Amount: amt},
+		{Account: persist.CustomerLiabilities, SubAccount: to, Entry: persist.Credit, Symbol: s, Amount: amt},
+	}
+
+	batch := r.getClient(ctx).Batch()
+	for i, entry := range entries {
+		record := map[string]interface{}{
+			"entry":      entry.Entry.String(),
+			"account":    entry.Account.String(),
+			"subAccount": entry.SubAccount,
+			"symbol":     entry.Symbol.String(),
+			"amount":     entry.Amount.StringFixedBank(entry.Symbol.RoundingPlace()),
+			"timestamp":  ts,
+		}
+
+		doc := r.getClient(ctx).Collection(r.ledgerAccountSubspace(entry.Account)).Doc(fmt.Sprintf("internal-%s-%d", id, i))
+		batch.Set(doc, record)
+	}
+
+	_, err := batch.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("RecordInternalTransfer: %w", err)
+	}
+
+	return nil
+}
+
+// RecordGrant debits the Promotions account with the cost of a promotional grant and
+// credits the customer liability sub-account receiving it in one batch. Document IDs are
+// derived from the grant so a repeated write replaces them.
+func (r *LedgerRepository) RecordGrant(ctx context.Context, g *persist.Grant) error {
+	entries := []persist.LedgerEntry{
+		{Account: persist.Promotions, Entry: persist.Debit},
+		{Account: persist.CustomerLiabilities, SubAccount: g.Account, Entry: persist.Credit},
+	}
+
+	batch := r.getClient(ctx).Batch()
+	for i, entry := range entries {
+		record := map[string]interface{}{
+			"entry":      entry.Entry.String(),
+			"account":    entry.Account.String(),
+			"subAccount": entry.SubAccount,
+			"symbol":     g.Symbol.String(),
+			"amount":     g.Amount.StringFixedBank(g.Symbol.RoundingPlace()),
+			"timestamp":  g.Timestamp.Value(),
+		}
+
+		doc := r.getClient(ctx).Collection(r.ledgerAccountSubspace(entry.Account)).Doc(fmt.Sprintf("grant-%s-%d", g.ID, i))
+		batch.Set(doc, record)
+	}
+
+	_, err := batch.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("RecordGrant: %w", err)
+	}
+
+	return nil
+}
+
+func (r *LedgerRepository) GetTrialBalance(ctx context.Context) (*persist.TrialBalance, error) {
+	return r.GetTrialBalanceAt(ctx, time.Time{})
+}
+
+// GetTrialBalanceAt totals all entries posted before the provided time. A zero time
+// includes every entry.
+func (r *LedgerRepository) GetTrialBalanceAt(ctx context.Context, t time.Time) (*persist.TrialBalance, error) {
+	entries, err := r.entries(ctx, persist.LedgerQuery{To: t}, nil, 0)
+	if err != nil {
+		return nil, fmt.Errorf("GetTrialBalanceAt: %w", err)
+	}
+
+	tb := &persist.TrialBalance{}
+	for _, entry := range entries {
+		tb.Add(entry)
+	}
+
+	return tb, nil
+}
+
+func (r *LedgerRepository) GetEntries(ctx context.Context, q persist.LedgerQuery) (*persist.LedgerPage, error) {
+	after, err := q.After()
+	if err != nil {
+		return nil, err
+	}
+
+	// one entry past the page from each account is enough to know whether
+	// another page follows
+	entries, err := r.entries(ctx, q, after, q.PageSize()+1)
+	if err != nil {
+		return nil, fmt.Errorf("GetEntries: %w", err)
+	}
+
+	return persist.PageLedgerEntries(entries, q)
+}
+
+// entries reads the ledger account collections covered by the query in page order,
+// starting after the cursor and reading at most limit entries from each account. A
+// limit of 0 reads every matching entry.
+func (r *LedgerRepository) entries(ctx context.Context, q persist.LedgerQuery, after *persist.LedgerCursor, limit int) ([]*persist.LedgerEntry, error) {
+	var out []*persist.LedgerEntry
+
+	for _, a := range q.Accounts() {
+		query := r.getClient(ctx).Collection(r.ledgerAccountSubspace(a)).Query
+		if q.Symbol != nil {
+			query = query.Where("symbol", "==", q.Symbol.String())
+		}
+		if !q.From.IsZero() {
+			query = query.Where("timestamp", ">=", q.From.UnixNano())
+		}
+		if !q.To.IsZero() {
+			query = query.Where("timestamp", "<", q.To.UnixNano())
+		}
+
+		query = query.OrderBy("timestamp", firestore.Asc).OrderBy(firestore.DocumentID, firestore.Asc)
+		if after != nil {
+			switch {
+			case a == after.Account:
+				query = query.StartAfter(after.Timestamp, after.ID)
+			case a > after.Account:
+				query = query.Where("timestamp", ">=", after.Timestamp)
+			default:
+				query = query.Where("timestamp", ">", after.Timestamp)
+			}
+		}
+		if limit > 0 {
+			query = query.Limit(limit)
+		}
+
+		iter := query.Documents(ctx)
+		for {
+			doc, err := iter.Next()
+			if err != nil {
+				if errors.Is(err, iterator.Done) {
+					break
+				}
+				return nil, err
+			}
+
+			entry := documentToEntry(doc.Data())
+			entry.ID = doc.Ref.ID
+			entry.Account = a
+			out = append(out, entry)
+		}
+	}
+
+	return out, nil
+}
+
 func (r *LedgerRepository) getClient(ctx context.Context) *firestore.Client {
 
 	var client *firestore.Client
@@ -200,6 +463,12 @@ func (r *LedgerRepository) ledgerAccountSubspace(a persist.LedgerAccount) string
 		return fmt.Sprintf("ledger/%s/%s", persist.Liability, persist.TransfersPayable)
 	case persist.Transfers:
 		return fmt.Sprintf("ledger/%s/%s", persist.Asset, persist.Transfers)
+	case persist.CustomerLiabilities:
+		return fmt.Sprintf("ledger/%s/%s", persist.Liability, persist.CustomerLiabilities)
+	case persist.TradeClearing:
+		return fmt.Sprintf("ledger/%s/%s", persist.Asset, persist.TradeClearing)
+	case persist.Promotions:
+		return fmt.Sprintf("ledger/%s/%s", persist.Asset, persist.Promotions)
 	default:
 		return "ledger"
 	}
@@ -212,6 +481,10 @@ func documentToEntry(m map[string]interface{}) *persist.LedgerEntry {
 		entry.Account.FromString(v.(string))
 	}
 
+	if v, ok := m["subAccount"]; ok {
+		entry.SubAccount = v.(string)
+	}
+
 	if v, ok := m["entry"]; ok {
 		entry.Entry.FromString(v.(string))
 	}
@@ -0,0 +1,228 @@
+package firebase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/easterthebunny/spew-order/internal/merkle"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/shopspring/decimal"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// /liabilities/{treeid}
+// /liabilities/{treeid}/proofs/{accountid}
+type LiabilityRepository struct {
+	client *firestore.Client
+}
+
+func NewLiabilityRepository(client *firestore.Client) *LiabilityRepository {
+	return &LiabilityRepository{client: client}
+}
+
+func (lr *LiabilityRepository) SetLiabilityTree(ctx context.Context, t *persist.LiabilityTree) error {
+	if t == nil {
+		return fmt.Errorf("%w for liability tree", persist.ErrCannotSaveNilValue)
+	}
+
+	_, err := lr.collection(ctx).Doc(t.ID).Set(ctx, liabilityTreeToDocument(t))
+	if err != nil {
+		return fmt.Errorf("SetLiabilityTree: %w", err)
+	}
+
+	return nil
+}
+
+func (lr *LiabilityRepository) GetLatestLiabilityTree(ctx context.Context) (*persist.LiabilityTree, error) {
+	iter := lr.collection(ctx).OrderBy("timestamp", firestore.Desc).Limit(1).Documents(ctx)
+
+	doc, err := iter.Next()
+	if err != nil {
+		if errors.Is(err, iterator.Done) {
+			return nil, persist.ErrObjectNotExist
+		}
+
+		return nil, fmt.Errorf("GetLatestLiabilityTree: %w", err)
+	}
+
+	t, err := documentToLiabilityTree(doc.Data())
+	if err != nil {
+		return nil, fmt.Errorf("GetLatestLiabilityTree: %w", err)
+	}
+
+	return t, nil
+}
+
+func (lr *LiabilityRepository) SetLiabilityProof(ctx context.Context, p *persist.LiabilityProof) error {
+	if p == nil {
+		return fmt.Errorf("%w for liability proof", persist.ErrCannotSaveNilValue)
+	}
+
+	_, err := lr.collection(ctx).Doc(p.TreeID).Collection("proofs").Doc(p.Account).Set(ctx, liabilityProofToDocument(p))
+	if err != nil {
+		return fmt.Errorf("SetLiabilityProof: %w", err)
+	}
+
+	return nil
+}
+
+func (lr *LiabilityRepository) GetLiabilityProof(ctx context.Context, tree persist.Key, account persist.Key) (*persist.LiabilityProof, error) {
+
+	dsnap, err := lr.collection(ctx).Doc(tree.String()).Collection("proofs").Doc(account.String()).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, persist.ErrObjectNotExist
+		}
+
+		return nil, fmt.Errorf("GetLiabilityProof: %w", err)
+	}
+
+	p, err := documentToLiabilityProof(dsnap.Data())
+	if err != nil {
+		return nil, fmt.Errorf("GetLiabilityProof: %w", err)
+	}
+
+	return p, nil
+}
+
+func (lr *LiabilityRepository) collection(ctx context.Context) *firestore.CollectionRef {
+	var client *firestore.Client
+	if lr.client == nil {
+		client = clientFromContext(ctx)
+	} else {
+		client = lr.client
+	}
+	return client.Collection("liabilities")
+}
+
+func liabilityTreeToDocument(t *persist.LiabilityTree) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        t.ID,
+		"timestamp": t.Timestamp.Value(),
+		"root":      merkleNodeToDocument(t.Root),
+		"accounts":  t.Accounts,
+	}
+}
+
+func documentToLiabilityTree(m map[string]interface{}) (*persist.LiabilityTree, error) {
+	t := &persist.LiabilityTree{}
+
+	if v, ok := m["id"]; ok {
+		t.ID = v.(string)
+	}
+
+	if v, ok := m["timestamp"]; ok {
+		t.Timestamp = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	if v, ok := m["root"]; ok {
+		n, err := documentToMerkleNode(v.(map[string]interface{}))
+		if err != nil {
+			return nil, err
+		}
+		t.Root = n
+	}
+
+	if v, ok := m["accounts"]; ok {
+		t.Accounts = int(v.(int64))
+	}
+
+	return t, nil
+}
+
+func liabilityProofToDocument(p *persist.LiabilityProof) map[string]interface{} {
+	path := make([]interface{}, len(p.Proof.Path))
+	for i, s := range p.Proof.Path {
+		n := merkleNodeToDocument(s.Node)
+		n["left"] = s.Left
+		path[i] = n
+	}
+
+	return map[string]interface{}{
+		"tree_id": p.TreeID,
+		"account": p.Account,
+		"nonce":   p.Nonce,
+		"leaf":    merkleNodeToDocument(p.Proof.Leaf),
+		"path":    path,
+	}
+}
+
+func documentToLiabilityProof(m map[string]interface{}) (*persist.LiabilityProof, error) {
+	p := &persist.LiabilityProof{}
+
+	if v, ok := m["tree_id"]; ok {
+		p.TreeID = v.(string)
+	}
+
+	if v, ok := m["account"]; ok {
+		p.Account = v.(string)
+	}
+
+	if v, ok := m["nonce"]; ok {
+		p.Nonce = v.(string)
+	}
+
+	if v, ok := m["leaf"]; ok {
+		n, err := documentToMerkleNode(v.(map[string]interface{}))
+		if err != nil {
+			return nil, err
+		}
+		p.Proof.Leaf = n
+	}
+
+	if v, ok := m["path"]; ok {
+		for _, x := range v.([]interface{}) {
+			sm := x.(map[string]interface{})
+			n, err := documentToMerkleNode(sm)
+			if err != nil {
+				return nil, err
+			}
+
+			s := merkle.Step{Node: n}
+			if l, ok := sm["left"]; ok {
+				s.Left = l.(bool)
+			}
+
+			p.Proof.Path = append(p.Proof.Path, s)
+		}
+	}
+
+	return p, nil
+}
+
+func merkleNodeToDocument(n merkle.Node) map[string]interface{} {
+	sums := make(map[string]interface{})
+	for k, v := range n.Sums {
+		sums[k] = v.String()
+	}
+
+	return map[string]interface{}{
+		"hash": n.Hash,
+		"sums": sums,
+	}
+}
+
+func documentToMerkleNode(m map[string]interface{}) (merkle.Node, error) {
+	n := merkle.Node{Sums: make(merkle.Sums)}
+
+	if v, ok := m["hash"]; ok {
+		n.Hash = v.(string)
+	}
+
+	if v, ok := m["sums"]; ok {
+		for k, x := range v.(map[string]interface{}) {
+			amt, err := decimal.NewFromString(x.(string))
+			if err != nil {
+				return n, err
+			}
+			n.Sums[k] = amt
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,147 @@
+package firebase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// /markets/{base}{target}
+// /markets/{base}{target}/closes/{date}
+type MarketRepository struct {
+	client *firestore.Client
+}
+
+func NewMarketRepository(client *firestore.Client) *MarketRepository {
+	return &MarketRepository{client: client}
+}
+
+func (mr *MarketRepository) SetLastTrade(ctx context.Context, t *persist.Trade) error {
+	if t == nil {
+		return fmt.Errorf("%w for trade", persist.ErrCannotSaveNilValue)
+	}
+
+	doc := mr.marketDoc(ctx, t.Base, t.Target)
+	batch := mr.getClient(ctx).Batch()
+	batch.Set(doc, tradeToDocument(t))
+	batch.Set(doc.Collection("closes").Doc(t.Date()), tradeToDocument(t))
+
+	_, err := batch.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("SetLastTrade: %w", err)
+	}
+
+	return nil
+}
+
+func (mr *MarketRepository) GetLastTrade(ctx context.Context, base, target types.Symbol) (*persist.Trade, error) {
+
+	dsnap, err := mr.marketDoc(ctx, base, target).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, persist.ErrObjectNotExist
+		}
+
+		return nil, fmt.Errorf("GetLastTrade: %w", err)
+	}
+
+	t, err := documentToTrade(dsnap.Data())
+	if err != nil {
+		return nil, fmt.Errorf("GetLastTrade: %w", err)
+	}
+
+	return t, nil
+}
+
+func (mr *MarketRepository) GetClosingTrades(ctx context.Context, base, target types.Symbol, from, to time.Time) ([]*persist.Trade, error) {
+
+	docs, err := mr.marketDoc(ctx, base, target).Collection("closes").
+		Where("date", ">=", persist.SnapshotDate(from)).
+		Where("date", "<=", persist.SnapshotDate(to)).
+		OrderBy("date", firestore.Asc).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("GetClosingTrades: %w", err)
+	}
+
+	trades := []*persist.Trade{}
+	for _, doc := range docs {
+		t, err := documentToTrade(doc.Data())
+		if err != nil {
+			return nil, fmt.Errorf("GetClosingTrades: %w", err)
+		}
+
+		trades = append(trades, t)
+	}
+
+	return trades, nil
+}
+
+func (mr *MarketRepository) marketDoc(ctx context.Context, base, target types.Symbol) *firestore.DocumentRef {
+	pair := types.SwapPair{Base: base, Target: target}
+	return mr.getClient(ctx).Collection("markets").Doc(pair.String())
+}
+
+func (mr *MarketRepository) getClient(ctx context.Context) *firestore.Client {
+
+	var client *firestore.Client
+	if mr.client == nil {
+		client = clientFromContext(ctx)
+	} else {
+		client = mr.client
+	}
+	return client
+}
+
+func tradeToDocument(t *persist.Trade) map[string]interface{} {
+	return map[string]interface{}{
+		"base":      t.Base.String(),
+		"target":    t.Target.String(),
+		"price":     t.Price.String(),
+		"quantity":  t.Quantity.String(),
+		"timestamp": t.Timestamp.Value(),
+		"date":      t.Date(),
+	}
+}
+
+func documentToTrade(m map[string]interface{}) (*persist.Trade, error) {
+	t := &persist.Trade{}
+
+	var err error
+	if v, ok := m["base"]; ok {
+		if t.Base, err = types.FromString(v.(string)); err != nil {
+			return nil, err
+		}
+	}
+
+	if v, ok := m["target"]; ok {
+		if t.Target, err = types.FromString(v.(string)); err != nil {
+			return nil, err
+		}
+	}
+
+	if v, ok := m["price"]; ok {
+		if t.Price, err = decimal.NewFromString(v.(string)); err != nil {
+			return nil, err
+		}
+	}
+
+	if v, ok := m["quantity"]; ok {
+		if t.Quantity, err = decimal.NewFromString(v.(string)); err != nil {
+			return nil, err
+		}
+	}
+
+	if v, ok := m["timestamp"]; ok {
+		t.Timestamp = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	return t, nil
+}
@@ -149,6 +149,53 @@ func (or *OrderRepository) UpdateOrderStatus(ctx context.Context, k persist.Key,
 	})
 }
 
+func (or *OrderRepository) RejectOrder(ctx context.Context, k persist.Key, r persist.RejectReason) error {
+	return or.getClient(ctx).RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		var txErr error
+		var o *persist.Order
+
+		o, _, txErr = or.getOrder(ctx, tx, k)
+		if txErr != nil {
+			return fmt.Errorf("RejectOrder: %w", txErr)
+		}
+
+		o.Status = persist.StatusRejected
+		o.Reason = r
+
+		col := fmt.Sprintf("accounts/%s/orders", or.account.ID)
+		d := or.getClient(ctx).Collection(col).Doc(uuid.NewV4().String())
+		txErr = tx.Create(d, orderToDocument(o, time.Now().UnixNano()))
+		if txErr != nil {
+			return fmt.Errorf("RejectOrder: %w", txErr)
+		}
+
+		return txErr
+	})
+}
+
+func (or *OrderRepository) FlagOrder(ctx context.Context, k persist.Key, r persist.RejectReason) error {
+	return or.getClient(ctx).RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		var txErr error
+		var o *persist.Order
+
+		o, _, txErr = or.getOrder(ctx, tx, k)
+		if txErr != nil {
+			return fmt.Errorf("FlagOrder: %w", txErr)
+		}
+
+		o.Reason = r
+
+		col := fmt.Sprintf("accounts/%s/orders", or.account.ID)
+		d := or.getClient(ctx).Collection(col).Doc(uuid.NewV4().String())
+		txErr = tx.Create(d, orderToDocument(o, time.Now().UnixNano()))
+		if txErr != nil {
+			return fmt.Errorf("FlagOrder: %w", txErr)
+		}
+
+		return txErr
+	})
+}
+
 func (or *OrderRepository) getClient(ctx context.Context) *firestore.Client {
 
 	var client *firestore.Client
@@ -212,6 +259,10 @@ func orderToDocument(order *persist.Order, version int64) map[string]interface{}
 		"transactions": tr,
 	}
 
+	if order.Reason != "" {
+		m["reason"] = string(order.Reason)
+	}
+
 	return m
 }
 
@@ -222,6 +273,10 @@ func documentToOrder(m map[string]interface{}) *persist.Order {
 		order.Status.FromString(v.(string))
 	}
 
+	if v, ok := m["reason"]; ok {
+		order.Reason = persist.RejectReason(v.(string))
+	}
+
 	if v, ok := m["transactions"]; ok {
 		json.Unmarshal(v.([]byte), &order.Transactions)
 	}
@@ -0,0 +1,180 @@
+package firebase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
+	"google.golang.org/api/iterator"
+)
+
+// /reconciliations/{runid}
+type ReconciliationRepository struct {
+	client *firestore.Client
+}
+
+func NewReconciliationRepository(client *firestore.Client) *ReconciliationRepository {
+	return &ReconciliationRepository{client: client}
+}
+
+func (rr *ReconciliationRepository) SetReconciliation(ctx context.Context, r *persist.Reconciliation) error {
+	if r == nil {
+		return fmt.Errorf("%w for reconciliation", persist.ErrCannotSaveNilValue)
+	}
+
+	_, err := rr.getClient(ctx).Collection("reconciliations").Doc(r.ID).Set(ctx, reconciliationToDocument(r))
+	if err != nil {
+		return fmt.Errorf("SetReconciliation: %w", err)
+	}
+
+	return nil
+}
+
+func (rr *ReconciliationRepository) GetReconciliations(ctx context.Context) (runs []*persist.Reconciliation, err error) {
+	iter := rr.getClient(ctx).Collection("reconciliations").OrderBy("timestamp", firestore.Asc).Documents(ctx)
+	var doc *firestore.DocumentSnapshot
+	for {
+		doc, err = iter.Next()
+		if err != nil {
+			if errors.Is(err, iterator.Done) {
+				err = nil
+			} else {
+				err = fmt.Errorf("GetReconciliations: %w", err)
+			}
+
+			break
+		}
+
+		var r *persist.Reconciliation
+		r, err = documentToReconciliation(doc.Data())
+		if err != nil {
+			err = fmt.Errorf("GetReconciliations: %w", err)
+			break
+		}
+
+		runs = append(runs, r)
+	}
+
+	return
+}
+
+func (rr *ReconciliationRepository) getClient(ctx context.Context) *firestore.Client {
+
+	var client *firestore.Client
+	if rr.client == nil {
+		client = clientFromContext(ctx)
+	} else {
+		client = rr.client
+	}
+	return client
+}
+
+func reconciliationToDocument(r *persist.Reconciliation) map[string]interface{} {
+	items := make([]interface{}, len(r.Items))
+	for i, item := range r.Items {
+		holdings := make(map[string]interface{})
+		for src, amt := range item.Holdings {
+			holdings[src] = amt.String()
+		}
+
+		items[i] = map[string]interface{}{
+			"symbol":      item.Symbol.String(),
+			"holdings":    holdings,
+			"total":       item.Total.String(),
+			"liabilities": item.Liabilities.String(),
+			"payable":     item.Payable.String(),
+			"threshold":   item.Threshold.String(),
+			"discrepant":  item.Discrepant,
+		}
+	}
+
+	errs := make([]interface{}, len(r.Errors))
+	for i, e := range r.Errors {
+		errs[i] = e
+	}
+
+	return map[string]interface{}{
+		"id":        r.ID,
+		"timestamp": r.Timestamp.Value(),
+		"items":     items,
+		"errors":    errs,
+	}
+}
+
+func documentToReconciliation(m map[string]interface{}) (*persist.Reconciliation, error) {
+	r := &persist.Reconciliation{}
+
+	if v, ok := m["id"]; ok {
+		r.ID = v.(string)
+	}
+
+	if v, ok := m["timestamp"]; ok {
+		r.Timestamp = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	if v, ok := m["items"]; ok {
+		for _, x := range v.([]interface{}) {
+			item, err := documentToReconciliationItem(x.(map[string]interface{}))
+			if err != nil {
+				return nil, err
+			}
+			r.Items = append(r.Items, item)
+		}
+	}
+
+	if v, ok := m["errors"]; ok {
+		for _, x := range v.([]interface{}) {
+			r.Errors = append(r.Errors, x.(string))
+		}
+	}
+
+	return r, nil
+}
+
+func documentToReconciliationItem(m map[string]interface{}) (persist.ReconciliationItem, error) {
+	item := persist.ReconciliationItem{Holdings: make(map[string]decimal.Decimal)}
+
+	if v, ok := m["symbol"]; ok {
+		sym, err := types.FromString(v.(string))
+		if err != nil {
+			return item, err
+		}
+		item.Symbol = sym
+	}
+
+	if v, ok := m["holdings"]; ok {
+		for src, x := range v.(map[string]interface{}) {
+			amt, err := decimal.NewFromString(x.(string))
+			if err != nil {
+				return item, err
+			}
+			item.Holdings[src] = amt
+		}
+	}
+
+	for name, dst := range map[string]*decimal.Decimal{
+		"total":       &item.Total,
+		"liabilities": &item.Liabilities,
+		"payable":     &item.Payable,
+		"threshold":   &item.Threshold,
+	} {
+		if v, ok := m[name]; ok {
+			amt, err := decimal.NewFromString(v.(string))
+			if err != nil {
+				return item, err
+			}
+			*dst = amt
+		}
+	}
+
+	if v, ok := m["discrepant"]; ok {
+		item.Discrepant = v.(bool)
+	}
+
+	return item, nil
+}
@@ -0,0 +1,143 @@
+package firebase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
+	"google.golang.org/api/iterator"
+)
+
+// /root/account/{accountid}/snapshots/{date}
+type BalanceSnapshotRepository struct {
+	client  *firestore.Client
+	account *persist.Account
+}
+
+func NewBalanceSnapshotRepository(client *firestore.Client, account *persist.Account) *BalanceSnapshotRepository {
+	return &BalanceSnapshotRepository{client: client, account: account}
+}
+
+func (sr *BalanceSnapshotRepository) SetSnapshot(ctx context.Context, s *persist.BalanceSnapshot) error {
+	if s == nil {
+		return fmt.Errorf("%w for balance snapshot", persist.ErrCannotSaveNilValue)
+	}
+
+	_, err := sr.collection(ctx).Doc(s.Date).Set(ctx, balanceSnapshotToDocument(s))
+	if err != nil {
+		return fmt.Errorf("SetSnapshot: %w", err)
+	}
+
+	return nil
+}
+
+func (sr *BalanceSnapshotRepository) GetSnapshots(ctx context.Context, from, to time.Time) (snapshots []*persist.BalanceSnapshot, err error) {
+	iter := sr.collection(ctx).
+		Where("date", ">=", persist.SnapshotDate(from)).
+		Where("date", "<=", persist.SnapshotDate(to)).
+		OrderBy("date", firestore.Asc).
+		Documents(ctx)
+
+	var doc *firestore.DocumentSnapshot
+	for {
+		doc, err = iter.Next()
+		if err != nil {
+			if errors.Is(err, iterator.Done) {
+				err = nil
+			} else {
+				err = fmt.Errorf("GetSnapshots: %w", err)
+			}
+
+			break
+		}
+
+		var s *persist.BalanceSnapshot
+		s, err = documentToBalanceSnapshot(doc.Data())
+		if err != nil {
+			err = fmt.Errorf("GetSnapshots: %w", err)
+			return
+		}
+
+		snapshots = append(snapshots, s)
+	}
+
+	return
+}
+
+func (sr *BalanceSnapshotRepository) collection(ctx context.Context) *firestore.CollectionRef {
+	return sr.getClient(ctx).Collection(fmt.Sprintf("accounts/%s/snapshots", sr.account.ID))
+}
+
+func (sr *BalanceSnapshotRepository) getClient(ctx context.Context) *firestore.Client {
+
+	var client *firestore.Client
+	if sr.client == nil {
+		client = clientFromContext(ctx)
+	} else {
+		client = sr.client
+	}
+	return client
+}
+
+func balanceSnapshotToDocument(s *persist.BalanceSnapshot) map[string]interface{} {
+	balances := []interface{}{}
+	for _, b := range s.Balances {
+		balances = append(balances, map[string]interface{}{
+			"symbol":    b.Symbol.String(),
+			"posted":    b.Posted.StringFixedBank(b.Symbol.RoundingPlace()),
+			"available": b.Available.StringFixedBank(b.Symbol.RoundingPlace()),
+		})
+	}
+
+	return map[string]interface{}{
+		"date":      s.Date,
+		"timestamp": s.Timestamp.Value(),
+		"balances":  balances,
+	}
+}
+
+func documentToBalanceSnapshot(m map[string]interface{}) (*persist.BalanceSnapshot, error) {
+	s := &persist.BalanceSnapshot{}
+
+	if v, ok := m["date"]; ok {
+		s.Date = v.(string)
+	}
+
+	if v, ok := m["timestamp"]; ok {
+		s.Timestamp = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	if v, ok := m["balances"]; ok {
+		for _, item := range v.([]interface{}) {
+			b := item.(map[string]interface{})
+
+			sym, err := types.FromString(b["symbol"].(string))
+			if err != nil {
+				return nil, err
+			}
+
+			posted, err := decimal.NewFromString(b["posted"].(string))
+			if err != nil {
+				return nil, err
+			}
+
+			available, err := decimal.NewFromString(b["available"].(string))
+			if err != nil {
+				return nil, err
+			}
+
+			s.Balances = append(s.Balances, persist.SnapshotBalance{
+				Symbol:    sym,
+				Posted:    posted,
+				Available: available,
+			})
+		}
+	}
+
+	return s, nil
+}
@@ -0,0 +1,245 @@
+package firebase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// /root/account/{accountid}/withdrawals/{withdrawalid}
+type WithdrawalRepository struct {
+	client  *firestore.Client
+	account *persist.Account
+}
+
+func NewWithdrawalRepository(client *firestore.Client, account *persist.Account) *WithdrawalRepository {
+	return &WithdrawalRepository{client: client, account: account}
+}
+
+func (wr *WithdrawalRepository) SetWithdrawal(ctx context.Context, w *persist.Withdrawal) error {
+	if w == nil {
+		return fmt.Errorf("%w for withdrawal", persist.ErrCannotSaveNilValue)
+	}
+
+	_, err := wr.collection(ctx).Doc(w.ID).Set(ctx, withdrawalToDocument(w))
+	if err != nil {
+		return fmt.Errorf("SetWithdrawal: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateWithdrawal saves the withdrawal in a transaction that reads the stored
+// withdrawal at the expected update time
+func (wr *WithdrawalRepository) UpdateWithdrawal(ctx context.Context, w *persist.Withdrawal, last persist.NanoTime) error {
+	if w == nil {
+		return fmt.Errorf("%w for withdrawal", persist.ErrCannotSaveNilValue)
+	}
+
+	doc := wr.collection(ctx).Doc(w.ID)
+	err := wr.getClient(ctx).RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		dsnap, err := tx.Get(doc)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return persist.ErrObjectNotExist
+			}
+			return err
+		}
+
+		current, err := documentToWithdrawal(dsnap.Data())
+		if err != nil {
+			return err
+		}
+
+		if current.Updated.Value() != last.Value() {
+			return fmt.Errorf("%w: withdrawal updated at %s", persist.ErrPreconditionFailed, current.Updated)
+		}
+
+		return tx.Set(doc, withdrawalToDocument(w))
+	})
+	if err != nil {
+		return fmt.Errorf("UpdateWithdrawal: %w", err)
+	}
+
+	return nil
+}
+
+func (wr *WithdrawalRepository) GetWithdrawal(ctx context.Context, k persist.Key) (*persist.Withdrawal, error) {
+
+	dsnap, err := wr.collection(ctx).Doc(k.String()).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, persist.ErrObjectNotExist
+		}
+
+		return nil, fmt.Errorf("GetWithdrawal: %w", err)
+	}
+
+	w, err := documentToWithdrawal(dsnap.Data())
+	if err != nil {
+		return nil, fmt.Errorf("GetWithdrawal: %w", err)
+	}
+
+	return w, nil
+}
+
+func (wr *WithdrawalRepository) GetWithdrawals(ctx context.Context, st ...persist.WithdrawalStatus) (list []*persist.Withdrawal, err error) {
+
+	q := wr.collection(ctx).Query
+	if len(st) > 0 {
+		in := []string{}
+		for _, s := range st {
+			in = append(in, string(s))
+		}
+		q = q.Where("status", "in", in)
+	}
+
+	iter := q.OrderBy("created", firestore.Asc).Documents(ctx)
+	var doc *firestore.DocumentSnapshot
+	for {
+		doc, err = iter.Next()
+		if err != nil {
+			if errors.Is(err, iterator.Done) {
+				err = nil
+			} else {
+				err = fmt.Errorf("GetWithdrawals: %w", err)
+			}
+
+			break
+		}
+
+		var w *persist.Withdrawal
+		w, err = documentToWithdrawal(doc.Data())
+		if err != nil {
+			err = fmt.Errorf("GetWithdrawals: %w", err)
+			return
+		}
+
+		list = append(list, w)
+	}
+
+	return
+}
+
+func (wr *WithdrawalRepository) collection(ctx context.Context) *firestore.CollectionRef {
+	return wr.getClient(ctx).Collection(fmt.Sprintf("accounts/%s/withdrawals", wr.account.ID))
+}
+
+func (wr *WithdrawalRepository) getClient(ctx context.Context) *firestore.Client {
+
+	var client *firestore.Client
+	if wr.client == nil {
+		client = clientFromContext(ctx)
+	} else {
+		client = wr.client
+	}
+	return client
+}
+
+func withdrawalToDocument(w *persist.Withdrawal) map[string]interface{} {
+	doc := map[string]interface{}{
+		"id":               w.ID,
+		"account":          w.Account,
+		"symbol":           w.Symbol.String(),
+		"amount":           w.Amount.StringFixedBank(w.Symbol.RoundingPlace()),
+		"fee":              w.Fee.StringFixedBank(w.Symbol.RoundingPlace()),
+		"address":          w.Address,
+		"hold_id":          w.HoldID,
+		"status":           string(w.Status),
+		"transaction_hash": w.TransactionHash,
+		"reason":           w.Reason,
+		"settlement":       string(w.Settlement),
+		"created":          w.Created.Value(),
+		"updated":          w.Updated.Value(),
+	}
+
+	if w.Settled != nil {
+		doc["settled"] = w.Settled.Value()
+	}
+
+	return doc
+}
+
+func documentToWithdrawal(m map[string]interface{}) (*persist.Withdrawal, error) {
+	w := &persist.Withdrawal{}
+
+	if v, ok := m["id"]; ok {
+		w.ID = v.(string)
+	}
+
+	if v, ok := m["account"]; ok {
+		w.Account = v.(string)
+	}
+
+	if v, ok := m["symbol"]; ok {
+		sym, err := types.FromString(v.(string))
+		if err != nil {
+			return nil, err
+		}
+		w.Symbol = sym
+	}
+
+	if v, ok := m["amount"]; ok {
+		amt, err := decimal.NewFromString(v.(string))
+		if err != nil {
+			return nil, err
+		}
+		w.Amount = amt
+	}
+
+	if v, ok := m["fee"]; ok {
+		fee, err := decimal.NewFromString(v.(string))
+		if err != nil {
+			return nil, err
+		}
+		w.Fee = fee
+	}
+
+	if v, ok := m["address"]; ok {
+		w.Address = v.(string)
+	}
+
+	if v, ok := m["hold_id"]; ok {
+		w.HoldID = v.(string)
+	}
+
+	if v, ok := m["status"]; ok {
+		w.Status = persist.WithdrawalStatus(v.(string))
+	}
+
+	if v, ok := m["transaction_hash"]; ok {
+		w.TransactionHash = v.(string)
+	}
+
+	if v, ok := m["reason"]; ok {
+		w.Reason = v.(string)
+	}
+
+	if v, ok := m["settlement"]; ok {
+		w.Settlement = persist.WithdrawalSettlement(v.(string))
+	}
+
+	if v, ok := m["settled"]; ok {
+		settled := persist.NanoTime(time.Unix(0, v.(int64)))
+		w.Settled = &settled
+	}
+
+	if v, ok := m["created"]; ok {
+		w.Created = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	if v, ok := m["updated"]; ok {
+		w.Updated = persist.NanoTime(time.Unix(0, v.(int64)))
+	}
+
+	return w, nil
+}
@@ -90,3 +90,31 @@ func (r *AccountRepository) Transactions(a *persist.Account) persist.Transaction
 func (r *AccountRepository) Orders(a *persist.Account) persist.OrderRepository {
 	return NewOrderRepository(r.kvstore, a)
 }
+
+func (r *AccountRepository) Idempotency(a *persist.Account) persist.IdempotencyRepository {
+	return NewIdempotencyRepository(r.kvstore, a)
+}
+
+func (r *AccountRepository) Snapshots(a *persist.Account) persist.BalanceSnapshotRepository {
+	return NewBalanceSnapshotRepository(r.kvstore, a)
+}
+
+func (r *AccountRepository) Withdrawals(a *persist.Account) persist.WithdrawalRepository {
+	return NewWithdrawalRepository(r.kvstore, a)
+}
+
+func (r *AccountRepository) Allowlist(a *persist.Account) persist.AllowlistRepository {
+	return NewAllowlistRepository(r.kvstore, a)
+}
+
+func (r *AccountRepository) Deposits(a *persist.Account) persist.DepositRepository {
+	return NewDepositRepository(r.kvstore, a)
+}
+
+func (r *AccountRepository) DepositRegistry() persist.DepositRegistry {
+	return NewDepositRegistry(r.kvstore)
+}
+
+func (r *AccountRepository) Campaigns() persist.CampaignRepository {
+	return NewCampaignRepository(r.kvstore)
+}
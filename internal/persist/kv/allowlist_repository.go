@@ -0,0 +1,94 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/key"
+	"github.com/easterthebunny/spew-order/internal/persist"
+)
+
+type AllowlistRepository struct {
+	kvstore persist.KVStore
+	account *persist.Account
+}
+
+func NewAllowlistRepository(store persist.KVStore, account *persist.Account) *AllowlistRepository {
+	return &AllowlistRepository{kvstore: store, account: account}
+}
+
+func (ar *AllowlistRepository) SetAllowlistEntry(ctx context.Context, e *persist.AllowlistEntry) error {
+	if e == nil {
+		return fmt.Errorf("%w for allowlist entry", persist.ErrCannotSaveNilValue)
+	}
+
+	enc := persist.JSON
+	b, err := e.Encode(enc)
+	if err != nil {
+		return err
+	}
+
+	attrs := persist.KVStoreObjectAttrsToUpdate{
+		ContentEncoding: encodingToStr(enc),
+		Metadata:        make(map[string]string),
+	}
+
+	return ar.kvstore.Set(allowlistKey(*ar.account, stringer(e.ID)), b, &attrs)
+}
+
+func (ar *AllowlistRepository) GetAllowlistEntry(ctx context.Context, k persist.Key) (e *persist.AllowlistEntry, err error) {
+
+	b, err := ar.kvstore.Get(allowlistKey(*ar.account, k))
+	if err != nil {
+		return
+	}
+
+	attr, err := ar.kvstore.Attrs(allowlistKey(*ar.account, k))
+	if err != nil {
+		return
+	}
+
+	e = &persist.AllowlistEntry{}
+	err = e.Decode(b, encodingFromStr(attr.ContentEncoding))
+
+	return
+}
+
+func (ar *AllowlistRepository) GetAllowlist(ctx context.Context) (list []*persist.AllowlistEntry, err error) {
+
+	q := persist.KVStoreQuery{
+		StartOffset: allowlistSubspace(*ar.account).Pack(key.Tuple{}).String()}
+
+	attrs, err := ar.kvstore.RangeGet(&q, 0)
+	if err != nil {
+		return
+	}
+
+	for _, attr := range attrs {
+		var b []byte
+		b, err = ar.kvstore.Get(attr.Name)
+		if err != nil {
+			return
+		}
+
+		e := &persist.AllowlistEntry{}
+		err = e.Decode(b, encodingFromStr(attr.ContentEncoding))
+		if err != nil {
+			return
+		}
+
+		list = append(list, e)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return time.Time(list[i].Created).Before(time.Time(list[j].Created))
+	})
+
+	return
+}
+
+func (ar *AllowlistRepository) DeleteAllowlistEntry(ctx context.Context, k persist.Key) error {
+	return ar.kvstore.Delete(allowlistKey(*ar.account, k))
+}
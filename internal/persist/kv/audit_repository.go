@@ -0,0 +1,81 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/easterthebunny/spew-order/internal/key"
+	"github.com/easterthebunny/spew-order/internal/persist"
+)
+
+type AuditRepository struct {
+	kvstore persist.KVStore
+}
+
+func NewAuditRepository(store persist.KVStore) *AuditRepository {
+	return &AuditRepository{kvstore: store}
+}
+
+func (ar *AuditRepository) SetAuditEntry(ctx context.Context, e *persist.AuditEntry) error {
+	if e == nil {
+		return fmt.Errorf("%w for audit entry", persist.ErrCannotSaveNilValue)
+	}
+
+	enc := persist.JSON
+	b, err := e.Encode(enc)
+	if err != nil {
+		return err
+	}
+
+	attrs := persist.KVStoreObjectAttrsToUpdate{
+		ContentEncoding: encodingToStr(enc),
+		Metadata:        make(map[string]string),
+	}
+
+	return ar.kvstore.Set(auditKey(*e), b, &attrs)
+}
+
+func (ar *AuditRepository) GetAuditEntries(ctx context.Context) (entries []*persist.AuditEntry, err error) {
+
+	q := persist.KVStoreQuery{
+		StartOffset: auditSubspace().Pack(key.Tuple{}).String()}
+
+	attrs, err := ar.kvstore.RangeGet(&q, 0)
+	if err != nil {
+		return
+	}
+
+	for _, attr := range attrs {
+		var b []byte
+		b, err = ar.kvstore.Get(attr.Name)
+		if err != nil {
+			return
+		}
+
+		e := &persist.AuditEntry{}
+		err = e.Decode(b, encodingFromStr(attr.ContentEncoding))
+		if err != nil {
+			return
+		}
+
+		entries = append(entries, e)
+	}
+
+	return
+}
+
+func (ar *AuditRepository) GetAccountAuditEntries(ctx context.Context, account string) ([]*persist.AuditEntry, error) {
+	all, err := ar.GetAuditEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*persist.AuditEntry{}
+	for _, e := range all {
+		if e.Account == account {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, nil
+}
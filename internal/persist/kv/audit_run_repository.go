@@ -0,0 +1,92 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/easterthebunny/spew-order/internal/key"
+	"github.com/easterthebunny/spew-order/internal/persist"
+)
+
+type AuditRunRepository struct {
+	kvstore persist.KVStore
+}
+
+func NewAuditRunRepository(store persist.KVStore) *AuditRunRepository {
+	return &AuditRunRepository{kvstore: store}
+}
+
+func (ar *AuditRunRepository) SetAuditRun(ctx context.Context, r *persist.AuditRun) error {
+	if r == nil {
+		return fmt.Errorf("%w for audit run", persist.ErrCannotSaveNilValue)
+	}
+
+	enc := persist.JSON
+	b, err := r.Encode(enc)
+	if err != nil {
+		return err
+	}
+
+	attrs := persist.KVStoreObjectAttrsToUpdate{
+		ContentEncoding: encodingToStr(enc),
+		Metadata:        make(map[string]string),
+	}
+
+	return ar.kvstore.Set(auditRunKey(stringer(r.ID)), b, &attrs)
+}
+
+func (ar *AuditRunRepository) GetAuditRun(ctx context.Context, k persist.Key) (*persist.AuditRun, error) {
+	id := auditRunKey(k)
+
+	attrs, err := ar.kvstore.Attrs(id)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ar.kvstore.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &persist.AuditRun{}
+	err = r.Decode(b, encodingFromStr(attrs.ContentEncoding))
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (ar *AuditRunRepository) GetAuditRuns(ctx context.Context) (runs []*persist.AuditRun, err error) {
+
+	q := persist.KVStoreQuery{
+		StartOffset: auditRunSubspace().Pack(key.Tuple{}).String()}
+
+	attrs, err := ar.kvstore.RangeGet(&q, 0)
+	if err != nil {
+		return
+	}
+
+	for _, attr := range attrs {
+		var b []byte
+		b, err = ar.kvstore.Get(attr.Name)
+		if err != nil {
+			return
+		}
+
+		r := &persist.AuditRun{}
+		err = r.Decode(b, encodingFromStr(attr.ContentEncoding))
+		if err != nil {
+			return
+		}
+
+		runs = append(runs, r)
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Timestamp.Value() < runs[j].Timestamp.Value()
+	})
+
+	return
+}
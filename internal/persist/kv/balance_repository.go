@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/easterthebunny/spew-order/internal/key"
 	"github.com/easterthebunny/spew-order/internal/persist"
@@ -12,6 +13,9 @@ import (
 	"github.com/shopspring/decimal"
 )
 
+// HoldLockTimeout is the age at which a hold lock is considered abandoned
+var HoldLockTimeout = 10 * time.Second
+
 type BalanceRepository struct {
 	kvstore persist.KVStore
 	account *persist.Account
@@ -118,6 +122,52 @@ func (b *BalanceRepository) CreateHold(ctx context.Context, hold *persist.Balanc
 	return b.kvstore.Set(k, bts, &attrs)
 }
 
+// CreateHoldIfAvailable stores a new hold while holding the hold lock for the account
+// symbol. The lock is a conditional write so only one writer can check the available
+// balance and create a hold at a time.
+func (b *BalanceRepository) CreateHoldIfAvailable(ctx context.Context, hold *persist.BalanceItem) error {
+	if hold == nil {
+		return fmt.Errorf("%w for hold", persist.ErrCannotSaveNilValue)
+	}
+
+	unlock, err := b.lockHolds(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	available, err := b.GetBalance(ctx)
+	if err != nil {
+		return err
+	}
+
+	holds, err := b.FindHolds(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range holds {
+		available = available.Sub(h.Amount)
+	}
+
+	if available.LessThan(hold.Amount) {
+		return persist.ErrInsufficientBalance
+	}
+
+	return b.CreateHold(ctx, hold)
+}
+
+// lockHolds acquires the hold lock for the account symbol. A lock older than
+// HoldLockTimeout is assumed abandoned and is taken over.
+func (b *BalanceRepository) lockHolds(ctx context.Context) (func(), error) {
+	unlock, err := acquireLock(ctx, b.kvstore, holdLockKey(*b.account, b.symbol), HoldLockTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("Balance::lockHolds -- %w", err)
+	}
+
+	return unlock, nil
+}
+
 func (b *BalanceRepository) UpdateHold(ctx context.Context, id persist.Key, amt decimal.Decimal) error {
 
 	holds, err := b.FindHolds(ctx)
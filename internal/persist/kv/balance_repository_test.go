@@ -2,6 +2,7 @@ package kv
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -160,3 +161,84 @@ type ky string
 func (f ky) String() string {
 	return string(f)
 }
+
+func TestCreateHoldIfAvailable(t *testing.T) {
+
+	s := persist.NewMockKVStore()
+	a := persist.Account{ID: uuid.NewV4().String()}
+	ctx := context.Background()
+
+	br := NewBalanceRepository(s, &a, types.SymbolBitcoin)
+	assert.NoError(t, br.AddToBalance(ctx, decimal.NewFromInt(10)))
+
+	holds := make([]*persist.BalanceItem, 20)
+	start := time.Now()
+	for i := range holds {
+		holds[i] = persist.NewBalanceItem(decimal.NewFromInt(1))
+		holds[i].Timestamp = persist.NanoTime(start.Add(time.Duration(i)))
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(holds))
+	for i := range holds {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = br.CreateHoldIfAvailable(ctx, holds[i])
+		}(i)
+	}
+	wg.Wait()
+
+	var created int
+	for _, err := range errs {
+		if err == nil {
+			created++
+		} else {
+			assert.ErrorIs(t, err, persist.ErrInsufficientBalance)
+		}
+	}
+	assert.Equal(t, 10, created)
+
+	found, err := br.FindHolds(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, found, 10)
+
+	// the lock is released after each hold
+	_, err = s.Get(holdLockKey(a, types.SymbolBitcoin))
+	assert.ErrorIs(t, err, persist.ErrObjectNotExist)
+}
+
+func TestLockHoldsTakeover(t *testing.T) {
+
+	s := persist.NewMockKVStore()
+	a := persist.Account{ID: uuid.NewV4().String()}
+	ctx := context.Background()
+
+	br := NewBalanceRepository(s, &a, types.SymbolBitcoin)
+
+	timeout := HoldLockTimeout
+	defer func() { HoldLockTimeout = timeout }()
+	HoldLockTimeout = 20 * time.Millisecond
+
+	stale, err := br.lockHolds(ctx)
+	assert.NoError(t, err)
+
+	// an abandoned lock is taken over once it expires
+	<-time.After(2 * HoldLockTimeout)
+	unlock, err := br.lockHolds(ctx)
+	assert.NoError(t, err)
+
+	// releasing the abandoned lock leaves the new owner holding it
+	stale()
+	_, err = s.Get(holdLockKey(a, types.SymbolBitcoin))
+	assert.NoError(t, err)
+
+	tctx, cancel := context.WithTimeout(ctx, HoldLockTimeout/2)
+	defer cancel()
+	_, err = br.lockHolds(tctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	unlock()
+	_, err = s.Get(holdLockKey(a, types.SymbolBitcoin))
+	assert.ErrorIs(t, err, persist.ErrObjectNotExist)
+}
@@ -54,12 +54,30 @@ func (br *BookRepository) SetBookItem(ctx context.Context, bi *persist.BookItem)
 func (br *BookRepository) GetHeadBatch(ctx context.Context, bi *persist.BookItem, limit int, offset *persist.BookItem) (items []*persist.BookItem, err error) {
 	query := &persist.KVStoreQuery{
 		StartOffset: bookItemSubspace(*bi, &bi.ActionType).Pack(key.Tuple{}).String()}
-	attrs, err := br.kvstore.RangeGet(query, 10)
+
+	// the store cannot start a range after a key so the items up to and
+	// including the offset are read and skipped
+	var after string
+	rangeLimit := limit
+	if offset != nil {
+		after = bookItemKey(*offset)
+		rangeLimit = 0
+	}
+
+	attrs, err := br.kvstore.RangeGet(query, rangeLimit)
 	if err != nil {
 		return
 	}
 
 	for _, attr := range attrs {
+		if attr.Name <= after {
+			continue
+		}
+
+		if limit > 0 && len(items) >= limit {
+			break
+		}
+
 		var data []byte
 		data, err = br.kvstore.Get(attr.Name)
 		if err != nil {
@@ -0,0 +1,337 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/key"
+	"github.com/easterthebunny/spew-order/internal/persist"
+)
+
+// CampaignLockTimeout is the age at which a campaign lock is considered abandoned
+var CampaignLockTimeout = 10 * time.Second
+
+type CampaignRepository struct {
+	kvstore persist.KVStore
+}
+
+func NewCampaignRepository(store persist.KVStore) *CampaignRepository {
+	return &CampaignRepository{kvstore: store}
+}
+
+func (cr *CampaignRepository) SetCampaign(ctx context.Context, c *persist.Campaign) error {
+	if c == nil {
+		return fmt.Errorf("%w for campaign", persist.ErrCannotSaveNilValue)
+	}
+
+	return cr.set(campaignKey(stringer(c.ID)), c)
+}
+
+func (cr *CampaignRepository) CreateCampaign(ctx context.Context, c *persist.Campaign) error {
+	if c == nil {
+		return fmt.Errorf("%w for campaign", persist.ErrCannotSaveNilValue)
+	}
+
+	enc := persist.JSON
+	b, err := c.Encode(enc)
+	if err != nil {
+		return err
+	}
+
+	attrs := persist.KVStoreObjectAttrsToUpdate{
+		ContentEncoding: encodingToStr(enc),
+		Metadata:        make(map[string]string),
+	}
+
+	return cr.kvstore.SetIfNotExists(campaignKey(stringer(c.ID)), b, &attrs)
+}
+
+func (cr *CampaignRepository) GetCampaign(ctx context.Context, id persist.Key) (c *persist.Campaign, err error) {
+
+	k := campaignKey(id)
+	b, err := cr.kvstore.Get(k)
+	if err != nil {
+		return
+	}
+
+	attr, err := cr.kvstore.Attrs(k)
+	if err != nil {
+		return
+	}
+
+	c = &persist.Campaign{}
+	err = c.Decode(b, encodingFromStr(attr.ContentEncoding))
+	return
+}
+
+func (cr *CampaignRepository) GetCampaigns(ctx context.Context) (campaigns []*persist.Campaign, err error) {
+
+	q := persist.KVStoreQuery{
+		StartOffset: campaignSubspace().Pack(key.Tuple{}).String()}
+
+	attrs, err := cr.kvstore.RangeGet(&q, 0)
+	if err != nil {
+		return
+	}
+
+	for _, attr := range attrs {
+		var b []byte
+		b, err = cr.kvstore.Get(attr.Name)
+		if err != nil {
+			return
+		}
+
+		c := &persist.Campaign{}
+		err = c.Decode(b, encodingFromStr(attr.ContentEncoding))
+		if err != nil {
+			return
+		}
+
+		campaigns = append(campaigns, c)
+	}
+
+	sort.SliceStable(campaigns, func(i, j int) bool {
+		return campaigns[i].Created.Value() < campaigns[j].Created.Value()
+	})
+
+	return
+}
+
+// CreateGrant checks the campaign budget and owner limit while holding the campaign
+// lock. The lock is a conditional write so only one grant is made from a campaign at
+// a time.
+func (cr *CampaignRepository) CreateGrant(ctx context.Context, g *persist.Grant) error {
+	if g == nil {
+		return fmt.Errorf("%w for grant", persist.ErrCannotSaveNilValue)
+	}
+
+	unlock, err := acquireLock(ctx, cr.kvstore, campaignLockKey(stringer(g.Campaign)), CampaignLockTimeout)
+	if err != nil {
+		return fmt.Errorf("Campaign::CreateGrant -- %w", err)
+	}
+	defer unlock()
+
+	c, err := cr.GetCampaign(ctx, stringer(g.Campaign))
+	if err != nil {
+		return fmt.Errorf("Campaign::CreateGrant -- %w", err)
+	}
+
+	if _, err := cr.kvstore.Get(grantKey(*g)); err == nil {
+		return persist.ErrObjectExists
+	} else if !errors.Is(err, persist.ErrObjectNotExist) {
+		return err
+	}
+
+	if c.Remaining().LessThan(g.Amount) {
+		return persist.ErrCampaignBudget
+	}
+
+	grants, err := cr.GetGrants(ctx, stringer(c.ID))
+	if err != nil {
+		return err
+	}
+
+	var cnt int
+	for _, existing := range grants {
+		if existing.Owner == g.Owner {
+			cnt++
+		}
+	}
+
+	if cnt >= c.PerAccountLimit {
+		return persist.ErrGrantLimit
+	}
+
+	if err := cr.set(grantKey(*g), g); err != nil {
+		return err
+	}
+
+	c.Granted = c.Granted.Add(g.Amount)
+	return cr.set(campaignKey(stringer(c.ID)), c)
+}
+
+func (cr *CampaignRepository) GetGrant(ctx context.Context, campaign persist.Key, id persist.Key) (g *persist.Grant, err error) {
+
+	k := grantKey(persist.Grant{Campaign: campaign.String(), ID: id.String()})
+	b, err := cr.kvstore.Get(k)
+	if err != nil {
+		return
+	}
+
+	attr, err := cr.kvstore.Attrs(k)
+	if err != nil {
+		return
+	}
+
+	g = &persist.Grant{}
+	err = g.Decode(b, encodingFromStr(attr.ContentEncoding))
+	return
+}
+
+// UpdateGrant saves the grant with a generation precondition on the stored grant read
+// with the expected status
+func (cr *CampaignRepository) UpdateGrant(ctx context.Context, g *persist.Grant, from persist.GrantStatus) error {
+	if g == nil {
+		return fmt.Errorf("%w for grant", persist.ErrCannotSaveNilValue)
+	}
+
+	k := grantKey(*g)
+	attr, err := cr.kvstore.Attrs(k)
+	if err != nil {
+		return err
+	}
+
+	b, err := cr.kvstore.Get(k)
+	if err != nil {
+		return err
+	}
+
+	current := &persist.Grant{}
+	err = current.Decode(b, encodingFromStr(attr.ContentEncoding))
+	if err != nil {
+		return err
+	}
+
+	if current.Status != from {
+		return fmt.Errorf("%w: grant is %s", persist.ErrPreconditionFailed, current.Status)
+	}
+
+	enc := persist.JSON
+	b, err = g.Encode(enc)
+	if err != nil {
+		return err
+	}
+
+	attrs := persist.KVStoreObjectAttrsToUpdate{
+		ContentEncoding: encodingToStr(enc),
+		Metadata:        make(map[string]string),
+	}
+
+	_, err = cr.kvstore.SetIfGenerationMatch(k, b, &attrs, attr.Generation)
+	return err
+}
+
+func (cr *CampaignRepository) DeleteGrant(ctx context.Context, g *persist.Grant) error {
+	if g == nil {
+		return nil
+	}
+
+	unlock, err := acquireLock(ctx, cr.kvstore, campaignLockKey(stringer(g.Campaign)), CampaignLockTimeout)
+	if err != nil {
+		return fmt.Errorf("Campaign::DeleteGrant -- %w", err)
+	}
+	defer unlock()
+
+	if _, err := cr.kvstore.Get(grantKey(*g)); err != nil {
+		if errors.Is(err, persist.ErrObjectNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	c, err := cr.GetCampaign(ctx, stringer(g.Campaign))
+	if err != nil {
+		return fmt.Errorf("Campaign::DeleteGrant -- %w", err)
+	}
+
+	if err := cr.kvstore.Delete(grantKey(*g)); err != nil {
+		return err
+	}
+
+	c.Granted = c.Granted.Sub(g.Amount)
+	return cr.set(campaignKey(stringer(c.ID)), c)
+}
+
+func (cr *CampaignRepository) GetGrants(ctx context.Context, campaign persist.Key) (grants []*persist.Grant, err error) {
+
+	q := persist.KVStoreQuery{
+		StartOffset: grantSubspace(campaign).Pack(key.Tuple{}).String()}
+
+	attrs, err := cr.kvstore.RangeGet(&q, 0)
+	if err != nil {
+		return
+	}
+
+	for _, attr := range attrs {
+		var b []byte
+		b, err = cr.kvstore.Get(attr.Name)
+		if err != nil {
+			return
+		}
+
+		g := &persist.Grant{}
+		err = g.Decode(b, encodingFromStr(attr.ContentEncoding))
+		if err != nil {
+			return
+		}
+
+		if g.Campaign != campaign.String() {
+			continue
+		}
+
+		grants = append(grants, g)
+	}
+
+	sort.SliceStable(grants, func(i, j int) bool {
+		return grants[i].Timestamp.Value() < grants[j].Timestamp.Value()
+	})
+
+	return
+}
+
+func (cr *CampaignRepository) SetReferral(ctx context.Context, r *persist.Referral) error {
+	if r == nil {
+		return fmt.Errorf("%w for referral", persist.ErrCannotSaveNilValue)
+	}
+
+	enc := persist.JSON
+	b, err := r.Encode(enc)
+	if err != nil {
+		return err
+	}
+
+	attrs := persist.KVStoreObjectAttrsToUpdate{
+		ContentEncoding: encodingToStr(enc),
+		Metadata:        make(map[string]string),
+	}
+
+	return cr.kvstore.SetIfNotExists(referralKey(stringer(r.Account)), b, &attrs)
+}
+
+func (cr *CampaignRepository) GetReferral(ctx context.Context, acct persist.Key) (r *persist.Referral, err error) {
+
+	k := referralKey(acct)
+	b, err := cr.kvstore.Get(k)
+	if err != nil {
+		return
+	}
+
+	attr, err := cr.kvstore.Attrs(k)
+	if err != nil {
+		return
+	}
+
+	r = &persist.Referral{}
+	err = r.Decode(b, encodingFromStr(attr.ContentEncoding))
+	return
+}
+
+func (cr *CampaignRepository) set(k string, v interface {
+	Encode(persist.EncodingType) ([]byte, error)
+}) error {
+	enc := persist.JSON
+	b, err := v.Encode(enc)
+	if err != nil {
+		return err
+	}
+
+	attrs := persist.KVStoreObjectAttrsToUpdate{
+		ContentEncoding: encodingToStr(enc),
+		Metadata:        make(map[string]string),
+	}
+
+	return cr.kvstore.Set(k, b, &attrs)
+}
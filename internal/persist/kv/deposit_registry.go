@@ -0,0 +1,58 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+)
+
+type DepositRegistry struct {
+	kvstore persist.KVStore
+}
+
+func NewDepositRegistry(store persist.KVStore) *DepositRegistry {
+	return &DepositRegistry{kvstore: store}
+}
+
+func (dr *DepositRegistry) Register(ctx context.Context, r *persist.DepositRegistration) error {
+	if r == nil {
+		return fmt.Errorf("%w for deposit registration", persist.ErrCannotSaveNilValue)
+	}
+
+	enc := persist.JSON
+	b, err := r.Encode(enc)
+	if err != nil {
+		return err
+	}
+
+	attrs := persist.KVStoreObjectAttrsToUpdate{
+		ContentEncoding: encodingToStr(enc),
+		Metadata:        make(map[string]string),
+	}
+
+	return dr.kvstore.SetIfNotExists(registryKey(r.Symbol, r.TransactionHash, r.OutputIndex), b, &attrs)
+}
+
+func (dr *DepositRegistry) GetRegistration(ctx context.Context, s types.Symbol, hash string, index int) (r *persist.DepositRegistration, err error) {
+
+	k := registryKey(s, hash, index)
+	b, err := dr.kvstore.Get(k)
+	if err != nil {
+		return
+	}
+
+	attr, err := dr.kvstore.Attrs(k)
+	if err != nil {
+		return
+	}
+
+	r = &persist.DepositRegistration{}
+	err = r.Decode(b, encodingFromStr(attr.ContentEncoding))
+	return
+}
+
+func (dr *DepositRegistry) DeleteRegistration(ctx context.Context, s types.Symbol, hash string, index int) error {
+	return dr.kvstore.Delete(registryKey(s, hash, index))
+}
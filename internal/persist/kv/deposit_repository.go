@@ -0,0 +1,108 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/key"
+	"github.com/easterthebunny/spew-order/internal/persist"
+)
+
+type DepositRepository struct {
+	kvstore persist.KVStore
+	account *persist.Account
+}
+
+func NewDepositRepository(store persist.KVStore, account *persist.Account) *DepositRepository {
+	return &DepositRepository{kvstore: store, account: account}
+}
+
+func (dr *DepositRepository) SetDeposit(ctx context.Context, d *persist.Deposit) error {
+	if d == nil {
+		return fmt.Errorf("%w for deposit", persist.ErrCannotSaveNilValue)
+	}
+
+	enc := persist.JSON
+	b, err := d.Encode(enc)
+	if err != nil {
+		return err
+	}
+
+	attrs := persist.KVStoreObjectAttrsToUpdate{
+		ContentEncoding: encodingToStr(enc),
+		Metadata:        make(map[string]string),
+	}
+
+	return dr.kvstore.Set(depositKey(*dr.account, stringer(d.ID)), b, &attrs)
+}
+
+func (dr *DepositRepository) GetDeposit(ctx context.Context, k persist.Key) (d *persist.Deposit, err error) {
+
+	b, err := dr.kvstore.Get(depositKey(*dr.account, k))
+	if err != nil {
+		return
+	}
+
+	attr, err := dr.kvstore.Attrs(depositKey(*dr.account, k))
+	if err != nil {
+		return
+	}
+
+	d = &persist.Deposit{}
+	err = d.Decode(b, encodingFromStr(attr.ContentEncoding))
+
+	return
+}
+
+func (dr *DepositRepository) GetDeposits(ctx context.Context, status ...persist.DepositStatus) (list []*persist.Deposit, err error) {
+
+	q := persist.KVStoreQuery{
+		StartOffset: depositSubspace(*dr.account).Pack(key.Tuple{}).String()}
+
+	attrs, err := dr.kvstore.RangeGet(&q, 0)
+	if err != nil {
+		return
+	}
+
+	for _, attr := range attrs {
+		var b []byte
+		b, err = dr.kvstore.Get(attr.Name)
+		if err != nil {
+			return
+		}
+
+		d := &persist.Deposit{}
+		err = d.Decode(b, encodingFromStr(attr.ContentEncoding))
+		if err != nil {
+			return
+		}
+
+		if !hasDepositStatus(d, status) {
+			continue
+		}
+
+		list = append(list, d)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return time.Time(list[i].Created).Before(time.Time(list[j].Created))
+	})
+
+	return
+}
+
+func hasDepositStatus(d *persist.Deposit, status []persist.DepositStatus) bool {
+	if len(status) == 0 {
+		return true
+	}
+
+	for _, s := range status {
+		if d.Status == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,108 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/easterthebunny/spew-order/internal/persist"
+)
+
+type IdempotencyRepository struct {
+	kvstore persist.KVStore
+	account *persist.Account
+}
+
+func NewIdempotencyRepository(store persist.KVStore, account *persist.Account) *IdempotencyRepository {
+	return &IdempotencyRepository{kvstore: store, account: account}
+}
+
+func (ir *IdempotencyRepository) GetRecord(ctx context.Context, k persist.Key) (record *persist.IdempotencyRecord, err error) {
+
+	b, err := ir.kvstore.Get(idempotencyKey(*ir.account, k))
+	if err != nil {
+		return
+	}
+
+	attr, err := ir.kvstore.Attrs(idempotencyKey(*ir.account, k))
+	if err != nil {
+		return
+	}
+
+	record = &persist.IdempotencyRecord{}
+	err = record.Decode(b, encodingFromStr(attr.ContentEncoding))
+
+	return
+}
+
+func (ir *IdempotencyRepository) SetRecord(ctx context.Context, r *persist.IdempotencyRecord) error {
+	if r == nil {
+		return fmt.Errorf("%w for idempotency record", persist.ErrCannotSaveNilValue)
+	}
+
+	enc := persist.JSON
+	b, err := r.Encode(enc)
+	if err != nil {
+		return err
+	}
+
+	attrs := persist.KVStoreObjectAttrsToUpdate{
+		ContentEncoding: encodingToStr(enc),
+		Metadata:        make(map[string]string),
+	}
+
+	return ir.kvstore.Set(idempotencyKey(*ir.account, stringer(r.Key)), b, &attrs)
+}
+
+// CreateRecord writes the record if the key is free. A stored record that can be
+// replaced is overwritten with a generation precondition so that only one of several
+// concurrent writers claims the key.
+func (ir *IdempotencyRepository) CreateRecord(ctx context.Context, r *persist.IdempotencyRecord, replace func(*persist.IdempotencyRecord) bool) error {
+	if r == nil {
+		return fmt.Errorf("%w for idempotency record", persist.ErrCannotSaveNilValue)
+	}
+
+	enc := persist.JSON
+	b, err := r.Encode(enc)
+	if err != nil {
+		return err
+	}
+
+	attrs := persist.KVStoreObjectAttrsToUpdate{
+		ContentEncoding: encodingToStr(enc),
+		Metadata:        make(map[string]string),
+	}
+
+	k := idempotencyKey(*ir.account, stringer(r.Key))
+	err = ir.kvstore.SetIfNotExists(k, b, &attrs)
+	if !errors.Is(err, persist.ErrObjectExists) {
+		return err
+	}
+
+	attr, err := ir.kvstore.Attrs(k)
+	if err != nil {
+		return err
+	}
+
+	existing, err := ir.GetRecord(ctx, stringer(r.Key))
+	if err != nil {
+		return err
+	}
+
+	if !replace(existing) {
+		return persist.ErrObjectExists
+	}
+
+	// the record read is only replaced if it was not changed after the attributes
+	// were read
+	_, err = ir.kvstore.SetIfGenerationMatch(k, b, &attrs, attr.Generation)
+	if errors.Is(err, persist.ErrPreconditionFailed) {
+		return persist.ErrObjectExists
+	}
+
+	return err
+}
+
+func (ir *IdempotencyRepository) DeleteRecord(ctx context.Context, k persist.Key) error {
+	return ir.kvstore.Delete(idempotencyKey(*ir.account, k))
+}
@@ -18,6 +18,22 @@ const (
 	transactionSub
 	ledgerSub
 	addressSub
+	idempotencySub
+	auditSub
+	lockSub
+	snapshotSub
+	withdrawalSub
+	allowlistSub
+	depositSub
+	registrySub
+	reconciliationSub
+	auditRunSub
+	liabilitySub
+	liabilityProofSub
+	marketSub
+	campaignSub
+	grantSub
+	referralSub
 )
 
 var (
@@ -30,12 +46,125 @@ var _ persist.BalanceRepository = &BalanceRepository{}
 var _ persist.AuthorizationRepository = &AuthorizationRepository{}
 var _ persist.TransactionRepository = &TransactionRepository{}
 var _ persist.OrderRepository = &OrderRepository{}
+var _ persist.IdempotencyRepository = &IdempotencyRepository{}
+var _ persist.AuditRepository = &AuditRepository{}
+var _ persist.BalanceSnapshotRepository = &BalanceSnapshotRepository{}
+var _ persist.WithdrawalRepository = &WithdrawalRepository{}
+var _ persist.AllowlistRepository = &AllowlistRepository{}
+var _ persist.DepositRepository = &DepositRepository{}
+var _ persist.DepositRegistry = &DepositRegistry{}
+var _ persist.ReconciliationRepository = &ReconciliationRepository{}
+var _ persist.AuditRunRepository = &AuditRunRepository{}
+var _ persist.LiabilityRepository = &LiabilityRepository{}
+var _ persist.MarketRepository = &MarketRepository{}
+var _ persist.CampaignRepository = &CampaignRepository{}
 
 func ledgerSubspace() key.Subspace {
 	// /root/ledger
 	return gsRoot.Sub(ledgerSub)
 }
 
+func auditSubspace() key.Subspace {
+	// /root/audit
+	return gsRoot.Sub(auditSub)
+}
+
+func auditKey(e persist.AuditEntry) string {
+	// /root/audit/{timestamp}/{id}
+	return auditSubspace().Pack(key.Tuple{e.Timestamp.Value(), e.ID}).String()
+}
+
+func reconciliationSubspace() key.Subspace {
+	// /root/reconciliation
+	return gsRoot.Sub(reconciliationSub)
+}
+
+func reconciliationKey(r persist.Reconciliation) string {
+	// /root/reconciliation/{timestamp}/{id}
+	return reconciliationSubspace().Pack(key.Tuple{r.Timestamp.Value(), r.ID}).String()
+}
+
+func auditRunSubspace() key.Subspace {
+	// /root/auditrun
+	return gsRoot.Sub(auditRunSub)
+}
+
+func auditRunKey(id persist.Key) string {
+	// /root/auditrun/{runid}
+	return auditRunSubspace().Pack(key.Tuple{id.String()}).String()
+}
+
+func liabilitySubspace() key.Subspace {
+	// /root/liability
+	return gsRoot.Sub(liabilitySub)
+}
+
+func liabilityKey(t persist.LiabilityTree) string {
+	// /root/liability/{treeid}
+	return liabilitySubspace().Pack(key.Tuple{t.ID}).String()
+}
+
+func liabilityProofKey(tree, acct persist.Key) string {
+	// /root/liabilityproof/{treeid}/{accountid}
+	return gsRoot.Sub(liabilityProofSub).
+		Sub(tree.String()).
+		Pack(key.Tuple{acct.String()}).String()
+}
+
+func marketSubspace(base, target types.Symbol) key.Subspace {
+	// /root/market/{base}/{target}
+	return gsRoot.Sub(marketSub).
+		Sub(base.String()).
+		Sub(target.String())
+}
+
+func lastTradeKey(base, target types.Symbol) string {
+	// /root/market/{base}/{target}/latest
+	return marketSubspace(base, target).Pack(key.Tuple{"latest"}).String()
+}
+
+func closingTradeSubspace(base, target types.Symbol) key.Subspace {
+	// /root/market/{base}/{target}/close
+	return marketSubspace(base, target).Sub("close")
+}
+
+func closingTradeKey(t persist.Trade) string {
+	// /root/market/{base}/{target}/close/{date}
+	return closingTradeSubspace(t.Base, t.Target).Pack(key.Tuple{t.Date()}).String()
+}
+
+func campaignSubspace() key.Subspace {
+	// /root/campaign
+	return gsRoot.Sub(campaignSub)
+}
+
+func campaignKey(id persist.Key) string {
+	// /root/campaign/{campaignid}
+	return campaignSubspace().Pack(key.Tuple{id.String()}).String()
+}
+
+func campaignLockKey(id persist.Key) string {
+	// /root/lock/campaign/{campaignid}
+	return gsRoot.Sub(lockSub).
+		Sub(campaignSub).
+		Pack(key.Tuple{id.String()}).String()
+}
+
+func grantSubspace(campaign persist.Key) key.Subspace {
+	// /root/grant/{campaignid}
+	return gsRoot.Sub(grantSub).Sub(campaign.String())
+}
+
+func grantKey(g persist.Grant) string {
+	// /root/grant/{campaignid}/{grantid}
+	return grantSubspace(stringer(g.Campaign)).Pack(key.Tuple{g.ID}).String()
+}
+
+func referralKey(acct persist.Key) string {
+	// /root/referral/{accountid}
+	return gsRoot.Sub(referralSub).Pack(key.Tuple{acct.String()}).String()
+}
+
 func addressKey(addr string, sym types.Symbol) string {
 	// /root/addresses/{symbol}/{addr}
 	return gsRoot.Sub(accountSub).
@@ -98,6 +227,60 @@ func orderIDKey(acct persist.Account, k persist.Key) string {
 		Pack(key.Tuple{k.String()}).String()
 }
 
+func idempotencyKey(acct persist.Account, k persist.Key) string {
+	// /root/account/{accountid}/idempotency/{key}
+	return accountSubspace(&acct).
+		Sub(idempotencySub).
+		Pack(key.Tuple{k.String()}).String()
+}
+
+func snapshotSubspace(acct persist.Account) key.Subspace {
+	// /root/account/{accountid}/snapshot
+	return accountSubspace(&acct).Sub(snapshotSub)
+}
+
+func snapshotKey(acct persist.Account, date string) string {
+	// /root/account/{accountid}/snapshot/{date}
+	return snapshotSubspace(acct).Pack(key.Tuple{date}).String()
+}
+
+func withdrawalSubspace(acct persist.Account) key.Subspace {
+	// /root/account/{accountid}/withdrawal
+	return accountSubspace(&acct).Sub(withdrawalSub)
+}
+
+func withdrawalKey(acct persist.Account, id persist.Key) string {
+	// /root/account/{accountid}/withdrawal/{withdrawalid}
+	return withdrawalSubspace(acct).Pack(key.Tuple{id.String()}).String()
+}
+
+func allowlistSubspace(acct persist.Account) key.Subspace {
+	// /root/account/{accountid}/allowlist
+	return accountSubspace(&acct).Sub(allowlistSub)
+}
+
+func allowlistKey(acct persist.Account, id persist.Key) string {
+	// /root/account/{accountid}/allowlist/{entryid}
+	return allowlistSubspace(acct).Pack(key.Tuple{id.String()}).String()
+}
+
+func depositSubspace(acct persist.Account) key.Subspace {
+	// /root/account/{accountid}/deposit
+	return accountSubspace(&acct).Sub(depositSub)
+}
+
+func depositKey(acct persist.Account, id persist.Key) string {
+	// /root/account/{accountid}/deposit/{depositid}
+	return depositSubspace(acct).Pack(key.Tuple{id.String()}).String()
+}
+
+func registryKey(s types.Symbol, hash string, index int) string {
+	// /root/registry/{symbol}/{hash}/{index}
+	return gsRoot.Sub(registrySub).
+		Sub(s.String()).
+		Pack(key.Tuple{hash, index}).String()
+}
+
 func balanceKey(acct persist.Account, sym types.Symbol) string {
 	// /root/account/{accountid}/symbol/{symbol}/balance
 	return accountSubspace(&acct).
@@ -106,6 +289,14 @@ func balanceKey(acct persist.Account, sym types.Symbol) string {
 		Pack(key.Tuple{balanceSub}).String()
 }
 
+func holdLockKey(acct persist.Account, sym types.Symbol) string {
+	// /root/account/{accountid}/symbol/{symbol}/lock
+	return accountSubspace(&acct).
+		Sub(symbolsSub).
+		Sub(sym.String()).
+		Pack(key.Tuple{lockSub}).String()
+}
+
 func holdSubspace(acct persist.Account, sym types.Symbol) key.Subspace {
 	// /root/account/{accountid}/symbol/{symbol}/hold
 	return accountSubspace(&acct).
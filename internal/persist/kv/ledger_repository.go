@@ -1,8 +1,10 @@
 package kv
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/easterthebunny/spew-order/internal/key"
@@ -45,6 +47,28 @@ func (r *LedgerRepository) RecordDeposit(ctx context.Context, s types.Symbol, am
 	return r.record(entry, key2)
 }
 
+// RecordDepositCredit makes the same entries as RecordDeposit at the registration time.
+// Keys are derived from that time so a repeated write replaces the entries.
+func (r *LedgerRepository) RecordDepositCredit(ctx context.Context, reg *persist.DepositRegistration, amt decimal.Decimal) error {
+	entries := []*persist.LedgerEntry{
+		{Account: persist.Transfers, Entry: persist.Debit},
+		{Account: persist.TransfersPayable, Entry: persist.Credit},
+	}
+
+	for _, entry := range entries {
+		entry.Symbol = reg.Symbol
+		entry.Amount = amt
+		entry.Timestamp = reg.Timestamp
+
+		err := r.record(entry, r.ledgerAccountSubspace(entry.Account).Sub(int(entry.Entry)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (r *LedgerRepository) RecordTransfer(ctx context.Context, s types.Symbol, amt decimal.Decimal) error {
 
 	entry := &persist.LedgerEntry{
@@ -191,6 +215,270 @@ func (r *LedgerRepository) RecordFee(ctx context.Context, s types.Symbol, amt de
 	return r.record(entry, key4)
 }
 
+// RecordWithdrawal writes the transfer and fee entries of a settled withdrawal at the
+// time it was settled. Keys are derived from that time so a repeated write replaces
+// the entries. The fee is posted a nanosecond later so it does not replace the transfer
+// entries in the same ledger accounts.
+func (r *LedgerRepository) RecordWithdrawal(ctx context.Context, w *persist.Withdrawal) error {
+	if w.Settled == nil {
+		return fmt.Errorf("Ledger::RecordWithdrawal -- withdrawal %s is not settled", w.ID)
+	}
+
+	ts := time.Time(*w.Settled)
+	entries := []*persist.LedgerEntry{
+		{Account: persist.Transfers, Entry: persist.Credit, Amount: w.Amount, Timestamp: persist.NanoTime(ts)},
+		{Account: persist.TransfersPayable, Entry: persist.Debit, Amount: w.Amount, Timestamp: persist.NanoTime(ts)},
+	}
+
+	if w.Fee.IsPositive() {
+		fts := persist.NanoTime(ts.Add(time.Nanosecond))
+		entries = append(entries,
+			&persist.LedgerEntry{Account: persist.Transfers, Entry: persist.Credit, Amount: w.Fee, Timestamp: fts},
+			&persist.LedgerEntry{Account: persist.TransfersPayable, Entry: persist.Debit, Amount: w.Fee, Timestamp: fts},
+			&persist.LedgerEntry{Account: persist.Cash, Entry: persist.Debit, Amount: w.Fee, Timestamp: fts},
+			&persist.LedgerEntry{Account: persist.Sales, Entry: persist.Credit, Amount: w.Fee, Timestamp: fts},
+		)
+	}
+
+	for _, entry := range entries {
+		entry.Symbol = w.Symbol
+
+		err := r.record(entry, r.ledgerAccountSubspace(entry.Account).Sub(int(entry.Entry)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RecordTrade is run for each side of a settled trade. The customer liability sub-account
+// is credited with the amount received and debited with the amount paid. Each is offset in
+// the Trade Clearing account, which nets to zero once both sides of a trade are recorded.
+func (r *LedgerRepository) RecordTrade(ctx context.Context, leg persist.TradeLeg) error {
+	ts := persist.NanoTime(time.Now())
+
+	entries := []*persist.LedgerEntry{
+		{Account: persist.CustomerLiabilities, Entry: persist.Credit, Symbol: leg.AddSymbol, Amount: leg.AddAmount},
+		{Account: persist.TradeClearing, Entry: persist.Debit, Symbol: leg.AddSymbol, Amount: leg.AddAmount},
+		{Account: persist.CustomerLiabilities, Entry: persist.Debit, Symbol: leg.SubSymbol, Amount: leg.SubAmount},
+		{Account: persist.TradeClearing, Entry: persist.Credit, Symbol: leg.SubSymbol, Amount: leg.SubAmount},
+	}
+
+	for _, entry := range entries {
+		entry.SubAccount = leg.Account
+		entry.Timestamp = ts
+
+		err := r.record(entry, r.ledgerAccountSubspace(entry.Account).Sub(int(entry.Entry)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RecordInternalTransfer debits the customer liability sub-account funds are sent from and
+// credits the sub-account they are sent to. The total customer liability is unchanged.
+func (r *LedgerRepository) RecordInternalTransfer(ctx context.Context, id, from, to string, s types.Symbol, amt decimal.Decimal) error {
+	ts := persist.NanoTime(time.Now())
+
+	entries := []*persist.LedgerEntry{
+		{Account: persist.CustomerLiabilities, SubAccount: from, Entry: persist.Debit, Symbol: s, Amount: amt},
+		{Account: persist.CustomerLiabilities, SubAccount: to, Entry: persist.Credit, Symbol: s, Amount: amt},
+	}
+
+	for _, entry := range entries {
+		entry.Timestamp = ts
+
+		err := r.record(entry, r.ledgerAccountSubspace(entry.Account).Sub(int(entry.Entry)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RecordGrant debits the Promotions account with the cost of a promotional grant and
+// credits the customer liability sub-account receiving it. Keys are derived from the
+// grant time so a repeated write replaces the entries.
+func (r *LedgerRepository) RecordGrant(ctx context.Context, g *persist.Grant) error {
+	entries := []*persist.LedgerEntry{
+		{Account: persist.Promotions, Entry: persist.Debit},
+		{Account: persist.CustomerLiabilities, SubAccount: g.Account, Entry: persist.Credit},
+	}
+
+	for _, entry := range entries {
+		entry.Symbol = g.Symbol
+		entry.Amount = g.Amount
+		entry.Timestamp = g.Timestamp
+
+		err := r.record(entry, r.ledgerAccountSubspace(entry.Account).Sub(int(entry.Entry)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *LedgerRepository) GetTrialBalance(ctx context.Context) (*persist.TrialBalance, error) {
+	return r.GetTrialBalanceAt(ctx, time.Time{})
+}
+
+// GetTrialBalanceAt totals all entries posted before the provided time. A zero time
+// includes every entry.
+func (r *LedgerRepository) GetTrialBalanceAt(ctx context.Context, t time.Time) (*persist.TrialBalance, error) {
+	entries, err := r.entries(persist.LedgerQuery{To: t}, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tb := &persist.TrialBalance{}
+	for _, entry := range entries {
+		tb.Add(entry)
+	}
+
+	return tb, nil
+}
+
+func (r *LedgerRepository) GetEntries(ctx context.Context, q persist.LedgerQuery) (*persist.LedgerPage, error) {
+	after, err := q.After()
+	if err != nil {
+		return nil, err
+	}
+
+	// one entry past the page from each account is enough to know whether
+	// another page follows
+	entries, err := r.entries(q, after, q.PageSize()+1)
+	if err != nil {
+		return nil, err
+	}
+
+	return persist.PageLedgerEntries(entries, q)
+}
+
+// entries scans the ledger accounts covered by the query and returns the entries
+// that match its filters and follow the cursor, reading at most limit entries from
+// each account. A limit of 0 reads every matching entry. The symbol and timestamp
+// are read from the keys so only the entries returned are fetched.
+func (r *LedgerRepository) entries(q persist.LedgerQuery, after *persist.LedgerCursor, limit int) ([]*persist.LedgerEntry, error) {
+	var out []*persist.LedgerEntry
+
+	for _, a := range q.Accounts() {
+		sub := r.ledgerAccountSubspace(a)
+
+		prefixes := []key.Subspace{sub}
+		if q.Symbol != nil {
+			prefixes = []key.Subspace{
+				sub.Sub(int(persist.Debit)).Sub(q.Symbol.String()),
+				sub.Sub(int(persist.Credit)).Sub(q.Symbol.String()),
+			}
+		}
+
+		var found []*persist.LedgerEntry
+		encoding := make(map[string]string)
+		for _, p := range prefixes {
+			kq := persist.KVStoreQuery{
+				Prefix: p.Pack(key.Tuple{}).String(),
+			}
+
+			attr, err := r.kvstore.RangeGet(&kq, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, at := range attr {
+				entry, err := ledgerEntryFromKey(sub, at.Name)
+				if err != nil {
+					return nil, fmt.Errorf("Ledger::entries -- %w", err)
+				}
+
+				entry.Account = a
+				if q.Match(entry) && after.Includes(entry) {
+					found = append(found, entry)
+					encoding[at.Name] = at.ContentEncoding
+				}
+			}
+		}
+
+		sort.Slice(found, func(i, j int) bool {
+			if found[i].Timestamp.Value() != found[j].Timestamp.Value() {
+				return found[i].Timestamp.Value() < found[j].Timestamp.Value()
+			}
+			return found[i].ID < found[j].ID
+		})
+
+		if limit > 0 && len(found) > limit {
+			found = found[:limit]
+		}
+
+		for _, entry := range found {
+			bts, err := r.kvstore.Get(entry.ID)
+			if err != nil {
+				return nil, fmt.Errorf("Ledger::entries -- %w", err)
+			}
+
+			err = entry.Decode(bts, encodingFromStr(encoding[entry.ID]))
+			if err != nil {
+				return nil, err
+			}
+
+			entry.Account = a
+			out = append(out, entry)
+		}
+	}
+
+	return out, nil
+}
+
+// ledgerEntryFromKey reads the entry type, symbol, timestamp and sub-account packed
+// into a ledger entry key under the account subspace. The key is the entry ID.
+func ledgerEntryFromKey(sub key.Subspace, name string) (*persist.LedgerEntry, error) {
+	k, err := key.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(k, sub.Bytes()) {
+		return nil, fmt.Errorf("key %s is outside the ledger account", name)
+	}
+
+	t, err := key.Unpack(k[len(sub.Bytes()):])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(t) < 3 {
+		return nil, fmt.Errorf("key %s is not a ledger entry", name)
+	}
+
+	et, ok1 := t[0].(int64)
+	sym, ok2 := t[1].(string)
+	ts, ok3 := t[2].(int64)
+	if !ok1 || !ok2 || !ok3 {
+		return nil, fmt.Errorf("key %s is not a ledger entry", name)
+	}
+
+	entry := &persist.LedgerEntry{
+		ID:        name,
+		Entry:     persist.EntryType(et),
+		Timestamp: persist.NanoTime(time.Unix(0, ts)),
+	}
+
+	entry.Symbol, err = types.FromString(sym)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(t) > 3 {
+		entry.SubAccount, _ = t[3].(string)
+	}
+
+	return entry, nil
+}
+
 func (r *LedgerRepository) record(e *persist.LedgerEntry, keys ...key.Subspace) error {
 	enc := persist.JSON
 	b, err := e.Encode(enc)
@@ -204,6 +492,9 @@ func (r *LedgerRepository) record(e *persist.LedgerEntry, keys ...key.Subspace)
 	}
 
 	p := key.Tuple{e.Timestamp.Value()}
+	if e.SubAccount != "" {
+		p = append(p, e.SubAccount)
+	}
 
 	for _, k := range keys {
 		err = r.kvstore.Set(k.Sub(e.Symbol.String()).Pack(p).String(), b, &attrs)
@@ -239,6 +530,12 @@ func (r *LedgerRepository) ledgerAccountSubspace(a persist.LedgerAccount) key.Su
 		return r.accountTypeSubspace(persist.Liability).Sub(int(persist.TransfersPayable))
 	case persist.Transfers:
 		return r.accountTypeSubspace(persist.Asset).Sub(int(persist.Transfers))
+	case persist.CustomerLiabilities:
+		return r.accountTypeSubspace(persist.Liability).Sub(int(persist.CustomerLiabilities))
+	case persist.TradeClearing:
+		return r.accountTypeSubspace(persist.Asset).Sub(int(persist.TradeClearing))
+	case persist.Promotions:
+		return r.accountTypeSubspace(persist.Asset).Sub(int(persist.Promotions))
 	}
 
 	return ledgerSubspace()
@@ -3,6 +3,7 @@ package kv
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/easterthebunny/spew-order/internal/persist"
 	"github.com/easterthebunny/spew-order/pkg/types"
@@ -172,3 +173,105 @@ func TestIntegratedLedger(t *testing.T) {
 	assert.Equal(t, true, startBTC.Equal(liabilities[btc]))
 	assert.Equal(t, true, startETH.Equal(liabilities[eth]))
 }
+
+func TestRecordTrade(t *testing.T) {
+	s := persist.NewMockKVStore()
+	r := NewLedgerRepository(s)
+	ctx := context.Background()
+
+	legs := []persist.TradeLeg{
+		{
+			Account:   "a",
+			AddSymbol: types.SymbolBitcoin,
+			AddAmount: decimal.NewFromFloat(0.5),
+			SubSymbol: types.SymbolEthereum,
+			SubAmount: decimal.NewFromFloat(12.25),
+		},
+		{
+			Account:   "b",
+			AddSymbol: types.SymbolEthereum,
+			AddAmount: decimal.NewFromFloat(12.25),
+			SubSymbol: types.SymbolBitcoin,
+			SubAmount: decimal.NewFromFloat(0.5),
+		},
+	}
+
+	for _, leg := range legs {
+		assert.NoError(t, r.RecordTrade(ctx, leg))
+	}
+
+	// four entries for each leg
+	assert.Equal(t, 8, s.Len())
+
+	tb, err := r.GetTrialBalance(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, tb.Unbalanced(), 0)
+
+	for _, a := range []persist.LedgerAccount{persist.CustomerLiabilities, persist.TradeClearing} {
+		for sym, v := range tb.Net(a) {
+			assert.True(t, v.IsZero(), "%s should net to zero for %s", a, sym)
+		}
+	}
+
+	m, err := r.GetLiabilityBalance(ctx, persist.CustomerLiabilities)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.00000000", m[types.SymbolBitcoin].StringFixedBank(types.SymbolBitcoin.RoundingPlace()))
+}
+
+func TestGetEntries(t *testing.T) {
+	s := persist.NewMockKVStore()
+	r := NewLedgerRepository(s)
+	ctx := context.Background()
+
+	assert.NoError(t, r.RecordDeposit(ctx, types.SymbolBitcoin, decimal.NewFromFloat(0.3)))
+	assert.NoError(t, r.RecordDeposit(ctx, types.SymbolEthereum, decimal.NewFromFloat(2)))
+
+	end := time.Now()
+	assert.NoError(t, r.RecordTransfer(ctx, types.SymbolBitcoin, decimal.NewFromFloat(0.1)))
+
+	account := persist.Transfers
+	page, err := r.GetEntries(ctx, persist.LedgerQuery{Account: &account})
+	assert.NoError(t, err)
+	assert.Len(t, page.Entries, 3)
+
+	btc := types.SymbolBitcoin
+	page, err = r.GetEntries(ctx, persist.LedgerQuery{Symbol: &btc, To: end})
+	assert.NoError(t, err)
+	assert.Len(t, page.Entries, 2)
+
+	tb, err := r.GetTrialBalanceAt(ctx, end)
+	assert.NoError(t, err)
+
+	for _, l := range tb.Lines {
+		if l.Account == persist.TransfersPayable && l.Symbol == types.SymbolBitcoin {
+			assert.Equal(t, "0.30000000", l.Balance().StringFixedBank(btc.RoundingPlace()))
+		}
+	}
+
+	// transfers between customers post both legs at the same time
+	assert.NoError(t, r.RecordInternalTransfer(ctx, "t1", "a", "b", btc, decimal.NewFromFloat(0.01)))
+	assert.NoError(t, r.RecordInternalTransfer(ctx, "t2", "c", "d", btc, decimal.NewFromFloat(0.02)))
+
+	all, err := r.GetEntries(ctx, persist.LedgerQuery{Limit: 100})
+	assert.NoError(t, err)
+
+	var seen []*persist.LedgerEntry
+	q := persist.LedgerQuery{Limit: 1}
+	for {
+		page, err := r.GetEntries(ctx, q)
+		assert.NoError(t, err)
+
+		seen = append(seen, page.Entries...)
+		if page.Cursor == "" {
+			break
+		}
+		q.Cursor = page.Cursor
+	}
+
+	assert.Len(t, seen, 10)
+	assert.Equal(t, all.Entries, seen)
+	for _, e := range seen {
+		assert.NotEmpty(t, e.ID)
+		assert.True(t, e.Amount.IsPositive())
+	}
+}
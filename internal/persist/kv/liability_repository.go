@@ -0,0 +1,112 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/easterthebunny/spew-order/internal/key"
+	"github.com/easterthebunny/spew-order/internal/persist"
+)
+
+type LiabilityRepository struct {
+	kvstore persist.KVStore
+}
+
+func NewLiabilityRepository(store persist.KVStore) *LiabilityRepository {
+	return &LiabilityRepository{kvstore: store}
+}
+
+func (lr *LiabilityRepository) SetLiabilityTree(ctx context.Context, t *persist.LiabilityTree) error {
+	if t == nil {
+		return fmt.Errorf("%w for liability tree", persist.ErrCannotSaveNilValue)
+	}
+
+	enc := persist.JSON
+	b, err := t.Encode(enc)
+	if err != nil {
+		return err
+	}
+
+	attrs := persist.KVStoreObjectAttrsToUpdate{
+		ContentEncoding: encodingToStr(enc),
+		Metadata:        make(map[string]string),
+	}
+
+	return lr.kvstore.Set(liabilityKey(*t), b, &attrs)
+}
+
+func (lr *LiabilityRepository) GetLatestLiabilityTree(ctx context.Context) (*persist.LiabilityTree, error) {
+
+	q := persist.KVStoreQuery{
+		StartOffset: liabilitySubspace().Pack(key.Tuple{}).String()}
+
+	attrs, err := lr.kvstore.RangeGet(&q, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *persist.LiabilityTree
+	for _, attr := range attrs {
+		b, err := lr.kvstore.Get(attr.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		t := &persist.LiabilityTree{}
+		err = t.Decode(b, encodingFromStr(attr.ContentEncoding))
+		if err != nil {
+			return nil, err
+		}
+
+		if latest == nil || t.Timestamp.Value() > latest.Timestamp.Value() {
+			latest = t
+		}
+	}
+
+	if latest == nil {
+		return nil, persist.ErrObjectNotExist
+	}
+
+	return latest, nil
+}
+
+func (lr *LiabilityRepository) SetLiabilityProof(ctx context.Context, p *persist.LiabilityProof) error {
+	if p == nil {
+		return fmt.Errorf("%w for liability proof", persist.ErrCannotSaveNilValue)
+	}
+
+	enc := persist.JSON
+	b, err := p.Encode(enc)
+	if err != nil {
+		return err
+	}
+
+	attrs := persist.KVStoreObjectAttrsToUpdate{
+		ContentEncoding: encodingToStr(enc),
+		Metadata:        make(map[string]string),
+	}
+
+	return lr.kvstore.Set(liabilityProofKey(stringer(p.TreeID), stringer(p.Account)), b, &attrs)
+}
+
+func (lr *LiabilityRepository) GetLiabilityProof(ctx context.Context, tree persist.Key, account persist.Key) (*persist.LiabilityProof, error) {
+	k := liabilityProofKey(tree, account)
+
+	attrs, err := lr.kvstore.Attrs(k)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := lr.kvstore.Get(k)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &persist.LiabilityProof{}
+	err = p.Decode(b, encodingFromStr(attrs.ContentEncoding))
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
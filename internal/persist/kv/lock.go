@@ -0,0 +1,65 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/persist"
+)
+
+// acquireLock writes the lock object at k, waiting for an active lock to be released.
+// A lock last written more than timeout ago is assumed abandoned and is taken over with
+// a generation precondition so only one writer can replace it. The returned function
+// releases the lock only while it is still at the generation written here, so a lock
+// taken over by another writer is never removed.
+func acquireLock(ctx context.Context, store persist.KVStore, k string, timeout time.Duration) (func(), error) {
+	attrs := persist.KVStoreObjectAttrsToUpdate{
+		ContentEncoding: persist.JSONEncodingTypeName,
+		Metadata:        make(map[string]string),
+	}
+
+	val, err := json.Marshal(time.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+
+	var current int64
+	wait := time.Millisecond
+	for {
+		gen, err := store.SetIfGenerationMatch(k, val, &attrs, current)
+		if err == nil {
+			return func() { store.DeleteIfGenerationMatch(k, gen) }, nil
+		}
+
+		if !errors.Is(err, persist.ErrPreconditionFailed) {
+			return nil, err
+		}
+
+		current = 0
+		attr, err := store.Attrs(k)
+		if err != nil {
+			if errors.Is(err, persist.ErrObjectNotExist) {
+				continue
+			}
+			return nil, err
+		}
+
+		if time.Since(attr.Updated) > timeout {
+			current = attr.Generation
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("acquireLock -- %w", ctx.Err())
+		case <-time.After(wait):
+		}
+
+		if wait < 100*time.Millisecond {
+			wait *= 2
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/key"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+)
+
+type MarketRepository struct {
+	kvstore persist.KVStore
+}
+
+func NewMarketRepository(store persist.KVStore) *MarketRepository {
+	return &MarketRepository{kvstore: store}
+}
+
+func (mr *MarketRepository) SetLastTrade(ctx context.Context, t *persist.Trade) error {
+	if t == nil {
+		return fmt.Errorf("%w for trade", persist.ErrCannotSaveNilValue)
+	}
+
+	enc := persist.JSON
+	b, err := t.Encode(enc)
+	if err != nil {
+		return err
+	}
+
+	attrs := persist.KVStoreObjectAttrsToUpdate{
+		ContentEncoding: encodingToStr(enc),
+		Metadata:        make(map[string]string),
+	}
+
+	if err := mr.kvstore.Set(lastTradeKey(t.Base, t.Target), b, &attrs); err != nil {
+		return err
+	}
+
+	return mr.kvstore.Set(closingTradeKey(*t), b, &attrs)
+}
+
+func (mr *MarketRepository) GetLastTrade(ctx context.Context, base, target types.Symbol) (*persist.Trade, error) {
+
+	k := lastTradeKey(base, target)
+	attrs, err := mr.kvstore.Attrs(k)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := mr.kvstore.Get(k)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &persist.Trade{}
+	err = t.Decode(b, encodingFromStr(attrs.ContentEncoding))
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (mr *MarketRepository) GetClosingTrades(ctx context.Context, base, target types.Symbol, from, to time.Time) (trades []*persist.Trade, err error) {
+
+	start := persist.SnapshotDate(from)
+	end := persist.SnapshotDate(to)
+
+	q := persist.KVStoreQuery{
+		StartOffset: closingTradeSubspace(base, target).Pack(key.Tuple{}).String()}
+
+	attrs, err := mr.kvstore.RangeGet(&q, 0)
+	if err != nil {
+		return
+	}
+
+	for _, attr := range attrs {
+		var b []byte
+		b, err = mr.kvstore.Get(attr.Name)
+		if err != nil {
+			return
+		}
+
+		t := &persist.Trade{}
+		err = t.Decode(b, encodingFromStr(attr.ContentEncoding))
+		if err != nil {
+			return
+		}
+
+		if d := t.Date(); d < start || d > end {
+			continue
+		}
+
+		trades = append(trades, t)
+	}
+
+	sort.Slice(trades, func(i, j int) bool {
+		return trades[i].Date() < trades[j].Date()
+	})
+
+	return
+}
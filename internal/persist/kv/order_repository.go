@@ -103,3 +103,26 @@ func (or *OrderRepository) UpdateOrderStatus(ctx context.Context, k persist.Key,
 	}
 	return or.SetOrder(ctx, order)
 }
+
+func (or *OrderRepository) RejectOrder(ctx context.Context, k persist.Key, r persist.RejectReason) error {
+
+	order, err := or.GetOrder(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	order.Status = persist.StatusRejected
+	order.Reason = r
+	return or.SetOrder(ctx, order)
+}
+
+func (or *OrderRepository) FlagOrder(ctx context.Context, k persist.Key, r persist.RejectReason) error {
+
+	order, err := or.GetOrder(ctx, k)
+	if err != nil {
+		return err
+	}
+
+	order.Reason = r
+	return or.SetOrder(ctx, order)
+}
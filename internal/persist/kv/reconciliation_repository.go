@@ -0,0 +1,70 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/easterthebunny/spew-order/internal/key"
+	"github.com/easterthebunny/spew-order/internal/persist"
+)
+
+type ReconciliationRepository struct {
+	kvstore persist.KVStore
+}
+
+func NewReconciliationRepository(store persist.KVStore) *ReconciliationRepository {
+	return &ReconciliationRepository{kvstore: store}
+}
+
+func (rr *ReconciliationRepository) SetReconciliation(ctx context.Context, r *persist.Reconciliation) error {
+	if r == nil {
+		return fmt.Errorf("%w for reconciliation", persist.ErrCannotSaveNilValue)
+	}
+
+	enc := persist.JSON
+	b, err := r.Encode(enc)
+	if err != nil {
+		return err
+	}
+
+	attrs := persist.KVStoreObjectAttrsToUpdate{
+		ContentEncoding: encodingToStr(enc),
+		Metadata:        make(map[string]string),
+	}
+
+	return rr.kvstore.Set(reconciliationKey(*r), b, &attrs)
+}
+
+func (rr *ReconciliationRepository) GetReconciliations(ctx context.Context) (runs []*persist.Reconciliation, err error) {
+
+	q := persist.KVStoreQuery{
+		StartOffset: reconciliationSubspace().Pack(key.Tuple{}).String()}
+
+	attrs, err := rr.kvstore.RangeGet(&q, 0)
+	if err != nil {
+		return
+	}
+
+	for _, attr := range attrs {
+		var b []byte
+		b, err = rr.kvstore.Get(attr.Name)
+		if err != nil {
+			return
+		}
+
+		r := &persist.Reconciliation{}
+		err = r.Decode(b, encodingFromStr(attr.ContentEncoding))
+		if err != nil {
+			return
+		}
+
+		runs = append(runs, r)
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Timestamp.Value() < runs[j].Timestamp.Value()
+	})
+
+	return
+}
@@ -0,0 +1,74 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/key"
+	"github.com/easterthebunny/spew-order/internal/persist"
+)
+
+type BalanceSnapshotRepository struct {
+	kvstore persist.KVStore
+	account *persist.Account
+}
+
+func NewBalanceSnapshotRepository(store persist.KVStore, account *persist.Account) *BalanceSnapshotRepository {
+	return &BalanceSnapshotRepository{kvstore: store, account: account}
+}
+
+func (sr *BalanceSnapshotRepository) SetSnapshot(ctx context.Context, s *persist.BalanceSnapshot) error {
+	if s == nil {
+		return fmt.Errorf("%w for balance snapshot", persist.ErrCannotSaveNilValue)
+	}
+
+	enc := persist.JSON
+	b, err := s.Encode(enc)
+	if err != nil {
+		return err
+	}
+
+	attrs := persist.KVStoreObjectAttrsToUpdate{
+		ContentEncoding: encodingToStr(enc),
+		Metadata:        make(map[string]string),
+	}
+
+	return sr.kvstore.Set(snapshotKey(*sr.account, s.Date), b, &attrs)
+}
+
+func (sr *BalanceSnapshotRepository) GetSnapshots(ctx context.Context, from, to time.Time) (snapshots []*persist.BalanceSnapshot, err error) {
+
+	start := persist.SnapshotDate(from)
+	end := persist.SnapshotDate(to)
+
+	q := persist.KVStoreQuery{
+		StartOffset: snapshotSubspace(*sr.account).Pack(key.Tuple{}).String()}
+
+	attrs, err := sr.kvstore.RangeGet(&q, 0)
+	if err != nil {
+		return
+	}
+
+	for _, attr := range attrs {
+		var b []byte
+		b, err = sr.kvstore.Get(attr.Name)
+		if err != nil {
+			return
+		}
+
+		s := &persist.BalanceSnapshot{}
+		err = s.Decode(b, encodingFromStr(attr.ContentEncoding))
+		if err != nil {
+			return
+		}
+
+		if s.Date < start || s.Date > end {
+			continue
+		}
+
+		snapshots = append(snapshots, s)
+	}
+
+	return
+}
@@ -0,0 +1,151 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/key"
+	"github.com/easterthebunny/spew-order/internal/persist"
+)
+
+type WithdrawalRepository struct {
+	kvstore persist.KVStore
+	account *persist.Account
+}
+
+func NewWithdrawalRepository(store persist.KVStore, account *persist.Account) *WithdrawalRepository {
+	return &WithdrawalRepository{kvstore: store, account: account}
+}
+
+func (wr *WithdrawalRepository) SetWithdrawal(ctx context.Context, w *persist.Withdrawal) error {
+	if w == nil {
+		return fmt.Errorf("%w for withdrawal", persist.ErrCannotSaveNilValue)
+	}
+
+	enc := persist.JSON
+	b, err := w.Encode(enc)
+	if err != nil {
+		return err
+	}
+
+	attrs := persist.KVStoreObjectAttrsToUpdate{
+		ContentEncoding: encodingToStr(enc),
+		Metadata:        make(map[string]string),
+	}
+
+	return wr.kvstore.Set(withdrawalKey(*wr.account, stringer(w.ID)), b, &attrs)
+}
+
+// UpdateWithdrawal saves the withdrawal with a generation precondition on the stored
+// withdrawal read at the expected update time
+func (wr *WithdrawalRepository) UpdateWithdrawal(ctx context.Context, w *persist.Withdrawal, last persist.NanoTime) error {
+	if w == nil {
+		return fmt.Errorf("%w for withdrawal", persist.ErrCannotSaveNilValue)
+	}
+
+	k := withdrawalKey(*wr.account, stringer(w.ID))
+	attr, err := wr.kvstore.Attrs(k)
+	if err != nil {
+		return err
+	}
+
+	b, err := wr.kvstore.Get(k)
+	if err != nil {
+		return err
+	}
+
+	current := &persist.Withdrawal{}
+	err = current.Decode(b, encodingFromStr(attr.ContentEncoding))
+	if err != nil {
+		return err
+	}
+
+	if current.Updated.Value() != last.Value() {
+		return fmt.Errorf("%w: withdrawal updated at %s", persist.ErrPreconditionFailed, current.Updated)
+	}
+
+	enc := persist.JSON
+	b, err = w.Encode(enc)
+	if err != nil {
+		return err
+	}
+
+	attrs := persist.KVStoreObjectAttrsToUpdate{
+		ContentEncoding: encodingToStr(enc),
+		Metadata:        make(map[string]string),
+	}
+
+	_, err = wr.kvstore.SetIfGenerationMatch(k, b, &attrs, attr.Generation)
+	return err
+}
+
+func (wr *WithdrawalRepository) GetWithdrawal(ctx context.Context, k persist.Key) (w *persist.Withdrawal, err error) {
+
+	b, err := wr.kvstore.Get(withdrawalKey(*wr.account, k))
+	if err != nil {
+		return
+	}
+
+	attr, err := wr.kvstore.Attrs(withdrawalKey(*wr.account, k))
+	if err != nil {
+		return
+	}
+
+	w = &persist.Withdrawal{}
+	err = w.Decode(b, encodingFromStr(attr.ContentEncoding))
+
+	return
+}
+
+func (wr *WithdrawalRepository) GetWithdrawals(ctx context.Context, status ...persist.WithdrawalStatus) (list []*persist.Withdrawal, err error) {
+
+	q := persist.KVStoreQuery{
+		StartOffset: withdrawalSubspace(*wr.account).Pack(key.Tuple{}).String()}
+
+	attrs, err := wr.kvstore.RangeGet(&q, 0)
+	if err != nil {
+		return
+	}
+
+	for _, attr := range attrs {
+		var b []byte
+		b, err = wr.kvstore.Get(attr.Name)
+		if err != nil {
+			return
+		}
+
+		w := &persist.Withdrawal{}
+		err = w.Decode(b, encodingFromStr(attr.ContentEncoding))
+		if err != nil {
+			return
+		}
+
+		if !hasWithdrawalStatus(w, status) {
+			continue
+		}
+
+		list = append(list, w)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return time.Time(list[i].Created).Before(time.Time(list[j].Created))
+	})
+
+	return
+}
+
+func hasWithdrawalStatus(w *persist.Withdrawal, status []persist.WithdrawalStatus) bool {
+	if len(status) == 0 {
+		return true
+	}
+
+	for _, s := range status {
+		if w.Status == s {
+			return true
+		}
+	}
+
+	return false
+}
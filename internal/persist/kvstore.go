@@ -14,6 +14,7 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/storage"
@@ -22,13 +23,25 @@ import (
 )
 
 var ErrObjectNotExist = fmt.Errorf("object not found for key")
+var ErrObjectExists = fmt.Errorf("object already exists for key")
+var ErrPreconditionFailed = fmt.Errorf("object changed since it was read")
 
 // KVStore ...
 type KVStore interface {
 	Get(string) ([]byte, error)
 	Attrs(string) (*KVStoreObjectAttrs, error)
 	Set(string, []byte, *KVStoreObjectAttrsToUpdate) error
+	// SetIfNotExists only writes the value when no object is stored at the key and
+	// returns ErrObjectExists otherwise
+	SetIfNotExists(string, []byte, *KVStoreObjectAttrsToUpdate) error
+	// SetIfGenerationMatch only writes the value when the stored object is at the
+	// generation provided and returns the generation written. A generation of 0 requires
+	// that no object is stored. ErrPreconditionFailed is returned when the object changed.
+	SetIfGenerationMatch(string, []byte, *KVStoreObjectAttrsToUpdate, int64) (int64, error)
 	Delete(string) error
+	// DeleteIfGenerationMatch only deletes the object when it is at the generation
+	// provided and returns ErrPreconditionFailed otherwise
+	DeleteIfGenerationMatch(string, int64) error
 	RangeGet(*KVStoreQuery, int) ([]*KVStoreObjectAttrs, error)
 }
 
@@ -43,6 +56,7 @@ type KVStoreObjectAttrs struct {
 	ContentEncoding string
 	Created         time.Time
 	Updated         time.Time
+	Generation      int64
 }
 
 type KVStoreObjectAttrsToUpdate struct {
@@ -117,7 +131,8 @@ func (gs *GoogleKVStore) Attrs(sKey string) (attrs *KVStoreObjectAttrs, err erro
 		Metadata:        obj.Metadata,
 		ContentEncoding: obj.ContentEncoding,
 		Created:         obj.Created,
-		Updated:         obj.Updated}
+		Updated:         obj.Updated,
+		Generation:      obj.Generation}
 
 	return
 }
@@ -200,6 +215,87 @@ func (gs *GoogleKVStore) Set(sKey string, data []byte, attrs *KVStoreObjectAttrs
 	return nil
 }
 
+// SetIfNotExists writes the object with a precondition that it does not already exist
+func (gs *GoogleKVStore) SetIfNotExists(sKey string, data []byte, attrs *KVStoreObjectAttrsToUpdate) error {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+
+	handle := gs.client.Bucket(gs.bucket).Object(sKey)
+
+	wc := handle.If(storage.Conditions{DoesNotExist: true}).NewWriter(ctx)
+	if attrs != nil {
+		wc.ContentEncoding = attrs.ContentEncoding
+		wc.Metadata = attrs.Metadata
+	}
+
+	if _, err := io.Copy(wc, bytes.NewReader(data)); err != nil {
+		wc.Close()
+		return err
+	}
+
+	if err := wc.Close(); err != nil {
+		if e, ok := err.(*googleapi.Error); ok && e.Code == http.StatusPreconditionFailed {
+			return ErrObjectExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+// SetIfGenerationMatch writes the object with a precondition on the stored generation
+func (gs *GoogleKVStore) SetIfGenerationMatch(sKey string, data []byte, attrs *KVStoreObjectAttrsToUpdate, generation int64) (int64, error) {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+
+	cond := storage.Conditions{GenerationMatch: generation}
+	if generation == 0 {
+		cond = storage.Conditions{DoesNotExist: true}
+	}
+
+	wc := gs.client.Bucket(gs.bucket).Object(sKey).If(cond).NewWriter(ctx)
+	if attrs != nil {
+		wc.ContentEncoding = attrs.ContentEncoding
+		wc.Metadata = attrs.Metadata
+	}
+
+	if _, err := io.Copy(wc, bytes.NewReader(data)); err != nil {
+		wc.Close()
+		return 0, err
+	}
+
+	if err := wc.Close(); err != nil {
+		if e, ok := err.(*googleapi.Error); ok && e.Code == http.StatusPreconditionFailed {
+			return 0, ErrPreconditionFailed
+		}
+		return 0, err
+	}
+
+	return wc.Attrs().Generation, nil
+}
+
+// DeleteIfGenerationMatch deletes the object with a precondition on the stored generation
+func (gs *GoogleKVStore) DeleteIfGenerationMatch(sKey string, generation int64) error {
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+
+	err := gs.client.Bucket(gs.bucket).Object(sKey).If(storage.Conditions{GenerationMatch: generation}).Delete(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return ErrObjectNotExist
+		}
+		if e, ok := err.(*googleapi.Error); ok && e.Code == http.StatusPreconditionFailed {
+			return ErrPreconditionFailed
+		}
+		return err
+	}
+
+	return nil
+}
+
 // RangeGet returns a set of size `limit`. Set `limit` to 0 for no limit.
 func (gs *GoogleKVStore) RangeGet(q *KVStoreQuery, limit int) ([]*KVStoreObjectAttrs, error) {
 	// bucket := "bucket-name"
@@ -211,6 +307,7 @@ func (gs *GoogleKVStore) RangeGet(q *KVStoreQuery, limit int) ([]*KVStoreObjectA
 	var qu *storage.Query
 	if q != nil {
 		qu = &storage.Query{
+			Prefix:      q.Prefix,
 			StartOffset: q.StartOffset}
 	}
 	qu.SetAttrSelection([]string{"Name", "MetaData", "Created", "ContentEncoding"})
@@ -234,7 +331,8 @@ func (gs *GoogleKVStore) RangeGet(q *KVStoreQuery, limit int) ([]*KVStoreObjectA
 				Metadata:        attrs.Metadata,
 				ContentEncoding: attrs.ContentEncoding,
 				Created:         attrs.Created,
-				Updated:         attrs.Updated}
+				Updated:         attrs.Updated,
+				Generation:      attrs.Generation}
 		}
 
 		attr = append(attr, a)
@@ -313,14 +411,19 @@ func NewMockKVStore() *MockKVStore {
 
 // MockKVStore ...
 type MockKVStore struct {
+	mu       sync.Mutex
 	key      []string
 	data     map[string][]byte
 	meta     map[string]*KVStoreObjectAttrs
+	gen      int64
 	logLevel int
 }
 
 // Get ...
 func (gsm *MockKVStore) Get(key string) ([]byte, error) {
+	gsm.mu.Lock()
+	defer gsm.mu.Unlock()
+
 	if gsm.logLevel > 0 {
 		log.Printf("GET %s", key)
 	}
@@ -331,18 +434,65 @@ func (gsm *MockKVStore) Get(key string) ([]byte, error) {
 }
 
 func (gsm *MockKVStore) Attrs(key string) (a *KVStoreObjectAttrs, err error) {
+	gsm.mu.Lock()
+	defer gsm.mu.Unlock()
+
 	if gsm.logLevel > 0 {
 		log.Printf("ATTRS %s", key)
 	}
-	a, ok := gsm.meta[key]
+	m, ok := gsm.meta[key]
 	if !ok {
 		err = ErrObjectNotExist
+		return
 	}
-	return
+
+	// return a copy so later writes do not change attributes already read
+	cp := *m
+	return &cp, nil
 }
 
 // Set ...
 func (gsm *MockKVStore) Set(key string, b []byte, attrs *KVStoreObjectAttrsToUpdate) error {
+	gsm.mu.Lock()
+	defer gsm.mu.Unlock()
+
+	return gsm.set(key, b, attrs)
+}
+
+// SetIfNotExists ...
+func (gsm *MockKVStore) SetIfNotExists(key string, b []byte, attrs *KVStoreObjectAttrsToUpdate) error {
+	gsm.mu.Lock()
+	defer gsm.mu.Unlock()
+
+	if _, ok := gsm.data[key]; ok {
+		return ErrObjectExists
+	}
+
+	return gsm.set(key, b, attrs)
+}
+
+// SetIfGenerationMatch ...
+func (gsm *MockKVStore) SetIfGenerationMatch(key string, b []byte, attrs *KVStoreObjectAttrsToUpdate, generation int64) (int64, error) {
+	gsm.mu.Lock()
+	defer gsm.mu.Unlock()
+
+	var current int64
+	if m, ok := gsm.meta[key]; ok {
+		current = m.Generation
+	}
+
+	if current != generation {
+		return 0, ErrPreconditionFailed
+	}
+
+	if err := gsm.set(key, b, attrs); err != nil {
+		return 0, err
+	}
+
+	return gsm.meta[key].Generation, nil
+}
+
+func (gsm *MockKVStore) set(key string, b []byte, attrs *KVStoreObjectAttrsToUpdate) error {
 	if gsm.logLevel > 0 {
 		log.Printf("SET %s", key)
 	}
@@ -362,11 +512,15 @@ func (gsm *MockKVStore) Set(key string, b []byte, attrs *KVStoreObjectAttrsToUpd
 			sAttrs.Metadata = attrs.Metadata
 			sAttrs.Created = m.Created
 		}
+		sAttrs.Updated = time.Now()
 	} else {
 		gsm.key = append(gsm.key, key)
 		sort.Strings(gsm.key)
 	}
 
+	gsm.gen++
+	sAttrs.Generation = gsm.gen
+
 	gsm.data[key] = b
 	gsm.meta[key] = sAttrs
 	return nil
@@ -374,6 +528,32 @@ func (gsm *MockKVStore) Set(key string, b []byte, attrs *KVStoreObjectAttrsToUpd
 
 // Delete ...
 func (gsm *MockKVStore) Delete(key string) error {
+	gsm.mu.Lock()
+	defer gsm.mu.Unlock()
+
+	gsm.delete(key)
+	return nil
+}
+
+// DeleteIfGenerationMatch ...
+func (gsm *MockKVStore) DeleteIfGenerationMatch(key string, generation int64) error {
+	gsm.mu.Lock()
+	defer gsm.mu.Unlock()
+
+	m, ok := gsm.meta[key]
+	if !ok {
+		return ErrObjectNotExist
+	}
+
+	if m.Generation != generation {
+		return ErrPreconditionFailed
+	}
+
+	gsm.delete(key)
+	return nil
+}
+
+func (gsm *MockKVStore) delete(key string) {
 	if gsm.logLevel > 0 {
 		log.Printf("DELETE %s", key)
 	}
@@ -385,11 +565,13 @@ func (gsm *MockKVStore) Delete(key string) error {
 	}
 	delete(gsm.data, key)
 	delete(gsm.meta, key)
-	return nil
 }
 
 // RangeGet ...
 func (gsm *MockKVStore) RangeGet(q *KVStoreQuery, limit int) (attrs []*KVStoreObjectAttrs, err error) {
+	gsm.mu.Lock()
+	defer gsm.mu.Unlock()
+
 	var cnt int
 	var qry string
 
@@ -419,5 +601,8 @@ func (gsm *MockKVStore) RangeGet(q *KVStoreQuery, limit int) (attrs []*KVStoreOb
 
 // Len ...
 func (gsm *MockKVStore) Len() int {
+	gsm.mu.Lock()
+	defer gsm.mu.Unlock()
+
 	return len(gsm.key)
 }
@@ -0,0 +1,183 @@
+package persist
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid ledger cursor")
+)
+
+// DefaultLedgerPageSize is the number of entries returned by a ledger query when
+// no limit is provided
+var DefaultLedgerPageSize = 100
+
+// LedgerQuery filters entries in the main ledger. Nil or zero values match all entries.
+type LedgerQuery struct {
+	Account *LedgerAccount
+	Symbol  *types.Symbol
+	// From is the inclusive start of the time range
+	From time.Time
+	// To is the exclusive end of the time range
+	To time.Time
+	// Cursor continues a query from the end of a previous page
+	Cursor string
+	Limit  int
+}
+
+// Accounts returns the ledger accounts covered by the query
+func (q LedgerQuery) Accounts() []LedgerAccount {
+	if q.Account != nil {
+		return []LedgerAccount{*q.Account}
+	}
+	return LedgerAccounts
+}
+
+// Match reports whether the entry falls inside the query filters, ignoring the cursor
+func (q LedgerQuery) Match(e *LedgerEntry) bool {
+	if q.Account != nil && e.Account != *q.Account {
+		return false
+	}
+
+	if q.Symbol != nil && e.Symbol != *q.Symbol {
+		return false
+	}
+
+	ts := time.Time(e.Timestamp)
+	if !q.From.IsZero() && ts.Before(q.From) {
+		return false
+	}
+
+	if !q.To.IsZero() && !ts.Before(q.To) {
+		return false
+	}
+
+	return true
+}
+
+// PageSize returns the query limit, or the default page size when no limit is set
+func (q LedgerQuery) PageSize() int {
+	if q.Limit <= 0 {
+		return DefaultLedgerPageSize
+	}
+	return q.Limit
+}
+
+// After decodes the query cursor. A nil cursor is returned when the query starts
+// from the first entry.
+func (q LedgerQuery) After() (*LedgerCursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+
+	parts := strings.SplitN(string(b), "|", 3)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed position", ErrInvalidCursor)
+	}
+
+	ts, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+
+	a, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+
+	return &LedgerCursor{
+		Timestamp: ts,
+		Account:   LedgerAccount(a),
+		ID:        parts[2],
+		key:       string(b),
+	}, nil
+}
+
+// LedgerCursor is the position of the last entry on a page of ledger entries.
+// Entries are ordered by timestamp, then ledger account, then entry ID.
+type LedgerCursor struct {
+	Timestamp int64
+	Account   LedgerAccount
+	ID        string
+	key       string
+}
+
+// Includes reports whether the entry sorts after the cursor position
+func (c *LedgerCursor) Includes(e *LedgerEntry) bool {
+	return c == nil || e.sortKey() > c.key
+}
+
+// LedgerPage is a single page of ledger entries ordered by time. Cursor is empty
+// when there are no more entries.
+type LedgerPage struct {
+	Entries []*LedgerEntry
+	Cursor  string
+}
+
+// PageLedgerEntries sorts the entries matching the query by time and returns the
+// page following the query cursor
+func PageLedgerEntries(entries []*LedgerEntry, q LedgerQuery) (*LedgerPage, error) {
+	after, err := q.After()
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []*LedgerEntry{}
+	for _, e := range entries {
+		if q.Match(e) && after.Includes(e) {
+			matched = append(matched, e)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].sortKey() < matched[j].sortKey()
+	})
+
+	limit := q.PageSize()
+	page := &LedgerPage{Entries: matched}
+	if len(matched) > limit {
+		page.Entries = matched[:limit]
+		page.Cursor = base64.RawURLEncoding.EncodeToString([]byte(page.Entries[limit-1].sortKey()))
+	}
+
+	return page, nil
+}
+
+// sortKey orders entries by time, breaking ties by ledger account and then by the
+// entry ID so entries posted at the same time are never skipped between pages
+func (e *LedgerEntry) sortKey() string {
+	return fmt.Sprintf("%020d|%02d|%s", e.Timestamp.Value(), e.Account, e.ID)
+}
+
+// Type returns whether the ledger account is an asset or liability account
+func (a LedgerAccount) Type() AccountType {
+	switch a {
+	case Cash, Transfers, TradeClearing, Promotions:
+		return Asset
+	default:
+		return Liability
+	}
+}
+
+// Balance returns the balance of the line on the normal side of the account;
+// debits for asset accounts and credits for liability accounts
+func (l TrialBalanceLine) Balance() decimal.Decimal {
+	if l.Account.Type() == Asset {
+		return l.Debits.Sub(l.Credits)
+	}
+	return l.Credits.Sub(l.Debits)
+}
@@ -0,0 +1,108 @@
+package persist
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPageLedgerEntries(t *testing.T) {
+	entries := []*LedgerEntry{}
+	for i := 0; i < 5; i++ {
+		for _, s := range []types.Symbol{types.SymbolBitcoin, types.SymbolEthereum} {
+			entries = append(entries, &LedgerEntry{
+				ID:        fmt.Sprintf("%d-%s", i, s),
+				Account:   Cash,
+				Entry:     Debit,
+				Symbol:    s,
+				Amount:    decimal.NewFromInt(int64(i)),
+				Timestamp: NanoTime(time.Unix(int64(5-i), 0)),
+			})
+		}
+	}
+
+	t.Run("Cursor", func(t *testing.T) {
+		var seen []*LedgerEntry
+		q := LedgerQuery{Limit: 3}
+		for {
+			page, err := PageLedgerEntries(entries, q)
+			assert.NoError(t, err)
+
+			seen = append(seen, page.Entries...)
+			if page.Cursor == "" {
+				break
+			}
+			q.Cursor = page.Cursor
+		}
+
+		assert.Len(t, seen, len(entries))
+		for i := 1; i < len(seen); i++ {
+			assert.False(t, seen[i].Timestamp.Value() < seen[i-1].Timestamp.Value(), "entries should be ordered by time")
+		}
+	})
+
+	t.Run("SameTimestamp", func(t *testing.T) {
+		ts := NanoTime(time.Unix(1, 0))
+		same := []*LedgerEntry{}
+		for i := 0; i < 4; i++ {
+			same = append(same, &LedgerEntry{
+				ID:        fmt.Sprintf("entry-%d", i),
+				Account:   Cash,
+				Entry:     Debit,
+				Symbol:    types.SymbolBitcoin,
+				Amount:    decimal.NewFromInt(1),
+				Timestamp: ts,
+			})
+		}
+
+		var seen []*LedgerEntry
+		q := LedgerQuery{Limit: 1}
+		for {
+			page, err := PageLedgerEntries(same, q)
+			assert.NoError(t, err)
+
+			seen = append(seen, page.Entries...)
+			if page.Cursor == "" {
+				break
+			}
+			q.Cursor = page.Cursor
+		}
+
+		assert.ElementsMatch(t, same, seen)
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		btc := types.SymbolBitcoin
+		q := LedgerQuery{
+			Symbol: &btc,
+			From:   time.Unix(2, 0),
+			To:     time.Unix(4, 0),
+		}
+
+		page, err := PageLedgerEntries(entries, q)
+		assert.NoError(t, err)
+		assert.Len(t, page.Entries, 2)
+		assert.Equal(t, "", page.Cursor)
+
+		for _, e := range page.Entries {
+			assert.Equal(t, btc, e.Symbol)
+		}
+	})
+
+	t.Run("InvalidCursor", func(t *testing.T) {
+		_, err := PageLedgerEntries(entries, LedgerQuery{Cursor: "%%%"})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+}
+
+func TestTrialBalanceLineBalance(t *testing.T) {
+	l := TrialBalanceLine{Account: Cash, Debits: decimal.NewFromInt(5), Credits: decimal.NewFromInt(2)}
+	assert.Equal(t, "3", l.Balance().String())
+
+	l.Account = TransfersPayable
+	assert.Equal(t, "-3", l.Balance().String())
+}
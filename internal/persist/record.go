@@ -8,17 +8,22 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"time"
 
+	"github.com/easterthebunny/spew-order/internal/merkle"
 	"github.com/easterthebunny/spew-order/pkg/types"
 	uuid "github.com/satori/go.uuid"
 	"github.com/shopspring/decimal"
 )
 
 var (
-	ErrCannotSaveNilValue = errors.New("cannot save nil value")
-	ErrCannotParseValue   = errors.New("datastore collection parse error")
+	ErrCannotSaveNilValue  = errors.New("cannot save nil value")
+	ErrCannotParseValue    = errors.New("datastore collection parse error")
+	ErrInsufficientBalance = errors.New("available balance too low for hold")
+	ErrCampaignBudget      = errors.New("campaign budget too low for grant")
+	ErrGrantLimit          = errors.New("account grant limit reached for campaign")
 )
 
 type Key interface {
@@ -44,12 +49,30 @@ type AccountRepository interface {
 	Balances(*Account, types.Symbol) BalanceRepository
 	Transactions(*Account) TransactionRepository
 	Orders(*Account) OrderRepository
+	Idempotency(*Account) IdempotencyRepository
+	Snapshots(*Account) BalanceSnapshotRepository
+	Withdrawals(*Account) WithdrawalRepository
+	Allowlist(*Account) AllowlistRepository
+	Deposits(*Account) DepositRepository
+	DepositRegistry() DepositRegistry
+	Campaigns() CampaignRepository
 }
 
 // Account represents the entity object persisted to storage
 type Account struct {
-	ID        string           `json:"id"`
-	Addresses []FundingAddress `json:"addresses"`
+	ID       string `json:"id"`
+	Label    string `json:"label,omitempty"`
+	Archived bool   `json:"archived,omitempty"`
+	Tier     string `json:"tier,omitempty"`
+	// Allowlist limits withdrawals to addresses on the account allowlist
+	Allowlist bool `json:"allowlist,omitempty"`
+	// AllowlistDisableAt is when a request to turn off the allowlist takes effect
+	AllowlistDisableAt *NanoTime `json:"allowlistDisableAt,omitempty"`
+	// Owner is the id of the authorization holding the account
+	Owner string `json:"owner,omitempty"`
+	// FirstTrade is the id of the first order on the account to trade
+	FirstTrade string           `json:"firstTrade,omitempty"`
+	Addresses  []FundingAddress `json:"addresses"`
 }
 
 type FundingAddress struct {
@@ -72,10 +95,20 @@ type BalanceRepository interface {
 	AddToBalance(context.Context, decimal.Decimal) error
 	FindHolds(context.Context) ([]*BalanceItem, error)
 	CreateHold(context.Context, *BalanceItem) error
+	// CreateHoldIfAvailable creates the hold only if the posted balance less all
+	// existing holds covers the hold amount. The check and the write are atomic.
+	CreateHoldIfAvailable(context.Context, *BalanceItem) error
 	DeleteHold(context.Context, Key) error
 	UpdateHold(context.Context, Key, decimal.Decimal) error
 }
 
+// PostCompactor rolls balance posts up into stored balances outside of the request path
+type PostCompactor interface {
+	// CompactPosts folds a batch of posts into their balances and returns the number
+	// of posts removed
+	CompactPosts(context.Context) (int, error)
+}
+
 type BalanceItem struct {
 	ID        string          `json:"id"`
 	Timestamp NanoTime        `json:"timestamp"`
@@ -183,12 +216,16 @@ type OrderRepository interface {
 	SetOrder(context.Context, *Order) error
 	GetOrdersByStatus(context.Context, ...FillStatus) ([]*Order, error)
 	UpdateOrderStatus(context.Context, Key, FillStatus, []string) error
+	RejectOrder(context.Context, Key, RejectReason) error
+	// FlagOrder records the reason on the order without changing its status
+	FlagOrder(context.Context, Key, RejectReason) error
 }
 
 type Order struct {
-	Status       FillStatus  `json:"status"`
-	Transactions [][]string  `json:"transactions"`
-	Base         types.Order `json:"base"`
+	Status       FillStatus   `json:"status"`
+	Reason       RejectReason `json:"reason,omitempty"`
+	Transactions [][]string   `json:"transactions"`
+	Base         types.Order  `json:"base"`
 }
 
 func (o Order) Encode(enc EncodingType) ([]byte, error) {
@@ -216,6 +253,9 @@ const (
 	OrderTransactionType    = "order"
 	DepositTransactionType  = "deposit"
 	TransferTransactionType = "transfer"
+	InternalTransactionType = "internal"
+	FeeTransactionType      = "fee"
+	GrantTransactionType    = "grant"
 )
 
 type TransactionRepository interface {
@@ -231,6 +271,821 @@ func (t *Transaction) Decode(b []byte, enc EncodingType) error {
 	return decode(b, enc, t)
 }
 
+// IdempotencyRepository stores client supplied request keys for an account
+type IdempotencyRepository interface {
+	GetRecord(context.Context, Key) (*IdempotencyRecord, error)
+	SetRecord(context.Context, *IdempotencyRecord) error
+	// CreateRecord stores the record only when no record is stored for the key or
+	// the stored record is one the replace func allows to be overwritten. The check
+	// and write are atomic and ErrObjectExists is returned when the key is taken.
+	CreateRecord(context.Context, *IdempotencyRecord, func(*IdempotencyRecord) bool) error
+	DeleteRecord(context.Context, Key) error
+}
+
+type IdempotencyType string
+
+const (
+	OrderIdempotencyType      = "order"
+	WithdrawalIdempotencyType = "withdrawal"
+	InternalIdempotencyType   = "internal"
+)
+
+// IdempotencyRecord links a client supplied request key to the object created by
+// the first request made with it. An empty Reference indicates that the original
+// request has not yet completed.
+type IdempotencyRecord struct {
+	Key       string          `json:"key"`
+	Type      IdempotencyType `json:"type"`
+	Reference string          `json:"reference"`
+	Timestamp NanoTime        `json:"timestamp"`
+}
+
+func (r IdempotencyRecord) Encode(enc EncodingType) ([]byte, error) {
+	return encode(enc, r)
+}
+
+func (r *IdempotencyRecord) Decode(b []byte, enc EncodingType) error {
+	return decode(b, enc, r)
+}
+
+// BalanceSnapshotRepository stores a daily record of account balances
+type BalanceSnapshotRepository interface {
+	SetSnapshot(context.Context, *BalanceSnapshot) error
+	// GetSnapshots returns the snapshots for the days from and to, inclusive, in date order
+	GetSnapshots(ctx context.Context, from, to time.Time) ([]*BalanceSnapshot, error)
+}
+
+// SnapshotDateFormat is the layout of the day a balance snapshot is taken for
+const SnapshotDateFormat = "2006-01-02"
+
+// BalanceSnapshot is the posted and available balance of every symbol on an
+// account for a single day. Only one snapshot is kept for each day.
+type BalanceSnapshot struct {
+	Date      string            `json:"date"`
+	Timestamp NanoTime          `json:"timestamp"`
+	Balances  []SnapshotBalance `json:"balances"`
+}
+
+type SnapshotBalance struct {
+	Symbol    types.Symbol    `json:"symbol"`
+	Posted    decimal.Decimal `json:"posted"`
+	Available decimal.Decimal `json:"available"`
+}
+
+// NewBalanceSnapshot returns an empty snapshot for the UTC day of t
+func NewBalanceSnapshot(t time.Time) *BalanceSnapshot {
+	return &BalanceSnapshot{
+		Date:      SnapshotDate(t),
+		Timestamp: NanoTime(time.Now()),
+	}
+}
+
+// SnapshotDate formats the UTC day of t as a snapshot date
+func SnapshotDate(t time.Time) string {
+	return t.UTC().Format(SnapshotDateFormat)
+}
+
+func (s BalanceSnapshot) Encode(enc EncodingType) ([]byte, error) {
+	return encode(enc, s)
+}
+
+func (s *BalanceSnapshot) Decode(b []byte, enc EncodingType) error {
+	return decode(b, enc, s)
+}
+
+// WithdrawalRepository stores withdrawal requests for an account
+type WithdrawalRepository interface {
+	SetWithdrawal(context.Context, *Withdrawal) error
+	// UpdateWithdrawal saves the withdrawal only while the stored withdrawal was last
+	// updated at the provided time. ErrPreconditionFailed is returned when it changed.
+	UpdateWithdrawal(context.Context, *Withdrawal, NanoTime) error
+	GetWithdrawal(context.Context, Key) (*Withdrawal, error)
+	// GetWithdrawals returns withdrawals for the account, oldest first. When one or more
+	// statuses are provided only withdrawals in those statuses are returned.
+	GetWithdrawals(context.Context, ...WithdrawalStatus) ([]*Withdrawal, error)
+}
+
+type WithdrawalStatus string
+
+const (
+	// WithdrawalRequested is a withdrawal with funds on hold waiting for approval
+	WithdrawalRequested WithdrawalStatus = "requested"
+	// WithdrawalReview is a withdrawal over a withdrawal limit waiting for manual review
+	WithdrawalReview WithdrawalStatus = "review"
+	// WithdrawalApproved is a withdrawal ready to be sent to the funding source
+	WithdrawalApproved WithdrawalStatus = "approved"
+	// WithdrawalBroadcasting is a withdrawal being sent to the funding source. A
+	// withdrawal left in this status may or may not have been sent.
+	WithdrawalBroadcasting WithdrawalStatus = "broadcasting"
+	// WithdrawalBroadcast is a withdrawal accepted by the funding source and waiting
+	// for confirmation
+	WithdrawalBroadcast WithdrawalStatus = "broadcast"
+	// WithdrawalConfirmed is a completed withdrawal; funds are debited from the account
+	WithdrawalConfirmed WithdrawalStatus = "confirmed"
+	// WithdrawalFailed is a withdrawal that could not be sent and waits to be refunded
+	WithdrawalFailed WithdrawalStatus = "failed"
+	// WithdrawalRefunded is a failed withdrawal with funds returned to the account
+	WithdrawalRefunded WithdrawalStatus = "refunded"
+)
+
+// Final reports whether the status is the end of the withdrawal workflow
+func (s WithdrawalStatus) Final() bool {
+	return s == WithdrawalConfirmed || s == WithdrawalRefunded
+}
+
+// WithdrawalSettlement is the progress of posting a confirmed withdrawal to the account
+// and the ledgers. Each step is saved as it completes so an interrupted confirmation
+// resumes without posting anything twice.
+type WithdrawalSettlement string
+
+const (
+	// WithdrawalDebiting is a withdrawal being debited from the account. A withdrawal
+	// left in this step may or may not have been debited.
+	WithdrawalDebiting WithdrawalSettlement = "debiting"
+	// WithdrawalDebited is a withdrawal debited from the account with transaction
+	// records and ledger entries still to be written
+	WithdrawalDebited WithdrawalSettlement = "debited"
+	// WithdrawalRecorded is a withdrawal with every record written
+	WithdrawalRecorded WithdrawalSettlement = "recorded"
+)
+
+// Withdrawal tracks a request to send funds from an account to an external address
+// through each stage of processing
+type Withdrawal struct {
+	ID              string           `json:"id"`
+	Account         string           `json:"account"`
+	Symbol          types.Symbol     `json:"symbol"`
+	Amount          decimal.Decimal  `json:"amount"`
+	Fee             decimal.Decimal  `json:"fee"`
+	Address         string           `json:"address"`
+	HoldID          string           `json:"holdId"`
+	Status          WithdrawalStatus `json:"status"`
+	TransactionHash string           `json:"transactionHash,omitempty"`
+	Reason          string           `json:"reason,omitempty"`
+	// Settlement is the progress of posting a confirmed withdrawal
+	Settlement WithdrawalSettlement `json:"settlement,omitempty"`
+	// Settled is when posting began and is the time of the withdrawal ledger entries
+	Settled *NanoTime `json:"settled,omitempty"`
+	Created NanoTime  `json:"created"`
+	Updated NanoTime  `json:"updated"`
+}
+
+func NewWithdrawal(account string, s types.Symbol, amt decimal.Decimal, address string) *Withdrawal {
+	tm := NanoTime(time.Now())
+	return &Withdrawal{
+		ID:      uuid.NewV4().String(),
+		Account: account,
+		Symbol:  s,
+		Amount:  amt,
+		Address: address,
+		Status:  WithdrawalRequested,
+		Created: tm,
+		Updated: tm,
+	}
+}
+
+func (w Withdrawal) Encode(enc EncodingType) ([]byte, error) {
+	return encode(enc, w)
+}
+
+func (w *Withdrawal) Decode(b []byte, enc EncodingType) error {
+	return decode(b, enc, w)
+}
+
+// DepositRepository stores the deposits received by an account
+type DepositRepository interface {
+	SetDeposit(context.Context, *Deposit) error
+	GetDeposit(context.Context, Key) (*Deposit, error)
+	// GetDeposits returns deposits with any of the provided statuses, oldest first. All
+	// deposits are returned when no status is provided.
+	GetDeposits(context.Context, ...DepositStatus) ([]*Deposit, error)
+}
+
+type DepositStatus string
+
+const (
+	// DepositPending is a deposit waiting for network confirmations; funds are not
+	// available to the account
+	DepositPending DepositStatus = "pending"
+	// DepositCredited is a confirmed deposit credited to the account balance
+	DepositCredited DepositStatus = "credited"
+)
+
+// Deposit tracks funds received at an account funding address until they have enough
+// network confirmations to be credited
+type Deposit struct {
+	ID              string          `json:"id"`
+	Account         string          `json:"account"`
+	Symbol          types.Symbol    `json:"symbol"`
+	Amount          decimal.Decimal `json:"amount"`
+	Address         string          `json:"address"`
+	TransactionHash string          `json:"transactionHash"`
+	// OutputIndex identifies the transaction output paid to the address
+	OutputIndex int `json:"outputIndex"`
+	// Reference is the funding source identifier used to look up confirmation updates
+	Reference     string        `json:"reference,omitempty"`
+	Confirmations int           `json:"confirmations"`
+	Status        DepositStatus `json:"status"`
+	Created       NanoTime      `json:"created"`
+	Updated       NanoTime      `json:"updated"`
+}
+
+// DepositID returns the deposit identifier for a transaction output so that repeated
+// notifications for the same output update a single deposit
+func DepositID(s types.Symbol, hash string, index int) string {
+	return uuid.NewV5(uuid.NamespaceURL, fmt.Sprintf("deposit:%s:%s:%d", s, hash, index)).String()
+}
+
+func NewDeposit(account string, s types.Symbol, amt decimal.Decimal, address string, hash string, index int) *Deposit {
+	tm := NanoTime(time.Now())
+	return &Deposit{
+		ID:              DepositID(s, hash, index),
+		Account:         account,
+		Symbol:          s,
+		Amount:          amt,
+		Address:         address,
+		TransactionHash: hash,
+		OutputIndex:     index,
+		Status:          DepositPending,
+		Created:         tm,
+		Updated:         tm,
+	}
+}
+
+func (d Deposit) Encode(enc EncodingType) ([]byte, error) {
+	return encode(enc, d)
+}
+
+func (d *Deposit) Decode(b []byte, enc EncodingType) error {
+	return decode(b, enc, d)
+}
+
+// DepositRegistry records every deposit credited to any account. A transaction output
+// can only be registered once which prevents a replayed funding notification from
+// being credited a second time.
+type DepositRegistry interface {
+	// Register saves the registration and returns ErrObjectExists when the transaction
+	// output is already registered. The check and write are atomic.
+	Register(context.Context, *DepositRegistration) error
+	// GetRegistration returns ErrObjectNotExist when the output is not registered
+	GetRegistration(ctx context.Context, s types.Symbol, hash string, index int) (*DepositRegistration, error)
+	// DeleteRegistration releases a registration when crediting the deposit fails
+	DeleteRegistration(ctx context.Context, s types.Symbol, hash string, index int) error
+}
+
+// DepositRegistration links a transaction output to the account and deposit it was
+// credited to
+type DepositRegistration struct {
+	Symbol          types.Symbol `json:"symbol"`
+	TransactionHash string       `json:"transactionHash"`
+	OutputIndex     int          `json:"outputIndex"`
+	Account         string       `json:"account"`
+	Deposit         string       `json:"deposit"`
+	Timestamp       NanoTime     `json:"timestamp"`
+}
+
+func (r DepositRegistration) Encode(enc EncodingType) ([]byte, error) {
+	return encode(enc, r)
+}
+
+func (r *DepositRegistration) Decode(b []byte, enc EncodingType) error {
+	return decode(b, enc, r)
+}
+
+// CampaignRepository stores promotion campaigns, the grants paid from them and the
+// referrals between accounts
+type CampaignRepository interface {
+	SetCampaign(context.Context, *Campaign) error
+	GetCampaign(context.Context, Key) (*Campaign, error)
+	GetCampaigns(context.Context) ([]*Campaign, error)
+	// CreateCampaign saves the campaign and returns ErrObjectExists when a campaign with
+	// the same id is stored. The check and write are atomic.
+	CreateCampaign(context.Context, *Campaign) error
+	// CreateGrant saves the grant and adds it to the amount granted by the campaign. It
+	// returns ErrObjectExists when the grant was already made, ErrCampaignBudget when the
+	// remaining budget does not cover the grant and ErrGrantLimit when the grant owner has
+	// reached the campaign limit. The checks and the writes are atomic.
+	CreateGrant(context.Context, *Grant) error
+	// GetGrant returns the grant made by the campaign with the grant id
+	GetGrant(ctx context.Context, campaign Key, id Key) (*Grant, error)
+	// UpdateGrant saves the grant only while the stored grant has the provided status
+	// and returns ErrPreconditionFailed when it does not. The check and write are atomic.
+	UpdateGrant(context.Context, *Grant, GrantStatus) error
+	// DeleteGrant releases a grant that could not be credited and returns the amount to
+	// the campaign budget
+	DeleteGrant(context.Context, *Grant) error
+	// GetGrants returns the grants made by a campaign, oldest first
+	GetGrants(context.Context, Key) ([]*Grant, error)
+	// SetReferral saves the referral and returns ErrObjectExists when the account was
+	// already referred. The check and write are atomic.
+	SetReferral(context.Context, *Referral) error
+	// GetReferral returns ErrObjectNotExist when the account was not referred
+	GetReferral(context.Context, Key) (*Referral, error)
+}
+
+type CampaignRule string
+
+const (
+	// SignUpBonus pays a fixed amount when an account is created
+	SignUpBonus CampaignRule = "sign_up_bonus"
+	// FirstDepositMatch pays a share of the first credited deposit in the campaign symbol
+	FirstDepositMatch CampaignRule = "first_deposit_match"
+	// FirstTradeReward pays a fixed amount when an account first trades
+	FirstTradeReward CampaignRule = "first_trade_reward"
+	// ReferralReward pays the referring account a fixed amount when a referred account is
+	// credited its first deposit
+	ReferralReward CampaignRule = "referral_reward"
+)
+
+// CampaignRules lists every rule a campaign can pay grants under
+var CampaignRules = []CampaignRule{
+	SignUpBonus,
+	FirstDepositMatch,
+	FirstTradeReward,
+	ReferralReward,
+}
+
+// Campaign pays promotional grants to accounts for the events matching its rule. Grants
+// are only made between Start and End and while the budget covers them.
+type Campaign struct {
+	ID     string       `json:"id"`
+	Name   string       `json:"name"`
+	Rule   CampaignRule `json:"rule"`
+	Symbol types.Symbol `json:"symbol"`
+	// Amount is paid for each grant. For a deposit match it is the largest grant paid.
+	Amount decimal.Decimal `json:"amount"`
+	// MatchRate is the share of a deposit paid by a deposit match
+	MatchRate decimal.Decimal `json:"matchRate"`
+	Budget    decimal.Decimal `json:"budget"`
+	Granted   decimal.Decimal `json:"granted"`
+	// PerAccountLimit is the most grants a single owner receives from the campaign across
+	// all of its accounts
+	PerAccountLimit int      `json:"perAccountLimit"`
+	Start           NanoTime `json:"start"`
+	End             NanoTime `json:"end"`
+	Created         NanoTime `json:"created"`
+}
+
+func NewCampaign(name string, rule CampaignRule, s types.Symbol, start, end time.Time) *Campaign {
+	return &Campaign{
+		ID:              uuid.NewV4().String(),
+		Name:            name,
+		Rule:            rule,
+		Symbol:          s,
+		Amount:          decimal.Zero,
+		MatchRate:       decimal.Zero,
+		Budget:          decimal.Zero,
+		Granted:         decimal.Zero,
+		PerAccountLimit: 1,
+		Start:           NanoTime(start),
+		End:             NanoTime(end),
+		Created:         NanoTime(time.Now()),
+	}
+}
+
+// Active reports whether t falls inside the campaign window
+func (c Campaign) Active(t time.Time) bool {
+	return !t.Before(time.Time(c.Start)) && t.Before(time.Time(c.End))
+}
+
+// Remaining is the budget not yet granted
+func (c Campaign) Remaining() decimal.Decimal {
+	return c.Budget.Sub(c.Granted)
+}
+
+func (c Campaign) Encode(enc EncodingType) ([]byte, error) {
+	return encode(enc, c)
+}
+
+func (c *Campaign) Decode(b []byte, enc EncodingType) error {
+	return decode(b, enc, c)
+}
+
+// GrantStatus tracks how far the payment of a grant has progressed
+type GrantStatus string
+
+const (
+	// GrantReserved grants are counted against the campaign budget but nothing has been
+	// credited to the account
+	GrantReserved GrantStatus = "reserved"
+	// GrantCrediting grants may have been credited to the account balance. A grant left
+	// in this status was interrupted and needs manual review.
+	GrantCrediting GrantStatus = "crediting"
+	// GrantCredited grants are credited to the account balance but the transaction
+	// record and ledger entries may not be written
+	GrantCredited GrantStatus = "credited"
+	// GrantPaid grants are credited and recorded
+	GrantPaid GrantStatus = "paid"
+)
+
+// Grant is a single promotional payment from a campaign to an account. Owner is the
+// authorization holding the account and Event names what the grant was paid for so the
+// same event is never paid twice to one owner.
+type Grant struct {
+	ID        string          `json:"id"`
+	Campaign  string          `json:"campaign"`
+	Owner     string          `json:"owner"`
+	Account   string          `json:"account"`
+	Event     string          `json:"event"`
+	Symbol    types.Symbol    `json:"symbol"`
+	Amount    decimal.Decimal `json:"amount"`
+	Status    GrantStatus     `json:"status"`
+	Timestamp NanoTime        `json:"timestamp"`
+}
+
+// GrantID is a stable id for the grant a campaign pays an owner for an event
+func GrantID(campaign, owner, event string) string {
+	return uuid.NewV5(uuid.NamespaceURL, fmt.Sprintf("grant:%s:%s:%s", campaign, owner, event)).String()
+}
+
+func NewGrant(c *Campaign, owner, account, event string, amt decimal.Decimal) *Grant {
+	return &Grant{
+		ID:        GrantID(c.ID, owner, event),
+		Campaign:  c.ID,
+		Owner:     owner,
+		Account:   account,
+		Event:     event,
+		Symbol:    c.Symbol,
+		Amount:    amt,
+		Status:    GrantReserved,
+		Timestamp: NanoTime(time.Now()),
+	}
+}
+
+func (g Grant) Encode(enc EncodingType) ([]byte, error) {
+	return encode(enc, g)
+}
+
+func (g *Grant) Decode(b []byte, enc EncodingType) error {
+	return decode(b, enc, g)
+}
+
+// Referral links an account to the account that referred it
+type Referral struct {
+	Account   string   `json:"account"`
+	Referrer  string   `json:"referrer"`
+	Timestamp NanoTime `json:"timestamp"`
+}
+
+func (r Referral) Encode(enc EncodingType) ([]byte, error) {
+	return encode(enc, r)
+}
+
+func (r *Referral) Decode(b []byte, enc EncodingType) error {
+	return decode(b, enc, r)
+}
+
+// AllowlistRepository stores the withdrawal addresses allowed on an account
+type AllowlistRepository interface {
+	SetAllowlistEntry(context.Context, *AllowlistEntry) error
+	GetAllowlistEntry(context.Context, Key) (*AllowlistEntry, error)
+	GetAllowlist(context.Context) ([]*AllowlistEntry, error)
+	DeleteAllowlistEntry(context.Context, Key) error
+}
+
+// AllowlistEntry is a withdrawal address on an account allowlist. The address can only
+// be used for withdrawals after ActiveAt.
+type AllowlistEntry struct {
+	ID       string       `json:"id"`
+	Symbol   types.Symbol `json:"symbol"`
+	Address  string       `json:"address"`
+	Label    string       `json:"label,omitempty"`
+	Created  NanoTime     `json:"created"`
+	ActiveAt NanoTime     `json:"activeAt"`
+}
+
+func NewAllowlistEntry(s types.Symbol, address string, cooldown time.Duration) *AllowlistEntry {
+	now := time.Now()
+	return &AllowlistEntry{
+		ID:       uuid.NewV4().String(),
+		Symbol:   s,
+		Address:  address,
+		Created:  NanoTime(now),
+		ActiveAt: NanoTime(now.Add(cooldown)),
+	}
+}
+
+func (e AllowlistEntry) Encode(enc EncodingType) ([]byte, error) {
+	return encode(enc, e)
+}
+
+func (e *AllowlistEntry) Decode(b []byte, enc EncodingType) error {
+	return decode(b, enc, e)
+}
+
+type AuditRepository interface {
+	SetAuditEntry(context.Context, *AuditEntry) error
+	GetAuditEntries(context.Context) ([]*AuditEntry, error)
+	// GetAccountAuditEntries returns the audit entries for a single account, oldest first
+	GetAccountAuditEntries(context.Context, string) ([]*AuditEntry, error)
+}
+
+type AuditEntryType string
+
+const (
+	HoldReleasedAuditType      AuditEntryType = "hold_released"
+	AllowlistAddedAuditType    AuditEntryType = "allowlist_added"
+	AllowlistLabeledAuditType  AuditEntryType = "allowlist_labeled"
+	AllowlistRemovedAuditType  AuditEntryType = "allowlist_removed"
+	AllowlistEnabledAuditType  AuditEntryType = "allowlist_enabled"
+	AllowlistDisabledAuditType AuditEntryType = "allowlist_disabled"
+)
+
+// AuditEntry records a change made to account data outside of normal order and
+// funding processing, such as a repair made by a maintenance job
+type AuditEntry struct {
+	ID        string         `json:"id"`
+	Type      AuditEntryType `json:"type"`
+	Account   string         `json:"account"`
+	Symbol    string         `json:"symbol"`
+	Reference string         `json:"reference"`
+	Amount    string         `json:"amount"`
+	Detail    string         `json:"detail"`
+	Timestamp NanoTime       `json:"timestamp"`
+}
+
+func NewAuditEntry(t AuditEntryType) *AuditEntry {
+	return &AuditEntry{
+		ID:        uuid.NewV4().String(),
+		Type:      t,
+		Timestamp: NanoTime(time.Now()),
+	}
+}
+
+func (e AuditEntry) Encode(enc EncodingType) ([]byte, error) {
+	return encode(enc, e)
+}
+
+func (e *AuditEntry) Decode(b []byte, enc EncodingType) error {
+	return decode(b, enc, e)
+}
+
+// ReconciliationRepository stores the result of each custodian reconciliation run
+type ReconciliationRepository interface {
+	SetReconciliation(context.Context, *Reconciliation) error
+	// GetReconciliations returns every stored run, oldest first
+	GetReconciliations(context.Context) ([]*Reconciliation, error)
+}
+
+// Reconciliation compares customer liabilities with the holdings reported by the
+// funding sources for each symbol at a point in time
+type Reconciliation struct {
+	ID        string               `json:"id"`
+	Timestamp NanoTime             `json:"timestamp"`
+	Items     []ReconciliationItem `json:"items"`
+	// Errors are funding sources that could not report holdings during the run
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Discrepant returns the items with a difference over the threshold
+func (r Reconciliation) Discrepant() []ReconciliationItem {
+	var out []ReconciliationItem
+	for _, i := range r.Items {
+		if i.Discrepant {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// ReconciliationItem is the reconciliation of a single symbol. Liabilities is the sum
+// of all account balances and Payable is the transfers payable ledger balance; both
+// are compared with the total holdings.
+type ReconciliationItem struct {
+	Symbol types.Symbol `json:"symbol"`
+	// Holdings are the funds reported by each funding source by source name
+	Holdings    map[string]decimal.Decimal `json:"holdings"`
+	Total       decimal.Decimal            `json:"total"`
+	Liabilities decimal.Decimal            `json:"liabilities"`
+	Payable     decimal.Decimal            `json:"payable"`
+	Threshold   decimal.Decimal            `json:"threshold"`
+	Discrepant  bool                       `json:"discrepant"`
+}
+
+// Difference is the amount holdings exceed account balances; negative values are a
+// shortfall
+func (i ReconciliationItem) Difference() decimal.Decimal {
+	return i.Total.Sub(i.Liabilities)
+}
+
+// PayableDifference is the amount holdings exceed the transfers payable ledger balance
+func (i ReconciliationItem) PayableDifference() decimal.Decimal {
+	return i.Total.Sub(i.Payable)
+}
+
+func (r Reconciliation) Encode(enc EncodingType) ([]byte, error) {
+	return encode(enc, r)
+}
+
+func (r *Reconciliation) Decode(b []byte, enc EncodingType) error {
+	return decode(b, enc, r)
+}
+
+// AuditRunRepository stores the result of each balance audit
+type AuditRunRepository interface {
+	SetAuditRun(context.Context, *AuditRun) error
+	GetAuditRun(context.Context, Key) (*AuditRun, error)
+	// GetAuditRuns returns every stored run, oldest first
+	GetAuditRuns(context.Context) ([]*AuditRun, error)
+}
+
+const (
+	// AuditUserAccounts is the audit value for the total of all user account balances
+	AuditUserAccounts = "user_accounts"
+	// AuditThroughput is the audit value for the total amount held for orders
+	AuditThroughput = "order_throughput"
+	// AuditBalance is the audit value for ledger liabilities less ledger assets
+	AuditBalance = "balance"
+)
+
+// AuditRun is a balance audit of the exchange at a point in time. Values are kept for
+// each symbol by name; ledger account balances use the ledger account name.
+type AuditRun struct {
+	ID        string   `json:"id"`
+	Timestamp NanoTime `json:"timestamp"`
+	// AsOf is the point in time the balances were rebuilt for
+	AsOf   NanoTime                                    `json:"asOf"`
+	Values map[types.Symbol]map[string]decimal.Decimal `json:"values"`
+	Errors []string                                    `json:"errors"`
+}
+
+// Set saves an audit value for a symbol
+func (r *AuditRun) Set(s types.Symbol, name string, amt decimal.Decimal) {
+	if r.Values == nil {
+		r.Values = make(map[types.Symbol]map[string]decimal.Decimal)
+	}
+
+	if _, ok := r.Values[s]; !ok {
+		r.Values[s] = make(map[string]decimal.Decimal)
+	}
+
+	r.Values[s][name] = amt
+}
+
+// AuditChange is an audit value that differs between two runs
+type AuditChange struct {
+	Symbol types.Symbol    `json:"symbol"`
+	Name   string          `json:"name"`
+	From   decimal.Decimal `json:"from"`
+	To     decimal.Decimal `json:"to"`
+}
+
+// AuditDiff lists what changed from one audit run to a later one
+type AuditDiff struct {
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Changes []AuditChange `json:"changes"`
+	// NewErrors are errors reported by the later run only
+	NewErrors []string `json:"newErrors"`
+	// ResolvedErrors are errors reported by the earlier run only
+	ResolvedErrors []string `json:"resolvedErrors"`
+}
+
+// Diff compares the run with a later run. Values missing from one of the runs are
+// compared as zero.
+func (r AuditRun) Diff(next AuditRun) *AuditDiff {
+	d := &AuditDiff{From: r.ID, To: next.ID, Changes: []AuditChange{}, NewErrors: []string{}, ResolvedErrors: []string{}}
+
+	names := make(map[types.Symbol]map[string]struct{})
+	for _, run := range []AuditRun{r, next} {
+		for s, vals := range run.Values {
+			if _, ok := names[s]; !ok {
+				names[s] = make(map[string]struct{})
+			}
+			for n := range vals {
+				names[s][n] = struct{}{}
+			}
+		}
+	}
+
+	for s, ns := range names {
+		for n := range ns {
+			from := r.Values[s][n]
+			to := next.Values[s][n]
+			if !from.Equal(to) {
+				d.Changes = append(d.Changes, AuditChange{Symbol: s, Name: n, From: from, To: to})
+			}
+		}
+	}
+
+	sort.Slice(d.Changes, func(i, j int) bool {
+		if d.Changes[i].Symbol == d.Changes[j].Symbol {
+			return d.Changes[i].Name < d.Changes[j].Name
+		}
+		return d.Changes[i].Symbol < d.Changes[j].Symbol
+	})
+
+	d.NewErrors = append(d.NewErrors, missingFrom(next.Errors, r.Errors)...)
+	d.ResolvedErrors = append(d.ResolvedErrors, missingFrom(r.Errors, next.Errors)...)
+
+	return d
+}
+
+// missingFrom returns the values of a that are not in b
+func missingFrom(a, b []string) []string {
+	seen := make(map[string]struct{}, len(b))
+	for _, x := range b {
+		seen[x] = struct{}{}
+	}
+
+	var out []string
+	for _, x := range a {
+		if _, ok := seen[x]; !ok {
+			out = append(out, x)
+		}
+	}
+
+	return out
+}
+
+func (r AuditRun) Encode(enc EncodingType) ([]byte, error) {
+	return encode(enc, r)
+}
+
+func (r *AuditRun) Decode(b []byte, enc EncodingType) error {
+	return decode(b, enc, r)
+}
+
+// LiabilityRepository stores proof of liabilities trees and the inclusion proof of each
+// account in them
+type LiabilityRepository interface {
+	SetLiabilityTree(context.Context, *LiabilityTree) error
+	// GetLatestLiabilityTree returns the most recently generated tree
+	GetLatestLiabilityTree(context.Context) (*LiabilityTree, error)
+	SetLiabilityProof(context.Context, *LiabilityProof) error
+	GetLiabilityProof(ctx context.Context, tree Key, account Key) (*LiabilityProof, error)
+}
+
+// LiabilityTree is the published root of a Merkle sum tree over every account balance.
+// The root sums are the total liabilities for each symbol.
+type LiabilityTree struct {
+	ID        string      `json:"id"`
+	Timestamp NanoTime    `json:"timestamp"`
+	Root      merkle.Node `json:"root"`
+	Accounts  int         `json:"accounts"`
+}
+
+func (t LiabilityTree) Encode(enc EncodingType) ([]byte, error) {
+	return encode(enc, t)
+}
+
+func (t *LiabilityTree) Decode(b []byte, enc EncodingType) error {
+	return decode(b, enc, t)
+}
+
+// LiabilityProof is the inclusion proof of a single account in a liability tree. The
+// nonce is only given to the account holder so the account hash cannot be linked to
+// the account by anyone else.
+type LiabilityProof struct {
+	TreeID  string       `json:"treeId"`
+	Account string       `json:"account"`
+	Nonce   string       `json:"nonce"`
+	Proof   merkle.Proof `json:"proof"`
+}
+
+func (p LiabilityProof) Encode(enc EncodingType) ([]byte, error) {
+	return encode(enc, p)
+}
+
+func (p *LiabilityProof) Decode(b []byte, enc EncodingType) error {
+	return decode(b, enc, p)
+}
+
+// MarketRepository stores the price of the latest trade on each market along with the
+// closing trade of every day the market traded
+type MarketRepository interface {
+	// SetLastTrade saves the trade as the latest trade of its market and as the closing
+	// trade for its day
+	SetLastTrade(context.Context, *Trade) error
+	// GetLastTrade returns ErrObjectNotExist if the market has never traded
+	GetLastTrade(ctx context.Context, base, target types.Symbol) (*Trade, error)
+	// GetClosingTrades returns the closing trade for each traded day from and to,
+	// inclusive, in date order
+	GetClosingTrades(ctx context.Context, base, target types.Symbol, from, to time.Time) ([]*Trade, error)
+}
+
+// Trade is a match on a market. Price is the amount of base paid for one target.
+type Trade struct {
+	Base      types.Symbol    `json:"base"`
+	Target    types.Symbol    `json:"target"`
+	Price     decimal.Decimal `json:"price"`
+	Quantity  decimal.Decimal `json:"quantity"`
+	Timestamp NanoTime        `json:"timestamp"`
+}
+
+// Date is the UTC day the trade closes
+func (t Trade) Date() string {
+	return SnapshotDate(time.Time(t.Timestamp))
+}
+
+func (t Trade) Encode(enc EncodingType) ([]byte, error) {
+	return encode(enc, t)
+}
+
+func (t *Trade) Decode(b []byte, enc EncodingType) error {
+	return decode(b, enc, t)
+}
+
 type AccountType int
 
 const (
@@ -256,17 +1111,34 @@ const (
 	Sales
 	TransfersPayable
 	Transfers
+	CustomerLiabilities
+	TradeClearing
+	Promotions
 	DefaultAccount
 )
 
 const (
-	CashStr             = "cash"
-	SalesStr            = "sales"
-	TransfersPayableStr = "transfers_payable"
-	TransfersStr        = "transfers"
-	DefaultAccountStr   = "default"
+	CashStr                = "cash"
+	SalesStr               = "sales"
+	TransfersPayableStr    = "transfers_payable"
+	TransfersStr           = "transfers"
+	CustomerLiabilitiesStr = "customer_liabilities"
+	TradeClearingStr       = "trade_clearing"
+	PromotionsStr          = "promotions"
+	DefaultAccountStr      = "default"
 )
 
+// LedgerAccounts lists every account kept in the main ledger
+var LedgerAccounts = []LedgerAccount{
+	Cash,
+	Sales,
+	TransfersPayable,
+	Transfers,
+	CustomerLiabilities,
+	TradeClearing,
+	Promotions,
+}
+
 func (a LedgerAccount) String() string {
 	switch a {
 	case Cash:
@@ -277,6 +1149,12 @@ func (a LedgerAccount) String() string {
 		return TransfersPayableStr
 	case Transfers:
 		return TransfersStr
+	case CustomerLiabilities:
+		return CustomerLiabilitiesStr
+	case TradeClearing:
+		return TradeClearingStr
+	case Promotions:
+		return PromotionsStr
 	default:
 		return DefaultAccountStr
 	}
@@ -292,6 +1170,12 @@ func (a *LedgerAccount) FromString(s string) {
 		*a = TransfersPayable
 	case TransfersStr:
 		*a = Transfers
+	case CustomerLiabilitiesStr:
+		*a = CustomerLiabilities
+	case TradeClearingStr:
+		*a = TradeClearing
+	case PromotionsStr:
+		*a = Promotions
 	default:
 		*a = DefaultAccount
 	}
@@ -334,11 +1218,15 @@ func (e *EntryType) FromString(s string) {
 }
 
 type LedgerEntry struct {
-	Account   LedgerAccount   `json:"account"`
-	Entry     EntryType       `json:"entry"`
-	Symbol    types.Symbol    `json:"symbol"`
-	Amount    decimal.Decimal `json:"amount"`
-	Timestamp NanoTime        `json:"timestamp"`
+	// ID identifies the stored entry and is assigned by the repository
+	ID      string        `json:"-"`
+	Account LedgerAccount `json:"account"`
+	// SubAccount identifies the customer account an entry applies to, if any
+	SubAccount string          `json:"subAccount,omitempty"`
+	Entry      EntryType       `json:"entry"`
+	Symbol     types.Symbol    `json:"symbol"`
+	Amount     decimal.Decimal `json:"amount"`
+	Timestamp  NanoTime        `json:"timestamp"`
 }
 
 func (e LedgerEntry) Encode(enc EncodingType) ([]byte, error) {
@@ -352,14 +1240,128 @@ func (e *LedgerEntry) Decode(b []byte, enc EncodingType) error {
 type LedgerRepository interface {
 	// RecordDeposit saves a transfer to the exchange in the main ledger
 	RecordDeposit(context.Context, types.Symbol, decimal.Decimal) error
+	// RecordDepositCredit saves a deposit credited from a registered transaction output
+	// at the registration time. Recording the same registration again replaces its entries.
+	RecordDepositCredit(context.Context, *DepositRegistration, decimal.Decimal) error
 	// RecordTransfer saves a transfer from the exchange in the main ledger
 	RecordTransfer(context.Context, types.Symbol, decimal.Decimal) error
 	// GetLiabilityBalance ...
 	GetLiabilityBalance(context.Context, LedgerAccount) (balances map[types.Symbol]decimal.Decimal, err error)
 	// GetAssetBalance ...
 	GetAssetBalance(context.Context, LedgerAccount) (balances map[types.Symbol]decimal.Decimal, err error)
-	// RecordFee saves a fee paid from a completed order in the main ledger
+	// RecordFee saves a fee paid from a completed order or withdrawal in the main ledger
 	RecordFee(context.Context, types.Symbol, decimal.Decimal) error
+	// RecordWithdrawal saves the transfer and fee of a settled withdrawal in the main
+	// ledger. Recording the same withdrawal again replaces its entries.
+	RecordWithdrawal(context.Context, *Withdrawal) error
+	// RecordTrade saves journal entries for one side of a settled trade in the main ledger
+	RecordTrade(context.Context, TradeLeg) error
+	// RecordInternalTransfer moves a customer liability between two customer sub-accounts.
+	// Recording the same transfer id again replaces its entries.
+	RecordInternalTransfer(ctx context.Context, id, from, to string, s types.Symbol, amt decimal.Decimal) error
+	// RecordGrant pays a promotional grant from the promotions account to a customer
+	// sub-account at the grant time. Recording the same grant again replaces its entries.
+	RecordGrant(context.Context, *Grant) error
+	// GetTrialBalance totals debits and credits for every ledger account and symbol
+	GetTrialBalance(context.Context) (*TrialBalance, error)
+	// GetTrialBalanceAt totals debits and credits posted before the provided time
+	GetTrialBalanceAt(context.Context, time.Time) (*TrialBalance, error)
+	// GetEntries returns a page of ledger entries matching the query
+	GetEntries(context.Context, LedgerQuery) (*LedgerPage, error)
+}
+
+// TradeLeg is the balance change for a single customer account from a settled trade
+type TradeLeg struct {
+	// ID identifies the fill of a single order; it is unique to each leg of a trade
+	ID        string
+	Account   string
+	AddSymbol types.Symbol
+	AddAmount decimal.Decimal
+	SubSymbol types.Symbol
+	SubAmount decimal.Decimal
+}
+
+// TrialBalanceLine holds the debit and credit totals posted to a ledger account for
+// a single symbol
+type TrialBalanceLine struct {
+	Account LedgerAccount   `json:"account"`
+	Symbol  types.Symbol    `json:"symbol"`
+	Debits  decimal.Decimal `json:"debits"`
+	Credits decimal.Decimal `json:"credits"`
+}
+
+// TrialBalance lists debit and credit totals for the ledger. In a balanced ledger
+// total debits equal total credits for every symbol.
+type TrialBalance struct {
+	Lines []TrialBalanceLine `json:"lines"`
+}
+
+// Add posts a ledger entry to the trial balance
+func (t *TrialBalance) Add(e *LedgerEntry) {
+	for i, l := range t.Lines {
+		if l.Account == e.Account && l.Symbol == e.Symbol {
+			t.Lines[i] = l.add(e)
+			return
+		}
+	}
+
+	l := TrialBalanceLine{
+		Account: e.Account,
+		Symbol:  e.Symbol,
+		Debits:  decimal.NewFromInt(0),
+		Credits: decimal.NewFromInt(0),
+	}
+	t.Lines = append(t.Lines, l.add(e))
+}
+
+// Totals returns the total debits and credits for each symbol
+func (t *TrialBalance) Totals() (debits map[types.Symbol]decimal.Decimal, credits map[types.Symbol]decimal.Decimal) {
+	debits = make(map[types.Symbol]decimal.Decimal)
+	credits = make(map[types.Symbol]decimal.Decimal)
+
+	for _, l := range t.Lines {
+		debits[l.Symbol] = l.Debits.Add(debits[l.Symbol])
+		credits[l.Symbol] = l.Credits.Add(credits[l.Symbol])
+	}
+
+	return
+}
+
+// Unbalanced returns the symbols for which total debits do not equal total credits
+func (t *TrialBalance) Unbalanced() []types.Symbol {
+	var out []types.Symbol
+
+	debits, credits := t.Totals()
+	for s, d := range debits {
+		if !d.Equal(credits[s]) {
+			out = append(out, s)
+		}
+	}
+
+	return out
+}
+
+// Net returns the credit balance of the ledger account for each symbol
+func (t *TrialBalance) Net(a LedgerAccount) map[types.Symbol]decimal.Decimal {
+	out := make(map[types.Symbol]decimal.Decimal)
+
+	for _, l := range t.Lines {
+		if l.Account == a {
+			out[l.Symbol] = l.Credits.Sub(l.Debits)
+		}
+	}
+
+	return out
+}
+
+func (l TrialBalanceLine) add(e *LedgerEntry) TrialBalanceLine {
+	switch e.Entry {
+	case Debit:
+		l.Debits = l.Debits.Add(e.Amount)
+	case Credit:
+		l.Credits = l.Credits.Add(e.Amount)
+	}
+	return l
 }
 
 type FillStatus int
@@ -369,15 +1371,21 @@ const (
 	StatusPartial
 	StatusFilled
 	StatusCanceled
+	StatusRejected
+	// StatusPartialCanceled is a partially filled order whose unfilled remainder was
+	// canceled; it holds nothing and never returns to the book
+	StatusPartialCanceled
 	StatusDefault
 )
 
 const (
-	StatusOpenStr     = "open"
-	StatusPartialStr  = "partial"
-	StatusFilledStr   = "filled"
-	StatusCanceledStr = "canceled"
-	StatusDefaultStr  = "default"
+	StatusOpenStr            = "open"
+	StatusPartialStr         = "partial"
+	StatusFilledStr          = "filled"
+	StatusCanceledStr        = "canceled"
+	StatusRejectedStr        = "rejected"
+	StatusPartialCanceledStr = "partial_canceled"
+	StatusDefaultStr         = "default"
 )
 
 func (s FillStatus) String() string {
@@ -390,6 +1398,10 @@ func (s FillStatus) String() string {
 		return StatusFilledStr
 	case StatusCanceled:
 		return StatusCanceledStr
+	case StatusRejected:
+		return StatusRejectedStr
+	case StatusPartialCanceled:
+		return StatusPartialCanceledStr
 	default:
 		return StatusDefaultStr
 	}
@@ -405,6 +1417,10 @@ func (s *FillStatus) FromString(str string) {
 		*s = StatusFilled
 	case StatusCanceledStr:
 		*s = StatusCanceled
+	case StatusRejectedStr:
+		*s = StatusRejected
+	case StatusPartialCanceledStr:
+		*s = StatusPartialCanceled
 	default:
 		*s = StatusDefault
 	}
@@ -438,11 +1454,27 @@ func (s *FillStatus) UnmarshalJSON(b []byte) error {
 		*s = StatusFilled
 	case "canceled":
 		*s = StatusCanceled
+	case "rejected":
+		*s = StatusRejected
+	case "partial_canceled":
+		*s = StatusPartialCanceled
 	}
 
 	return nil
 }
 
+// RejectReason is a machine readable explanation for why an order could not
+// be processed.
+type RejectReason string
+
+const (
+	RejectReasonBookUnavailable  RejectReason = "book_unavailable"
+	RejectReasonSettlementFailed RejectReason = "settlement_failed"
+	RejectReasonHoldUpdateFailed RejectReason = "hold_update_failed"
+	RejectReasonBookUpdateFailed RejectReason = "book_update_failed"
+	RejectReasonProcessingFailed RejectReason = "processing_failed"
+)
+
 type NanoTime time.Time
 
 func (t NanoTime) Value() int64 {
@@ -141,6 +141,34 @@ func TestOrderByteEncoding(t *testing.T) {
 	})
 }
 
+func TestAuditRunDiff(t *testing.T) {
+	a := AuditRun{ID: "a", Errors: []string{"resolved", "kept"}}
+	a.Set(types.SymbolBitcoin, AuditUserAccounts, decimal.NewFromInt(1))
+	a.Set(types.SymbolBitcoin, AuditBalance, decimal.Zero)
+	a.Set(types.SymbolCardano, AuditUserAccounts, decimal.NewFromInt(2))
+
+	b := AuditRun{ID: "b", Errors: []string{"kept", "new"}}
+	b.Set(types.SymbolBitcoin, AuditUserAccounts, decimal.NewFromInt(3))
+	b.Set(types.SymbolBitcoin, AuditBalance, decimal.Zero)
+	b.Set(types.SymbolEthereum, AuditUserAccounts, decimal.NewFromInt(4))
+
+	d := a.Diff(b)
+	assert.Equal(t, "a", d.From)
+	assert.Equal(t, "b", d.To)
+	assert.Equal(t, []string{"new"}, d.NewErrors)
+	assert.Equal(t, []string{"resolved"}, d.ResolvedErrors)
+
+	// values missing from a run compare as zero
+	if assert.Len(t, d.Changes, 3) {
+		assert.Equal(t, types.SymbolBitcoin, d.Changes[0].Symbol)
+		assert.True(t, d.Changes[0].To.Equal(decimal.NewFromInt(3)))
+		assert.Equal(t, types.SymbolEthereum, d.Changes[1].Symbol)
+		assert.True(t, d.Changes[1].From.IsZero())
+		assert.Equal(t, types.SymbolCardano, d.Changes[2].Symbol)
+		assert.True(t, d.Changes[2].To.IsZero())
+	}
+}
+
 func TestFillStatusMarshalJSON(t *testing.T) {
 	fs := StatusCanceled
 
@@ -6,13 +6,16 @@ import (
 	"errors"
 
 	"github.com/easterthebunny/spew-order/internal/contexts"
+	"github.com/easterthebunny/spew-order/internal/persist"
 	"github.com/easterthebunny/spew-order/pkg/domain"
 	"github.com/easterthebunny/spew-order/pkg/types"
+	uuid "github.com/satori/go.uuid"
 	"github.com/shopspring/decimal"
 )
 
 var (
-	OrderTopic = "OrderRequests"
+	OrderTopic      = "OrderRequests"
+	ErrBatchAborted = errors.New("order not placed; another order in the batch failed")
 )
 
 func NewGoogleOrderQueue(projectID string, manager *domain.BalanceManager) (*OrderQueue, error) {
@@ -51,64 +54,253 @@ func (o *OrderQueue) CancelOrder(ctx context.Context, order types.Order) (err er
 	return
 }
 
-func (o *OrderQueue) PublishOrderRequest(ctx context.Context, or types.OrderRequest) (order types.Order, err error) {
+// CancelOrders publishes a cancel message for every open or partially filled order
+// on the account that passes the provided filter. A nil filter matches every order.
+// Orders that could not be published are returned separately from the canceled
+// orders so that a single failure does not abort the remaining cancellations.
+func (o *OrderQueue) CancelOrders(ctx context.Context, a *domain.Account, filter func(types.Order) bool) (canceled []types.Order, failed []types.Order, err error) {
 
-	aID, err := contexts.GetAccountID(ctx)
+	orders, err := o.balance.GetOrdersByStatus(ctx, a, persist.StatusOpen, persist.StatusPartial)
 	if err != nil {
 		return
 	}
 
-	acct, err := o.balance.GetAccount(ctx, aID)
-	if err != nil {
-		return
-	}
+	for _, order := range orders {
+		if filter != nil && !filter(order.Base) {
+			continue
+		}
 
-	if acct == nil {
-		err = contexts.ErrAccountNotFoundInContext
-		return
-	}
+		if cerr := o.CancelOrder(ctx, order.Base); cerr != nil {
+			failed = append(failed, order.Base)
+			continue
+		}
 
-	// place hold on account
-	symbol, hold := or.Type.HoldAmount(or.Action, or.Base, or.Target)
-	if hold.LessThanOrEqual(decimal.NewFromInt(0)) {
-		err = errors.New("order type not supported")
-		return
+		canceled = append(canceled, order.Base)
 	}
 
-	// set a hold on the traded amount
-	holdid, err := o.balance.SetHoldOnAccount(ctx, acct, symbol, hold)
+	return
+}
+
+// PublishOrderRequest places holds for the order request on the account in the context,
+// saves the order as open, and publishes it to the order topic. Holds already placed
+// on the request are reused rather than placed again. If the request carries a client
+// order id that was already used, the original order is returned and nothing is published.
+// A request that fails is abandoned: its holds are released and an order already saved
+// is rejected. The client order id is only released when no order was saved so a retry
+// with the same id finds the rejected order instead of placing a second one.
+func (o *OrderQueue) PublishOrderRequest(ctx context.Context, or types.OrderRequest) (order types.Order, err error) {
+
+	acct, err := o.contextAccount(ctx)
 	if err != nil {
 		return
 	}
 
-	or.HoldID = holdid
-
-	// set a hold on the fee amount if not dealing with native token
-	if or.Target != types.SymbolCipherMtn {
-		var feeHoldId string
-		feeHoldId, err = o.balance.SetHoldOnAccount(ctx, acct, types.SymbolCipherMtn, types.StandardFee)
+	if or.ClientOrderID != "" {
+		var existing *persist.Order
+		existing, err = o.balance.ReserveOrderKey(ctx, acct, or.ClientOrderID)
 		if err != nil {
 			return
 		}
 
-		or.FeeHoldID = feeHoldId
+		if existing != nil {
+			// holds placed ahead of time for a duplicate request are not needed
+			o.releaseOrderRequest(ctx, acct, or)
+			order = existing.Base
+			return
+		}
+
+		defer func() {
+			if err != nil && uuid.Equal(order.ID, uuid.Nil) {
+				o.balance.ReleaseKey(ctx, acct, or.ClientOrderID)
+			}
+		}()
 	}
 
-	order, err = o.balance.CreateOrder(ctx, acct, or)
+	or, err = o.holdOrderRequest(ctx, acct, or)
 	if err != nil {
 		return
 	}
 
+	order, err = o.balance.CreateOrder(ctx, acct, or)
+	if err == nil {
+		err = o.publishOrder(ctx, order)
+	}
+
+	if err != nil {
+		o.abandonOrderRequest(ctx, acct, or, order)
+	}
+
+	return
+}
+
+func (o *OrderQueue) publishOrder(ctx context.Context, order types.Order) error {
+
 	om := domain.OrderMessage{
 		Action: domain.OpenOrderMessageType,
 		Order:  order}
 
 	b, err := json.Marshal(om)
 	if err != nil {
-		return
+		return err
 	}
 
 	_, err = o.client.Publish(ctx, OrderTopic, b)
+	return err
+}
+
+// BatchResult is the outcome of a single order request submitted as part of
+// a batch
+type BatchResult struct {
+	Order types.Order
+	Err   error
+}
+
+// PublishOrderRequests publishes multiple order requests for the account in the context.
+// In best effort mode each request is placed independently of the others. When atomic
+// is true, holds for every request are placed before any order is published and a single
+// failed hold releases all holds placed by the batch. Orders are then published in
+// sequence; an order that fails to publish is abandoned along with every order after it
+// and their holds are released. Orders published before the failure stay open and are
+// reported in the results.
+func (o *OrderQueue) PublishOrderRequests(ctx context.Context, reqs []types.OrderRequest, atomic bool) (results []BatchResult, err error) {
+
+	acct, err := o.contextAccount(ctx)
+	if err != nil {
+		return
+	}
+
+	results = make([]BatchResult, len(reqs))
+
+	if atomic {
+		held := make([]types.OrderRequest, 0, len(reqs))
+		for i, or := range reqs {
+			h, herr := o.holdOrderRequest(ctx, acct, or)
+			if herr != nil {
+				for _, placed := range held {
+					o.releaseOrderRequest(ctx, acct, placed)
+				}
+
+				for j := range results {
+					results[j].Err = ErrBatchAborted
+				}
+				results[i].Err = herr
+
+				return
+			}
+
+			held = append(held, h)
+		}
+
+		reqs = held
+	}
+
+	for i, or := range reqs {
+		results[i].Order, results[i].Err = o.PublishOrderRequest(ctx, or)
+		if results[i].Err == nil || !atomic {
+			continue
+		}
+
+		// PublishOrderRequest already abandoned the request that failed
+		for j := i + 1; j < len(reqs); j++ {
+			o.releaseOrderRequest(ctx, acct, reqs[j])
+			results[j].Err = ErrBatchAborted
+		}
+
+		break
+	}
 
 	return
 }
+
+// FindOrderByKey returns the order previously created on the account with the client
+// order id, or nil if there is none
+func (o *OrderQueue) FindOrderByKey(ctx context.Context, a *domain.Account, clientOrderID string) (*persist.Order, error) {
+	return o.balance.FindOrderByKey(ctx, a, clientOrderID)
+}
+
+func (o *OrderQueue) contextAccount(ctx context.Context) (*domain.Account, error) {
+
+	aID, err := contexts.GetAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	acct, err := o.balance.GetAccount(ctx, aID)
+	if err != nil {
+		return nil, err
+	}
+
+	if acct == nil {
+		return nil, contexts.ErrAccountNotFoundInContext
+	}
+
+	return acct, nil
+}
+
+// holdOrderRequest places the trade and fee holds required by the order request and
+// returns the request with the hold ids set
+func (o *OrderQueue) holdOrderRequest(ctx context.Context, acct *domain.Account, or types.OrderRequest) (types.OrderRequest, error) {
+
+	if or.HoldID == "" {
+		// place hold on account
+		symbol, hold := or.Type.HoldAmount(or.Action, or.Base, or.Target)
+		if hold.LessThanOrEqual(decimal.NewFromInt(0)) {
+			return or, errors.New("order type not supported")
+		}
+
+		// set a hold on the traded amount
+		holdid, err := o.balance.SetHoldOnAccount(ctx, acct, symbol, hold)
+		if err != nil {
+			return or, err
+		}
+
+		or.HoldID = holdid
+	}
+
+	// set a hold on the fee amount if not dealing with native token
+	if or.Target != types.SymbolCipherMtn && or.FeeHoldID == "" {
+		feeHoldId, err := o.balance.SetHoldOnAccount(ctx, acct, types.SymbolCipherMtn, types.StandardFee)
+		if err != nil {
+			o.releaseOrderRequest(ctx, acct, or)
+			return or, err
+		}
+
+		or.FeeHoldID = feeHoldId
+	}
+
+	return or, nil
+}
+
+// releaseOrderRequest removes any holds placed for an order request that was not published
+func (o *OrderQueue) releaseOrderRequest(ctx context.Context, acct *domain.Account, or types.OrderRequest) {
+
+	if or.HoldID != "" {
+		symbol, _ := or.Type.HoldAmount(or.Action, or.Base, or.Target)
+		o.balance.RemoveHoldOnAccount(ctx, acct, symbol, key(or.HoldID))
+	}
+
+	if or.FeeHoldID != "" {
+		o.balance.RemoveHoldOnAccount(ctx, acct, types.SymbolCipherMtn, key(or.FeeHoldID))
+	}
+}
+
+// abandonOrderRequest closes out an order request with holds placed that failed to
+// publish. An order that was already saved is rejected, which releases its holds;
+// otherwise the holds are released directly.
+func (o *OrderQueue) abandonOrderRequest(ctx context.Context, acct *domain.Account, or types.OrderRequest, order types.Order) {
+
+	if !uuid.Equal(order.ID, uuid.Nil) {
+		err := o.balance.RejectOrder(ctx, order, persist.RejectReasonProcessingFailed)
+		if err == nil {
+			return
+		}
+	}
+
+	o.releaseOrderRequest(ctx, acct, or)
+}
+
+type key string
+
+func (k key) String() string {
+	return string(k)
+}
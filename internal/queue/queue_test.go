@@ -2,6 +2,7 @@ package queue
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -121,3 +122,283 @@ func TestPublishOrderRequest(t *testing.T) {
 
 	})
 }
+
+func TestPublishOrderRequests(t *testing.T) {
+
+	// set up the mocked pub sub and drain the subscription to the topic
+	subscription := make(chan domain.PubSubMessage)
+	mps := NewMockPubSub()
+	mps.Subscribe(OrderTopic, subscription)
+	go func() {
+		for range subscription {
+		}
+	}()
+
+	acct := domain.NewAccount()
+	store := persist.NewMockKVStore()
+	repo := kv.NewAccountRepository(store)
+	l := kv.NewLedgerRepository(store)
+	err := repo.Save(context.Background(), &persist.Account{ID: acct.ID.String()})
+	if err != nil {
+		t.FailNow()
+	}
+	svc := domain.NewBalanceManager(repo, l, funding.NewMockSource())
+
+	ctx := contexts.AttachAccountID(context.Background(), acct.ID.String())
+	svc.PostAmtToBalance(ctx, acct, types.SymbolBitcoin, decimal.NewFromFloat(1.0))
+	svc.PostAmtToBalance(ctx, acct, types.SymbolCipherMtn, decimal.NewFromFloat(1000))
+
+	q := NewOrderQueue(mps, svc)
+
+	// each request holds 0.75 BTC; only one can be placed with the available balance
+	newRequest := func() types.OrderRequest {
+		return types.OrderRequest{
+			Base:   types.SymbolBitcoin,
+			Target: types.SymbolEthereum,
+			Action: types.ActionTypeBuy,
+			Type: &types.LimitOrderType{
+				Base:     types.SymbolBitcoin,
+				Price:    decimal.NewFromFloat(0.25),
+				Quantity: decimal.NewFromFloat(3.0)}}
+	}
+
+	t.Run("AllOrNothing", func(t *testing.T) {
+		results, err := q.PublishOrderRequests(ctx, []types.OrderRequest{newRequest(), newRequest()}, true)
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+
+		assert.ErrorIs(t, results[0].Err, ErrBatchAborted)
+		assert.ErrorIs(t, results[1].Err, domain.ErrInsufficientBalanceForHold)
+
+		// the hold placed for the first request should be released
+		bal, err := svc.GetAvailableBalance(ctx, acct, types.SymbolBitcoin)
+		assert.NoError(t, err)
+		assert.Equal(t, "1", bal.String())
+
+		bal, err = svc.GetAvailableBalance(ctx, acct, types.SymbolCipherMtn)
+		assert.NoError(t, err)
+		assert.Equal(t, "1000", bal.String())
+	})
+
+	t.Run("BestEffort", func(t *testing.T) {
+		results, err := q.PublishOrderRequests(ctx, []types.OrderRequest{newRequest(), newRequest()}, false)
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+
+		assert.NoError(t, results[0].Err)
+		assert.NotEqual(t, "", results[0].Order.HoldID)
+		assert.ErrorIs(t, results[1].Err, domain.ErrInsufficientBalanceForHold)
+
+		bal, err := svc.GetAvailableBalance(ctx, acct, types.SymbolBitcoin)
+		assert.NoError(t, err)
+		assert.Equal(t, "0.25", bal.String())
+	})
+}
+
+func TestPublishOrderRequest_ClientOrderID(t *testing.T) {
+
+	// set up the mocked pub sub and drain the subscription to the topic
+	subscription := make(chan domain.PubSubMessage)
+	mps := NewMockPubSub()
+	mps.Subscribe(OrderTopic, subscription)
+	go func() {
+		for range subscription {
+		}
+	}()
+
+	acct := domain.NewAccount()
+	store := persist.NewMockKVStore()
+	repo := kv.NewAccountRepository(store)
+	l := kv.NewLedgerRepository(store)
+	err := repo.Save(context.Background(), &persist.Account{ID: acct.ID.String()})
+	if err != nil {
+		t.FailNow()
+	}
+	svc := domain.NewBalanceManager(repo, l, funding.NewMockSource())
+
+	ctx := contexts.AttachAccountID(context.Background(), acct.ID.String())
+	svc.PostAmtToBalance(ctx, acct, types.SymbolBitcoin, decimal.NewFromFloat(2.0))
+	svc.PostAmtToBalance(ctx, acct, types.SymbolCipherMtn, decimal.NewFromFloat(1000))
+
+	q := NewOrderQueue(mps, svc)
+
+	or := types.OrderRequest{
+		Base:          types.SymbolBitcoin,
+		Target:        types.SymbolEthereum,
+		Action:        types.ActionTypeBuy,
+		ClientOrderID: "client-order-1",
+		Type: &types.LimitOrderType{
+			Base:     types.SymbolBitcoin,
+			Price:    decimal.NewFromFloat(0.25),
+			Quantity: decimal.NewFromFloat(2.0)}}
+
+	first, err := q.PublishOrderRequest(ctx, or)
+	assert.NoError(t, err)
+
+	second, err := q.PublishOrderRequest(ctx, or)
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID, "repeated request returns the original order")
+
+	// only the original order should hold funds
+	bal, err := svc.GetAvailableBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.5", bal.String())
+
+	existing, err := q.FindOrderByKey(ctx, acct, "client-order-1")
+	assert.NoError(t, err)
+	if assert.NotNil(t, existing) {
+		assert.Equal(t, first.ID, existing.Base.ID)
+		assert.Equal(t, "client-order-1", existing.Base.ClientOrderID)
+	}
+}
+
+// failingPubSub publishes the first n messages and fails every message after
+type failingPubSub struct {
+	PubSub
+	n int
+}
+
+func (f *failingPubSub) Publish(ctx context.Context, topic string, data []byte) (string, error) {
+	if f.n <= 0 {
+		return "", errors.New("publish failed")
+	}
+	f.n--
+	return f.PubSub.Publish(ctx, topic, data)
+}
+
+func TestPublishOrderRequests_PublishFailure(t *testing.T) {
+
+	subscription := make(chan domain.PubSubMessage)
+	mps := NewMockPubSub()
+	mps.Subscribe(OrderTopic, subscription)
+	go func() {
+		for range subscription {
+		}
+	}()
+
+	acct := domain.NewAccount()
+	store := persist.NewMockKVStore()
+	repo := kv.NewAccountRepository(store)
+	l := kv.NewLedgerRepository(store)
+	err := repo.Save(context.Background(), &persist.Account{ID: acct.ID.String()})
+	if err != nil {
+		t.FailNow()
+	}
+	svc := domain.NewBalanceManager(repo, l, funding.NewMockSource())
+
+	ctx := contexts.AttachAccountID(context.Background(), acct.ID.String())
+	svc.PostAmtToBalance(ctx, acct, types.SymbolBitcoin, decimal.NewFromFloat(1.0))
+	svc.PostAmtToBalance(ctx, acct, types.SymbolCipherMtn, decimal.NewFromFloat(1000))
+
+	// only the first order in the batch is published
+	q := NewOrderQueue(&failingPubSub{PubSub: mps, n: 1}, svc)
+
+	// each request holds 0.25 BTC
+	newRequest := func() types.OrderRequest {
+		return types.OrderRequest{
+			Account: acct.ID,
+			Base:    types.SymbolBitcoin,
+			Target:  types.SymbolEthereum,
+			Action:  types.ActionTypeBuy,
+			Type: &types.LimitOrderType{
+				Base:     types.SymbolBitcoin,
+				Price:    decimal.NewFromFloat(0.25),
+				Quantity: decimal.NewFromFloat(1.0)}}
+	}
+
+	results, err := q.PublishOrderRequests(ctx, []types.OrderRequest{newRequest(), newRequest(), newRequest()}, true)
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+
+	assert.NoError(t, results[0].Err)
+	assert.Error(t, results[1].Err)
+	assert.NotErrorIs(t, results[1].Err, ErrBatchAborted)
+	assert.ErrorIs(t, results[2].Err, ErrBatchAborted)
+
+	// only the published order keeps its holds
+	bal, err := svc.GetAvailableBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.75", bal.String())
+
+	bal, err = svc.GetAvailableBalance(ctx, acct, types.SymbolCipherMtn)
+	assert.NoError(t, err)
+	assert.Equal(t, decimal.NewFromInt(1000).Sub(types.StandardFee).String(), bal.String())
+
+	// the order saved before publishing failed is rejected rather than left open
+	open, err := svc.GetOrdersByStatus(ctx, acct, persist.StatusOpen)
+	assert.NoError(t, err)
+	if assert.Len(t, open, 1) {
+		assert.Equal(t, results[0].Order.ID, open[0].Base.ID)
+	}
+
+	rejected, err := svc.GetOrdersByStatus(ctx, acct, persist.StatusRejected)
+	assert.NoError(t, err)
+	assert.Len(t, rejected, 1)
+}
+
+func TestPublishOrderRequest_PublishFailure(t *testing.T) {
+
+	subscription := make(chan domain.PubSubMessage)
+	mps := NewMockPubSub()
+	mps.Subscribe(OrderTopic, subscription)
+	go func() {
+		for range subscription {
+		}
+	}()
+
+	acct := domain.NewAccount()
+	store := persist.NewMockKVStore()
+	repo := kv.NewAccountRepository(store)
+	l := kv.NewLedgerRepository(store)
+	err := repo.Save(context.Background(), &persist.Account{ID: acct.ID.String()})
+	if err != nil {
+		t.FailNow()
+	}
+	svc := domain.NewBalanceManager(repo, l, funding.NewMockSource())
+
+	ctx := contexts.AttachAccountID(context.Background(), acct.ID.String())
+	svc.PostAmtToBalance(ctx, acct, types.SymbolBitcoin, decimal.NewFromFloat(1.0))
+	svc.PostAmtToBalance(ctx, acct, types.SymbolCipherMtn, decimal.NewFromFloat(1000))
+
+	or := types.OrderRequest{
+		Account:       acct.ID,
+		Base:          types.SymbolBitcoin,
+		Target:        types.SymbolEthereum,
+		Action:        types.ActionTypeBuy,
+		ClientOrderID: "client-order-1",
+		Type: &types.LimitOrderType{
+			Base:     types.SymbolBitcoin,
+			Price:    decimal.NewFromFloat(0.25),
+			Quantity: decimal.NewFromFloat(1.0)}}
+
+	// nothing is published
+	q := NewOrderQueue(&failingPubSub{PubSub: mps}, svc)
+
+	first, err := q.PublishOrderRequest(ctx, or)
+	assert.Error(t, err)
+
+	// the saved order is rejected and its holds are released
+	bal, err := svc.GetAvailableBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", bal.String())
+
+	rejected, err := svc.GetOrdersByStatus(ctx, acct, persist.StatusRejected)
+	assert.NoError(t, err)
+	if assert.Len(t, rejected, 1) {
+		assert.Equal(t, first.ID, rejected[0].Base.ID)
+	}
+
+	// a retry with the same client order id finds the rejected order instead of
+	// placing a second one
+	second, err := NewOrderQueue(mps, svc).PublishOrderRequest(ctx, or)
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+
+	bal, err = svc.GetAvailableBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", bal.String())
+
+	open, err := svc.GetOrdersByStatus(ctx, acct, persist.StatusOpen)
+	assert.NoError(t, err)
+	assert.Len(t, open, 0)
+}
@@ -10,6 +10,20 @@ const (
 	ActionTypeSELL ActionType = "SELL"
 )
 
+// Defines values for BatchMode.
+const (
+	BatchModeALLORNOTHING BatchMode = "ALL_OR_NOTHING"
+
+	BatchModeBESTEFFORT BatchMode = "BEST_EFFORT"
+)
+
+// Defines values for DepositStatus.
+const (
+	DepositStatusCREDITED DepositStatus = "CREDITED"
+
+	DepositStatusPENDING DepositStatus = "PENDING"
+)
+
 // Defines values for OrderStatus.
 const (
 	OrderStatusCANCELLED OrderStatus = "CANCELLED"
@@ -19,6 +33,10 @@ const (
 	OrderStatusOPEN OrderStatus = "OPEN"
 
 	OrderStatusPARTIAL OrderStatus = "PARTIAL"
+
+	OrderStatusPARTIALCANCELLED OrderStatus = "PARTIAL_CANCELLED"
+
+	OrderStatusREJECTED OrderStatus = "REJECTED"
 )
 
 // Defines values for OrderTypeName.
@@ -28,6 +46,13 @@ const (
 	OrderTypeNameMARKET OrderTypeName = "MARKET"
 )
 
+// Defines values for StatementFormatParam.
+const (
+	StatementFormatParamCsv StatementFormatParam = "csv"
+
+	StatementFormatParamOfx StatementFormatParam = "ofx"
+)
+
 // Defines values for SymbolType.
 const (
 	SymbolTypeBCH SymbolType = "BCH"
@@ -47,15 +72,65 @@ const (
 const (
 	TransactionTypeDEPOSIT TransactionType = "DEPOSIT"
 
+	TransactionTypeFEE TransactionType = "FEE"
+
+	TransactionTypeGRANT TransactionType = "GRANT"
+
+	TransactionTypeINTERNAL TransactionType = "INTERNAL"
+
 	TransactionTypeORDER TransactionType = "ORDER"
 
 	TransactionTypeTRANSFER TransactionType = "TRANSFER"
 )
 
+// Defines values for WithdrawalStatus.
+const (
+	WithdrawalStatusAPPROVED WithdrawalStatus = "APPROVED"
+
+	WithdrawalStatusBROADCAST WithdrawalStatus = "BROADCAST"
+
+	WithdrawalStatusBROADCASTING WithdrawalStatus = "BROADCASTING"
+
+	WithdrawalStatusCONFIRMED WithdrawalStatus = "CONFIRMED"
+
+	WithdrawalStatusFAILED WithdrawalStatus = "FAILED"
+
+	WithdrawalStatusREFUNDED WithdrawalStatus = "REFUNDED"
+
+	WithdrawalStatusREQUESTED WithdrawalStatus = "REQUESTED"
+
+	WithdrawalStatusREVIEW WithdrawalStatus = "REVIEW"
+)
+
 // Balances account
 type Account struct {
-	Balances *BalanceList `json:"balances,omitempty"`
-	Id       string       `json:"id"`
+
+	// Withdrawals are limited to active allowlist addresses
+	Allowlist *bool `json:"allowlist,omitempty"`
+
+	// Time a pending request to turn off the allowlist takes effect
+	AllowlistDisableAt *string      `json:"allowlistDisableAt,omitempty"`
+	Archived           *bool        `json:"archived,omitempty"`
+	Balances           *BalanceList `json:"balances,omitempty"`
+	Id                 string       `json:"id"`
+	Label              *string      `json:"label,omitempty"`
+
+	// Worth of a set of balances in a single quote symbol
+	Valuation *Valuation `json:"valuation,omitempty"`
+}
+
+// sub-account creation request
+type AccountRequest struct {
+	Label *string `json:"label,omitempty"`
+}
+
+// account update request
+type AccountUpdateRequest struct {
+
+	// Enable the withdrawal allowlist immediately, or disable it after the cooldown
+	Allowlist *bool   `json:"allowlist,omitempty"`
+	Archived  *bool   `json:"archived,omitempty"`
+	Label     *string `json:"label,omitempty"`
 }
 
 // Action type: * `BUY` - use base currency to buy target currency * `SELL` - sell target currency for base currency
@@ -70,17 +145,107 @@ type AddressItem struct {
 	Symbol SymbolType `json:"symbol"`
 }
 
+// withdrawal address on the account allowlist
+type AllowlistEntry struct {
+
+	// Address is usable for withdrawals
+	Active bool `json:"active"`
+
+	// Time the address becomes usable for withdrawals
+	ActiveAt string  `json:"activeAt"`
+	Address  string  `json:"address"`
+	Created  string  `json:"created"`
+	Id       string  `json:"id"`
+	Label    *string `json:"label,omitempty"`
+
+	// Symbol Type: * `BTC` - bitcoin currency identifier * `ETH` - ethereum currency identifier * `BCH` - bitcoin cash currency identifier * `DOGE` - dogecoin currency identifier * `UNI` - uniswap currency identifer * `CMTN` - cipher mountain currency identifer
+	Symbol SymbolType `json:"symbol"`
+}
+
+// AllowlistEntryList defines model for AllowlistEntryList.
+type AllowlistEntryList []AllowlistEntry
+
+// allowlist address request
+type AllowlistEntryRequest struct {
+	Address string  `json:"address"`
+	Label   *string `json:"label,omitempty"`
+
+	// Symbol Type: * `BTC` - bitcoin currency identifier * `ETH` - ethereum currency identifier * `BCH` - bitcoin cash currency identifier * `DOGE` - dogecoin currency identifier * `UNI` - uniswap currency identifer * `CMTN` - cipher mountain currency identifer
+	Symbol SymbolType `json:"symbol"`
+}
+
+// allowlist address update request
+type AllowlistEntryUpdateRequest struct {
+	Label string `json:"label"`
+}
+
+// change made to the account allowlist
+type AllowlistEvent struct {
+	Detail    *string `json:"detail,omitempty"`
+	EntryId   *string `json:"entryId,omitempty"`
+	Id        string  `json:"id"`
+	Symbol    *string `json:"symbol,omitempty"`
+	Timestamp string  `json:"timestamp"`
+	Type      string  `json:"type"`
+}
+
+// AllowlistEventList defines model for AllowlistEventList.
+type AllowlistEventList []AllowlistEvent
+
+// BalanceHistory defines model for BalanceHistory.
+type BalanceHistory []BalanceSnapshot
+
 // BalanceItem defines model for BalanceItem.
 type BalanceItem struct {
-	Quantity CurrencyValue `json:"quantity"`
+	Pending  *CurrencyValue `json:"pending,omitempty"`
+	Quantity CurrencyValue  `json:"quantity"`
 
 	// Symbol Type: * `BTC` - bitcoin currency identifier * `ETH` - ethereum currency identifier * `BCH` - bitcoin cash currency identifier * `DOGE` - dogecoin currency identifier * `UNI` - uniswap currency identifer * `CMTN` - cipher mountain currency identifer
-	Symbol SymbolType `json:"symbol"`
+	Symbol SymbolType     `json:"symbol"`
+	Value  *CurrencyValue `json:"value,omitempty"`
 }
 
 // BalanceList defines model for BalanceList.
 type BalanceList []BalanceItem
 
+// BalanceSnapshot defines model for BalanceSnapshot.
+type BalanceSnapshot struct {
+	Balances []SnapshotBalanceItem `json:"balances"`
+	Date     string                `json:"date"`
+
+	// Worth of a set of balances in a single quote symbol
+	Valuation *Valuation `json:"valuation,omitempty"`
+}
+
+// Batch mode: * `ALL_OR_NOTHING` - hold funds for every order in the batch or place none of them * `BEST_EFFORT` - place each order in the batch independently
+type BatchMode string
+
+// Request to create multiple orders on the order book
+type BatchOrderRequest struct {
+	// Batch mode: * `ALL_OR_NOTHING` - hold funds for every order in the batch or place none of them * `BEST_EFFORT` - place each order in the batch independently
+	Mode   BatchMode      `json:"mode"`
+	Orders []OrderRequest `json:"orders"`
+}
+
+// BatchOrderResponse defines model for BatchOrderResponse.
+type BatchOrderResponse struct {
+	// Batch mode: * `ALL_OR_NOTHING` - hold funds for every order in the batch or place none of them * `BEST_EFFORT` - place each order in the batch independently
+	Mode    BatchMode            `json:"mode"`
+	Results BatchOrderResultList `json:"results"`
+}
+
+// Result for a single order in a batch
+type BatchOrderResult struct {
+	Error *ResponseError `json:"error,omitempty"`
+
+	// Position of the order in the batch request
+	Index int        `json:"index"`
+	Order *BookOrder `json:"order,omitempty"`
+}
+
+// BatchOrderResultList defines model for BatchOrderResultList.
+type BatchOrderResultList []BatchOrderResult
+
 // BookOrder defines model for BookOrder.
 type BookOrder struct {
 	Guid string `json:"guid"`
@@ -88,7 +253,10 @@ type BookOrder struct {
 	// Request to create a new order on the order book
 	Order OrderRequest `json:"order"`
 
-	// Symbol Type: * `OPEN` - incomplete order * `PARTIAL` - partial order * `FILLED` - filled order * `CANCELLED` - cancelled order
+	// Machine readable reason the order was rejected
+	Reason *string `json:"reason,omitempty"`
+
+	// Symbol Type: * `OPEN` - incomplete order * `PARTIAL` - partial order * `FILLED` - filled order * `CANCELLED` - cancelled order * `REJECTED` - order could not be processed
 	Status OrderStatus `json:"status"`
 }
 
@@ -98,6 +266,102 @@ type BookOrderList []BookOrder
 // CurrencyValue defines model for CurrencyValue.
 type CurrencyValue string
 
+// Funds received at an account deposit address
+type Deposit struct {
+	Address string `json:"address"`
+
+	// Network confirmations received
+	Confirmations int           `json:"confirmations"`
+	Created       string        `json:"created"`
+	Id            string        `json:"id"`
+	Quantity      CurrencyValue `json:"quantity"`
+
+	// Network confirmations needed before the deposit is credited
+	RequiredConfirmations int `json:"requiredConfirmations"`
+
+	// Deposit Status: * `PENDING` - waiting for network confirmations * `CREDITED` - confirmed and added to the balance
+	Status DepositStatus `json:"status"`
+
+	// Symbol Type: * `BTC` - bitcoin currency identifier * `ETH` - ethereum currency identifier * `BCH` - bitcoin cash currency identifier * `DOGE` - dogecoin currency identifier * `UNI` - uniswap currency identifer * `CMTN` - cipher mountain currency identifer
+	Symbol          SymbolType `json:"symbol"`
+	TransactionHash string     `json:"transactionHash"`
+	Updated         string     `json:"updated"`
+}
+
+// DepositList defines model for DepositList.
+type DepositList []Deposit
+
+// Deposit Status: * `PENDING` - waiting for network confirmations * `CREDITED` - confirmed and added to the balance
+type DepositStatus string
+
+// Withdrawal fee quote. Quantity is sent to the withdrawal address and total is taken from the account.
+type FeeEstimate struct {
+	Fee      CurrencyValue `json:"fee"`
+	Quantity CurrencyValue `json:"quantity"`
+
+	// Symbol Type: * `BTC` - bitcoin currency identifier * `ETH` - ethereum currency identifier * `BCH` - bitcoin cash currency identifier * `DOGE` - dogecoin currency identifier * `UNI` - uniswap currency identifer * `CMTN` - cipher mountain currency identifer
+	Symbol SymbolType    `json:"symbol"`
+	Total  CurrencyValue `json:"total"`
+}
+
+// transfer between accounts owned by the same user
+type InternalTransferRequest struct {
+	// The uuid of the account receiving the funds
+	Account  string        `json:"account"`
+	Quantity CurrencyValue `json:"quantity"`
+
+	// Symbol Type: * `BTC` - bitcoin currency identifier * `ETH` - ethereum currency identifier * `BCH` - bitcoin cash currency identifier * `DOGE` - dogecoin currency identifier * `UNI` - uniswap currency identifer * `CMTN` - cipher mountain currency identifer
+	Symbol SymbolType `json:"symbol"`
+}
+
+// A Merkle sum tree node hash and the balance totals below it
+type LiabilityNode struct {
+	Hash string         `json:"hash"`
+	Sums []LiabilitySum `json:"sums"`
+}
+
+// Inclusion proof of an account in a proof of liabilities tree. The account hash is built from the account id and nonce.
+type LiabilityProof struct {
+	AccountHash string `json:"accountHash"`
+
+	// A Merkle sum tree node hash and the balance totals below it
+	Leaf  LiabilityNode        `json:"leaf"`
+	Nonce string               `json:"nonce"`
+	Path  []LiabilityProofStep `json:"path"`
+
+	// A Merkle sum tree node hash and the balance totals below it
+	Root   LiabilityNode `json:"root"`
+	TreeId string        `json:"treeId"`
+}
+
+// A sibling on the path from the account leaf to the root
+type LiabilityProofStep struct {
+	Hash string `json:"hash"`
+
+	// True when the sibling is the left child
+	Left bool           `json:"left"`
+	Sums []LiabilitySum `json:"sums"`
+}
+
+// Published root of a proof of liabilities tree
+type LiabilityRoot struct {
+	// Number of accounts in the tree
+	Accounts int    `json:"accounts"`
+	Id       string `json:"id"`
+
+	// A Merkle sum tree node hash and the balance totals below it
+	Root      LiabilityNode `json:"root"`
+	Timestamp string        `json:"timestamp"`
+}
+
+// LiabilitySum defines model for LiabilitySum.
+type LiabilitySum struct {
+	Quantity CurrencyValue `json:"quantity"`
+
+	// Symbol Type: * `BTC` - bitcoin currency identifier * `ETH` - ethereum currency identifier * `BCH` - bitcoin cash currency identifier * `DOGE` - dogecoin currency identifier * `UNI` - uniswap currency identifer * `CMTN` - cipher mountain currency identifer
+	Symbol SymbolType `json:"symbol"`
+}
+
 // LimitOrderRequest defines model for LimitOrderRequest.
 type LimitOrderRequest struct {
 	// Embedded struct due to allOf(#/components/schemas/OrderType)
@@ -115,8 +379,20 @@ type MarketOrderRequest struct {
 	OrderType `yaml:",inline"`
 	// Embedded fields due to inline allOf schema
 	// Symbol Type: * `BTC` - bitcoin currency identifier * `ETH` - ethereum currency identifier * `BCH` - bitcoin cash currency identifier * `DOGE` - dogecoin currency identifier * `UNI` - uniswap currency identifer * `CMTN` - cipher mountain currency identifer
-	Base     SymbolType    `json:"base"`
-	Quantity CurrencyValue `json:"quantity"`
+	Base SymbolType `json:"base"`
+
+	// Maximum allowed price deviation from the first matched price, as a fraction. Any unfilled remainder beyond the cap is canceled.
+	MaxDeviation *string       `json:"maxDeviation,omitempty"`
+	Quantity     CurrencyValue `json:"quantity"`
+}
+
+// Result of a mass order cancellation
+type OrderCancelSummary struct {
+	Canceled BookOrderList `json:"canceled"`
+
+	// Number of orders a cancellation was requested for
+	Count  int           `json:"count"`
+	Failed BookOrderList `json:"failed"`
 }
 
 // Request to create a new order on the order book
@@ -127,6 +403,9 @@ type OrderRequest struct {
 	// Symbol Type: * `BTC` - bitcoin currency identifier * `ETH` - ethereum currency identifier * `BCH` - bitcoin cash currency identifier * `DOGE` - dogecoin currency identifier * `UNI` - uniswap currency identifer * `CMTN` - cipher mountain currency identifer
 	Base SymbolType `json:"base"`
 
+	// Optional client supplied order identifier. An order submitted again with the same identifier returns the original order.
+	ClientOrderId *string `json:"clientOrderId,omitempty"`
+
 	// Symbol Type: * `BTC` - bitcoin currency identifier * `ETH` - ethereum currency identifier * `BCH` - bitcoin cash currency identifier * `DOGE` - dogecoin currency identifier * `UNI` - uniswap currency identifer * `CMTN` - cipher mountain currency identifer
 	Target SymbolType       `json:"target"`
 	Type   OrderRequestType `json:"type"`
@@ -157,16 +436,41 @@ type PatchCommand struct {
 // PatchCommandList defines model for PatchCommandList.
 type PatchCommandList []PatchCommand
 
+// link between an account and the account that referred it
+type Referral struct {
+	Account   string `json:"account"`
+	Referrer  string `json:"referrer"`
+	Timestamp string `json:"timestamp"`
+}
+
+// account that referred this account
+type ReferralRequest struct {
+	// The uuid of the referring account
+	Referrer string `json:"referrer"`
+}
+
 // ResponseError defines model for ResponseError.
 type ResponseError struct {
 	Detail string `json:"detail"`
 }
 
+// SnapshotBalanceItem defines model for SnapshotBalanceItem.
+type SnapshotBalanceItem struct {
+	Available CurrencyValue `json:"available"`
+	Posted    CurrencyValue `json:"posted"`
+
+	// Symbol Type: * `BTC` - bitcoin currency identifier * `ETH` - ethereum currency identifier * `BCH` - bitcoin cash currency identifier * `DOGE` - dogecoin currency identifier * `UNI` - uniswap currency identifer * `CMTN` - cipher mountain currency identifer
+	Symbol SymbolType `json:"symbol"`
+}
+
 // Symbol Type: * `BTC` - bitcoin currency identifier * `ETH` - ethereum currency identifier * `BCH` - bitcoin cash currency identifier * `DOGE` - dogecoin currency identifier * `UNI` - uniswap currency identifer * `CMTN` - cipher mountain currency identifer
 type SymbolType string
 
 // Account balance change
 type Transaction struct {
+	// The other account in an internal transfer
+	Account *string `json:"account,omitempty"`
+
 	Fee      CurrencyValue `json:"fee"`
 	Orderid  string        `json:"orderid"`
 	Quantity CurrencyValue `json:"quantity"`
@@ -176,7 +480,7 @@ type Transaction struct {
 	Timestamp       string     `json:"timestamp"`
 	TransactionHash string     `json:"transactionHash"`
 
-	// Transaction Type: * `ORDER` - transaction resulting from a match on the order book * `DEPOSIT` - transaction resulting from a funding deposit * `TRANSFER` - transaction resulting from a funding withdrawal
+	// Transaction Type: * `ORDER` - transaction resulting from a match on the order book * `DEPOSIT` - transaction resulting from a funding deposit * `TRANSFER` - transaction resulting from a funding withdrawal * `INTERNAL` - transaction resulting from a transfer between accounts * `FEE` - network fee charged on a funding withdrawal * `GRANT` - promotional funds paid by a campaign
 	Type TransactionType `json:"type"`
 }
 
@@ -192,40 +496,195 @@ type TransactionRequest struct {
 	Symbol SymbolType `json:"symbol"`
 }
 
-// Transaction Type: * `ORDER` - transaction resulting from a match on the order book * `DEPOSIT` - transaction resulting from a funding deposit * `TRANSFER` - transaction resulting from a funding withdrawal
+// Transaction Type: * `ORDER` - transaction resulting from a match on the order book * `DEPOSIT` - transaction resulting from a funding deposit * `TRANSFER` - transaction resulting from a funding withdrawal * `INTERNAL` - transaction resulting from a transfer between accounts * `FEE` - network fee charged on a funding withdrawal * `GRANT` - promotional funds paid by a campaign
 type TransactionType string
 
+// Worth of a set of balances in a single quote symbol
+type Valuation struct {
+	// Symbol Type: * `BTC` - bitcoin currency identifier * `ETH` - ethereum currency identifier * `BCH` - bitcoin cash currency identifier * `DOGE` - dogecoin currency identifier * `UNI` - uniswap currency identifer * `CMTN` - cipher mountain currency identifer
+	Quote SymbolType    `json:"quote"`
+	Total CurrencyValue `json:"total"`
+
+	// Symbols held that no priced market connects to the quote symbol
+	Unpriced *[]SymbolType `json:"unpriced,omitempty"`
+}
+
+// Funds withdrawal to an external address
+type Withdrawal struct {
+	Address string        `json:"address"`
+	Created string        `json:"created"`
+	Fee     CurrencyValue `json:"fee"`
+	Id      string        `json:"id"`
+
+	// Reason the withdrawal is in review or failed
+	Reason   *string       `json:"reason,omitempty"`
+	Quantity CurrencyValue `json:"quantity"`
+
+	// Withdrawal Status: * `REQUESTED` - funds on hold waiting for approval * `REVIEW` - over a withdrawal limit and waiting for manual review * `APPROVED` - waiting to be sent * `BROADCASTING` - being sent to the network * `BROADCAST` - sent and waiting for confirmation * `CONFIRMED` - complete * `FAILED` - could not be sent; waiting for refund * `REFUNDED` - held funds returned to the account
+	Status WithdrawalStatus `json:"status"`
+
+	// Symbol Type: * `BTC` - bitcoin currency identifier * `ETH` - ethereum currency identifier * `BCH` - bitcoin cash currency identifier * `DOGE` - dogecoin currency identifier * `UNI` - uniswap currency identifer * `CMTN` - cipher mountain currency identifer
+	Symbol          SymbolType `json:"symbol"`
+	TransactionHash *string    `json:"transactionHash,omitempty"`
+	Updated         string     `json:"updated"`
+}
+
+// WithdrawalList defines model for WithdrawalList.
+type WithdrawalList []Withdrawal
+
+// Withdrawal Status: * `REQUESTED` - funds on hold waiting for approval * `REVIEW` - over a withdrawal limit and waiting for manual review * `APPROVED` - waiting to be sent * `BROADCASTING` - being sent to the network * `BROADCAST` - sent and waiting for confirmation * `CONFIRMED` - complete * `FAILED` - could not be sent; waiting for refund * `REFUNDED` - held funds returned to the account
+type WithdrawalStatus string
+
 // AccountPathParam defines model for AccountPathParam.
 type AccountPathParam string
 
+// AllowlistEntryPathParam defines model for AllowlistEntryPathParam.
+type AllowlistEntryPathParam string
+
+// FromDateParam defines model for FromDateParam.
+type FromDateParam string
+
+// IdempotencyKeyHeader defines model for IdempotencyKeyHeader.
+type IdempotencyKeyHeader string
+
+// MarketParam defines model for MarketParam.
+type MarketParam string
+
 // Symbol Type: * `BTC` - bitcoin currency identifier * `ETH` - ethereum currency identifier * `BCH` - bitcoin cash currency identifier * `DOGE` - dogecoin currency identifier * `UNI` - uniswap currency identifer * `CMTN` - cipher mountain currency identifer
 type OrderPathParam SymbolType
 
 // Symbol Type: * `OPEN` - incomplete order * `PARTIAL` - partial order * `FILLED` - filled order * `CANCELLED` - cancelled order
 type OrderStatusParam OrderStatus
 
+// Action type: * `BUY` - use base currency to buy target currency * `SELL` - sell target currency for base currency
+type SideParam ActionType
+
+// Symbol Type: * `BTC` - bitcoin currency identifier * `ETH` - ethereum currency identifier * `BCH` - bitcoin cash currency identifier * `DOGE` - dogecoin currency identifier * `UNI` - uniswap currency identifer * `CMTN` - cipher mountain currency identifer
+type QuoteParam SymbolType
+
+// StatementFormatParam defines model for StatementFormatParam.
+type StatementFormatParam string
+
 // SymbolPathParam defines model for SymbolPathParam.
 type SymbolPathParam string
 
+// ToDateParam defines model for ToDateParam.
+type ToDateParam string
+
+// WithdrawalPathParam defines model for WithdrawalPathParam.
+type WithdrawalPathParam string
+
+// GetApiAccountsAccountIDParams defines parameters for GetApiAccountsAccountID.
+type GetApiAccountsAccountIDParams struct {
+	Quote *QuoteParam `json:"quote,omitempty"`
+}
+
+// GetApiAccountsAccountIDBalancesHistoryParams defines parameters for GetApiAccountsAccountIDBalancesHistory.
+type GetApiAccountsAccountIDBalancesHistoryParams struct {
+	From  *FromDateParam `json:"from,omitempty"`
+	To    *ToDateParam   `json:"to,omitempty"`
+	Quote *QuoteParam    `json:"quote,omitempty"`
+}
+
+// GetApiAccountsAccountIDWithdrawalsFeeParams defines parameters for GetApiAccountsAccountIDWithdrawalsFee.
+type GetApiAccountsAccountIDWithdrawalsFeeParams struct {
+	Symbol   SymbolType    `json:"symbol"`
+	Quantity CurrencyValue `json:"quantity"`
+	Address  *string       `json:"address,omitempty"`
+}
+
+// GetApiAccountsAccountIDStatementParams defines parameters for GetApiAccountsAccountIDStatement.
+type GetApiAccountsAccountIDStatementParams struct {
+	From   *FromDateParam        `json:"from,omitempty"`
+	To     *ToDateParam          `json:"to,omitempty"`
+	Format *StatementFormatParam `json:"format,omitempty"`
+}
+
+// PostApiAccountsJSONBody defines parameters for PostApiAccounts.
+type PostApiAccountsJSONBody AccountRequest
+
+// PatchApiAccountsAccountIDJSONBody defines parameters for PatchApiAccountsAccountID.
+type PatchApiAccountsAccountIDJSONBody AccountUpdateRequest
+
+// PostApiAccountsAccountIDAllowlistJSONBody defines parameters for PostApiAccountsAccountIDAllowlist.
+type PostApiAccountsAccountIDAllowlistJSONBody AllowlistEntryRequest
+
+// PatchApiAccountsAccountIDAllowlistEntryIDJSONBody defines parameters for PatchApiAccountsAccountIDAllowlistEntryID.
+type PatchApiAccountsAccountIDAllowlistEntryIDJSONBody AllowlistEntryUpdateRequest
+
+// DeleteApiAccountsAccountIDOrdersParams defines parameters for DeleteApiAccountsAccountIDOrders.
+type DeleteApiAccountsAccountIDOrdersParams struct {
+	Market *MarketParam `json:"market,omitempty"`
+	Side   *SideParam   `json:"side,omitempty"`
+}
+
 // GetApiAccountsAccountIDOrdersParams defines parameters for GetApiAccountsAccountIDOrders.
 type GetApiAccountsAccountIDOrdersParams struct {
 	Status *OrderStatusParam `json:"status,omitempty"`
 }
 
+// PostApiAccountsAccountIDOrdersBatchJSONBody defines parameters for PostApiAccountsAccountIDOrdersBatch.
+type PostApiAccountsAccountIDOrdersBatchJSONBody BatchOrderRequest
+
+// PostApiAccountsAccountIDOrdersParams defines parameters for PostApiAccountsAccountIDOrders.
+type PostApiAccountsAccountIDOrdersParams struct {
+	// Client supplied key that identifies a request. A request repeated with the same key within the retention window returns the original result.
+	IdempotencyKey *IdempotencyKeyHeader `json:"Idempotency-Key,omitempty"`
+}
+
 // PatchApiAccountsAccountIDOrdersJSONBody defines parameters for PatchApiAccountsAccountIDOrders.
 type PatchApiAccountsAccountIDOrdersJSONBody PatchCommandList
 
 // PostApiAccountsAccountIDOrdersJSONBody defines parameters for PostApiAccountsAccountIDOrders.
 type PostApiAccountsAccountIDOrdersJSONBody OrderRequest
 
+// PostApiAccountsAccountIDTransfersJSONBody defines parameters for PostApiAccountsAccountIDTransfers.
+type PostApiAccountsAccountIDTransfersJSONBody InternalTransferRequest
+
+// PostApiAccountsAccountIDTransfersParams defines parameters for PostApiAccountsAccountIDTransfers.
+type PostApiAccountsAccountIDTransfersParams struct {
+	// Client supplied key that identifies a request. A request repeated with the same key within the retention window returns the original result.
+	IdempotencyKey *IdempotencyKeyHeader `json:"Idempotency-Key,omitempty"`
+}
+
+// PostApiAccountsAccountIDReferralJSONBody defines parameters for PostApiAccountsAccountIDReferral.
+type PostApiAccountsAccountIDReferralJSONBody ReferralRequest
+
 // PostApiAccountsAccountIDTransactionsJSONBody defines parameters for PostApiAccountsAccountIDTransactions.
 type PostApiAccountsAccountIDTransactionsJSONBody TransactionRequest
 
+// PostApiAccountsAccountIDTransactionsParams defines parameters for PostApiAccountsAccountIDTransactions.
+type PostApiAccountsAccountIDTransactionsParams struct {
+	// Client supplied key that identifies a request. A request repeated with the same key within the retention window returns the original result.
+	IdempotencyKey *IdempotencyKeyHeader `json:"Idempotency-Key,omitempty"`
+}
+
+// PostApiAccountsJSONRequestBody defines body for PostApiAccounts for application/json ContentType.
+type PostApiAccountsJSONRequestBody PostApiAccountsJSONBody
+
+// PatchApiAccountsAccountIDJSONRequestBody defines body for PatchApiAccountsAccountID for application/json ContentType.
+type PatchApiAccountsAccountIDJSONRequestBody PatchApiAccountsAccountIDJSONBody
+
+// PostApiAccountsAccountIDAllowlistJSONRequestBody defines body for PostApiAccountsAccountIDAllowlist for application/json ContentType.
+type PostApiAccountsAccountIDAllowlistJSONRequestBody PostApiAccountsAccountIDAllowlistJSONBody
+
+// PatchApiAccountsAccountIDAllowlistEntryIDJSONRequestBody defines body for PatchApiAccountsAccountIDAllowlistEntryID for application/json ContentType.
+type PatchApiAccountsAccountIDAllowlistEntryIDJSONRequestBody PatchApiAccountsAccountIDAllowlistEntryIDJSONBody
+
 // PatchApiAccountsAccountIDOrdersJSONRequestBody defines body for PatchApiAccountsAccountIDOrders for application/json ContentType.
 type PatchApiAccountsAccountIDOrdersJSONRequestBody PatchApiAccountsAccountIDOrdersJSONBody
 
 // PostApiAccountsAccountIDOrdersJSONRequestBody defines body for PostApiAccountsAccountIDOrders for application/json ContentType.
 type PostApiAccountsAccountIDOrdersJSONRequestBody PostApiAccountsAccountIDOrdersJSONBody
 
+// PostApiAccountsAccountIDOrdersBatchJSONRequestBody defines body for PostApiAccountsAccountIDOrdersBatch for application/json ContentType.
+type PostApiAccountsAccountIDOrdersBatchJSONRequestBody PostApiAccountsAccountIDOrdersBatchJSONBody
+
+// PostApiAccountsAccountIDReferralJSONRequestBody defines body for PostApiAccountsAccountIDReferral for application/json ContentType.
+type PostApiAccountsAccountIDReferralJSONRequestBody PostApiAccountsAccountIDReferralJSONBody
+
 // PostApiAccountsAccountIDTransactionsJSONRequestBody defines body for PostApiAccountsAccountIDTransactions for application/json ContentType.
 type PostApiAccountsAccountIDTransactionsJSONRequestBody PostApiAccountsAccountIDTransactionsJSONBody
+
+// PostApiAccountsAccountIDTransfersJSONRequestBody defines body for PostApiAccountsAccountIDTransfers for application/json ContentType.
+type PostApiAccountsAccountIDTransfersJSONRequestBody PostApiAccountsAccountIDTransfersJSONBody
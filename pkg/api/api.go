@@ -3,3 +3,22 @@ package api
 const AccountPathParamName = "accountID"
 const OrderPathParamName = "orderID"
 const SymbolPathParamName = "symbolName"
+const WithdrawalPathParamName = "withdrawalID"
+const AllowlistEntryPathParamName = "entryID"
+const AuditRunPathParamName = "runID"
+
+const MarketQueryParamName = "market"
+const SideQueryParamName = "side"
+const AccountQueryParamName = "account"
+const SymbolQueryParamName = "symbol"
+const QuantityQueryParamName = "quantity"
+const AddressQueryParamName = "address"
+const FromQueryParamName = "from"
+const ToQueryParamName = "to"
+const AsOfQueryParamName = "as_of"
+const CursorQueryParamName = "cursor"
+const LimitQueryParamName = "limit"
+const FormatQueryParamName = "format"
+const QuoteQueryParamName = "quote"
+
+const IdempotencyKeyHeaderName = "Idempotency-Key"
@@ -36,3 +36,58 @@ func (b *TransactionList) Render(w http.ResponseWriter, r *http.Request) error {
 func (b *AddressItem) Render(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
+
+// Render implements the render.Renderer interface for use with chi-router
+func (b *OrderCancelSummary) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Render implements the render.Renderer interface for use with chi-router
+func (b *BatchOrderResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Render implements the render.Renderer interface for use with chi-router
+func (b *BalanceHistory) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Render implements the render.Renderer interface for use with chi-router
+func (b *Withdrawal) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Render implements the render.Renderer interface for use with chi-router
+func (b *AllowlistEntry) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Render implements the render.Renderer interface for use with chi-router
+func (b *AllowlistEvent) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Render implements the render.Renderer interface for use with chi-router
+func (b *FeeEstimate) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Render implements the render.Renderer interface for use with chi-router
+func (b *Deposit) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Render implements the render.Renderer interface for use with chi-router
+func (b *LiabilityRoot) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Render implements the render.Renderer interface for use with chi-router
+func (b *LiabilityProof) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Render implements the render.Renderer interface for use with chi-router
+func (b *Referral) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -5,7 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/easterthebunny/spew-order/internal/merkle"
 	"github.com/easterthebunny/spew-order/internal/persist"
 	"github.com/easterthebunny/spew-order/pkg/types"
 	"github.com/shopspring/decimal"
@@ -18,6 +22,12 @@ func OrderRequestFromBytes(b []byte) (or types.OrderRequest, err error) {
 		return
 	}
 
+	return OrderRequestFromAPI(o)
+}
+
+// OrderRequestFromAPI converts a decoded api order request to an internal order request
+func OrderRequestFromAPI(o OrderRequest) (or types.OrderRequest, err error) {
+
 	err = json.Unmarshal([]byte(fmt.Sprintf(`"%s"`, string(o.Base))), &or.Base)
 	if err != nil {
 		return
@@ -46,6 +56,10 @@ func OrderRequestFromBytes(b []byte) (or types.OrderRequest, err error) {
 
 	or.Type = ot
 
+	if o.ClientOrderId != nil {
+		or.ClientOrderID = *o.ClientOrderId
+	}
+
 	return
 }
 
@@ -98,6 +112,14 @@ func OrderTypeFromMap(m map[string]interface{}) (types.OrderType, error) {
 		}
 		ot.Quantity = q
 
+		if o.MaxDeviation != nil {
+			d, err := decimal.NewFromString(*o.MaxDeviation)
+			if err != nil {
+				return nil, err
+			}
+			ot.MaxDeviation = d
+		}
+
 		err = json.Unmarshal([]byte(fmt.Sprintf(`"%s"`, string(o.Base))), &ot.Base)
 		if err != nil {
 			return nil, err
@@ -108,6 +130,24 @@ func OrderTypeFromMap(m map[string]interface{}) (types.OrderType, error) {
 	}
 }
 
+// MarketFromString parses a trade pair in the form BASE-TARGET into its base
+// and target symbols
+func MarketFromString(m string) (base types.Symbol, target types.Symbol, err error) {
+	parts := strings.Split(strings.ToUpper(m), "-")
+	if len(parts) != 2 {
+		err = fmt.Errorf("invalid market '%s'", m)
+		return
+	}
+
+	base, err = types.FromString(parts[0])
+	if err != nil {
+		return
+	}
+
+	target, err = types.FromString(parts[1])
+	return
+}
+
 func BuildOrderRequest(or types.OrderRequest) OrderRequest {
 	out := OrderRequest{
 		Action: ActionType(or.Action.String()),
@@ -115,6 +155,11 @@ func BuildOrderRequest(or types.OrderRequest) OrderRequest {
 		Target: SymbolType(or.Target.String()),
 	}
 
+	if or.ClientOrderID != "" {
+		id := or.ClientOrderID
+		out.ClientOrderId = &id
+	}
+
 	switch tp := or.Type.(type) {
 	case *types.LimitOrderType:
 		out.Type = LimitOrderRequest{
@@ -124,11 +169,16 @@ func BuildOrderRequest(or types.OrderRequest) OrderRequest {
 			Quantity:  CurrencyValue(tp.Quantity.StringFixedBank(tp.Base.RoundingPlace())),
 		}
 	case *types.MarketOrderType:
-		out.Type = MarketOrderRequest{
+		mo := MarketOrderRequest{
 			OrderType: OrderType{Name: OrderTypeNameMARKET},
 			Base:      SymbolType(tp.Base.String()),
 			Quantity:  CurrencyValue(tp.Quantity.StringFixedBank(tp.Base.RoundingPlace())),
 		}
+		if !tp.MaxDeviation.IsZero() {
+			d := tp.MaxDeviation.String()
+			mo.MaxDeviation = &d
+		}
+		out.Type = mo
 	}
 
 	return out
@@ -144,6 +194,10 @@ func StringOrderStatus(f persist.FillStatus) OrderStatus {
 		return OrderStatusFILLED
 	case persist.StatusCanceled:
 		return OrderStatusCANCELLED
+	case persist.StatusRejected:
+		return OrderStatusREJECTED
+	case persist.StatusPartialCanceled:
+		return OrderStatusPARTIALCANCELLED
 	default:
 		return ""
 	}
@@ -159,11 +213,26 @@ func OrderStatusValue(f OrderStatus) persist.FillStatus {
 		return persist.StatusFilled
 	case OrderStatusCANCELLED:
 		return persist.StatusCanceled
+	case OrderStatusREJECTED:
+		return persist.StatusRejected
+	case OrderStatusPARTIALCANCELLED:
+		return persist.StatusPartialCanceled
 	default:
 		return 0
 	}
 }
 
+// OrderRejectReason returns the rejection reason for the order or nil if the
+// order was not rejected
+func OrderRejectReason(o *persist.Order) *string {
+	if o.Reason == "" {
+		return nil
+	}
+
+	reason := string(o.Reason)
+	return &reason
+}
+
 func StringTransactionType(t persist.TransactionType) TransactionType {
 	switch t {
 	case persist.DepositTransactionType:
@@ -172,7 +241,214 @@ func StringTransactionType(t persist.TransactionType) TransactionType {
 		return TransactionTypeORDER
 	case persist.TransferTransactionType:
 		return TransactionTypeTRANSFER
+	case persist.InternalTransactionType:
+		return TransactionTypeINTERNAL
+	case persist.FeeTransactionType:
+		return TransactionTypeFEE
+	case persist.GrantTransactionType:
+		return TransactionTypeGRANT
+	default:
+		return ""
+	}
+}
+
+func StringWithdrawalStatus(s persist.WithdrawalStatus) WithdrawalStatus {
+	switch s {
+	case persist.WithdrawalRequested:
+		return WithdrawalStatusREQUESTED
+	case persist.WithdrawalReview:
+		return WithdrawalStatusREVIEW
+	case persist.WithdrawalApproved:
+		return WithdrawalStatusAPPROVED
+	case persist.WithdrawalBroadcasting:
+		return WithdrawalStatusBROADCASTING
+	case persist.WithdrawalBroadcast:
+		return WithdrawalStatusBROADCAST
+	case persist.WithdrawalConfirmed:
+		return WithdrawalStatusCONFIRMED
+	case persist.WithdrawalFailed:
+		return WithdrawalStatusFAILED
+	case persist.WithdrawalRefunded:
+		return WithdrawalStatusREFUNDED
 	default:
 		return ""
 	}
 }
+
+// WithdrawalFromRecord converts a stored withdrawal to the api withdrawal
+func WithdrawalFromRecord(w *persist.Withdrawal) *Withdrawal {
+	out := &Withdrawal{
+		Id:       w.ID,
+		Symbol:   SymbolType(w.Symbol.String()),
+		Quantity: CurrencyValue(w.Amount.StringFixedBank(w.Symbol.RoundingPlace())),
+		Fee:      CurrencyValue(w.Fee.StringFixedBank(w.Symbol.RoundingPlace())),
+		Address:  w.Address,
+		Status:   StringWithdrawalStatus(w.Status),
+		Created:  time.Time(w.Created).Format(time.RFC3339),
+		Updated:  time.Time(w.Updated).Format(time.RFC3339),
+	}
+
+	if w.TransactionHash != "" {
+		hash := w.TransactionHash
+		out.TransactionHash = &hash
+	}
+
+	if w.Reason != "" {
+		reason := w.Reason
+		out.Reason = &reason
+	}
+
+	return out
+}
+
+// StringDepositStatus converts a stored deposit status to the api status
+func StringDepositStatus(s persist.DepositStatus) DepositStatus {
+	switch s {
+	case persist.DepositPending:
+		return DepositStatusPENDING
+	case persist.DepositCredited:
+		return DepositStatusCREDITED
+	default:
+		return ""
+	}
+}
+
+// DepositFromRecord converts a stored deposit to the api deposit along with the
+// confirmations needed to credit it
+func DepositFromRecord(d *persist.Deposit, required int) *Deposit {
+	return &Deposit{
+		Id:                    d.ID,
+		Symbol:                SymbolType(d.Symbol.String()),
+		Quantity:              CurrencyValue(d.Amount.StringFixedBank(d.Symbol.RoundingPlace())),
+		Address:               d.Address,
+		TransactionHash:       d.TransactionHash,
+		Confirmations:         d.Confirmations,
+		RequiredConfirmations: required,
+		Status:                StringDepositStatus(d.Status),
+		Created:               time.Time(d.Created).Format(time.RFC3339),
+		Updated:               time.Time(d.Updated).Format(time.RFC3339),
+	}
+}
+
+// AllowlistEntryFromRecord converts a stored allowlist entry to the api type. The entry
+// is active when its cooldown has passed at time t.
+func AllowlistEntryFromRecord(e *persist.AllowlistEntry, t time.Time) *AllowlistEntry {
+	out := &AllowlistEntry{
+		Id:       e.ID,
+		Symbol:   SymbolType(e.Symbol.String()),
+		Address:  e.Address,
+		Created:  time.Time(e.Created).Format(time.RFC3339),
+		ActiveAt: time.Time(e.ActiveAt).Format(time.RFC3339),
+		Active:   !t.Before(time.Time(e.ActiveAt)),
+	}
+
+	if e.Label != "" {
+		label := e.Label
+		out.Label = &label
+	}
+
+	return out
+}
+
+// AllowlistEventFromAudit converts an allowlist audit entry to the api type
+func AllowlistEventFromAudit(e *persist.AuditEntry) *AllowlistEvent {
+	out := &AllowlistEvent{
+		Id:        e.ID,
+		Type:      string(e.Type),
+		Timestamp: time.Time(e.Timestamp).Format(time.RFC3339),
+	}
+
+	if e.Reference != "" {
+		ref := e.Reference
+		out.EntryId = &ref
+	}
+
+	if e.Symbol != "" {
+		smb := e.Symbol
+		out.Symbol = &smb
+	}
+
+	if e.Detail != "" {
+		detail := e.Detail
+		out.Detail = &detail
+	}
+
+	return out
+}
+
+// BalanceHistoryFromSnapshots converts stored balance snapshots to the api balance history
+func BalanceHistoryFromSnapshots(snaps []*persist.BalanceSnapshot) BalanceHistory {
+	history := BalanceHistory{}
+	for _, snap := range snaps {
+		items := []SnapshotBalanceItem{}
+		for _, b := range snap.Balances {
+			items = append(items, SnapshotBalanceItem{
+				Symbol:    SymbolType(b.Symbol.String()),
+				Posted:    CurrencyValue(b.Posted.StringFixedBank(b.Symbol.RoundingPlace())),
+				Available: CurrencyValue(b.Available.StringFixedBank(b.Symbol.RoundingPlace())),
+			})
+		}
+
+		history = append(history, BalanceSnapshot{Date: snap.Date, Balances: items})
+	}
+
+	return history
+}
+
+// LiabilityRootFromRecord converts a stored liability tree to the published api root
+func LiabilityRootFromRecord(t *persist.LiabilityTree) *LiabilityRoot {
+	return &LiabilityRoot{
+		Id:        t.ID,
+		Timestamp: time.Time(t.Timestamp).Format(time.RFC3339),
+		Accounts:  t.Accounts,
+		Root:      LiabilityNodeFromMerkle(t.Root),
+	}
+}
+
+// LiabilityProofFromRecord converts a stored inclusion proof and its tree to the api type
+func LiabilityProofFromRecord(t *persist.LiabilityTree, p *persist.LiabilityProof) *LiabilityProof {
+	out := &LiabilityProof{
+		TreeId:      p.TreeID,
+		AccountHash: merkle.AccountHash(p.Account, p.Nonce),
+		Nonce:       p.Nonce,
+		Leaf:        LiabilityNodeFromMerkle(p.Proof.Leaf),
+		Path:        []LiabilityProofStep{},
+		Root:        LiabilityNodeFromMerkle(t.Root),
+	}
+
+	for _, s := range p.Proof.Path {
+		n := LiabilityNodeFromMerkle(s.Node)
+		out.Path = append(out.Path, LiabilityProofStep{Hash: n.Hash, Sums: n.Sums, Left: s.Left})
+	}
+
+	return out
+}
+
+// LiabilityNodeFromMerkle converts a tree node to the api type. Sums are written in full
+// precision and in symbol order so the node hash can be checked.
+func LiabilityNodeFromMerkle(n merkle.Node) LiabilityNode {
+	out := LiabilityNode{Hash: n.Hash, Sums: []LiabilitySum{}}
+	for name, amt := range n.Sums {
+		out.Sums = append(out.Sums, LiabilitySum{Symbol: SymbolType(name), Quantity: CurrencyValue(amt.String())})
+	}
+
+	sort.Slice(out.Sums, func(i, j int) bool {
+		return out.Sums[i].Symbol < out.Sums[j].Symbol
+	})
+
+	return out
+}
+
+// MerkleFromLiabilityNode converts an api tree node back to a node that can be verified
+func MerkleFromLiabilityNode(n LiabilityNode) (merkle.Node, error) {
+	out := merkle.Node{Hash: n.Hash, Sums: make(merkle.Sums)}
+	for _, s := range n.Sums {
+		amt, err := decimal.NewFromString(string(s.Quantity))
+		if err != nil {
+			return out, fmt.Errorf("invalid %s quantity '%s'", s.Symbol, s.Quantity)
+		}
+		out.Sums[string(s.Symbol)] = out.Sums[string(s.Symbol)].Add(amt)
+	}
+
+	return out, nil
+}
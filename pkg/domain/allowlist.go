@@ -0,0 +1,262 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+)
+
+var (
+	// AllowlistCooldown is how long a new allowlist address waits before it can be used
+	// and how long a request to turn off the allowlist waits before it takes effect
+	AllowlistCooldown = 24 * time.Hour
+	// MaxAllowlistLabelLength is the longest label that can be set on an allowlist entry
+	MaxAllowlistLabelLength = 64
+
+	ErrAddressNotAllowed      = errors.New("withdrawal address is not an active allowlist address")
+	ErrInvalidAddress         = errors.New("invalid address")
+	ErrAllowlistEntryExists   = errors.New("address is already on the allowlist")
+	ErrAllowlistLabelLength   = fmt.Errorf("allowlist label limited to %d characters", MaxAllowlistLabelLength)
+	ErrAllowlistEntryNotFound = errors.New("allowlist entry not found")
+)
+
+// AllowlistEnforced reports whether withdrawals from the account are limited to
+// allowlist addresses at time t
+func (a Account) AllowlistEnforced(t time.Time) bool {
+	return a.Allowlist && (a.AllowlistDisableAt.IsZero() || t.Before(a.AllowlistDisableAt))
+}
+
+func (a *Account) setAllowlist(p *persist.Account) {
+	a.Allowlist = p.Allowlist
+	a.AllowlistDisableAt = time.Time{}
+	if p.AllowlistDisableAt != nil {
+		a.AllowlistDisableAt = time.Time(*p.AllowlistDisableAt)
+	}
+}
+
+// GetAllowlist returns the allowlist entries on the account, oldest first
+func (m *BalanceManager) GetAllowlist(ctx context.Context, a *Account) ([]*persist.AllowlistEntry, error) {
+	list, err := m.acct.Allowlist(&persist.Account{ID: a.ID.String()}).GetAllowlist(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("BalanceManager::GetAllowlist::%w", err)
+	}
+
+	return list, nil
+}
+
+// AddAllowlistEntry adds a withdrawal address to the account allowlist. The address
+// becomes usable once the cooldown has passed.
+func (m *BalanceManager) AddAllowlistEntry(ctx context.Context, au persist.AuditRepository, a *Account, s types.Symbol, address string, label string) (*persist.AllowlistEntry, error) {
+
+	address = strings.TrimSpace(address)
+	if address == "" || !s.ValidateAddress(address) {
+		return nil, ErrInvalidAddress
+	}
+
+	if len(label) > MaxAllowlistLabelLength {
+		return nil, ErrAllowlistLabelLength
+	}
+
+	list, err := m.GetAllowlist(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range list {
+		if e.Symbol == s && e.Address == address {
+			return nil, ErrAllowlistEntryExists
+		}
+	}
+
+	e := persist.NewAllowlistEntry(s, address, AllowlistCooldown)
+	e.Label = label
+
+	err = m.acct.Allowlist(&persist.Account{ID: a.ID.String()}).SetAllowlistEntry(ctx, e)
+	if err != nil {
+		return nil, fmt.Errorf("BalanceManager::AddAllowlistEntry::%w", err)
+	}
+
+	err = auditAllowlist(ctx, au, persist.AllowlistAddedAuditType, a, e,
+		fmt.Sprintf("added %s usable from %s", address, time.Time(e.ActiveAt).UTC().Format(time.RFC3339)))
+	if err != nil {
+		return nil, fmt.Errorf("BalanceManager::AddAllowlistEntry::%w", err)
+	}
+
+	return e, nil
+}
+
+// LabelAllowlistEntry sets the display label of an allowlist entry
+func (m *BalanceManager) LabelAllowlistEntry(ctx context.Context, au persist.AuditRepository, a *Account, id persist.Key, label string) (*persist.AllowlistEntry, error) {
+
+	if len(label) > MaxAllowlistLabelLength {
+		return nil, ErrAllowlistLabelLength
+	}
+
+	e, err := m.getAllowlistEntry(ctx, a, id)
+	if err != nil {
+		return nil, err
+	}
+
+	prev := e.Label
+	e.Label = label
+
+	err = m.acct.Allowlist(&persist.Account{ID: a.ID.String()}).SetAllowlistEntry(ctx, e)
+	if err != nil {
+		return nil, fmt.Errorf("BalanceManager::LabelAllowlistEntry::%w", err)
+	}
+
+	err = auditAllowlist(ctx, au, persist.AllowlistLabeledAuditType, a, e,
+		fmt.Sprintf("label changed from '%s' to '%s'", prev, label))
+	if err != nil {
+		return nil, fmt.Errorf("BalanceManager::LabelAllowlistEntry::%w", err)
+	}
+
+	return e, nil
+}
+
+// RemoveAllowlistEntry removes an address from the account allowlist. Removal takes
+// effect immediately.
+func (m *BalanceManager) RemoveAllowlistEntry(ctx context.Context, au persist.AuditRepository, a *Account, id persist.Key) error {
+
+	e, err := m.getAllowlistEntry(ctx, a, id)
+	if err != nil {
+		return err
+	}
+
+	err = m.acct.Allowlist(&persist.Account{ID: a.ID.String()}).DeleteAllowlistEntry(ctx, id)
+	if err != nil {
+		return fmt.Errorf("BalanceManager::RemoveAllowlistEntry::%w", err)
+	}
+
+	err = auditAllowlist(ctx, au, persist.AllowlistRemovedAuditType, a, e, fmt.Sprintf("removed %s", e.Address))
+	if err != nil {
+		return fmt.Errorf("BalanceManager::RemoveAllowlistEntry::%w", err)
+	}
+
+	return nil
+}
+
+// EnableAllowlist limits withdrawals from the account to active allowlist addresses.
+// Enabling takes effect immediately and cancels a pending request to turn it off.
+func (m *BalanceManager) EnableAllowlist(ctx context.Context, au persist.AuditRepository, a *Account) error {
+
+	err := m.updateAccount(ctx, a, func(p *persist.Account) error {
+		p.Allowlist = true
+		p.AllowlistDisableAt = nil
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return auditAllowlist(ctx, au, persist.AllowlistEnabledAuditType, a, nil, "allowlist enabled")
+}
+
+// DisableAllowlist turns off the allowlist once the cooldown has passed. Withdrawals
+// stay limited to allowlist addresses until then.
+func (m *BalanceManager) DisableAllowlist(ctx context.Context, au persist.AuditRepository, a *Account) error {
+
+	var at time.Time
+	err := m.updateAccount(ctx, a, func(p *persist.Account) error {
+		if !p.Allowlist || p.AllowlistDisableAt != nil {
+			return nil
+		}
+
+		at = time.Now().Add(AllowlistCooldown)
+		t := persist.NanoTime(at)
+		p.AllowlistDisableAt = &t
+		return nil
+	})
+	if err != nil || at.IsZero() {
+		return err
+	}
+
+	return auditAllowlist(ctx, au, persist.AllowlistDisabledAuditType, a, nil,
+		fmt.Sprintf("allowlist disabled from %s", at.UTC().Format(time.RFC3339)))
+}
+
+// GetAllowlistHistory returns the audit trail of allowlist changes on the account
+func (m *BalanceManager) GetAllowlistHistory(ctx context.Context, au persist.AuditRepository, a *Account) ([]*persist.AuditEntry, error) {
+	entries, err := au.GetAccountAuditEntries(ctx, a.ID.String())
+	if err != nil {
+		return nil, fmt.Errorf("BalanceManager::GetAllowlistHistory::%w", err)
+	}
+
+	history := []*persist.AuditEntry{}
+	for _, e := range entries {
+		switch e.Type {
+		case persist.AllowlistAddedAuditType,
+			persist.AllowlistLabeledAuditType,
+			persist.AllowlistRemovedAuditType,
+			persist.AllowlistEnabledAuditType,
+			persist.AllowlistDisabledAuditType:
+			history = append(history, e)
+		}
+	}
+
+	return history, nil
+}
+
+// checkAllowlist returns ErrAddressNotAllowed when the account allowlist is enforced
+// and the address is not an active entry for the symbol
+func (m *BalanceManager) checkAllowlist(ctx context.Context, a *Account, s types.Symbol, address string) error {
+
+	p, err := m.acct.Find(ctx, a.ID)
+	if err != nil {
+		if errors.Is(err, persist.ErrObjectNotExist) {
+			return nil
+		}
+		return fmt.Errorf("BalanceManager::checkAllowlist::%w", err)
+	}
+
+	state := Account{}
+	state.setAllowlist(p)
+
+	now := time.Now()
+	if !state.AllowlistEnforced(now) {
+		return nil
+	}
+
+	list, err := m.GetAllowlist(ctx, a)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range list {
+		if e.Symbol == s && e.Address == address && !now.Before(time.Time(e.ActiveAt)) {
+			return nil
+		}
+	}
+
+	return ErrAddressNotAllowed
+}
+
+func (m *BalanceManager) getAllowlistEntry(ctx context.Context, a *Account, id persist.Key) (*persist.AllowlistEntry, error) {
+	e, err := m.acct.Allowlist(&persist.Account{ID: a.ID.String()}).GetAllowlistEntry(ctx, id)
+	if err != nil {
+		if errors.Is(err, persist.ErrObjectNotExist) {
+			return nil, ErrAllowlistEntryNotFound
+		}
+		return nil, fmt.Errorf("BalanceManager::getAllowlistEntry::%w", err)
+	}
+
+	return e, nil
+}
+
+func auditAllowlist(ctx context.Context, au persist.AuditRepository, t persist.AuditEntryType, a *Account, e *persist.AllowlistEntry, detail string) error {
+	entry := persist.NewAuditEntry(t)
+	entry.Account = a.ID.String()
+	entry.Detail = detail
+
+	if e != nil {
+		entry.Symbol = e.Symbol.String()
+		entry.Reference = e.ID
+	}
+
+	return au.SetAuditEntry(ctx, entry)
+}
@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/funding"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/kv"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowlist(t *testing.T) {
+	st := persist.NewMockKVStore()
+	au := kv.NewAuditRepository(st)
+	bm := NewBalanceManager(kv.NewAccountRepository(st), kv.NewLedgerRepository(st), funding.NewMockSource())
+	ctx := context.Background()
+
+	pending := "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"
+	active := "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"
+
+	acct, err := bm.GetAccount(ctx, uuid.NewV4().String())
+	assert.NoError(t, err)
+	assert.NoError(t, bm.PostAmtToBalance(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(10)))
+
+	_, err = bm.AddAllowlistEntry(ctx, au, acct, types.SymbolBitcoin, "not an address", "")
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+
+	e, err := bm.AddAllowlistEntry(ctx, au, acct, types.SymbolBitcoin, pending, "cold storage")
+	assert.NoError(t, err)
+	assert.True(t, time.Time(e.ActiveAt).After(time.Now()))
+
+	_, err = bm.AddAllowlistEntry(ctx, au, acct, types.SymbolBitcoin, pending, "")
+	assert.ErrorIs(t, err, ErrAllowlistEntryExists)
+
+	// withdrawals are not restricted until the allowlist is enabled
+	_, err = bm.WithdrawFunds(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(1), active, "")
+	assert.NoError(t, err)
+
+	assert.NoError(t, bm.EnableAllowlist(ctx, au, acct))
+	assert.True(t, acct.Allowlist)
+
+	_, err = bm.WithdrawFunds(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(1), active, "")
+	assert.ErrorIs(t, err, ErrAddressNotAllowed)
+
+	// addresses in their cooldown cannot be used yet
+	_, err = bm.WithdrawFunds(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(1), pending, "")
+	assert.ErrorIs(t, err, ErrAddressNotAllowed)
+
+	defer func(d time.Duration) { AllowlistCooldown = d }(AllowlistCooldown)
+	AllowlistCooldown = 0
+
+	a, err := bm.AddAllowlistEntry(ctx, au, acct, types.SymbolBitcoin, active, "")
+	assert.NoError(t, err)
+
+	_, err = bm.WithdrawFunds(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(1), active, "")
+	assert.NoError(t, err)
+
+	// an address allowed for one symbol is not allowed for another
+	assert.NoError(t, bm.PostAmtToBalance(ctx, acct, types.SymbolBitcoinCash, decimal.NewFromInt(1)))
+	_, err = bm.WithdrawFunds(ctx, acct, types.SymbolBitcoinCash, decimal.NewFromInt(1), active, "")
+	assert.ErrorIs(t, err, ErrAddressNotAllowed)
+
+	a, err = bm.LabelAllowlistEntry(ctx, au, acct, ky(a.ID), "exchange")
+	assert.NoError(t, err)
+	assert.Equal(t, "exchange", a.Label)
+
+	assert.NoError(t, bm.RemoveAllowlistEntry(ctx, au, acct, ky(a.ID)))
+	assert.ErrorIs(t, bm.RemoveAllowlistEntry(ctx, au, acct, ky(a.ID)), ErrAllowlistEntryNotFound)
+
+	_, err = bm.WithdrawFunds(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(1), active, "")
+	assert.ErrorIs(t, err, ErrAddressNotAllowed)
+
+	list, err := bm.GetAllowlist(ctx, acct)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	// turning the allowlist off waits for the cooldown
+	AllowlistCooldown = time.Hour
+	assert.NoError(t, bm.DisableAllowlist(ctx, au, acct))
+	assert.False(t, acct.AllowlistDisableAt.IsZero())
+
+	_, err = bm.WithdrawFunds(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(1), active, "")
+	assert.ErrorIs(t, err, ErrAddressNotAllowed)
+
+	history, err := bm.GetAllowlistHistory(ctx, au, acct)
+	assert.NoError(t, err)
+
+	var kinds []persist.AuditEntryType
+	for _, h := range history {
+		kinds = append(kinds, h.Type)
+	}
+
+	assert.ElementsMatch(t, []persist.AuditEntryType{
+		persist.AllowlistAddedAuditType,
+		persist.AllowlistEnabledAuditType,
+		persist.AllowlistAddedAuditType,
+		persist.AllowlistLabeledAuditType,
+		persist.AllowlistRemovedAuditType,
+		persist.AllowlistDisabledAuditType,
+	}, kinds)
+}
@@ -15,23 +15,33 @@ import (
 
 var (
 	ErrInsufficientBalanceForHold = errors.New("account balance too low for hold")
-	FundNewAccounts               = true
-	NewAccountFunds               = decimal.NewFromInt(5000)
 )
 
 func NewBalanceManager(repo persist.AccountRepository, l persist.LedgerRepository, f ...funding.Source) *BalanceManager {
-	return &BalanceManager{acct: repo, ledger: l, funding: f}
+	return &BalanceManager{acct: repo, ledger: l, funding: f, campaigns: &campaignCache{}}
 }
 
 type BalanceManager struct {
-	acct    persist.AccountRepository
-	ledger  persist.LedgerRepository
-	funding []funding.Source
+	acct      persist.AccountRepository
+	ledger    persist.LedgerRepository
+	funding   []funding.Source
+	campaigns *campaignCache
 }
 
 // GetAccount searches the persistance layer for an account. If one doesn't
 // exist, it creates one.
-func (m *BalanceManager) GetAccount(ctx context.Context, id string) (a *Account, err error) {
+func (m *BalanceManager) GetAccount(ctx context.Context, id string) (*Account, error) {
+	return m.getAccount(ctx, id, "")
+}
+
+// GetOwnedAccount is GetAccount for an account held by the authorization. The
+// authorization is saved as the account owner when the account is created or when an
+// account created before owners were saved is loaded.
+func (m *BalanceManager) GetOwnedAccount(ctx context.Context, authz *persist.Authorization, id string) (*Account, error) {
+	return m.getAccount(ctx, id, authz.ID)
+}
+
+func (m *BalanceManager) getAccount(ctx context.Context, id, owner string) (a *Account, err error) {
 
 	a = NewAccount()
 	uid, err := uuid.FromString(id)
@@ -58,6 +68,15 @@ func (m *BalanceManager) GetAccount(ctx context.Context, id string) (a *Account,
 		}
 	}
 
+	if owner != "" && p.Owner == "" {
+		p.Owner = owner
+		dirty = true
+	}
+
+	a.Label = p.Label
+	a.Archived = p.Archived
+	a.Tier = AccountTier(p.Tier)
+	a.setAllowlist(p)
 	for _, k := range p.Addresses {
 		a.Addresses[k.Symbol] = k.Address
 	}
@@ -74,6 +93,12 @@ func (m *BalanceManager) GetAccount(ctx context.Context, id string) (a *Account,
 		a.Balances[s] = bal
 	}
 
+	a.Pending, err = m.GetPendingBalances(ctx, a)
+	if err != nil {
+		err = fmt.Errorf("BalanceManager::GetAccount.GetPendingBalances::%w", err)
+		return nil, err
+	}
+
 	// only save the value once; protect against rapid back to back updates
 	if dirty {
 		if err := m.acct.Save(ctx, p); err != nil {
@@ -81,13 +106,8 @@ func (m *BalanceManager) GetAccount(ctx context.Context, id string) (a *Account,
 			return nil, err
 		}
 
-		// fund new accounts on create
-		if accountCreated && FundNewAccounts {
-
-			acct := &persist.Account{ID: a.ID.String()}
-			if err := m.fundAccount(ctx, acct, types.SymbolCipherMtn, NewAccountFunds); err != nil {
-				return nil, err
-			}
+		if accountCreated {
+			m.rewardSignUp(ctx, p)
 		}
 	}
 
@@ -185,47 +205,19 @@ func (m *BalanceManager) GetPostedBalance(ctx context.Context, a *Account, s typ
 	return
 }
 
-// SetHoldOnAccount places a hold on the account and Symbol specified
-// in the case of an insufficient balance, the hold will be removed and
-// an error returned.
+// SetHoldOnAccount places a hold on the account and Symbol specified. The hold
+// is only created if the available balance covers it; otherwise an
+// ErrInsufficientBalanceForHold error is returned.
 func (m *BalanceManager) SetHoldOnAccount(ctx context.Context, a *Account, s types.Symbol, amt decimal.Decimal) (holdid string, err error) {
 
 	acct := &persist.Account{ID: a.ID.String()}
 	r := m.acct.Balances(acct, s)
 	newHold := persist.NewBalanceItem(amt)
-	err = r.CreateHold(ctx, newHold)
+	err = r.CreateHoldIfAvailable(ctx, newHold)
 	if err != nil {
-		return
-	}
-
-	balance, err := m.GetPostedBalance(ctx, a, s)
-	if err != nil {
-		return
-	}
-
-	activeHolds, err := r.FindHolds(ctx)
-	if err != nil {
-		return
-	}
-
-	for _, hold := range activeHolds {
-		balance = balance.Sub(hold.Amount)
-
-		// only calculate holds up to the point of the new hold
-		// more holds could have been added in another thread
-		if hold.ID == newHold.ID {
-			break
-		}
-	}
-
-	if balance.LessThan(decimal.NewFromInt(0)) {
-
-		err = r.DeleteHold(ctx, ky(newHold.ID))
-		if err != nil {
-			return
+		if errors.Is(err, persist.ErrInsufficientBalance) {
+			err = ErrInsufficientBalanceForHold
 		}
-
-		err = ErrInsufficientBalanceForHold
 		return
 	}
 
@@ -258,74 +250,6 @@ func (m *BalanceManager) PostAmtToBalance(ctx context.Context, a *Account, s typ
 	return r.AddToBalance(ctx, amt)
 }
 
-func (m *BalanceManager) WithdrawFunds(ctx context.Context, a *Account, s types.Symbol, amt decimal.Decimal, hash string) (t *persist.Transaction, err error) {
-
-	switch s {
-	case types.SymbolCipherMtn:
-		return nil, fmt.Errorf("unsupported withdrawal: %s", s)
-	}
-
-	hid, err := m.SetHoldOnAccount(ctx, a, s, amt)
-	if err != nil {
-		return
-	}
-
-	tr := funding.Transaction{
-		Symbol:  s,
-		Address: hash,
-		Amount:  amt,
-	}
-
-	var src funding.Source
-	for _, check := range m.funding {
-		if check.Supports(s) {
-			src = check
-			break
-		}
-	}
-
-	if src == nil {
-		return nil, fmt.Errorf("supported funding source not available for %s", s)
-	}
-
-	trhash, err := src.Withdraw(&tr)
-	if err != nil {
-		return
-	}
-
-	err = m.PostAmtToBalance(ctx, a, s, amt.Mul(decimal.NewFromInt(-1)))
-	if err != nil {
-		return
-	}
-
-	err = m.RemoveHoldOnAccount(ctx, a, s, ky(hid))
-	if err != nil {
-		return
-	}
-
-	var tm = persist.NanoTime(time.Now())
-	trepo := m.acct.Transactions(&persist.Account{ID: a.ID.String()})
-	t = &persist.Transaction{
-		Type:            persist.TransferTransactionType,
-		TransactionHash: trhash,
-		AddressHash:     hash,
-		Symbol:          s.String(),
-		Quantity:        amt.Mul(decimal.NewFromInt(-1)).StringFixedBank(s.RoundingPlace()),
-		Timestamp:       tm,
-	}
-	err = trepo.SetTransaction(ctx, t)
-	if err != nil {
-		return
-	}
-
-	err = m.ledger.RecordTransfer(ctx, s, amt)
-	if err != nil {
-		return
-	}
-
-	return
-}
-
 func (m *BalanceManager) FundAccountByID(ctx context.Context, id uuid.UUID, s types.Symbol, amt decimal.Decimal) error {
 
 	a, err := m.acct.Find(ctx, id)
@@ -365,57 +289,6 @@ func (m *BalanceManager) fundAccount(ctx context.Context, a *persist.Account, s
 	return nil
 }
 
-func (m *BalanceManager) FundAccountByAddress(ctx context.Context, hash string, transaction string, s types.Symbol, amt decimal.Decimal) error {
-
-	a, err := m.acct.FindByAddress(ctx, hash, s)
-	if err != nil {
-		return err
-	}
-
-	if a == nil {
-		return fmt.Errorf("no address found for hash '%s' and symbol '%s' with value '%v'", hash, s, s)
-	}
-
-	r := m.acct.Balances(a, s)
-	err = r.AddToBalance(ctx, amt)
-	if err != nil {
-		return err
-	}
-
-	var tm = persist.NanoTime(time.Now())
-	trepo := m.acct.Transactions(a)
-
-	existing, err := trepo.GetTransactions(ctx)
-	if err != nil {
-		return err
-	}
-
-	for _, tr := range existing {
-		if tr.TransactionHash == transaction {
-			return fmt.Errorf("transaction hash already processed")
-		}
-	}
-
-	err = trepo.SetTransaction(ctx, &persist.Transaction{
-		Type:            persist.DepositTransactionType,
-		TransactionHash: transaction,
-		AddressHash:     hash,
-		Symbol:          s.String(),
-		Quantity:        amt.StringFixedBank(s.RoundingPlace()),
-		Timestamp:       tm,
-	})
-	if err != nil {
-		return err
-	}
-
-	err = m.ledger.RecordDeposit(ctx, s, amt)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
 // PostTransactionToBalance creates balance updates and transaction records in the appropriate
 // accounts and adds fee payments to the general ledger
 func (m *BalanceManager) PostTransactionToBalance(ctx context.Context, t *types.Transaction) error {
@@ -497,6 +370,19 @@ func (m *BalanceManager) makeOrderRecords(ctx context.Context, entry types.Balan
 		return err
 	}
 
+	// journal the trade against the customer liability sub-account
+	err = m.ledger.RecordTrade(ctx, persist.TradeLeg{
+		ID:        fmt.Sprintf("%s-%d", entry.Order.ID, t.UnixNano()),
+		Account:   acct.ID,
+		AddSymbol: entry.AddSymbol,
+		AddAmount: entry.AddQuantity,
+		SubSymbol: entry.SubSymbol,
+		SubAmount: entry.SubQuantity,
+	})
+	if err != nil {
+		return err
+	}
+
 	if entry.FeeQuantity.GreaterThan(decimal.NewFromInt(0)) {
 
 		// for flat fees apply the amount
@@ -524,19 +410,148 @@ func (m *BalanceManager) makeOrderRecords(ctx context.Context, entry types.Balan
 		return err
 	}
 
+	m.rewardTrade(ctx, entry)
+
 	return nil
 }
 
-// CreateOrder inserts an order into the provided account as an open order
+// CreateOrder inserts an order into the provided account as an open order. An empty
+// order is returned when the order could not be saved.
 func (m *BalanceManager) CreateOrder(ctx context.Context, a *Account, req types.OrderRequest) (types.Order, error) {
 	rep := m.acct.Orders(&persist.Account{ID: a.ID.String()})
 
 	order := types.NewOrderFromRequest(req)
 	err := rep.SetOrder(ctx, &persist.Order{Status: persist.StatusOpen, Base: order, Transactions: [][]string{}})
+	if err != nil {
+		return types.Order{}, err
+	}
+
+	if req.ClientOrderID != "" {
+		err = m.completeKey(ctx, a, persist.OrderIdempotencyType, req.ClientOrderID, order.ID.String())
+	}
 
 	return order, err
 }
 
+// GetOrdersByStatus returns all orders on the account that are in any of the
+// provided states
+func (m *BalanceManager) GetOrdersByStatus(ctx context.Context, a *Account, s ...persist.FillStatus) ([]*persist.Order, error) {
+	rep := m.acct.Orders(&persist.Account{ID: a.ID.String()})
+
+	orders, err := rep.GetOrdersByStatus(ctx, s...)
+	if err != nil {
+		return nil, fmt.Errorf("BalanceManager::GetOrdersByStatus::%w", err)
+	}
+
+	return orders, nil
+}
+
+// RejectOrder marks the order rejected with the provided reason and releases any
+// holds still placed for it. Orders already closed are left unchanged.
+func (m *BalanceManager) RejectOrder(ctx context.Context, order types.Order, reason persist.RejectReason) error {
+	var err error
+
+	rep := m.acct.Orders(&persist.Account{ID: order.Account.String()})
+	if rep == nil {
+		return errors.New("RejectOrder: unknown order acount")
+	}
+
+	po, err := rep.GetOrder(ctx, order.ID)
+	if err != nil {
+		return fmt.Errorf("RejectOrder::OrderRepository::%w", err)
+	}
+
+	switch po.Status {
+	case persist.StatusFilled, persist.StatusCanceled, persist.StatusRejected, persist.StatusPartialCanceled:
+		return nil
+	}
+
+	err = rep.RejectOrder(ctx, order.ID, reason)
+	if err != nil {
+		return fmt.Errorf("RejectOrder::OrderRepository::%w", err)
+	}
+
+	// holds may already be gone if part of the order was processed
+	smb, _ := order.Type.HoldAmount(order.Action, order.Base, order.Target)
+	err = m.RemoveHoldOnAccount(ctx, &Account{ID: order.Account}, smb, ky(order.HoldID))
+	if err != nil && !errors.Is(err, persist.ErrObjectNotExist) {
+		return fmt.Errorf("RejectOrder::RemoveHoldOnAccount::%w", err)
+	}
+
+	if order.FeeHoldID != "" {
+		err = m.RemoveHoldOnAccount(ctx, &Account{ID: order.Account}, types.SymbolCipherMtn, ky(order.FeeHoldID))
+		if err != nil && !errors.Is(err, persist.ErrObjectNotExist) {
+			return fmt.Errorf("RejectOrder::RemoveHoldOnAccount::%w", err)
+		}
+	}
+
+	return nil
+}
+
+// FlagOrder records the reason on an order that failed after part of it was
+// settled. The order keeps its status and holds so it can be found and resolved
+// by hand. Orders already closed are left unchanged.
+func (m *BalanceManager) FlagOrder(ctx context.Context, order types.Order, reason persist.RejectReason) error {
+	rep := m.acct.Orders(&persist.Account{ID: order.Account.String()})
+	if rep == nil {
+		return errors.New("FlagOrder: unknown order acount")
+	}
+
+	po, err := rep.GetOrder(ctx, order.ID)
+	if err != nil {
+		return fmt.Errorf("FlagOrder::OrderRepository::%w", err)
+	}
+
+	if po.Status != persist.StatusOpen && po.Status != persist.StatusPartial {
+		return nil
+	}
+
+	err = rep.FlagOrder(ctx, order.ID, reason)
+	if err != nil {
+		return fmt.Errorf("FlagOrder::OrderRepository::%w", err)
+	}
+
+	return nil
+}
+
+// CancelRemainder closes out the unfilled portion of an order that is not
+// allowed to rest on the book. Remaining holds are released and the order is
+// marked partially canceled when any part of it matched, otherwise canceled.
+func (m *BalanceManager) CancelRemainder(ctx context.Context, order types.Order, filled bool) error {
+	var err error
+
+	rep := m.acct.Orders(&persist.Account{ID: order.Account.String()})
+	if rep == nil {
+		return errors.New("CancelRemainder: unknown order acount")
+	}
+
+	smb, _ := order.Type.HoldAmount(order.Action, order.Base, order.Target)
+	err = m.RemoveHoldOnAccount(ctx, &Account{ID: order.Account}, smb, ky(order.HoldID))
+	if err != nil {
+		return fmt.Errorf("CancelRemainder::RemoveHoldOnAccount::%w", err)
+	}
+
+	if order.FeeHoldID != "" {
+		err = m.RemoveHoldOnAccount(ctx, &Account{ID: order.Account}, types.SymbolCipherMtn, ky(order.FeeHoldID))
+		if err != nil {
+			return fmt.Errorf("CancelRemainder::RemoveHoldOnAccount::%w", err)
+		}
+	}
+
+	// the status is only final once the holds are released
+	status := persist.StatusCanceled
+	if filled {
+		status = persist.StatusPartialCanceled
+	}
+
+	err = rep.UpdateOrderStatus(ctx, order.ID, status, []string{})
+	if err != nil {
+		return fmt.Errorf("CancelRemainder::OrderRepository::%w", err)
+	}
+
+	return nil
+}
+
 // CancelOrder cancels an order and removes any associated holds
 func (m *BalanceManager) CancelOrder(ctx context.Context, order types.Order) error {
 	var err error
@@ -140,3 +140,41 @@ func newSeededRepo() (persist.AccountRepository, persist.LedgerRepository, fundi
 
 	return repo, l, f
 }
+
+func TestWithdrawFunds_IdempotencyKey(t *testing.T) {
+
+	service := NewBalanceManager(newSeededRepo())
+	ctx := context.Background()
+	amt := decimal.NewFromFloat(1.5)
+
+	first, err := service.WithdrawFunds(ctx, a, types.SymbolBitcoin, amt, "address", "withdrawal-key")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	second, err := service.WithdrawFunds(ctx, a, types.SymbolBitcoin, amt, "address", "withdrawal-key")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if first.ID != second.ID {
+		t.Errorf("repeated withdrawal returned a new withdrawal")
+	}
+
+	// the balance should only be held for the first withdrawal
+	bal, err := service.GetAvailableBalance(ctx, a, types.SymbolBitcoin)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := decimal.NewFromFloat(5.72694568)
+	if !bal.Equal(expected) {
+		t.Errorf("unexpected balance %s; expected %s", bal.StringFixed(8), expected.StringFixed(8))
+	}
+
+	// a key used for a withdrawal cannot be used for an order
+	_, err = service.ReserveOrderKey(ctx, a, "withdrawal-key")
+	if err != ErrIdempotencyKeyReused {
+		t.Errorf("expected error [%s]; found %v", ErrIdempotencyKeyReused, err)
+	}
+}
@@ -5,19 +5,30 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/easterthebunny/spew-order/internal/persist"
 	"github.com/easterthebunny/spew-order/internal/persist/firebase"
 	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
 )
 
+var (
+	// ErrBookItemExists indicates the order was already processed and rests on the book
+	ErrBookItemExists = errors.New("book item exists")
+)
+
+// headBatchSize is the number of book items read from the head of the book at a time
+const headBatchSize = 10
+
 type OrderBook struct {
 	bir persist.BookRepository
 	bm  *BalanceManager
+	mr  persist.MarketRepository
 }
 
-func NewOrderBook(br persist.BookRepository, bm *BalanceManager) *OrderBook {
-	return &OrderBook{bir: br, bm: bm}
+func NewOrderBook(br persist.BookRepository, bm *BalanceManager, mr persist.MarketRepository) *OrderBook {
+	return &OrderBook{bir: br, bm: bm, mr: mr}
 }
 
 func (ob *OrderBook) CancelOrder(ctx context.Context, order types.Order) error {
@@ -42,28 +53,89 @@ func (ob *OrderBook) CancelOrder(ctx context.Context, order types.Order) error {
 	return nil
 }
 
+// ExecuteOrReject runs the order through ExecuteOrInsertOrder. If the order cannot be
+// processed, it is marked rejected with a reason and its holds are released so that
+// the funds are not locked by an order that will never execute. A repeated delivery
+// of an order that already rests on the book is ignored. An order that failed because
+// the book was unavailable is returned as an error so the message is delivered again,
+// and an order that failed after part of it was settled keeps its holds and is
+// flagged with the reason for review.
+func (ob *OrderBook) ExecuteOrReject(ctx context.Context, order types.Order) error {
+	err := ob.ExecuteOrInsertOrder(ctx, order)
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, ErrBookItemExists) {
+		log.Printf("ignoring order already on the book: %s", order.ID)
+		return nil
+	}
+
+	reason := persist.RejectReasonProcessingFailed
+	var rej *rejection
+	if errors.As(err, &rej) {
+		reason = rej.reason
+
+		// processing the order again would settle the same fills twice and
+		// rejecting it would release holds the settled fills still need
+		if rej.settled {
+			log.Printf("order %s failed after part of it was settled; flagging it for review: %s", order.ID, err)
+			// an error would deliver the order again and settle the same fills twice
+			if ferr := ob.bm.FlagOrder(ctx, order, reason); ferr != nil {
+				log.Printf("could not flag order %s for review: %s", order.ID, ferr)
+			}
+			return nil
+		}
+	}
+
+	if reason == persist.RejectReasonBookUnavailable {
+		return fmt.Errorf("ExecuteOrReject::%w", err)
+	}
+
+	log.Printf("rejecting order %s with reason %s: %s", order.ID, reason, err)
+	if rerr := ob.bm.RejectOrder(ctx, order, reason); rerr != nil {
+		return fmt.Errorf("ExecuteOrReject::%s::%w", err, rerr)
+	}
+
+	return nil
+}
+
 // ExecuteOrInsertOrder takes an order and matches it from top down in the order
 // book. This process will create account balance updates and update/delete
 // account holds. It assumes holds exist and will return an error if they don't.
-func (ob *OrderBook) ExecuteOrInsertOrder(ctx context.Context, order types.Order) error {
+func (ob *OrderBook) ExecuteOrInsertOrder(ctx context.Context, order types.Order) (err error) {
 	item := persist.NewBookItem(order)
 
+	// market orders never rest on the book; track whether any part of the
+	// order was matched and the price of the first match for the slippage cap
+	var filled bool
+	var reference decimal.Decimal
+
+	// a rejection after a match was settled cannot be undone by a reject; a
+	// failed settlement may have written part of its records as well
+	defer func() {
+		var rej *rejection
+		if errors.As(err, &rej) && (filled || rej.reason == persist.RejectReasonSettlementFailed) {
+			rej.settled = true
+		}
+	}()
+
 	ok, err := ob.bir.BookItemExists(ctx, &item)
 	if err != nil {
-		return fmt.Errorf("ExecuteOrInsertOrder::exist check::%w", err)
+		return reject(persist.RejectReasonBookUnavailable, fmt.Errorf("ExecuteOrInsertOrder::exist check::%w", err))
 	}
 
 	// maintain this function as idempotent and don't run the same action twice
 	// for the same record
 	if ok {
-		return fmt.Errorf("action not allowed; book item exists: %s", order.ID)
+		return fmt.Errorf("action not allowed; %w: %s", ErrBookItemExists, order.ID)
 	}
 
 	var offset *persist.BookItem
 	for {
-		batch, err := ob.bir.GetHeadBatch(ctx, &item, 10, offset)
+		batch, err := ob.bir.GetHeadBatch(ctx, &item, headBatchSize, offset)
 		if err != nil {
-			return fmt.Errorf("ExecuteOrInsertOrder::head batch::%w", err)
+			return reject(persist.RejectReasonBookUnavailable, fmt.Errorf("ExecuteOrInsertOrder::head batch::%w", err))
 		}
 
 		// in the following cases, run the batch loop again
@@ -81,6 +153,10 @@ func (ob *OrderBook) ExecuteOrInsertOrder(ctx context.Context, order types.Order
 				continue
 			}
 
+			if exceedsDeviation(order, bookOrder, &reference) {
+				return ob.cancelRemainder(ctx, order, filled)
+			}
+
 			tr, o := bookOrder.Resolve(order)
 
 			// a transaction indicates that order pairing occurred
@@ -91,8 +167,9 @@ func (ob *OrderBook) ExecuteOrInsertOrder(ctx context.Context, order types.Order
 				// before any book items or holds are removed
 				err = ob.pairOrders(ctx, tr)
 				if err != nil {
-					return fmt.Errorf("ExecuteOrInsertOrder::pair orders::%w", err)
+					return reject(persist.RejectReasonSettlementFailed, fmt.Errorf("ExecuteOrInsertOrder::pair orders::%w", err))
 				}
+				filled = true
 
 				switch {
 				case o != nil && o.ID == bookOrder.ID: // exits with return
@@ -144,7 +221,7 @@ func (ob *OrderBook) ExecuteOrInsertOrder(ctx context.Context, order types.Order
 					}
 
 					if updateError != nil {
-						return fmt.Errorf("ExecuteOrInsertOrder::partial match on book order:%w", updateError)
+						return reject(persist.RejectReasonHoldUpdateFailed, fmt.Errorf("ExecuteOrInsertOrder::partial match on book order:%w", updateError))
 					}
 
 					return nil
@@ -196,7 +273,7 @@ func (ob *OrderBook) ExecuteOrInsertOrder(ctx context.Context, order types.Order
 					}
 
 					if updateError != nil {
-						return fmt.Errorf("ExecuteOrInsertOrder::partial match on incoming order:%w", updateError)
+						return reject(persist.RejectReasonHoldUpdateFailed, fmt.Errorf("ExecuteOrInsertOrder::partial match on incoming order:%w", updateError))
 					}
 
 					newBatch = true
@@ -248,7 +325,7 @@ func (ob *OrderBook) ExecuteOrInsertOrder(ctx context.Context, order types.Order
 					}
 
 					if updateError != nil {
-						return fmt.Errorf("ExecuteOrInsertOrder::total match on both orders:%w", updateError)
+						return reject(persist.RejectReasonHoldUpdateFailed, fmt.Errorf("ExecuteOrInsertOrder::total match on both orders:%w", updateError))
 					}
 
 					return nil
@@ -258,7 +335,10 @@ func (ob *OrderBook) ExecuteOrInsertOrder(ctx context.Context, order types.Order
 			} else {
 				switch order.OrderRequest.Type.(type) {
 				case *types.MarketOrderType:
-					newBatch = true
+					// market orders cannot fill against other market orders;
+					// keep scanning for a priced order until the book runs out
+					newBatch = len(batch) == headBatchSize
+					continue
 				case *types.LimitOrderType:
 					newBatch = false
 				}
@@ -266,19 +346,111 @@ func (ob *OrderBook) ExecuteOrInsertOrder(ctx context.Context, order types.Order
 		}
 
 		if !newBatch {
-			// if the order book is empty, insert the order
-			err = ob.bir.SetBookItem(ctx, &item)
+			// the remainder of a market order is never added to the book
+			if _, ok := order.Type.(*types.MarketOrderType); ok {
+				return ob.cancelRemainder(ctx, order, filled)
+			}
+
+			// if the order book is empty, insert the remaining order
+			remainder := persist.NewBookItem(order)
+			err = ob.bir.SetBookItem(ctx, &remainder)
 			if err != nil {
-				return fmt.Errorf("ExecuteOrInsertOrder::%w", err)
+				return reject(persist.RejectReasonBookUpdateFailed, fmt.Errorf("ExecuteOrInsertOrder::%w", err))
 			}
 			return nil
 		}
 	}
 }
 
+func (ob *OrderBook) cancelRemainder(ctx context.Context, order types.Order, filled bool) error {
+	log.Printf("canceling unfilled market order remainder: %s", order.ID)
+	err := ob.bm.CancelRemainder(ctx, order, filled)
+	if err != nil {
+		return reject(persist.RejectReasonHoldUpdateFailed, fmt.Errorf("ExecuteOrInsertOrder::cancel remainder::%w", err))
+	}
+	return nil
+}
+
+// exceedsDeviation reports whether matching a market order against the book
+// order would move the fill price further than the maximum deviation set on
+// the request. The first matched price is recorded as the reference.
+func exceedsDeviation(order types.Order, book *types.Order, reference *decimal.Decimal) bool {
+	mt, ok := order.Type.(*types.MarketOrderType)
+	if !ok || !mt.MaxDeviation.IsPositive() {
+		return false
+	}
+
+	lt, ok := book.Type.(*types.LimitOrderType)
+	if !ok {
+		return false
+	}
+
+	if reference.IsZero() {
+		*reference = lt.Price
+		return false
+	}
+
+	deviation := lt.Price.Sub(*reference).Abs().Div(*reference)
+	return deviation.GreaterThan(mt.MaxDeviation)
+}
+
 func (ob *OrderBook) pairOrders(ctx context.Context, tr *types.Transaction) error {
 	log.Printf("maker order/account %s/%s :: taker order/account %s/%s", tr.A.Order.ID, tr.A.AccountID, tr.B.Order.ID, tr.B.AccountID)
-	return ob.bm.PostTransactionToBalance(ctx, tr)
+	if err := ob.bm.PostTransactionToBalance(ctx, tr); err != nil {
+		return err
+	}
+
+	// the balances are already posted so a failure to record the price does not
+	// fail the match
+	if err := ob.recordTrade(ctx, tr); err != nil {
+		log.Printf("failed to record trade for order %s: %s", tr.A.Order.ID, err)
+	}
+
+	return nil
+}
+
+// recordTrade saves the match as the last trade of its market. The trade is priced at
+// the maker order which always rests on the book as a limit order.
+func (ob *OrderBook) recordTrade(ctx context.Context, tr *types.Transaction) error {
+	maker := tr.A.Order
+	lt, ok := maker.Type.(*types.LimitOrderType)
+	if !ok {
+		return nil
+	}
+
+	qty := tr.A.SubQuantity
+	if tr.A.AddSymbol == maker.Target {
+		qty = tr.A.AddQuantity
+	}
+
+	return ob.mr.SetLastTrade(ctx, &persist.Trade{
+		Base:      maker.Base,
+		Target:    maker.Target,
+		Price:     lt.Price,
+		Quantity:  qty,
+		Timestamp: persist.NanoTime(time.Now()),
+	})
+}
+
+// rejection pairs an order processing error with the reason recorded when the
+// order is rejected
+type rejection struct {
+	reason persist.RejectReason
+	err    error
+	// settled is set when part of the order was settled before the failure
+	settled bool
+}
+
+func reject(reason persist.RejectReason, err error) error {
+	return &rejection{reason: reason, err: err}
+}
+
+func (r *rejection) Error() string {
+	return r.err.Error()
+}
+
+func (r *rejection) Unwrap() error {
+	return r.err
 }
 
 type ky string
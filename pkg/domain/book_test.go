@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -63,14 +64,227 @@ func newOrderBook(times []int64, amounts [][]float64, action types.ActionType) [
 
 func TestExecuteOrInsertOrder_EmptyBook(t *testing.T) {
 	st := persist.NewMockKVStore()
+	st1 := persist.NewMockKVStore()
+
 	br := kv.NewBookRepository(st)
-	s := &OrderBook{bir: br}
+	ar := kv.NewAccountRepository(st1)
+	lr := kv.NewLedgerRepository(st1)
+	bm := NewBalanceManager(ar, lr, funding.NewMockSource())
+	s := NewOrderBook(br, bm, kv.NewMarketRepository(st1))
 
+	ctx := context.Background()
 	order := newMarketBookOrder(12700, 0.01, types.ActionTypeSell)
-	err := s.ExecuteOrInsertOrder(context.Background(), order)
+	order = placeTestOrder(t, ctx, ar, bm, order)
+
+	err := s.ExecuteOrInsertOrder(ctx, order)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, st.Len(), "market orders should not be added to the book")
+
+	po, err := ar.Orders(&persist.Account{ID: order.Account.String()}).GetOrder(ctx, order.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, persist.StatusCanceled, po.Status)
 
+	smb, amt := order.Type.HoldAmount(order.Action, order.Base, order.Target)
+	bal, err := bm.GetAvailableBalance(ctx, &Account{ID: order.Account}, smb)
 	assert.NoError(t, err)
-	assert.Equal(t, 1, st.Len())
+	assert.True(t, amt.Equal(bal), "hold should be released")
+}
+
+func TestExecuteOrInsertOrder_MaxDeviation(t *testing.T) {
+	st := persist.NewMockKVStore()
+	st1 := persist.NewMockKVStore()
+
+	br := kv.NewBookRepository(st)
+	ar := kv.NewAccountRepository(st1)
+	lr := kv.NewLedgerRepository(st1)
+	bm := NewBalanceManager(ar, lr, funding.NewMockSource())
+	mr := kv.NewMarketRepository(st1)
+	s := NewOrderBook(br, bm, mr)
+
+	ctx := context.Background()
+	for _, b := range newOrderBook([]int64{1, 2}, [][]float64{{1.0, 0.5}, {0.8, 0.5}}, types.ActionTypeBuy) {
+		b = placeTestOrder(t, ctx, ar, bm, b)
+		bitem := persist.NewBookItem(b)
+		if err := br.SetBookItem(ctx, &bitem); err != nil {
+			t.Fatalf("error: %s", err)
+		}
+	}
+
+	order := newMarketBookOrder(12700, 1.0, types.ActionTypeSell)
+	order.Type.(*types.MarketOrderType).MaxDeviation = decimal.NewFromFloat(0.1)
+	order = placeTestOrder(t, ctx, ar, bm, order)
+
+	err := s.ExecuteOrInsertOrder(ctx, order)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, st.Len(), "order beyond the deviation cap should remain on the book")
+
+	po, err := ar.Orders(&persist.Account{ID: order.Account.String()}).GetOrder(ctx, order.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, persist.StatusPartialCanceled, po.Status)
+
+	bal, err := bm.GetAvailableBalance(ctx, &Account{ID: order.Account}, types.SymbolEthereum)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.5", bal.String(), "remainder hold should be released")
+
+	trade, err := mr.GetLastTrade(ctx, types.SymbolBitcoin, types.SymbolEthereum)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", trade.Price.String(), "trade should be priced at the matched book order")
+	assert.Equal(t, "0.5", trade.Quantity.String())
+
+	tb, err := lr.GetTrialBalance(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, tb.Unbalanced(), 0, "trade journal entries should balance")
+}
+
+func TestExecuteOrReject(t *testing.T) {
+	st := persist.NewMockKVStore()
+	st1 := persist.NewMockKVStore()
+
+	br := kv.NewBookRepository(st)
+	ar := kv.NewAccountRepository(st1)
+	lr := kv.NewLedgerRepository(st1)
+	bm := NewBalanceManager(ar, lr, funding.NewMockSource())
+	s := NewOrderBook(br, bm, kv.NewMarketRepository(st1))
+
+	ctx := context.Background()
+
+	t.Run("ReleasesHolds", func(t *testing.T) {
+		order := newMarketBookOrder(12700, 0.01, types.ActionTypeSell)
+		order = placeTestOrder(t, ctx, ar, bm, order)
+
+		// a missing hold causes the remainder cancellation to fail
+		smb, _ := order.Type.HoldAmount(order.Action, order.Base, order.Target)
+		err := bm.RemoveHoldOnAccount(ctx, &Account{ID: order.Account}, smb, ky(order.HoldID))
+		if err != nil {
+			t.Fatalf("error: %s", err)
+		}
+
+		err = s.ExecuteOrReject(ctx, order)
+		assert.NoError(t, err)
+
+		po, err := ar.Orders(&persist.Account{ID: order.Account.String()}).GetOrder(ctx, order.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, persist.StatusRejected, po.Status)
+		assert.Equal(t, persist.RejectReasonHoldUpdateFailed, po.Reason)
+
+		bal, err := bm.GetAvailableBalance(ctx, &Account{ID: order.Account}, types.SymbolCipherMtn)
+		assert.NoError(t, err)
+		assert.True(t, types.StandardFee.Equal(bal), "fee hold should be released")
+	})
+
+	t.Run("IgnoresOrderOnBook", func(t *testing.T) {
+		order := newLimitBookOrder(12700, 0.5, 1.0, types.ActionTypeSell)
+		order = placeTestOrder(t, ctx, ar, bm, order)
+
+		assert.NoError(t, s.ExecuteOrReject(ctx, order))
+		assert.NoError(t, s.ExecuteOrReject(ctx, order))
+
+		po, err := ar.Orders(&persist.Account{ID: order.Account.String()}).GetOrder(ctx, order.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, persist.StatusOpen, po.Status)
+		assert.Equal(t, 1, st.Len())
+	})
+}
+
+// unavailableBook fails every book read after the first n head batches
+type unavailableBook struct {
+	persist.BookRepository
+	batches int
+}
+
+func (b *unavailableBook) GetHeadBatch(ctx context.Context, item *persist.BookItem, limit int, offset *persist.BookItem) ([]*persist.BookItem, error) {
+	if b.batches <= 0 {
+		return nil, errors.New("book unavailable")
+	}
+	b.batches--
+	return b.BookRepository.GetHeadBatch(ctx, item, limit, offset)
+}
+
+func TestExecuteOrReject_BookUnavailable(t *testing.T) {
+	st := persist.NewMockKVStore()
+	st1 := persist.NewMockKVStore()
+
+	br := &unavailableBook{BookRepository: kv.NewBookRepository(st)}
+	ar := kv.NewAccountRepository(st1)
+	lr := kv.NewLedgerRepository(st1)
+	bm := NewBalanceManager(ar, lr, funding.NewMockSource())
+	s := NewOrderBook(br, bm, kv.NewMarketRepository(st1))
+
+	ctx := context.Background()
+
+	t.Run("ReturnsErrorForRetry", func(t *testing.T) {
+		order := newLimitBookOrder(12700, 0.5, 1.0, types.ActionTypeSell)
+		order = placeTestOrder(t, ctx, ar, bm, order)
+
+		assert.Error(t, s.ExecuteOrReject(ctx, order))
+
+		po, err := ar.Orders(&persist.Account{ID: order.Account.String()}).GetOrder(ctx, order.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, persist.StatusOpen, po.Status)
+
+		bal, err := bm.GetAvailableBalance(ctx, &Account{ID: order.Account}, types.SymbolCipherMtn)
+		assert.NoError(t, err)
+		assert.True(t, bal.IsZero(), "fee hold should remain for the retry")
+	})
+
+	t.Run("KeepsSettledOrder", func(t *testing.T) {
+		book := newLimitBookOrder(1, 1.0, 0.5, types.ActionTypeBuy)
+		book = placeTestOrder(t, ctx, ar, bm, book)
+		bitem := persist.NewBookItem(book)
+		assert.NoError(t, br.SetBookItem(ctx, &bitem))
+
+		// the order fills against the only book order and fails reading the next batch
+		br.batches = 1
+		order := newMarketBookOrder(12700, 1.0, types.ActionTypeSell)
+		order = placeTestOrder(t, ctx, ar, bm, order)
+
+		assert.NoError(t, s.ExecuteOrReject(ctx, order))
+
+		po, err := ar.Orders(&persist.Account{ID: order.Account.String()}).GetOrder(ctx, order.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, persist.StatusPartial, po.Status, "a partly settled order is not rejected")
+		assert.Equal(t, persist.RejectReasonBookUnavailable, po.Reason, "a partly settled order is flagged for review")
+
+		bal, err := bm.GetAvailableBalance(ctx, &Account{ID: order.Account}, types.SymbolEthereum)
+		assert.NoError(t, err)
+		assert.Equal(t, "0", bal.String(), "remainder hold should be left in place")
+	})
+}
+
+// placeTestOrder funds the order account, places holds for the order and
+// saves it as an open order
+func placeTestOrder(t *testing.T, ctx context.Context, ar persist.AccountRepository, bm *BalanceManager, order types.Order) types.Order {
+	smb, amt := order.Type.HoldAmount(order.Action, order.Base, order.Target)
+
+	err := bm.PostAmtToBalance(ctx, &Account{ID: order.Account}, smb, amt)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	err = bm.PostAmtToBalance(ctx, &Account{ID: order.Account}, types.SymbolCipherMtn, types.StandardFee)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	order.HoldID, err = bm.SetHoldOnAccount(ctx, &Account{ID: order.Account}, smb, amt)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	order.FeeHoldID, err = bm.SetHoldOnAccount(ctx, &Account{ID: order.Account}, types.SymbolCipherMtn, types.StandardFee)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	err = ar.Orders(&persist.Account{ID: order.Account.String()}).
+		SetOrder(ctx, &persist.Order{Status: persist.StatusOpen, Base: order})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	return order
 }
 
 func TestExecuteOrInsertOrder(t *testing.T) {
@@ -85,7 +299,7 @@ func TestExecuteOrInsertOrder(t *testing.T) {
 	f := funding.NewMockSource()
 
 	bm := NewBalanceManager(ar, lr, f)
-	s := NewOrderBook(br, bm)
+	s := NewOrderBook(br, bm, kv.NewMarketRepository(st1))
 
 	ctx := context.Background()
 
@@ -305,9 +519,10 @@ func TestExecuteOrInsertOrder(t *testing.T) {
 	})
 
 	t.Run("Multi-BatchMarketOrder", func(t *testing.T) {
-		// the expectation of this new order is to match 16 items from the order book
-		// and to remove two
-		expected = expected - 16
+		// the expectation of this new order is to match and remove all 17 buy
+		// items from the order book; the unfilled remainder is canceled rather
+		// than added to the book
+		expected = expected - 17
 
 		order := newMarketBookOrder(12800, 20.0, types.ActionTypeSell)
 
@@ -405,3 +620,78 @@ var times = []int64{
 	12355,
 	12356,
 }
+
+func TestExecuteOrInsertOrder_MarketOrdersAtHead(t *testing.T) {
+	st := persist.NewMockKVStore()
+	st1 := persist.NewMockKVStore()
+
+	br := kv.NewBookRepository(st)
+	ar := kv.NewAccountRepository(st1)
+	lr := kv.NewLedgerRepository(st1)
+	bm := NewBalanceManager(ar, lr, funding.NewMockSource())
+	s := NewOrderBook(br, bm, kv.NewMarketRepository(st1))
+
+	ctx := context.Background()
+
+	// more market orders than fit in one batch rest ahead of the only priced order
+	for i := 0; i < headBatchSize+2; i++ {
+		b := newMarketBookOrder(int64(i+1), 0.5, types.ActionTypeBuy)
+		bitem := persist.NewBookItem(b)
+		if err := br.SetBookItem(ctx, &bitem); err != nil {
+			t.Fatalf("error: %s", err)
+		}
+	}
+
+	book := newLimitBookOrder(100, 1.0, 0.5, types.ActionTypeBuy)
+	book = placeTestOrder(t, ctx, ar, bm, book)
+	bitem := persist.NewBookItem(book)
+	if err := br.SetBookItem(ctx, &bitem); err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	order := newMarketBookOrder(12700, 0.5, types.ActionTypeSell)
+	order = placeTestOrder(t, ctx, ar, bm, order)
+
+	assert.NoError(t, s.ExecuteOrInsertOrder(ctx, order))
+	assert.Equal(t, headBatchSize+2, st.Len(), "the priced order past the first batch should be filled")
+
+	po, err := ar.Orders(&persist.Account{ID: order.Account.String()}).GetOrder(ctx, order.ID)
+	assert.NoError(t, err)
+	assert.NotEqual(t, persist.StatusCanceled, po.Status)
+}
+
+func TestExecuteOrInsertOrder_SettlementFailure(t *testing.T) {
+	st := persist.NewMockKVStore()
+	st1 := persist.NewMockKVStore()
+
+	br := kv.NewBookRepository(st)
+	ar := kv.NewAccountRepository(st1)
+	lr := kv.NewLedgerRepository(st1)
+	bm := NewBalanceManager(ar, lr, funding.NewMockSource())
+	s := NewOrderBook(br, bm, kv.NewMarketRepository(st1))
+
+	ctx := context.Background()
+
+	book := newLimitBookOrder(1, 1.0, 0.5, types.ActionTypeBuy)
+	book = placeTestOrder(t, ctx, ar, bm, book)
+	bitem := persist.NewBookItem(book)
+	if err := br.SetBookItem(ctx, &bitem); err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	// the incoming order was never saved so its records fail after the book
+	// order records were written
+	order := newMarketBookOrder(12700, 0.5, types.ActionTypeSell)
+	smb, amt := order.Type.HoldAmount(order.Action, order.Base, order.Target)
+	if err := bm.PostAmtToBalance(ctx, &Account{ID: order.Account}, smb, amt); err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	err := s.ExecuteOrInsertOrder(ctx, order)
+
+	var rej *rejection
+	if assert.True(t, errors.As(err, &rej)) {
+		assert.Equal(t, persist.RejectReasonSettlementFailed, rej.reason)
+		assert.True(t, rej.settled, "a failed settlement may have written part of its records")
+	}
+}
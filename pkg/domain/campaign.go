@@ -0,0 +1,467 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidCampaign  = errors.New("invalid campaign")
+	ErrSelfReferral     = errors.New("an account cannot refer itself")
+	ErrReferrerNotFound = errors.New("referring account not found")
+	ErrAlreadyReferred  = errors.New("account already has a referrer")
+	ErrReferralClosed   = errors.New("referrals must be made before the first deposit")
+)
+
+// grant events that can only be paid once for each account
+const (
+	signUpEvent       = "sign_up"
+	firstDepositEvent = "first_deposit"
+	firstTradeEvent   = "first_trade"
+)
+
+// ValidateCampaign checks that a campaign has a known rule, a time window, a budget and a
+// grant amount the rule can pay
+func ValidateCampaign(c *persist.Campaign) error {
+	var known bool
+	for _, r := range persist.CampaignRules {
+		if c.Rule == r {
+			known = true
+			break
+		}
+	}
+
+	switch {
+	case !known:
+		return fmt.Errorf("%w: unknown rule '%s'", ErrInvalidCampaign, c.Rule)
+	case !time.Time(c.End).After(time.Time(c.Start)):
+		return fmt.Errorf("%w: end must be after start", ErrInvalidCampaign)
+	case !c.Budget.IsPositive():
+		return fmt.Errorf("%w: budget must be greater than 0", ErrInvalidCampaign)
+	case !c.Amount.IsPositive():
+		return fmt.Errorf("%w: amount must be greater than 0", ErrInvalidCampaign)
+	case c.PerAccountLimit < 1:
+		return fmt.Errorf("%w: per account limit must be at least 1", ErrInvalidCampaign)
+	case c.Rule == persist.FirstDepositMatch && !c.MatchRate.IsPositive():
+		return fmt.Errorf("%w: match rate must be greater than 0", ErrInvalidCampaign)
+	}
+
+	return nil
+}
+
+// DefaultSignUpCampaignID is the id of the sign up campaign saved by
+// CreateDefaultCampaigns. It is fixed so the campaign is only saved once.
+var DefaultSignUpCampaignID = uuid.NewV5(uuid.NamespaceURL, "campaign:default_sign_up").String()
+
+// NewAccountFunds is the amount of CMTN the default sign up campaign pays each new account
+var NewAccountFunds = decimal.NewFromInt(5000)
+
+// CreateDefaultCampaigns saves the default sign up campaign when it is not already saved.
+// The campaign pays NewAccountFunds to each new account from the budget provided so new
+// accounts are funded as they were before campaigns were added.
+func CreateDefaultCampaigns(ctx context.Context, repo persist.CampaignRepository, budget decimal.Decimal) error {
+	now := time.Now()
+	c := persist.NewCampaign("new account funds", persist.SignUpBonus, types.SymbolCipherMtn, now, now.AddDate(100, 0, 0))
+	c.ID = DefaultSignUpCampaignID
+	c.Amount = NewAccountFunds
+	c.Budget = budget
+
+	if err := ValidateCampaign(c); err != nil {
+		return fmt.Errorf("CreateDefaultCampaigns::%w", err)
+	}
+
+	err := repo.CreateCampaign(ctx, c)
+	if err != nil && !errors.Is(err, persist.ErrObjectExists) {
+		return fmt.Errorf("CreateDefaultCampaigns::%w", err)
+	}
+
+	return nil
+}
+
+// SetReferrer records the account that referred a to the exchange. The referrer is paid
+// by referral campaigns when a is credited its first deposit, so a referrer can only be
+// set once and only before any deposit is credited.
+func (m *BalanceManager) SetReferrer(ctx context.Context, a *Account, referrer uuid.UUID) error {
+
+	if uuid.Equal(a.ID, referrer) {
+		return ErrSelfReferral
+	}
+
+	_, err := m.acct.Find(ctx, referrer)
+	if err != nil {
+		if errors.Is(err, persist.ErrObjectNotExist) {
+			return ErrReferrerNotFound
+		}
+		return fmt.Errorf("BalanceManager::SetReferrer::%w", err)
+	}
+
+	credited, err := m.GetDeposits(ctx, a, persist.DepositCredited)
+	if err != nil {
+		return fmt.Errorf("BalanceManager::SetReferrer::%w", err)
+	}
+
+	if len(credited) > 0 {
+		return ErrReferralClosed
+	}
+
+	err = m.acct.Campaigns().SetReferral(ctx, &persist.Referral{
+		Account:   a.ID.String(),
+		Referrer:  referrer.String(),
+		Timestamp: persist.NanoTime(time.Now()),
+	})
+	if err != nil {
+		if errors.Is(err, persist.ErrObjectExists) {
+			return ErrAlreadyReferred
+		}
+		return fmt.Errorf("BalanceManager::SetReferrer::%w", err)
+	}
+
+	return nil
+}
+
+// rewardSignUp pays sign up bonuses to a newly created account
+func (m *BalanceManager) rewardSignUp(ctx context.Context, p *persist.Account) {
+	campaigns := m.activeCampaigns(ctx, persist.SignUpBonus)
+	m.payCampaigns(ctx, campaigns, grantOwner(p), p.ID, signUpEvent, fixedGrant)
+}
+
+// rewardDeposit pays deposit matches for the first deposit credited in each symbol and
+// pays the referrer of the account when it is the first deposit credited in any symbol
+func (m *BalanceManager) rewardDeposit(ctx context.Context, d *persist.Deposit) {
+	matches := m.activeCampaigns(ctx, persist.FirstDepositMatch)
+	referrals := m.activeCampaigns(ctx, persist.ReferralReward)
+	if len(matches) == 0 && len(referrals) == 0 {
+		return
+	}
+
+	credited, err := m.acct.Deposits(&persist.Account{ID: d.Account}).GetDeposits(ctx, persist.DepositCredited)
+	if err != nil {
+		log.Printf("BalanceManager::rewardDeposit: %s", err)
+		return
+	}
+
+	var inSymbol int
+	for _, c := range credited {
+		if c.Symbol == d.Symbol {
+			inSymbol++
+		}
+	}
+
+	if inSymbol == 1 && len(matches) > 0 {
+		owner, err := m.accountOwner(ctx, d.Account)
+		if err != nil {
+			log.Printf("BalanceManager::rewardDeposit: %s", err)
+			return
+		}
+
+		m.payCampaigns(ctx, matches, owner, d.Account, firstDepositEvent, func(c *persist.Campaign) decimal.Decimal {
+			if c.Symbol != d.Symbol {
+				return decimal.Zero
+			}
+			return decimal.Min(d.Amount.Mul(c.MatchRate), c.Amount)
+		})
+	}
+
+	if len(credited) != 1 || len(referrals) == 0 {
+		return
+	}
+
+	ref, err := m.acct.Campaigns().GetReferral(ctx, ky(d.Account))
+	if err != nil {
+		if !errors.Is(err, persist.ErrObjectNotExist) {
+			log.Printf("BalanceManager::rewardDeposit: %s", err)
+		}
+		return
+	}
+
+	owner, err := m.accountOwner(ctx, ref.Referrer)
+	if err != nil {
+		log.Printf("BalanceManager::rewardDeposit: %s", err)
+		return
+	}
+
+	// the event names the referred account so each referral is paid once
+	m.payCampaigns(ctx, referrals, owner, ref.Referrer, fmt.Sprintf("referral:%s", d.Account), fixedGrant)
+}
+
+// rewardTrade pays first trade rewards when the order traded is the first order on the
+// account to trade. The first order is saved on the account the first time it trades so
+// later trades are checked without loading the account transactions.
+func (m *BalanceManager) rewardTrade(ctx context.Context, entry types.BalanceEntry) {
+	campaigns := m.activeCampaigns(ctx, persist.FirstTradeReward)
+	if len(campaigns) == 0 {
+		return
+	}
+
+	p, err := m.acct.Find(ctx, entry.AccountID)
+	if err != nil {
+		log.Printf("BalanceManager::rewardTrade: %s", err)
+		return
+	}
+
+	order := entry.Order.ID.String()
+	if p.FirstTrade == "" {
+		p.FirstTrade, err = m.firstTradedOrder(ctx, p, order)
+		if err != nil {
+			log.Printf("BalanceManager::rewardTrade: %s", err)
+			return
+		}
+
+		if err = m.acct.Save(ctx, p); err != nil {
+			log.Printf("BalanceManager::rewardTrade: %s", err)
+			return
+		}
+	}
+
+	if p.FirstTrade != order {
+		return
+	}
+
+	m.payCampaigns(ctx, campaigns, grantOwner(p), p.ID, firstTradeEvent, fixedGrant)
+}
+
+// firstTradedOrder finds the first order to trade on an account that has not saved it.
+// The order being traded is the first unless the account traded another order before
+// first trades were saved.
+func (m *BalanceManager) firstTradedOrder(ctx context.Context, p *persist.Account, order string) (string, error) {
+	transactions, err := m.acct.Transactions(p).GetTransactions(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for _, t := range transactions {
+		if t.Type == persist.OrderTransactionType && t.OrderID != order {
+			return t.OrderID, nil
+		}
+	}
+
+	return order, nil
+}
+
+// CampaignCacheTTL is how long loaded campaigns are used before they are loaded again
+var CampaignCacheTTL = time.Minute
+
+// campaignCache keeps the campaigns loaded for rewards so every trade and deposit does
+// not load all campaigns. Budgets are checked against the stored campaign when a grant
+// is made so a cached campaign is only used to find the grants to attempt.
+type campaignCache struct {
+	mu        sync.Mutex
+	loaded    time.Time
+	campaigns []*persist.Campaign
+}
+
+func (cc *campaignCache) get(ctx context.Context, repo persist.CampaignRepository) ([]*persist.Campaign, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if !cc.loaded.IsZero() && time.Since(cc.loaded) < CampaignCacheTTL {
+		return cc.campaigns, nil
+	}
+
+	campaigns, err := repo.GetCampaigns(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cc.campaigns = campaigns
+	cc.loaded = time.Now()
+
+	return campaigns, nil
+}
+
+// activeCampaigns returns the campaigns with the rule that are inside their time window
+func (m *BalanceManager) activeCampaigns(ctx context.Context, rule persist.CampaignRule) []*persist.Campaign {
+	campaigns, err := m.campaigns.get(ctx, m.acct.Campaigns())
+	if err != nil {
+		log.Printf("BalanceManager::activeCampaigns: %s", err)
+		return nil
+	}
+
+	now := time.Now()
+	var active []*persist.Campaign
+	for _, c := range campaigns {
+		if c.Rule == rule && c.Active(now) {
+			active = append(active, c)
+		}
+	}
+
+	return active
+}
+
+// accountOwner returns the owner grants paid to the account are limited by
+func (m *BalanceManager) accountOwner(ctx context.Context, account string) (string, error) {
+	p, err := m.acct.Find(ctx, ky(account))
+	if err != nil {
+		return "", err
+	}
+
+	return grantOwner(p), nil
+}
+
+// grantOwner is the authorization holding the account. Accounts saved before owners
+// were saved are their own owner.
+func grantOwner(p *persist.Account) string {
+	if p.Owner == "" {
+		return p.ID
+	}
+	return p.Owner
+}
+
+// payCampaigns grants the owner the amount each campaign pays for the event into the
+// account. Grants that were already made or are outside the campaign limits are skipped.
+// Failures are logged instead of returned so a campaign never fails the event that
+// triggered it.
+func (m *BalanceManager) payCampaigns(ctx context.Context, campaigns []*persist.Campaign, owner, account, event string, amount func(*persist.Campaign) decimal.Decimal) {
+	for _, c := range campaigns {
+		amt := amount(c).Truncate(c.Symbol.RoundingPlace())
+		if !amt.IsPositive() {
+			continue
+		}
+
+		err := m.grant(ctx, persist.NewGrant(c, owner, account, event, amt))
+		if err != nil &&
+			!errors.Is(err, persist.ErrObjectExists) &&
+			!errors.Is(err, persist.ErrCampaignBudget) &&
+			!errors.Is(err, persist.ErrGrantLimit) {
+			log.Printf("BalanceManager::payCampaigns: campaign %s: %s", c.ID, err)
+		}
+	}
+}
+
+func fixedGrant(c *persist.Campaign) decimal.Decimal {
+	return c.Amount
+}
+
+// grant reserves the grant against the campaign budget before funding the account. The
+// grant is paid from the promotions ledger account into the customer liability of the
+// receiving account. A grant that was already reserved is finished from the status saved
+// by the earlier attempt.
+func (m *BalanceManager) grant(ctx context.Context, g *persist.Grant) error {
+
+	campaigns := m.acct.Campaigns()
+	err := campaigns.CreateGrant(ctx, g)
+	if errors.Is(err, persist.ErrObjectExists) {
+		g, err = campaigns.GetGrant(ctx, ky(g.Campaign), ky(g.ID))
+	}
+	if err != nil {
+		return fmt.Errorf("BalanceManager::grant::%w", err)
+	}
+
+	switch g.Status {
+	case persist.GrantPaid:
+		return persist.ErrObjectExists
+	case persist.GrantCrediting:
+		// the balance may have been credited so the grant is left for review
+		return fmt.Errorf("BalanceManager::grant: grant %s was interrupted while crediting the account", g.ID)
+	case persist.GrantReserved:
+		if err = m.creditGrant(ctx, g); err != nil {
+			return err
+		}
+	}
+
+	return m.recordGrant(ctx, g)
+}
+
+// creditGrant adds a reserved grant to the account balance. The grant is marked
+// crediting first so an interrupted credit is never repeated. When nothing was credited
+// the grant is deleted to release the budget for a later attempt.
+func (m *BalanceManager) creditGrant(ctx context.Context, g *persist.Grant) error {
+	campaigns := m.acct.Campaigns()
+
+	release := func(err error) error {
+		if rerr := campaigns.DeleteGrant(ctx, g); rerr != nil {
+			log.Printf("BalanceManager::grant: failed to release grant %s: %s", g.ID, rerr)
+		}
+		return fmt.Errorf("BalanceManager::grant::%w", err)
+	}
+
+	err := m.setGrantStatus(ctx, g, persist.GrantCrediting)
+	if err != nil {
+		// another attempt is paying the grant
+		if errors.Is(err, persist.ErrPreconditionFailed) {
+			return persist.ErrObjectExists
+		}
+		return release(err)
+	}
+
+	err = m.acct.Balances(&persist.Account{ID: g.Account}, g.Symbol).AddToBalance(ctx, g.Amount)
+	if err != nil {
+		return release(err)
+	}
+
+	err = m.setGrantStatus(ctx, g, persist.GrantCredited)
+	if err != nil {
+		return fmt.Errorf("BalanceManager::grant::%w", err)
+	}
+
+	return nil
+}
+
+// recordGrant writes the transaction record and ledger entries of a credited grant and
+// marks it paid. Both are keyed by the grant so a repeated attempt does not write them
+// twice.
+func (m *BalanceManager) recordGrant(ctx context.Context, g *persist.Grant) error {
+	trepo := m.acct.Transactions(&persist.Account{ID: g.Account})
+
+	existing, err := trepo.GetTransactions(ctx)
+	if err != nil {
+		return fmt.Errorf("BalanceManager::grant::%w", err)
+	}
+
+	var written bool
+	for _, tr := range existing {
+		if tr.Type == persist.GrantTransactionType && tr.TransactionHash == g.ID {
+			written = true
+			break
+		}
+	}
+
+	if !written {
+		err = trepo.SetTransaction(ctx, &persist.Transaction{
+			Type:            persist.GrantTransactionType,
+			TransactionHash: g.ID,
+			Symbol:          g.Symbol.String(),
+			Quantity:        g.Amount.StringFixedBank(g.Symbol.RoundingPlace()),
+			Timestamp:       g.Timestamp,
+		})
+		if err != nil {
+			return fmt.Errorf("BalanceManager::grant::%w", err)
+		}
+	}
+
+	err = m.ledger.RecordGrant(ctx, g)
+	if err != nil {
+		return fmt.Errorf("BalanceManager::grant::%w", err)
+	}
+
+	err = m.setGrantStatus(ctx, g, persist.GrantPaid)
+	if err != nil {
+		return fmt.Errorf("BalanceManager::grant::%w", err)
+	}
+
+	return nil
+}
+
+// setGrantStatus saves the grant with the status only while the stored grant has the
+// status it was read with
+func (m *BalanceManager) setGrantStatus(ctx context.Context, g *persist.Grant, s persist.GrantStatus) error {
+	next := *g
+	next.Status = s
+
+	err := m.acct.Campaigns().UpdateGrant(ctx, &next, g.Status)
+	if err != nil {
+		return err
+	}
+
+	*g = next
+	return nil
+}
@@ -0,0 +1,402 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/funding"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/kv"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCampaign(t *testing.T, ar persist.AccountRepository, rule persist.CampaignRule, s types.Symbol, amt, budget float64) *persist.Campaign {
+	now := time.Now()
+	c := persist.NewCampaign(string(rule), rule, s, now.Add(-time.Hour), now.Add(time.Hour))
+	c.Amount = decimal.NewFromFloat(amt)
+	c.Budget = decimal.NewFromFloat(budget)
+
+	assert.NoError(t, ValidateCampaign(c))
+	assert.NoError(t, ar.Campaigns().SetCampaign(context.Background(), c))
+	return c
+}
+
+func TestValidateCampaign(t *testing.T) {
+	now := time.Now()
+
+	valid := func() *persist.Campaign {
+		c := persist.NewCampaign("match", persist.FirstDepositMatch, types.SymbolBitcoin, now, now.Add(time.Hour))
+		c.Amount = decimal.NewFromInt(1)
+		c.MatchRate = decimal.NewFromFloat(0.5)
+		c.Budget = decimal.NewFromInt(10)
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*persist.Campaign)
+		valid  bool
+	}{
+		{name: "valid", modify: func(c *persist.Campaign) {}, valid: true},
+		{name: "unknown rule", modify: func(c *persist.Campaign) { c.Rule = "cashback" }},
+		{name: "empty window", modify: func(c *persist.Campaign) { c.End = c.Start }},
+		{name: "no budget", modify: func(c *persist.Campaign) { c.Budget = decimal.Zero }},
+		{name: "no amount", modify: func(c *persist.Campaign) { c.Amount = decimal.Zero }},
+		{name: "no account limit", modify: func(c *persist.Campaign) { c.PerAccountLimit = 0 }},
+		{name: "no match rate", modify: func(c *persist.Campaign) { c.MatchRate = decimal.Zero }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := valid()
+			test.modify(c)
+
+			err := ValidateCampaign(c)
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidCampaign)
+			}
+		})
+	}
+}
+
+func TestCampaignSignUpBonus(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	lr := kv.NewLedgerRepository(st)
+	bm := NewBalanceManager(ar, lr)
+	ctx := context.Background()
+
+	c := newTestCampaign(t, ar, persist.SignUpBonus, types.SymbolCipherMtn, 10, 15)
+
+	// campaigns outside their window are not paid
+	ended := persist.NewCampaign("ended", persist.SignUpBonus, types.SymbolCipherMtn, time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+	ended.Amount = decimal.NewFromInt(100)
+	ended.Budget = decimal.NewFromInt(1000)
+	assert.NoError(t, ar.Campaigns().SetCampaign(ctx, ended))
+
+	first := NewAccount()
+	_, err := bm.GetAccount(ctx, first.ID.String())
+	assert.NoError(t, err)
+
+	// loading the account again does not pay the bonus twice
+	_, err = bm.GetAccount(ctx, first.ID.String())
+	assert.NoError(t, err)
+
+	bal, err := bm.GetPostedBalance(ctx, first, types.SymbolCipherMtn)
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(10).Equal(bal), bal.String())
+
+	trs, err := ar.Transactions(&persist.Account{ID: first.ID.String()}).GetTransactions(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, trs, 1) {
+		assert.Equal(t, persist.TransactionType(persist.GrantTransactionType), trs[0].Type)
+		assert.Equal(t, persist.GrantID(c.ID, first.ID.String(), signUpEvent), trs[0].TransactionHash)
+	}
+
+	// the remaining budget does not cover another bonus
+	second := NewAccount()
+	_, err = bm.GetAccount(ctx, second.ID.String())
+	assert.NoError(t, err)
+
+	bal, err = bm.GetPostedBalance(ctx, second, types.SymbolCipherMtn)
+	assert.NoError(t, err)
+	assert.True(t, bal.IsZero(), bal.String())
+
+	stored, err := ar.Campaigns().GetCampaign(ctx, ky(c.ID))
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(10).Equal(stored.Granted), stored.Granted.String())
+
+	grants, err := ar.Campaigns().GetGrants(ctx, ky(ended.ID))
+	assert.NoError(t, err)
+	assert.Len(t, grants, 0)
+
+	// grants are paid from the promotions account into customer liabilities
+	trial, err := lr.GetTrialBalance(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, trial.Unbalanced(), 0)
+	assert.True(t, decimal.NewFromInt(-10).Equal(trial.Net(persist.Promotions)[types.SymbolCipherMtn]))
+	assert.True(t, decimal.NewFromInt(10).Equal(trial.Net(persist.CustomerLiabilities)[types.SymbolCipherMtn]))
+}
+
+func TestCampaignDepositMatchAndReferral(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	bm := NewBalanceManager(ar, kv.NewLedgerRepository(st), funding.NewMockSource())
+	ctx := context.Background()
+
+	match := persist.NewCampaign("match", persist.FirstDepositMatch, types.SymbolBitcoin, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	match.Amount = decimal.NewFromFloat(0.3)
+	match.MatchRate = decimal.NewFromFloat(0.5)
+	match.Budget = decimal.NewFromInt(10)
+	assert.NoError(t, ValidateCampaign(match))
+	assert.NoError(t, ar.Campaigns().SetCampaign(ctx, match))
+
+	referral := newTestCampaign(t, ar, persist.ReferralReward, types.SymbolCipherMtn, 25, 100)
+
+	referrer := NewAccount()
+	referred := NewAccount()
+	for i, a := range []*Account{referrer, referred} {
+		assert.NoError(t, ar.Save(ctx, &persist.Account{
+			ID: a.ID.String(),
+			Addresses: []persist.FundingAddress{
+				{Symbol: types.SymbolBitcoin, Address: "btc" + string(rune('a'+i))},
+				{Symbol: types.SymbolEthereum, Address: "eth" + string(rune('a'+i))},
+			},
+		}))
+	}
+
+	assert.ErrorIs(t, bm.SetReferrer(ctx, referred, referred.ID), ErrSelfReferral)
+	assert.ErrorIs(t, bm.SetReferrer(ctx, referred, uuid.NewV4()), ErrReferrerNotFound)
+	assert.NoError(t, bm.SetReferrer(ctx, referred, referrer.ID))
+	assert.ErrorIs(t, bm.SetReferrer(ctx, referred, referrer.ID), ErrAlreadyReferred)
+
+	deposit := func(address, hash string, s types.Symbol, amt float64) {
+		assert.NoError(t, bm.FundAccountByAddress(ctx, &funding.Transaction{
+			Symbol:          s,
+			Address:         address,
+			TransactionHash: hash,
+			Amount:          decimal.NewFromFloat(amt),
+		}))
+	}
+
+	balance := func(a *Account, s types.Symbol) decimal.Decimal {
+		bal, err := bm.GetPostedBalance(ctx, a, s)
+		assert.NoError(t, err)
+		return bal
+	}
+
+	// the first deposit in another symbol is not matched but pays the referrer
+	deposit("ethb", "hash1", types.SymbolEthereum, 2)
+	assert.True(t, decimal.NewFromInt(2).Equal(balance(referred, types.SymbolEthereum)))
+	assert.True(t, decimal.NewFromInt(25).Equal(balance(referrer, types.SymbolCipherMtn)))
+
+	// the first deposit in the campaign symbol is matched up to the campaign amount
+	deposit("btcb", "hash2", types.SymbolBitcoin, 1)
+	assert.True(t, decimal.NewFromFloat(1.3).Equal(balance(referred, types.SymbolBitcoin)), balance(referred, types.SymbolBitcoin).String())
+
+	// later deposits are not matched and do not pay the referrer again
+	deposit("btcb", "hash3", types.SymbolBitcoin, 0.2)
+	assert.True(t, decimal.NewFromFloat(1.5).Equal(balance(referred, types.SymbolBitcoin)), balance(referred, types.SymbolBitcoin).String())
+	assert.True(t, decimal.NewFromInt(25).Equal(balance(referrer, types.SymbolCipherMtn)))
+
+	grants, err := ar.Campaigns().GetGrants(ctx, ky(referral.ID))
+	assert.NoError(t, err)
+	if assert.Len(t, grants, 1) {
+		assert.Equal(t, referrer.ID.String(), grants[0].Account)
+	}
+
+	// referrals close once an account has been credited a deposit
+	deposit("btca", "hash4", types.SymbolBitcoin, 0.1)
+	other := NewAccount()
+	assert.NoError(t, ar.Save(ctx, &persist.Account{ID: other.ID.String()}))
+	assert.ErrorIs(t, bm.SetReferrer(ctx, referrer, other.ID), ErrReferralClosed)
+}
+
+func TestCampaignFirstTradeReward(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	bm := NewBalanceManager(ar, kv.NewLedgerRepository(st))
+	ctx := context.Background()
+
+	newTestCampaign(t, ar, persist.FirstTradeReward, types.SymbolCipherMtn, 5, 100)
+
+	trade := func(a, b types.Order) {
+		for _, o := range []types.Order{a, b} {
+			if _, err := ar.Find(ctx, o.Account); err != nil {
+				assert.NoError(t, ar.Save(ctx, &persist.Account{ID: o.Account.String()}))
+			}
+
+			assert.NoError(t, ar.Orders(&persist.Account{ID: o.Account.String()}).SetOrder(ctx, &persist.Order{
+				Status:       persist.StatusOpen,
+				Base:         o,
+				Transactions: [][]string{},
+			}))
+		}
+
+		assert.NoError(t, bm.PostTransactionToBalance(ctx, &types.Transaction{
+			A: types.BalanceEntry{
+				Order:       a,
+				AccountID:   a.Account,
+				AddSymbol:   types.SymbolEthereum,
+				AddQuantity: decimal.NewFromInt(1),
+				FeeQuantity: decimal.Zero,
+				SubSymbol:   types.SymbolBitcoin,
+				SubQuantity: decimal.NewFromFloat(0.05),
+			},
+			B: types.BalanceEntry{
+				Order:       b,
+				AccountID:   b.Account,
+				AddSymbol:   types.SymbolBitcoin,
+				AddQuantity: decimal.NewFromFloat(0.05),
+				FeeQuantity: decimal.Zero,
+				SubSymbol:   types.SymbolEthereum,
+				SubQuantity: decimal.NewFromInt(1),
+			},
+			Filled: []types.Order{a, b},
+		}))
+	}
+
+	buy := newLimitBookOrder(1, 0.05, 1, types.ActionTypeBuy)
+	sell := newLimitBookOrder(2, 0.05, 1, types.ActionTypeSell)
+	trade(buy, sell)
+
+	// a later order by the same account is not its first trade
+	again := newLimitBookOrder(3, 0.05, 1, types.ActionTypeBuy)
+	again.Account = buy.Account
+	trade(again, newLimitBookOrder(4, 0.05, 1, types.ActionTypeSell))
+
+	for _, id := range []uuid.UUID{buy.Account, sell.Account} {
+		bal, err := bm.GetPostedBalance(ctx, &Account{ID: id}, types.SymbolCipherMtn)
+		assert.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(5).Equal(bal), bal.String())
+	}
+}
+
+func TestCampaignOwnerLimit(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	bm := NewBalanceManager(ar, kv.NewLedgerRepository(st), funding.NewMockSource())
+	ctx := context.Background()
+
+	match := persist.NewCampaign("match", persist.FirstDepositMatch, types.SymbolBitcoin, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	match.Amount = decimal.NewFromInt(1)
+	match.MatchRate = decimal.NewFromInt(1)
+	match.Budget = decimal.NewFromInt(10)
+	assert.NoError(t, ValidateCampaign(match))
+	assert.NoError(t, ar.Campaigns().SetCampaign(ctx, match))
+
+	authz := &persist.Authorization{ID: uuid.NewV4().String()}
+	main := NewAccount()
+	_, err := bm.GetOwnedAccount(ctx, authz, main.ID.String())
+	assert.NoError(t, err)
+
+	p, err := ar.Find(ctx, main.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, authz.ID, p.Owner)
+
+	p.Addresses = []persist.FundingAddress{{Symbol: types.SymbolBitcoin, Address: "btca"}}
+	assert.NoError(t, ar.Save(ctx, p))
+
+	sub := NewAccount()
+	assert.NoError(t, ar.Save(ctx, &persist.Account{
+		ID:        sub.ID.String(),
+		Owner:     authz.ID,
+		Addresses: []persist.FundingAddress{{Symbol: types.SymbolBitcoin, Address: "btcb"}},
+	}))
+
+	for i, addr := range []string{"btca", "btcb"} {
+		assert.NoError(t, bm.FundAccountByAddress(ctx, &funding.Transaction{
+			Symbol:          types.SymbolBitcoin,
+			Address:         addr,
+			TransactionHash: fmt.Sprintf("hash%d", i),
+			Amount:          decimal.NewFromFloat(0.5),
+		}))
+	}
+
+	// the first deposit of each account is matched only once for the authorization
+	for id, expected := range map[uuid.UUID]float64{main.ID: 1, sub.ID: 0.5} {
+		bal, err := bm.GetPostedBalance(ctx, &Account{ID: id}, types.SymbolBitcoin)
+		assert.NoError(t, err)
+		assert.True(t, decimal.NewFromFloat(expected).Equal(bal), bal.String())
+	}
+
+	grants, err := ar.Campaigns().GetGrants(ctx, ky(match.ID))
+	assert.NoError(t, err)
+	if assert.Len(t, grants, 1) {
+		assert.Equal(t, authz.ID, grants[0].Owner)
+		assert.Equal(t, main.ID.String(), grants[0].Account)
+		assert.Equal(t, persist.GrantPaid, grants[0].Status)
+	}
+}
+
+func TestCampaignInterruptedGrant(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	lr := kv.NewLedgerRepository(st)
+	bm := NewBalanceManager(ar, lr)
+	ctx := context.Background()
+
+	c := newTestCampaign(t, ar, persist.SignUpBonus, types.SymbolCipherMtn, 10, 100)
+	campaigns := ar.Campaigns()
+
+	balance := func(a *Account) decimal.Decimal {
+		bal, err := bm.GetPostedBalance(ctx, a, types.SymbolCipherMtn)
+		assert.NoError(t, err)
+		return bal
+	}
+
+	// a grant reserved by an attempt that stopped before crediting is paid by the next
+	reserved := NewAccount()
+	g := persist.NewGrant(c, reserved.ID.String(), reserved.ID.String(), signUpEvent, c.Amount)
+	assert.NoError(t, campaigns.CreateGrant(ctx, g))
+	assert.NoError(t, bm.grant(ctx, persist.NewGrant(c, reserved.ID.String(), reserved.ID.String(), signUpEvent, c.Amount)))
+	assert.True(t, decimal.NewFromInt(10).Equal(balance(reserved)))
+
+	// a paid grant is not paid again
+	assert.ErrorIs(t, bm.grant(ctx, persist.NewGrant(c, reserved.ID.String(), reserved.ID.String(), signUpEvent, c.Amount)), persist.ErrObjectExists)
+	assert.True(t, decimal.NewFromInt(10).Equal(balance(reserved)))
+
+	// a credited grant is only recorded
+	credited := NewAccount()
+	g = persist.NewGrant(c, credited.ID.String(), credited.ID.String(), signUpEvent, c.Amount)
+	assert.NoError(t, campaigns.CreateGrant(ctx, g))
+	assert.NoError(t, ar.Balances(&persist.Account{ID: credited.ID.String()}, c.Symbol).AddToBalance(ctx, c.Amount))
+	assert.NoError(t, bm.setGrantStatus(ctx, g, persist.GrantCredited))
+	assert.NoError(t, bm.grant(ctx, persist.NewGrant(c, credited.ID.String(), credited.ID.String(), signUpEvent, c.Amount)))
+	assert.True(t, decimal.NewFromInt(10).Equal(balance(credited)))
+
+	trs, err := ar.Transactions(&persist.Account{ID: credited.ID.String()}).GetTransactions(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, trs, 1)
+
+	// a grant interrupted while crediting is left for review
+	crediting := NewAccount()
+	g = persist.NewGrant(c, crediting.ID.String(), crediting.ID.String(), signUpEvent, c.Amount)
+	assert.NoError(t, campaigns.CreateGrant(ctx, g))
+	assert.NoError(t, bm.setGrantStatus(ctx, g, persist.GrantCrediting))
+	assert.Error(t, bm.grant(ctx, persist.NewGrant(c, crediting.ID.String(), crediting.ID.String(), signUpEvent, c.Amount)))
+	assert.True(t, balance(crediting).IsZero())
+
+	stored, err := campaigns.GetCampaign(ctx, ky(c.ID))
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(30).Equal(stored.Granted), stored.Granted.String())
+
+	trial, err := lr.GetTrialBalance(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, trial.Unbalanced(), 0)
+	assert.True(t, decimal.NewFromInt(-20).Equal(trial.Net(persist.Promotions)[types.SymbolCipherMtn]))
+}
+
+func TestCreateDefaultCampaigns(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	bm := NewBalanceManager(ar, kv.NewLedgerRepository(st))
+	ctx := context.Background()
+
+	assert.NoError(t, CreateDefaultCampaigns(ctx, ar.Campaigns(), decimal.NewFromInt(10000)))
+
+	a := NewAccount()
+	_, err := bm.GetAccount(ctx, a.ID.String())
+	assert.NoError(t, err)
+
+	bal, err := bm.GetPostedBalance(ctx, a, types.SymbolCipherMtn)
+	assert.NoError(t, err)
+	assert.True(t, NewAccountFunds.Equal(bal), bal.String())
+
+	// creating the campaigns again keeps the amount already granted
+	assert.NoError(t, CreateDefaultCampaigns(ctx, ar.Campaigns(), decimal.NewFromInt(10000)))
+
+	campaigns, err := ar.Campaigns().GetCampaigns(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, campaigns, 1) {
+		assert.Equal(t, DefaultSignUpCampaignID, campaigns[0].ID)
+		assert.True(t, NewAccountFunds.Equal(campaigns[0].Granted), campaigns[0].Granted.String())
+	}
+}
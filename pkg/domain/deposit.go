@@ -0,0 +1,343 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/funding"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// RequiredConfirmations is the number of network confirmations a deposit needs before
+	// it is credited to the account. Symbols without an entry are credited as soon as the
+	// deposit is reported.
+	RequiredConfirmations = map[types.Symbol]int{}
+)
+
+// FundAccountByAddress records a deposit reported by a funding source for the account
+// holding the deposit address. Deposits are held as pending until they reach the
+// required number of confirmations and are then credited to the account. Repeated
+// reports for the same transaction update the confirmations of the pending deposit and
+// reports for a deposit that was already credited only finish any records left
+// unwritten by an interrupted credit.
+func (m *BalanceManager) FundAccountByAddress(ctx context.Context, tr *funding.Transaction) error {
+
+	registry := m.acct.DepositRegistry()
+	reg, err := registry.GetRegistration(ctx, tr.Symbol, tr.TransactionHash, tr.OutputIndex)
+	if err == nil {
+		return m.finishDeposit(ctx, reg)
+	}
+
+	if !errors.Is(err, persist.ErrObjectNotExist) {
+		return fmt.Errorf("BalanceManager::FundAccountByAddress::%w", err)
+	}
+
+	a, err := m.acct.FindByAddress(ctx, tr.Address, tr.Symbol)
+	if err != nil {
+		return err
+	}
+
+	if a == nil {
+		return fmt.Errorf("no address found for hash '%s' and symbol '%s' with value '%v'", tr.Address, tr.Symbol, tr.Amount)
+	}
+
+	d, err := m.acct.Deposits(a).GetDeposit(ctx, ky(persist.DepositID(tr.Symbol, tr.TransactionHash, tr.OutputIndex)))
+	if err != nil {
+		if !errors.Is(err, persist.ErrObjectNotExist) {
+			return fmt.Errorf("BalanceManager::FundAccountByAddress::%w", err)
+		}
+
+		// deposits credited before deposits were tracked only have a transaction record
+		// and were always recorded as the first output of the transaction
+		if tr.OutputIndex == 0 {
+			processed, err := m.depositTransactionExists(ctx, a, tr.TransactionHash)
+			if err != nil || processed {
+				return err
+			}
+		}
+
+		d = persist.NewDeposit(a.ID, tr.Symbol, tr.Amount, tr.Address, tr.TransactionHash, tr.OutputIndex)
+	}
+
+	if d.Status == persist.DepositCredited {
+		return nil
+	}
+
+	if tr.Reference != "" {
+		d.Reference = tr.Reference
+	}
+
+	return m.updateDeposit(ctx, d, tr.Confirmations, tr.Confirmed)
+}
+
+// GetDeposits returns the deposits received by the account, oldest first
+func (m *BalanceManager) GetDeposits(ctx context.Context, a *Account, status ...persist.DepositStatus) ([]*persist.Deposit, error) {
+	list, err := m.acct.Deposits(&persist.Account{ID: a.ID.String()}).GetDeposits(ctx, status...)
+	if err != nil {
+		return nil, fmt.Errorf("BalanceManager::GetDeposits::%w", err)
+	}
+
+	return list, nil
+}
+
+// GetPendingBalances totals the unconfirmed deposits on the account for each symbol.
+// Pending funds are not part of the available balance.
+func (m *BalanceManager) GetPendingBalances(ctx context.Context, a *Account) (map[types.Symbol]decimal.Decimal, error) {
+	list, err := m.GetDeposits(ctx, a, persist.DepositPending)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make(map[types.Symbol]decimal.Decimal)
+	for _, d := range list {
+		pending[d.Symbol] = pending[d.Symbol].Add(d.Amount)
+	}
+
+	return pending, nil
+}
+
+// updateDeposit saves the confirmations reported for a pending deposit and credits the
+// deposit once it is confirmed
+func (m *BalanceManager) updateDeposit(ctx context.Context, d *persist.Deposit, confirmations int, confirmed bool) error {
+
+	if confirmations > d.Confirmations {
+		d.Confirmations = confirmations
+	}
+
+	if confirmed || d.Confirmations >= RequiredConfirmations[d.Symbol] {
+		return m.creditDeposit(ctx, d)
+	}
+
+	d.Updated = persist.NanoTime(time.Now())
+	err := m.acct.Deposits(&persist.Account{ID: d.Account}).SetDeposit(ctx, d)
+	if err != nil {
+		return fmt.Errorf("BalanceManager::updateDeposit::%w", err)
+	}
+
+	return nil
+}
+
+// creditDeposit registers the transaction output in the deposit registry before adding
+// the deposit to the account balance. An output that is already registered was credited
+// by an earlier notification and is not credited again; only the records an
+// interrupted credit left unwritten are finished.
+func (m *BalanceManager) creditDeposit(ctx context.Context, d *persist.Deposit) error {
+
+	registry := m.acct.DepositRegistry()
+	reg := &persist.DepositRegistration{
+		Symbol:          d.Symbol,
+		TransactionHash: d.TransactionHash,
+		OutputIndex:     d.OutputIndex,
+		Account:         d.Account,
+		Deposit:         d.ID,
+		Timestamp:       persist.NanoTime(time.Now()),
+	}
+
+	err := registry.Register(ctx, reg)
+	if err != nil {
+		if errors.Is(err, persist.ErrObjectExists) {
+			reg, err = registry.GetRegistration(ctx, d.Symbol, d.TransactionHash, d.OutputIndex)
+			if err != nil {
+				return fmt.Errorf("BalanceManager::creditDeposit::%w", err)
+			}
+			return m.finishDeposit(ctx, reg)
+		}
+		return fmt.Errorf("BalanceManager::creditDeposit::%w", err)
+	}
+
+	a := &persist.Account{ID: d.Account}
+	err = m.acct.Balances(a, d.Symbol).AddToBalance(ctx, d.Amount)
+	if err != nil {
+		// nothing was credited so the output can be registered by a later attempt
+		if rerr := registry.DeleteRegistration(ctx, d.Symbol, d.TransactionHash, d.OutputIndex); rerr != nil {
+			log.Printf("BalanceManager::creditDeposit: failed to release registration for %s: %s", d.ID, rerr)
+		}
+		return fmt.Errorf("BalanceManager::creditDeposit::%w", err)
+	}
+
+	return m.recordDeposit(ctx, d, reg)
+}
+
+// finishDeposit completes the records of a registered deposit that was credited to the
+// account balance but not yet marked credited
+func (m *BalanceManager) finishDeposit(ctx context.Context, reg *persist.DepositRegistration) error {
+	if reg.Deposit == "" {
+		return nil
+	}
+
+	d, err := m.acct.Deposits(&persist.Account{ID: reg.Account}).GetDeposit(ctx, ky(reg.Deposit))
+	if err != nil {
+		// outputs registered for deposits credited before deposits were tracked have
+		// nothing to finish
+		if errors.Is(err, persist.ErrObjectNotExist) {
+			return nil
+		}
+		return fmt.Errorf("BalanceManager::finishDeposit::%w", err)
+	}
+
+	if d.Status == persist.DepositCredited {
+		return nil
+	}
+
+	return m.recordDeposit(ctx, d, reg)
+}
+
+// recordDeposit writes the transaction record and ledger entries for a deposit credited
+// to the account balance and marks the deposit credited. Each is keyed by the
+// registration so a repeated attempt does not write them twice.
+func (m *BalanceManager) recordDeposit(ctx context.Context, d *persist.Deposit, reg *persist.DepositRegistration) error {
+	a := &persist.Account{ID: d.Account}
+	trepo := m.acct.Transactions(a)
+
+	existing, err := trepo.GetTransactions(ctx)
+	if err != nil {
+		return fmt.Errorf("BalanceManager::recordDeposit::%w", err)
+	}
+
+	var written bool
+	for _, tr := range existing {
+		if tr.Type == persist.DepositTransactionType && tr.TransactionHash == d.TransactionHash && tr.Timestamp.Value() == reg.Timestamp.Value() {
+			written = true
+			break
+		}
+	}
+
+	if !written {
+		err = trepo.SetTransaction(ctx, &persist.Transaction{
+			Type:            persist.DepositTransactionType,
+			TransactionHash: d.TransactionHash,
+			AddressHash:     d.Address,
+			Symbol:          d.Symbol.String(),
+			Quantity:        d.Amount.StringFixedBank(d.Symbol.RoundingPlace()),
+			Timestamp:       reg.Timestamp,
+		})
+		if err != nil {
+			return fmt.Errorf("BalanceManager::recordDeposit::%w", err)
+		}
+	}
+
+	err = m.ledger.RecordDepositCredit(ctx, reg, d.Amount)
+	if err != nil {
+		return fmt.Errorf("BalanceManager::recordDeposit::%w", err)
+	}
+
+	d.Status = persist.DepositCredited
+	d.Updated = persist.NanoTime(time.Now())
+	err = m.acct.Deposits(a).SetDeposit(ctx, d)
+	if err != nil {
+		return fmt.Errorf("BalanceManager::recordDeposit::%w", err)
+	}
+
+	m.rewardDeposit(ctx, d)
+
+	return nil
+}
+
+func (m *BalanceManager) depositTransactionExists(ctx context.Context, a *persist.Account, hash string) (bool, error) {
+	existing, err := m.acct.Transactions(a).GetTransactions(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, tr := range existing {
+		if tr.Type == persist.DepositTransactionType && tr.TransactionHash == hash {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func NewDepositProcessor(bm *BalanceManager, u persist.AuthorizationRepository) *DepositProcessor {
+	return &DepositProcessor{balances: bm, auth: u}
+}
+
+// DepositProcessor polls funding sources for confirmation updates on pending deposits
+// and credits deposits that reach the required confirmations
+type DepositProcessor struct {
+	balances *BalanceManager
+	auth     persist.AuthorizationRepository
+}
+
+// DepositRun summarizes a single pass of the deposit processor
+type DepositRun struct {
+	// Credited are deposits credited to the account during the run
+	Credited []*persist.Deposit
+	// Pending are deposits still waiting for confirmations
+	Pending []*persist.Deposit
+}
+
+// ProcessAll updates the confirmations of every pending deposit
+func (p *DepositProcessor) ProcessAll(ctx context.Context) (*DepositRun, error) {
+	auths, err := p.auth.GetAuthorizations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("DepositProcessor::ProcessAll::%w", err)
+	}
+
+	run := &DepositRun{}
+	done := make(map[string]struct{})
+	for _, auth := range auths {
+		for _, acc := range auth.Accounts {
+			if _, ok := done[acc]; ok {
+				continue
+			}
+			done[acc] = struct{}{}
+
+			id, err := uuid.FromString(acc)
+			if err != nil {
+				continue
+			}
+
+			list, err := p.balances.GetDeposits(ctx, &Account{ID: id}, persist.DepositPending)
+			if err != nil {
+				return run, err
+			}
+
+			for _, d := range list {
+				err = p.process(ctx, d)
+				if err != nil {
+					return run, err
+				}
+
+				if d.Status == persist.DepositCredited {
+					run.Credited = append(run.Credited, d)
+				} else {
+					run.Pending = append(run.Pending, d)
+				}
+			}
+		}
+	}
+
+	return run, nil
+}
+
+func (p *DepositProcessor) process(ctx context.Context, d *persist.Deposit) error {
+	var confirmations int
+	var confirmed bool
+
+	if t, ok := p.balances.fundingSource(d.Symbol).(funding.DepositTracker); ok {
+		var err error
+		confirmations, confirmed, err = t.DepositConfirmations(&funding.Transaction{
+			Symbol:          d.Symbol,
+			TransactionHash: d.TransactionHash,
+			Address:         d.Address,
+			Amount:          d.Amount,
+			OutputIndex:     d.OutputIndex,
+			Reference:       d.Reference,
+			Confirmations:   d.Confirmations,
+		})
+		if err != nil {
+			return fmt.Errorf("DepositProcessor::process::%w", err)
+		}
+	}
+
+	// the required confirmations may have changed since the deposit was reported
+	return p.balances.updateDeposit(ctx, d, confirmations, confirmed)
+}
@@ -0,0 +1,234 @@
+package domain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/easterthebunny/spew-order/internal/funding"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/kv"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+type depositTestSource struct {
+	funding.Source
+	confirmations int
+}
+
+func (s *depositTestSource) DepositConfirmations(*funding.Transaction) (int, bool, error) {
+	return s.confirmations, false, nil
+}
+
+func TestDepositConfirmations(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	lr := kv.NewLedgerRepository(st)
+	ur := kv.NewAuthorizationRepository(st)
+	src := &depositTestSource{Source: funding.NewMockSource()}
+	bm := NewBalanceManager(ar, lr, src)
+	p := NewDepositProcessor(bm, ur)
+	ctx := context.Background()
+
+	defer func(r map[types.Symbol]int) { RequiredConfirmations = r }(RequiredConfirmations)
+	RequiredConfirmations = map[types.Symbol]int{types.SymbolBitcoin: 3}
+
+	acct := NewAccount()
+	assert.NoError(t, ur.SetAuthorization(ctx, &persist.Authorization{ID: uuid.NewV4().String(), Accounts: []string{acct.ID.String()}}))
+	assert.NoError(t, ar.Save(ctx, &persist.Account{
+		ID:        acct.ID.String(),
+		Addresses: []persist.FundingAddress{{Symbol: types.SymbolBitcoin, Address: "address"}},
+	}))
+
+	tr := &funding.Transaction{
+		Symbol:          types.SymbolBitcoin,
+		Address:         "address",
+		TransactionHash: "hash",
+		Amount:          decimal.NewFromInt(2),
+		Confirmations:   1,
+	}
+
+	// unconfirmed deposits are pending and not available
+	assert.NoError(t, bm.FundAccountByAddress(ctx, tr))
+
+	avail, err := bm.GetAvailableBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, avail.IsZero(), avail.String())
+
+	a, err := bm.GetAccount(ctx, acct.ID.String())
+	assert.NoError(t, err)
+	assert.True(t, a.Pending[types.SymbolBitcoin].Equal(decimal.NewFromInt(2)), a.Pending[types.SymbolBitcoin].String())
+
+	// repeated notifications update the confirmations
+	tr.Confirmations = 2
+	assert.NoError(t, bm.FundAccountByAddress(ctx, tr))
+
+	list, err := bm.GetDeposits(ctx, acct)
+	assert.NoError(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, 2, list[0].Confirmations)
+		assert.Equal(t, persist.DepositPending, list[0].Status)
+	}
+
+	// the processor credits deposits once the source reports enough confirmations
+	src.confirmations = 2
+	run, err := p.ProcessAll(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, run.Pending, 1)
+	assert.Len(t, run.Credited, 0)
+
+	src.confirmations = 3
+	run, err = p.ProcessAll(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, run.Credited, 1)
+
+	avail, err = bm.GetAvailableBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, avail.Equal(decimal.NewFromInt(2)), avail.String())
+
+	pending, err := bm.GetPendingBalances(ctx, acct)
+	assert.NoError(t, err)
+	assert.True(t, pending[types.SymbolBitcoin].IsZero())
+
+	// replayed notifications succeed without crediting again
+	tr.Confirmations = 10
+	assert.NoError(t, bm.FundAccountByAddress(ctx, tr))
+
+	avail, err = bm.GetAvailableBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, avail.Equal(decimal.NewFromInt(2)), avail.String())
+
+	// symbols without required confirmations are credited when reported
+	assert.NoError(t, ar.Save(ctx, &persist.Account{
+		ID:        acct.ID.String(),
+		Addresses: []persist.FundingAddress{{Symbol: types.SymbolEthereum, Address: "eth"}},
+	}))
+	assert.NoError(t, bm.FundAccountByAddress(ctx, &funding.Transaction{
+		Symbol:          types.SymbolEthereum,
+		Address:         "eth",
+		TransactionHash: "eth-hash",
+		Amount:          decimal.NewFromInt(1),
+	}))
+
+	avail, err = bm.GetAvailableBalance(ctx, acct, types.SymbolEthereum)
+	assert.NoError(t, err)
+	assert.True(t, avail.Equal(decimal.NewFromInt(1)), avail.String())
+}
+
+func TestDepositRegistry(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	lr := kv.NewLedgerRepository(st)
+	bm := NewBalanceManager(ar, lr, funding.NewMockSource())
+	ctx := context.Background()
+
+	acct := NewAccount()
+	assert.NoError(t, ar.Save(ctx, &persist.Account{
+		ID:        acct.ID.String(),
+		Addresses: []persist.FundingAddress{{Symbol: types.SymbolBitcoin, Address: "address"}},
+	}))
+
+	tr := &funding.Transaction{
+		Symbol:          types.SymbolBitcoin,
+		Address:         "address",
+		TransactionHash: "hash",
+		Amount:          decimal.NewFromInt(1),
+		Confirmed:       true,
+	}
+
+	assert.NoError(t, bm.FundAccountByAddress(ctx, tr))
+	assert.NoError(t, bm.FundAccountByAddress(ctx, tr))
+
+	avail, err := bm.GetAvailableBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, avail.Equal(decimal.NewFromInt(1)), avail.String())
+
+	reg, err := ar.DepositRegistry().GetRegistration(ctx, types.SymbolBitcoin, "hash", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, acct.ID.String(), reg.Account)
+
+	// each output of a transaction is a separate deposit
+	tr.OutputIndex = 1
+	assert.NoError(t, bm.FundAccountByAddress(ctx, tr))
+
+	avail, err = bm.GetAvailableBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, avail.Equal(decimal.NewFromInt(2)), avail.String())
+
+	// an output registered by another process is never credited
+	d := persist.NewDeposit(acct.ID.String(), types.SymbolBitcoin, decimal.NewFromInt(1), "address", "hash", 1)
+	assert.NoError(t, bm.creditDeposit(ctx, d))
+
+	avail, err = bm.GetAvailableBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, avail.Equal(decimal.NewFromInt(2)), avail.String())
+}
+
+func TestDepositRegistry_InterruptedCredit(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	lr := kv.NewLedgerRepository(st)
+	bm := NewBalanceManager(ar, lr, funding.NewMockSource())
+	ctx := context.Background()
+
+	acct := NewAccount()
+	pa := &persist.Account{
+		ID:        acct.ID.String(),
+		Addresses: []persist.FundingAddress{{Symbol: types.SymbolBitcoin, Address: "address"}},
+	}
+	assert.NoError(t, ar.Save(ctx, pa))
+
+	// a credit interrupted after the balance was updated leaves a registered output
+	// with a pending deposit
+	d := persist.NewDeposit(pa.ID, types.SymbolBitcoin, decimal.NewFromInt(1), "address", "hash", 0)
+	assert.NoError(t, ar.Deposits(pa).SetDeposit(ctx, d))
+	assert.NoError(t, ar.DepositRegistry().Register(ctx, &persist.DepositRegistration{
+		Symbol:          d.Symbol,
+		TransactionHash: d.TransactionHash,
+		Account:         d.Account,
+		Deposit:         d.ID,
+		Timestamp:       d.Created,
+	}))
+	assert.NoError(t, ar.Balances(pa, types.SymbolBitcoin).AddToBalance(ctx, d.Amount))
+
+	tr := &funding.Transaction{
+		Symbol:          types.SymbolBitcoin,
+		Address:         "address",
+		TransactionHash: "hash",
+		Amount:          decimal.NewFromInt(1),
+		Confirmed:       true,
+	}
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, bm.FundAccountByAddress(ctx, tr))
+	}
+
+	stored, err := ar.Deposits(pa).GetDeposit(ctx, ky(d.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, persist.DepositCredited, stored.Status)
+
+	avail, err := bm.GetAvailableBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, avail.Equal(decimal.NewFromInt(1)), avail.String())
+
+	records, err := ar.Transactions(pa).GetTransactions(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	// marking the deposit pending again as if the last step was interrupted does not
+	// post the ledger entries twice
+	stored.Status = persist.DepositPending
+	assert.NoError(t, ar.Deposits(pa).SetDeposit(ctx, stored))
+	assert.NoError(t, bm.FundAccountByAddress(ctx, tr))
+
+	records, err = ar.Transactions(pa).GetTransactions(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	tb, err := lr.GetTrialBalance(ctx)
+	assert.NoError(t, err)
+	net := tb.Net(persist.Transfers)[types.SymbolBitcoin]
+	assert.True(t, net.Abs().Equal(decimal.NewFromInt(1)), net.String())
+}
@@ -1,6 +1,8 @@
 package domain
 
 import (
+	"time"
+
 	"github.com/easterthebunny/spew-order/pkg/types"
 	uuid "github.com/satori/go.uuid"
 	"github.com/shopspring/decimal"
@@ -24,9 +26,18 @@ type OrderMessage struct {
 
 // Account ...
 type Account struct {
-	ID        uuid.UUID
-	Balances  map[types.Symbol]decimal.Decimal
+	ID       uuid.UUID
+	Label    string
+	Archived bool
+	Tier     AccountTier
+	Balances map[types.Symbol]decimal.Decimal
+	// Pending are unconfirmed deposits not yet included in balances
+	Pending   map[types.Symbol]decimal.Decimal
 	Addresses map[types.Symbol]string
+	// Allowlist is true when withdrawals are limited to allowlist addresses
+	Allowlist bool
+	// AllowlistDisableAt is when a pending request to turn off the allowlist takes effect
+	AllowlistDisableAt time.Time
 }
 
 func (Account) ActiveSymbols() []types.Symbol {
@@ -46,5 +57,6 @@ func NewAccount() *Account {
 	return &Account{
 		ID:        uuid.NewV4(),
 		Balances:  make(map[types.Symbol]decimal.Decimal),
+		Pending:   make(map[types.Symbol]decimal.Decimal),
 		Addresses: make(map[types.Symbol]string)}
 }
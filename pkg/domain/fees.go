@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/easterthebunny/spew-order/internal/funding"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// WithdrawalFees are the network fees charged on withdrawals of each symbol. Symbols
+	// without an entry are withdrawn without a fee.
+	WithdrawalFees = map[types.Symbol]WithdrawalFee{}
+
+	ErrFeeExceedsAmount = errors.New("withdrawal amount does not cover the withdrawal fee")
+)
+
+// WithdrawalFee describes how the network fee for a symbol is charged. The fee is the
+// fixed amount or, for dynamic fees, the estimate from the funding source when that is
+// higher. Fees are never lower than the symbol minimum fee. Deducted fees come out of
+// the requested amount; otherwise the fee is added to the amount taken from the account.
+type WithdrawalFee struct {
+	Amount  decimal.Decimal
+	Dynamic bool
+	Deduct  bool
+}
+
+// FeeQuote is the fee charged on a withdrawal. Amount is sent to the withdrawal address
+// and Total is taken from the account.
+type FeeQuote struct {
+	Symbol types.Symbol
+	Amount decimal.Decimal
+	Fee    decimal.Decimal
+	Total  decimal.Decimal
+}
+
+// EstimateWithdrawalFee quotes the fee for withdrawing the requested amount of a symbol
+// to an address. The address may be empty when only an estimate is needed.
+func (m *BalanceManager) EstimateWithdrawalFee(s types.Symbol, amt decimal.Decimal, address string) (*FeeQuote, error) {
+
+	q := &FeeQuote{Symbol: s, Amount: amt, Fee: decimal.Zero, Total: amt}
+
+	cfg, ok := WithdrawalFees[s]
+	if !ok {
+		return q, nil
+	}
+
+	fee := decimal.Max(cfg.Amount, s.MinimumFee())
+	if cfg.Dynamic {
+		if e, ok := m.fundingSource(s).(funding.FeeEstimator); ok {
+			est, err := e.EstimateWithdrawalFee(&funding.Transaction{Symbol: s, Address: address, Amount: amt})
+			if err != nil {
+				return nil, fmt.Errorf("BalanceManager::EstimateWithdrawalFee::%w", err)
+			}
+			fee = decimal.Max(fee, est)
+		}
+	}
+
+	place := s.RoundingPlace()
+	q.Fee = fee.Shift(place).Ceil().Shift(-place)
+	if cfg.Deduct {
+		q.Amount = amt.Sub(q.Fee)
+		if !q.Amount.IsPositive() {
+			return nil, ErrFeeExceedsAmount
+		}
+	} else {
+		q.Total = amt.Add(q.Fee)
+	}
+
+	return q, nil
+}
@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/easterthebunny/spew-order/internal/funding"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/kv"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+type feeTestSource struct {
+	withdrawalTestSource
+	estimate decimal.Decimal
+}
+
+func (s *feeTestSource) EstimateWithdrawalFee(*funding.Transaction) (decimal.Decimal, error) {
+	return s.estimate, nil
+}
+
+func TestWithdrawalFees(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	lr := kv.NewLedgerRepository(st)
+	ur := kv.NewAuthorizationRepository(st)
+	src := &feeTestSource{withdrawalTestSource: withdrawalTestSource{Source: funding.NewMockSource(), confirmed: true}}
+	bm := NewBalanceManager(ar, lr, src)
+	p := NewWithdrawalProcessor(bm, ur)
+	ctx := context.Background()
+
+	defer func(f map[types.Symbol]WithdrawalFee) { WithdrawalFees = f }(WithdrawalFees)
+	WithdrawalFees = map[types.Symbol]WithdrawalFee{}
+
+	// symbols without a configured fee are free to withdraw
+	q, err := bm.EstimateWithdrawalFee(types.SymbolBitcoin, decimal.NewFromInt(1), "")
+	assert.NoError(t, err)
+	assert.True(t, q.Fee.IsZero())
+
+	WithdrawalFees[types.SymbolBitcoin] = WithdrawalFee{Amount: decimal.NewFromFloat(0.001)}
+
+	q, err = bm.EstimateWithdrawalFee(types.SymbolBitcoin, decimal.NewFromInt(1), "")
+	assert.NoError(t, err)
+	assert.True(t, q.Amount.Equal(decimal.NewFromInt(1)), q.Amount.String())
+	assert.True(t, q.Total.Equal(decimal.NewFromFloat(1.001)), q.Total.String())
+
+	// dynamic fees use the source estimate when it is higher
+	src.estimate = decimal.NewFromFloat(0.005)
+	WithdrawalFees[types.SymbolBitcoin] = WithdrawalFee{Amount: decimal.NewFromFloat(0.001), Dynamic: true}
+
+	q, err = bm.EstimateWithdrawalFee(types.SymbolBitcoin, decimal.NewFromInt(1), "")
+	assert.NoError(t, err)
+	assert.True(t, q.Fee.Equal(decimal.NewFromFloat(0.005)), q.Fee.String())
+
+	// deducted fees come out of the amount sent
+	WithdrawalFees[types.SymbolBitcoin] = WithdrawalFee{Amount: decimal.NewFromFloat(0.001), Deduct: true}
+
+	q, err = bm.EstimateWithdrawalFee(types.SymbolBitcoin, decimal.NewFromInt(1), "")
+	assert.NoError(t, err)
+	assert.True(t, q.Amount.Equal(decimal.NewFromFloat(0.999)), q.Amount.String())
+	assert.True(t, q.Total.Equal(decimal.NewFromInt(1)), q.Total.String())
+
+	_, err = bm.EstimateWithdrawalFee(types.SymbolBitcoin, decimal.NewFromFloat(0.001), "")
+	assert.ErrorIs(t, err, ErrFeeExceedsAmount)
+
+	acct := NewAccount()
+	assert.NoError(t, ur.SetAuthorization(ctx, &persist.Authorization{ID: uuid.NewV4().String(), Accounts: []string{acct.ID.String()}}))
+	assert.NoError(t, bm.PostAmtToBalance(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(2)))
+
+	WithdrawalFees[types.SymbolBitcoin] = WithdrawalFee{Amount: decimal.NewFromFloat(0.001)}
+
+	w, err := bm.WithdrawFunds(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(1), "address", "")
+	assert.NoError(t, err)
+	assert.True(t, w.Fee.Equal(decimal.NewFromFloat(0.001)), w.Fee.String())
+
+	avail, err := bm.GetAvailableBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, avail.Equal(decimal.NewFromFloat(0.999)), avail.String())
+
+	// the fee quoted with the request is charged even if the configured fee changes
+	WithdrawalFees[types.SymbolBitcoin] = WithdrawalFee{Amount: decimal.NewFromFloat(0.1)}
+
+	run, err := p.ProcessAll(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, run.Completed, 1)
+
+	posted, err := bm.GetPostedBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, posted.Equal(decimal.NewFromFloat(0.999)), posted.String())
+
+	trs, err := ar.Transactions(&persist.Account{ID: acct.ID.String()}).GetTransactions(ctx)
+	assert.NoError(t, err)
+
+	var fees []*persist.Transaction
+	for _, tr := range trs {
+		if tr.Type == persist.FeeTransactionType {
+			fees = append(fees, tr)
+		}
+	}
+
+	if assert.Len(t, fees, 1) {
+		assert.Equal(t, "-0.00100000", fees[0].Quantity)
+		assert.Equal(t, "hash", fees[0].TransactionHash)
+	}
+
+	// the fee is revenue in the main ledger
+	tb, err := lr.GetTrialBalance(ctx)
+	assert.NoError(t, err)
+	sales := tb.Net(persist.Sales)[types.SymbolBitcoin]
+	assert.True(t, sales.Abs().Equal(decimal.NewFromFloat(0.001)), sales.String())
+}
@@ -0,0 +1,170 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+)
+
+// HoldGracePeriod is the minimum age of a hold before it can be reported as orphaned.
+// Withdrawals and new orders place holds before anything references them, so recent
+// holds are assumed to belong to requests still in progress.
+var HoldGracePeriod = 10 * time.Minute
+
+// OrphanedHold is a balance hold that is not referenced by any open order or withdrawal
+type OrphanedHold struct {
+	Account string
+	Symbol  types.Symbol
+	Hold    *persist.BalanceItem
+}
+
+func NewHoldAuditor(a persist.AccountRepository, u persist.AuthorizationRepository, au persist.AuditRepository) *HoldAuditor {
+	return &HoldAuditor{acct: a, auth: u, audit: au}
+}
+
+// HoldAuditor finds and releases balance holds that leaked from failed order and
+// withdrawal processing
+type HoldAuditor struct {
+	acct  persist.AccountRepository
+	auth  persist.AuthorizationRepository
+	audit persist.AuditRepository
+}
+
+// FindOrphanedHolds returns every hold older than the grace period that does not
+// belong to an open order or withdrawal on the same account
+func (h *HoldAuditor) FindOrphanedHolds(ctx context.Context) ([]OrphanedHold, error) {
+	auths, err := h.auth.GetAuthorizations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("HoldAuditor::FindOrphanedHolds::%w", err)
+	}
+
+	orphans := []OrphanedHold{}
+	cutoff := time.Now().Add(-HoldGracePeriod)
+	for _, auth := range auths {
+		for _, acc := range auth.Accounts {
+			a := &persist.Account{ID: acc}
+
+			refs, err := h.referencedHolds(ctx, a)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, s := range (Account{}).ActiveSymbols() {
+				holds, err := h.acct.Balances(a, s).FindHolds(ctx)
+				if err != nil {
+					return nil, fmt.Errorf("HoldAuditor::FindOrphanedHolds::%w", err)
+				}
+
+				for _, hold := range holds {
+					if _, ok := refs[hold.ID]; ok {
+						continue
+					}
+
+					if time.Time(hold.Timestamp).After(cutoff) {
+						continue
+					}
+
+					orphans = append(orphans, OrphanedHold{Account: acc, Symbol: s, Hold: hold})
+				}
+			}
+		}
+	}
+
+	return orphans, nil
+}
+
+// FindFlaggedOrders returns the open orders flagged for review after they failed
+// part way through settlement. Their holds are kept until they are resolved.
+func (h *HoldAuditor) FindFlaggedOrders(ctx context.Context) ([]*persist.Order, error) {
+	auths, err := h.auth.GetAuthorizations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("HoldAuditor::FindFlaggedOrders::%w", err)
+	}
+
+	flagged := []*persist.Order{}
+	for _, auth := range auths {
+		for _, acc := range auth.Accounts {
+			orders, err := h.acct.Orders(&persist.Account{ID: acc}).GetOrdersByStatus(ctx, persist.StatusOpen, persist.StatusPartial)
+			if err != nil {
+				return nil, fmt.Errorf("HoldAuditor::FindFlaggedOrders::%w", err)
+			}
+
+			for _, o := range orders {
+				if o.Reason != "" {
+					flagged = append(flagged, o)
+				}
+			}
+		}
+	}
+
+	return flagged, nil
+}
+
+// ReleaseOrphanedHolds deletes each hold and records the repair in the audit log.
+// Holds that were already removed are skipped.
+func (h *HoldAuditor) ReleaseOrphanedHolds(ctx context.Context, holds []OrphanedHold) (released []OrphanedHold, err error) {
+	for _, o := range holds {
+		a := &persist.Account{ID: o.Account}
+
+		err = h.acct.Balances(a, o.Symbol).DeleteHold(ctx, ky(o.Hold.ID))
+		if err != nil {
+			if errors.Is(err, persist.ErrObjectNotExist) {
+				err = nil
+				continue
+			}
+			err = fmt.Errorf("HoldAuditor::ReleaseOrphanedHolds::%w", err)
+			return
+		}
+
+		e := persist.NewAuditEntry(persist.HoldReleasedAuditType)
+		e.Account = o.Account
+		e.Symbol = o.Symbol.String()
+		e.Reference = o.Hold.ID
+		e.Amount = o.Hold.Amount.StringFixedBank(o.Symbol.RoundingPlace())
+		e.Detail = fmt.Sprintf("released orphaned hold created at %s", time.Time(o.Hold.Timestamp).UTC().Format(time.RFC3339))
+
+		err = h.audit.SetAuditEntry(ctx, e)
+		if err != nil {
+			err = fmt.Errorf("HoldAuditor::ReleaseOrphanedHolds::%w", err)
+			return
+		}
+
+		released = append(released, o)
+	}
+
+	return
+}
+
+// referencedHolds returns the set of hold ids still needed by orders and withdrawals
+// on the account
+func (h *HoldAuditor) referencedHolds(ctx context.Context, a *persist.Account) (map[string]struct{}, error) {
+	orders, err := h.acct.Orders(a).GetOrdersByStatus(ctx, persist.StatusOpen, persist.StatusPartial)
+	if err != nil {
+		return nil, fmt.Errorf("HoldAuditor::referencedHolds::%w", err)
+	}
+
+	refs := make(map[string]struct{})
+	for _, o := range orders {
+		refs[o.Base.HoldID] = struct{}{}
+		if o.Base.FeeHoldID != "" {
+			refs[o.Base.FeeHoldID] = struct{}{}
+		}
+	}
+
+	withdrawals, err := h.acct.Withdrawals(a).GetWithdrawals(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("HoldAuditor::referencedHolds::%w", err)
+	}
+
+	for _, w := range withdrawals {
+		if !w.Status.Final() {
+			refs[w.HoldID] = struct{}{}
+		}
+	}
+
+	return refs, nil
+}
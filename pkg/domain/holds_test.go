@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/funding"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/kv"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHoldAuditor(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	ur := kv.NewAuthorizationRepository(st)
+	au := kv.NewAuditRepository(st)
+	bm := NewBalanceManager(ar, kv.NewLedgerRepository(st), funding.NewMockSource())
+	ctx := context.Background()
+
+	order := placeTestOrder(t, ctx, ar, bm, newMarketBookOrder(12700, 0.01, types.ActionTypeSell))
+	acct := &persist.Account{ID: order.Account.String()}
+
+	err := ur.SetAuthorization(ctx, &persist.Authorization{ID: "auth", Accounts: []string{acct.ID}})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	// a hold left behind by a failed request and one still inside the grace period
+	old := persist.NewBalanceItem(decimal.NewFromFloat(0.5))
+	old.Timestamp = persist.NanoTime(time.Now().Add(-2 * HoldGracePeriod))
+	recent := persist.NewBalanceItem(decimal.NewFromFloat(0.25))
+
+	for _, h := range []*persist.BalanceItem{old, recent} {
+		if err := ar.Balances(acct, types.SymbolBitcoin).CreateHold(ctx, h); err != nil {
+			t.Fatalf("error: %s", err)
+		}
+	}
+
+	auditor := NewHoldAuditor(ar, ur, au)
+
+	orphans, err := auditor.FindOrphanedHolds(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, orphans, 1) {
+		assert.Equal(t, old.ID, orphans[0].Hold.ID)
+		assert.Equal(t, types.SymbolBitcoin, orphans[0].Symbol)
+	}
+
+	released, err := auditor.ReleaseOrphanedHolds(ctx, orphans)
+	assert.NoError(t, err)
+	assert.Len(t, released, 1)
+
+	holds, err := ar.Balances(acct, types.SymbolBitcoin).FindHolds(ctx)
+	assert.NoError(t, err)
+	for _, h := range holds {
+		assert.NotEqual(t, old.ID, h.ID)
+	}
+
+	entries, err := au.GetAuditEntries(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, persist.HoldReleasedAuditType, entries[0].Type)
+		assert.Equal(t, old.ID, entries[0].Reference)
+		assert.Equal(t, acct.ID, entries[0].Account)
+	}
+
+	// releasing again is a no-op
+	released, err = auditor.ReleaseOrphanedHolds(ctx, orphans)
+	assert.NoError(t, err)
+	assert.Len(t, released, 0)
+
+	orphans, err = auditor.FindOrphanedHolds(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, orphans, 0)
+}
+
+func TestHoldAuditor_FindFlaggedOrders(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	ur := kv.NewAuthorizationRepository(st)
+	bm := NewBalanceManager(ar, kv.NewLedgerRepository(st), funding.NewMockSource())
+	ctx := context.Background()
+
+	order := placeTestOrder(t, ctx, ar, bm, newMarketBookOrder(12700, 0.01, types.ActionTypeSell))
+	other := placeTestOrder(t, ctx, ar, bm, newMarketBookOrder(12701, 0.01, types.ActionTypeSell))
+
+	err := ur.SetAuthorization(ctx, &persist.Authorization{ID: "auth", Accounts: []string{order.Account.String(), other.Account.String()}})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	assert.NoError(t, bm.FlagOrder(ctx, order, persist.RejectReasonSettlementFailed))
+
+	auditor := NewHoldAuditor(ar, ur, kv.NewAuditRepository(st))
+	flagged, err := auditor.FindFlaggedOrders(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, flagged, 1) {
+		assert.Equal(t, order.ID, flagged[0].Base.ID)
+		assert.Equal(t, persist.StatusOpen, flagged[0].Status)
+		assert.Equal(t, persist.RejectReasonSettlementFailed, flagged[0].Reason)
+	}
+
+	// the holds of a flagged order are not orphaned
+	HoldGracePeriod = 0
+	defer func() { HoldGracePeriod = 10 * time.Minute }()
+
+	orphans, err := auditor.FindOrphanedHolds(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, orphans, 0)
+}
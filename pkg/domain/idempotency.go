@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/persist"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	// IdempotencyWindow is the length of time a client supplied key is retained. A
+	// request repeated with the same key inside this window returns the result of
+	// the original request.
+	IdempotencyWindow = 24 * time.Hour
+	// IdempotencyPendingTimeout is the length of time a key remains reserved for a
+	// request that never completed, after which the key can be used again
+	IdempotencyPendingTimeout = time.Minute
+
+	ErrIdempotencyKeyReused        = errors.New("idempotency key already used for a different request type")
+	ErrIdempotentRequestInProgress = errors.New("a request with the same idempotency key is in progress")
+)
+
+// FindOrderByKey returns the order created with the client supplied key if the key
+// was used within the idempotency window. A nil order is returned otherwise.
+func (m *BalanceManager) FindOrderByKey(ctx context.Context, a *Account, key string) (*persist.Order, error) {
+
+	rec, err := m.findKey(ctx, a, key)
+	if err != nil || rec == nil || rec.Reference == "" {
+		return nil, err
+	}
+
+	if rec.Type != persist.OrderIdempotencyType {
+		return nil, ErrIdempotencyKeyReused
+	}
+
+	return m.orderForKey(ctx, a, rec)
+}
+
+// ReserveOrderKey claims a client supplied key for a new order. If the key was
+// already used for an order within the idempotency window, that order is returned
+// and the key is left unchanged.
+func (m *BalanceManager) ReserveOrderKey(ctx context.Context, a *Account, key string) (*persist.Order, error) {
+
+	rec, err := m.reserveKey(ctx, a, persist.OrderIdempotencyType, key)
+	if err != nil || rec == nil {
+		return nil, err
+	}
+
+	return m.orderForKey(ctx, a, rec)
+}
+
+// ReleaseKey removes a reserved key so that it can be used again. It should be
+// called when a request fails before any lasting change is made.
+func (m *BalanceManager) ReleaseKey(ctx context.Context, a *Account, key string) error {
+	r := m.acct.Idempotency(&persist.Account{ID: a.ID.String()})
+	return r.DeleteRecord(ctx, ky(key))
+}
+
+func (m *BalanceManager) orderForKey(ctx context.Context, a *Account, rec *persist.IdempotencyRecord) (*persist.Order, error) {
+
+	id, err := uuid.FromString(rec.Reference)
+	if err != nil {
+		return nil, err
+	}
+
+	rep := m.acct.Orders(&persist.Account{ID: a.ID.String()})
+	return rep.GetOrder(ctx, id)
+}
+
+// findKey returns the record for a key if it was used within the idempotency window
+func (m *BalanceManager) findKey(ctx context.Context, a *Account, key string) (*persist.IdempotencyRecord, error) {
+
+	r := m.acct.Idempotency(&persist.Account{ID: a.ID.String()})
+	rec, err := r.GetRecord(ctx, ky(key))
+	if err != nil {
+		if errors.Is(err, persist.ErrObjectNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("BalanceManager::findKey::%w", err)
+	}
+
+	if keyExpired(rec) {
+		return nil, nil
+	}
+
+	return rec, nil
+}
+
+// keyExpired returns true when a completed record is outside the idempotency window or
+// a reserved key was never completed, so the key can be used again
+func keyExpired(rec *persist.IdempotencyRecord) bool {
+	age := time.Since(time.Time(rec.Timestamp))
+	return age > IdempotencyWindow || (rec.Reference == "" && age > IdempotencyPendingTimeout)
+}
+
+// reserveKey returns the completed record for a key used within the idempotency
+// window. If no such record exists, the key is reserved for a new request and a
+// nil record is returned. The reservation is a conditional write so only one of
+// several concurrent requests with the same key can reserve it.
+func (m *BalanceManager) reserveKey(ctx context.Context, a *Account, t persist.IdempotencyType, key string) (*persist.IdempotencyRecord, error) {
+
+	rec, err := m.findKey(ctx, a, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if rec != nil {
+		return checkKey(rec, t)
+	}
+
+	r := m.acct.Idempotency(&persist.Account{ID: a.ID.String()})
+	err = r.CreateRecord(ctx, &persist.IdempotencyRecord{
+		Key:       key,
+		Type:      t,
+		Timestamp: persist.NanoTime(time.Now()),
+	}, keyExpired)
+	if err == nil {
+		return nil, nil
+	}
+
+	if !errors.Is(err, persist.ErrObjectExists) {
+		return nil, fmt.Errorf("BalanceManager::reserveKey::%w", err)
+	}
+
+	// another request reserved the key after it was read
+	rec, err = m.findKey(ctx, a, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if rec == nil {
+		return nil, ErrIdempotentRequestInProgress
+	}
+
+	return checkKey(rec, t)
+}
+
+// checkKey returns the record when it is a completed request of the same type
+func checkKey(rec *persist.IdempotencyRecord, t persist.IdempotencyType) (*persist.IdempotencyRecord, error) {
+	if rec.Type != t {
+		return nil, ErrIdempotencyKeyReused
+	}
+
+	if rec.Reference == "" {
+		return nil, ErrIdempotentRequestInProgress
+	}
+
+	return rec, nil
+}
+
+// completeKey links a reserved key to the object created by the request
+func (m *BalanceManager) completeKey(ctx context.Context, a *Account, t persist.IdempotencyType, key string, ref string) error {
+
+	r := m.acct.Idempotency(&persist.Account{ID: a.ID.String()})
+	return r.SetRecord(ctx, &persist.IdempotencyRecord{
+		Key:       key,
+		Type:      t,
+		Reference: ref,
+		Timestamp: persist.NanoTime(time.Now()),
+	})
+}
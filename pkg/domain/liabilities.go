@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/merkle"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	uuid "github.com/satori/go.uuid"
+)
+
+func NewLiabilityProver(bm *BalanceManager, u persist.AuthorizationRepository, r persist.LiabilityRepository) *LiabilityProver {
+	return &LiabilityProver{balances: bm, auth: u, trees: r}
+}
+
+// LiabilityProver publishes the total owed to customers as the root of a Merkle sum tree
+// so each customer can check that their balance is counted in the total
+type LiabilityProver struct {
+	balances *BalanceManager
+	auth     persist.AuthorizationRepository
+	trees    persist.LiabilityRepository
+}
+
+// Generate builds a tree over the posted balances of every account and stores the root
+// along with the inclusion proof for each account. Each account is hashed with a new
+// random nonce so leaves cannot be linked to accounts between trees.
+func (lp *LiabilityProver) Generate(ctx context.Context, t time.Time) (*persist.LiabilityTree, error) {
+
+	auths, err := lp.auth.GetAuthorizations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("LiabilityProver::Generate::%w", err)
+	}
+
+	type entry struct {
+		account string
+		nonce   string
+		leaf    merkle.Node
+	}
+
+	var entries []entry
+	done := make(map[string]struct{})
+	for _, auth := range auths {
+		for _, acc := range auth.Accounts {
+			if _, ok := done[acc]; ok {
+				continue
+			}
+			done[acc] = struct{}{}
+
+			id, err := uuid.FromString(acc)
+			if err != nil {
+				continue
+			}
+
+			sums := make(merkle.Sums)
+			for _, s := range types.Symbols {
+				bal, err := lp.balances.GetPostedBalance(ctx, &Account{ID: id}, s)
+				if err != nil {
+					return nil, fmt.Errorf("LiabilityProver::Generate::%w", err)
+				}
+
+				if !bal.IsZero() {
+					sums[s.String()] = bal
+				}
+			}
+
+			nonce, err := newNonce()
+			if err != nil {
+				return nil, fmt.Errorf("LiabilityProver::Generate::%w", err)
+			}
+
+			entries = append(entries, entry{
+				account: acc,
+				nonce:   nonce,
+				leaf:    merkle.NewLeaf(merkle.AccountHash(acc, nonce), sums),
+			})
+		}
+	}
+
+	// order leaves by hash so the position of a leaf says nothing about the account
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].leaf.Hash < entries[j].leaf.Hash
+	})
+
+	leaves := make([]merkle.Node, len(entries))
+	for i, e := range entries {
+		leaves[i] = e.leaf
+	}
+
+	tree, err := merkle.New(leaves)
+	if err != nil {
+		return nil, fmt.Errorf("LiabilityProver::Generate::%w", err)
+	}
+
+	lt := &persist.LiabilityTree{
+		ID:        uuid.NewV4().String(),
+		Timestamp: persist.NanoTime(t),
+		Root:      tree.Root(),
+		Accounts:  len(entries),
+	}
+
+	// proofs are saved before the tree so a published tree always has every proof
+	for i, e := range entries {
+		p, err := tree.Proof(i)
+		if err != nil {
+			return nil, fmt.Errorf("LiabilityProver::Generate::%w", err)
+		}
+
+		err = lp.trees.SetLiabilityProof(ctx, &persist.LiabilityProof{
+			TreeID:  lt.ID,
+			Account: e.account,
+			Nonce:   e.nonce,
+			Proof:   *p,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("LiabilityProver::Generate::%w", err)
+		}
+	}
+
+	err = lp.trees.SetLiabilityTree(ctx, lt)
+	if err != nil {
+		return nil, fmt.Errorf("LiabilityProver::Generate::%w", err)
+	}
+
+	return lt, nil
+}
+
+// GetLatestTree returns the most recently published liability tree
+func (lp *LiabilityProver) GetLatestTree(ctx context.Context) (*persist.LiabilityTree, error) {
+	t, err := lp.trees.GetLatestLiabilityTree(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("LiabilityProver::GetLatestTree::%w", err)
+	}
+
+	return t, nil
+}
+
+// GetProof returns the latest liability tree and the inclusion proof of the account in it
+func (lp *LiabilityProver) GetProof(ctx context.Context, a *Account) (*persist.LiabilityTree, *persist.LiabilityProof, error) {
+	t, err := lp.trees.GetLatestLiabilityTree(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("LiabilityProver::GetProof::%w", err)
+	}
+
+	p, err := lp.trees.GetLiabilityProof(ctx, ky(t.ID), a.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("LiabilityProver::GetProof::%w", err)
+	}
+
+	return t, p, nil
+}
+
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
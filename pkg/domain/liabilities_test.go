@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/funding"
+	"github.com/easterthebunny/spew-order/internal/merkle"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/kv"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiabilityProofs(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	lr := kv.NewLedgerRepository(st)
+	ur := kv.NewAuthorizationRepository(st)
+	bm := NewBalanceManager(ar, lr, funding.NewMockSource())
+	lp := NewLiabilityProver(bm, ur, kv.NewLiabilityRepository(st))
+	ctx := context.Background()
+
+	_, err := lp.GetLatestTree(ctx)
+	assert.ErrorIs(t, err, persist.ErrObjectNotExist)
+
+	var accounts []*Account
+	for i := 1; i <= 3; i++ {
+		acct := NewAccount()
+		accounts = append(accounts, acct)
+		assert.NoError(t, ur.SetAuthorization(ctx, &persist.Authorization{ID: uuid.NewV4().String(), Accounts: []string{acct.ID.String()}}))
+		assert.NoError(t, ar.Save(ctx, &persist.Account{ID: acct.ID.String()}))
+		assert.NoError(t, bm.FundAccountByID(ctx, acct.ID, types.SymbolBitcoin, decimal.NewFromInt(int64(i))))
+	}
+	assert.NoError(t, bm.FundAccountByID(ctx, accounts[0].ID, types.SymbolCardano, decimal.NewFromInt(7)))
+
+	tree, err := lp.Generate(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, tree.Accounts)
+	assert.True(t, tree.Root.Sums[types.SymbolBitcoin.String()].Equal(decimal.NewFromInt(6)))
+	assert.True(t, tree.Root.Sums[types.SymbolCardano.String()].Equal(decimal.NewFromInt(7)))
+
+	latest, err := lp.GetLatestTree(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, tree.ID, latest.ID)
+
+	for i, acct := range accounts {
+		lt, p, err := lp.GetProof(ctx, acct)
+		assert.NoError(t, err)
+		assert.Equal(t, tree.ID, lt.ID)
+		assert.NoError(t, p.Proof.Verify(lt.Root))
+
+		// the leaf is built from the account id, nonce and balance
+		leaf := merkle.NewLeaf(merkle.AccountHash(acct.ID.String(), p.Nonce), p.Proof.Leaf.Sums)
+		assert.Equal(t, leaf.Hash, p.Proof.Leaf.Hash)
+		assert.True(t, p.Proof.Leaf.Sums[types.SymbolBitcoin.String()].Equal(decimal.NewFromInt(int64(i+1))))
+	}
+
+	// a new tree uses new nonces
+	next, err := lp.Generate(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.NotEqual(t, tree.Root.Hash, next.Root.Hash)
+
+	latest, err = lp.GetLatestTree(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, next.ID, latest.ID)
+}
@@ -0,0 +1,132 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
+)
+
+// AccountTier groups accounts that share the same withdrawal limits
+type AccountTier string
+
+const (
+	// DefaultAccountTier applies to accounts without an assigned tier
+	DefaultAccountTier AccountTier = "standard"
+
+	dailyLimitWindow   = 24 * time.Hour
+	monthlyLimitWindow = 30 * 24 * time.Hour
+)
+
+var (
+	// WithdrawalLimits are the withdrawal limits for each account tier and symbol. Tiers
+	// and symbols without an entry are not limited.
+	WithdrawalLimits = map[AccountTier]map[types.Symbol]WithdrawalLimit{}
+
+	ErrWithdrawalNotInReview = errors.New("withdrawal is not waiting for review")
+)
+
+// WithdrawalLimit caps the withdrawals of a single symbol. Daily and monthly totals are
+// rolling 24 hour and 30 day windows. A zero amount disables that limit.
+type WithdrawalLimit struct {
+	PerTransaction decimal.Decimal
+	Daily          decimal.Decimal
+	Monthly        decimal.Decimal
+}
+
+// TierOrDefault returns the account tier, or the default tier when none is assigned
+func (a Account) TierOrDefault() AccountTier {
+	if a.Tier == "" {
+		return DefaultAccountTier
+	}
+	return a.Tier
+}
+
+// SetAccountTier assigns the account to a withdrawal limit tier
+func (m *BalanceManager) SetAccountTier(ctx context.Context, a *Account, t AccountTier) error {
+	return m.updateAccount(ctx, a, func(p *persist.Account) error {
+		p.Tier = string(t)
+		return nil
+	})
+}
+
+// CheckWithdrawalLimits returns a description of the first withdrawal limit the
+// withdrawal would exceed, or an empty string when the withdrawal is within limits.
+// Only withdrawals requested before w count toward rolling totals, excluding those
+// that failed or were refunded.
+func (m *BalanceManager) CheckWithdrawalLimits(ctx context.Context, a *Account, w *persist.Withdrawal) (string, error) {
+
+	tier := a.TierOrDefault()
+	limit, ok := WithdrawalLimits[tier][w.Symbol]
+	if !ok {
+		return "", nil
+	}
+
+	place := w.Symbol.RoundingPlace()
+	if limit.PerTransaction.IsPositive() && w.Amount.GreaterThan(limit.PerTransaction) {
+		return fmt.Sprintf("exceeds %s per transaction limit of %s %s", tier, limit.PerTransaction.StringFixedBank(place), w.Symbol), nil
+	}
+
+	list, err := m.GetWithdrawals(ctx, a)
+	if err != nil {
+		return "", fmt.Errorf("BalanceManager::CheckWithdrawalLimits::%w", err)
+	}
+
+	now := time.Now()
+	daily := w.Amount
+	monthly := w.Amount
+	for _, x := range list {
+		created := time.Time(x.Created)
+		if x.ID == w.ID || x.Symbol != w.Symbol || !created.Before(time.Time(w.Created)) {
+			continue
+		}
+
+		if x.Status == persist.WithdrawalFailed || x.Status == persist.WithdrawalRefunded {
+			continue
+		}
+
+		if now.Sub(created) < dailyLimitWindow {
+			daily = daily.Add(x.Amount)
+		}
+
+		if now.Sub(created) < monthlyLimitWindow {
+			monthly = monthly.Add(x.Amount)
+		}
+	}
+
+	if limit.Daily.IsPositive() && daily.GreaterThan(limit.Daily) {
+		return fmt.Sprintf("exceeds %s 24 hour limit of %s %s", tier, limit.Daily.StringFixedBank(place), w.Symbol), nil
+	}
+
+	if limit.Monthly.IsPositive() && monthly.GreaterThan(limit.Monthly) {
+		return fmt.Sprintf("exceeds %s 30 day limit of %s %s", tier, limit.Monthly.StringFixedBank(place), w.Symbol), nil
+	}
+
+	return "", nil
+}
+
+// ReviewWithdrawal resolves a withdrawal held for manual review. Approved withdrawals
+// are sent by the WithdrawalProcessor without checking limits again; rejected
+// withdrawals fail and are refunded.
+func (m *BalanceManager) ReviewWithdrawal(ctx context.Context, w *persist.Withdrawal, approve bool, note string) error {
+
+	if w.Status != persist.WithdrawalReview {
+		return ErrWithdrawalNotInReview
+	}
+
+	if approve {
+		w.Reason = ""
+		return m.setWithdrawalStatus(ctx, w, persist.WithdrawalApproved)
+	}
+
+	reason := "rejected in review"
+	if note != "" {
+		reason = fmt.Sprintf("%s: %s", reason, note)
+	}
+
+	return m.failWithdrawal(ctx, w, reason)
+}
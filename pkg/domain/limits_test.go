@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/easterthebunny/spew-order/internal/funding"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/kv"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithdrawalLimits(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	ur := kv.NewAuthorizationRepository(st)
+	bm := NewBalanceManager(ar, kv.NewLedgerRepository(st), funding.NewMockSource())
+	p := NewWithdrawalProcessor(bm, ur)
+	ctx := context.Background()
+
+	defer func(l map[AccountTier]map[types.Symbol]WithdrawalLimit) { WithdrawalLimits = l }(WithdrawalLimits)
+	WithdrawalLimits = map[AccountTier]map[types.Symbol]WithdrawalLimit{
+		DefaultAccountTier: {
+			types.SymbolBitcoin: {
+				PerTransaction: decimal.NewFromInt(2),
+				Daily:          decimal.NewFromInt(3),
+			},
+		},
+	}
+
+	acct, err := bm.GetAccount(ctx, uuid.NewV4().String())
+	assert.NoError(t, err)
+	assert.NoError(t, ur.SetAuthorization(ctx, &persist.Authorization{ID: uuid.NewV4().String(), Accounts: []string{acct.ID.String()}}))
+	assert.NoError(t, bm.PostAmtToBalance(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(10)))
+
+	first, err := bm.WithdrawFunds(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(2), "address", "")
+	assert.NoError(t, err)
+	assert.Equal(t, persist.WithdrawalRequested, first.Status)
+
+	over, err := bm.WithdrawFunds(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(3), "address", "")
+	assert.NoError(t, err)
+	assert.Equal(t, persist.WithdrawalReview, over.Status)
+	assert.Contains(t, over.Reason, "per transaction")
+
+	// the withdrawal in review counts toward the rolling total
+	second, err := bm.WithdrawFunds(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(1), "address", "")
+	assert.NoError(t, err)
+	assert.Equal(t, persist.WithdrawalReview, second.Status)
+	assert.Contains(t, second.Reason, "24 hour")
+
+	run, err := p.ProcessAll(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, run.Completed, 1)
+	assert.Len(t, run.Review, 2)
+
+	review, err := p.FindReview(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, review, 2)
+
+	assert.NoError(t, bm.ReviewWithdrawal(ctx, over, false, "unverified address"))
+	assert.NoError(t, bm.ReviewWithdrawal(ctx, second, true, ""))
+	assert.ErrorIs(t, bm.ReviewWithdrawal(ctx, second, true, ""), ErrWithdrawalNotInReview)
+
+	run, err = p.ProcessAll(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, run.Completed, 2)
+
+	over, err = bm.GetWithdrawal(ctx, acct, ky(over.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, persist.WithdrawalRefunded, over.Status)
+	assert.Equal(t, "rejected in review: unverified address", over.Reason)
+
+	second, err = bm.GetWithdrawal(ctx, acct, ky(second.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, persist.WithdrawalConfirmed, second.Status)
+
+	posted, err := bm.GetPostedBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, posted.Equal(decimal.NewFromInt(7)), posted.String())
+
+	// accounts in an unlimited tier are not reviewed
+	assert.NoError(t, bm.SetAccountTier(ctx, acct, "unlimited"))
+	w, err := bm.WithdrawFunds(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(5), "address", "")
+	assert.NoError(t, err)
+	assert.Equal(t, persist.WithdrawalRequested, w.Status)
+}
@@ -0,0 +1,147 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ReconciliationThresholds is the largest difference between customer liabilities and
+	// custodian holdings that is not reported as a discrepancy. Symbols without an entry
+	// report any difference.
+	ReconciliationThresholds = map[types.Symbol]decimal.Decimal{}
+)
+
+func NewReconciler(bm *BalanceManager, u persist.AuthorizationRepository, r persist.ReconciliationRepository) *Reconciler {
+	return &Reconciler{balances: bm, auth: u, runs: r}
+}
+
+// Reconciler compares the funds owed to customers with the funds held by the funding
+// sources
+type Reconciler struct {
+	balances *BalanceManager
+	auth     persist.AuthorizationRepository
+	runs     persist.ReconciliationRepository
+}
+
+// Reconcile compares the holdings reported by each funding source with the sum of all
+// account balances and with the transfers payable ledger balance for every symbol a
+// source holds. The run is stored for history. Sources that fail to report holdings are
+// recorded as errors on the run and their symbols are not reconciled.
+func (rc *Reconciler) Reconcile(ctx context.Context, t time.Time) (*persist.Reconciliation, error) {
+
+	run := &persist.Reconciliation{
+		ID:        uuid.NewV4().String(),
+		Timestamp: persist.NanoTime(t),
+	}
+
+	holdings := make(map[types.Symbol]map[string]decimal.Decimal)
+	for _, src := range rc.balances.funding {
+		bals, err := src.Balances()
+		if err != nil {
+			run.Errors = append(run.Errors, fmt.Sprintf("%s: %s", src.Name(), err))
+			continue
+		}
+
+		for s, amt := range bals {
+			if _, ok := holdings[s]; !ok {
+				holdings[s] = make(map[string]decimal.Decimal)
+			}
+			holdings[s][src.Name()] = holdings[s][src.Name()].Add(amt)
+		}
+	}
+
+	liabilities, err := rc.accountBalances(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Reconciler::Reconcile::%w", err)
+	}
+
+	payable, err := rc.balances.ledger.GetLiabilityBalance(ctx, persist.TransfersPayable)
+	if err != nil {
+		return nil, fmt.Errorf("Reconciler::Reconcile::%w", err)
+	}
+
+	for s, h := range holdings {
+		item := persist.ReconciliationItem{
+			Symbol:      s,
+			Holdings:    h,
+			Total:       decimal.Zero,
+			Liabilities: liabilities[s],
+			Payable:     payable[s],
+			Threshold:   ReconciliationThresholds[s],
+		}
+
+		for _, amt := range h {
+			item.Total = item.Total.Add(amt)
+		}
+
+		item.Discrepant = item.Difference().Abs().GreaterThan(item.Threshold) ||
+			item.PayableDifference().Abs().GreaterThan(item.Threshold)
+
+		run.Items = append(run.Items, item)
+	}
+
+	sort.Slice(run.Items, func(i, j int) bool {
+		return run.Items[i].Symbol < run.Items[j].Symbol
+	})
+
+	err = rc.runs.SetReconciliation(ctx, run)
+	if err != nil {
+		return nil, fmt.Errorf("Reconciler::Reconcile::%w", err)
+	}
+
+	return run, nil
+}
+
+// GetReconciliations returns the stored reconciliation runs, oldest first
+func (rc *Reconciler) GetReconciliations(ctx context.Context) ([]*persist.Reconciliation, error) {
+	runs, err := rc.runs.GetReconciliations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Reconciler::GetReconciliations::%w", err)
+	}
+
+	return runs, nil
+}
+
+// accountBalances totals the posted balance of every account for each symbol
+func (rc *Reconciler) accountBalances(ctx context.Context) (map[types.Symbol]decimal.Decimal, error) {
+	auths, err := rc.auth.GetAuthorizations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[types.Symbol]decimal.Decimal)
+	done := make(map[string]struct{})
+	for _, auth := range auths {
+		for _, acc := range auth.Accounts {
+			if _, ok := done[acc]; ok {
+				continue
+			}
+			done[acc] = struct{}{}
+
+			id, err := uuid.FromString(acc)
+			if err != nil {
+				continue
+			}
+
+			a := &Account{ID: id}
+			for _, s := range a.ActiveSymbols() {
+				bal, err := rc.balances.GetPostedBalance(ctx, a, s)
+				if err != nil {
+					return nil, err
+				}
+
+				totals[s] = totals[s].Add(bal)
+			}
+		}
+	}
+
+	return totals, nil
+}
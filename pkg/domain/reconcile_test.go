@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/funding"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/kv"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+type reconcileTestSource struct {
+	funding.Source
+	name     string
+	balances map[types.Symbol]decimal.Decimal
+	err      error
+}
+
+func (s *reconcileTestSource) Name() string {
+	return s.name
+}
+
+func (s *reconcileTestSource) Balances() (map[types.Symbol]decimal.Decimal, error) {
+	return s.balances, s.err
+}
+
+func TestReconcile(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	lr := kv.NewLedgerRepository(st)
+	ur := kv.NewAuthorizationRepository(st)
+	src := &reconcileTestSource{Source: funding.NewMockSource(), name: "CUSTODIAN"}
+	failing := &reconcileTestSource{Source: funding.NewMockSource(), name: "BROKEN", err: errors.New("unavailable")}
+	bm := NewBalanceManager(ar, lr, src, failing)
+	rc := NewReconciler(bm, ur, kv.NewReconciliationRepository(st))
+	ctx := context.Background()
+
+	defer func(th map[types.Symbol]decimal.Decimal) { ReconciliationThresholds = th }(ReconciliationThresholds)
+	ReconciliationThresholds = map[types.Symbol]decimal.Decimal{types.SymbolEthereum: decimal.NewFromFloat(0.01)}
+
+	acct := NewAccount()
+	assert.NoError(t, ur.SetAuthorization(ctx, &persist.Authorization{ID: uuid.NewV4().String(), Accounts: []string{acct.ID.String()}}))
+	assert.NoError(t, ar.Save(ctx, &persist.Account{ID: acct.ID.String()}))
+	assert.NoError(t, bm.FundAccountByID(ctx, acct.ID, types.SymbolBitcoin, decimal.NewFromInt(2)))
+	assert.NoError(t, bm.FundAccountByID(ctx, acct.ID, types.SymbolEthereum, decimal.NewFromInt(5)))
+
+	src.balances = map[types.Symbol]decimal.Decimal{
+		types.SymbolBitcoin:  decimal.NewFromFloat(1.5),
+		types.SymbolEthereum: decimal.NewFromFloat(5.005),
+	}
+
+	run, err := rc.Reconcile(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, run.Errors, 1)
+
+	if assert.Len(t, run.Items, 2) {
+		btc := run.Items[0]
+		assert.Equal(t, types.SymbolBitcoin, btc.Symbol)
+		assert.True(t, btc.Total.Equal(decimal.NewFromFloat(1.5)), btc.Total.String())
+		assert.True(t, btc.Liabilities.Equal(decimal.NewFromInt(2)), btc.Liabilities.String())
+		assert.True(t, btc.Payable.Equal(decimal.NewFromInt(2)), btc.Payable.String())
+		assert.True(t, btc.Difference().Equal(decimal.NewFromFloat(-0.5)), btc.Difference().String())
+		assert.True(t, btc.Holdings["CUSTODIAN"].Equal(decimal.NewFromFloat(1.5)))
+		assert.True(t, btc.Discrepant)
+
+		// differences within the threshold are not reported
+		eth := run.Items[1]
+		assert.Equal(t, types.SymbolEthereum, eth.Symbol)
+		assert.False(t, eth.Discrepant)
+	}
+
+	assert.Len(t, run.Discrepant(), 1)
+
+	src.balances[types.SymbolBitcoin] = decimal.NewFromInt(2)
+	_, err = rc.Reconcile(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+
+	// each run is kept for history
+	runs, err := rc.GetReconciliations(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, runs, 2) {
+		assert.Equal(t, run.ID, runs[0].ID)
+		assert.Len(t, runs[1].Discrepant(), 0)
+	}
+}
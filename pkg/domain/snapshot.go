@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/persist"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	// MaxBalanceHistory is the longest date range returned by a single balance history query
+	MaxBalanceHistory = 366 * 24 * time.Hour
+
+	ErrInvalidHistoryRange = errors.New("invalid balance history range")
+)
+
+// SnapshotBalances saves the posted and available balance of every active symbol on the
+// account for the day of t. A snapshot already saved for the same day is replaced.
+func (m *BalanceManager) SnapshotBalances(ctx context.Context, a *Account, t time.Time) (*persist.BalanceSnapshot, error) {
+
+	snap := persist.NewBalanceSnapshot(t)
+	for _, s := range a.ActiveSymbols() {
+		posted, err := m.GetPostedBalance(ctx, a, s)
+		if err != nil {
+			return nil, fmt.Errorf("BalanceManager::SnapshotBalances::%w", err)
+		}
+
+		available, err := m.GetAvailableBalance(ctx, a, s)
+		if err != nil {
+			return nil, fmt.Errorf("BalanceManager::SnapshotBalances::%w", err)
+		}
+
+		snap.Balances = append(snap.Balances, persist.SnapshotBalance{
+			Symbol:    s,
+			Posted:    posted,
+			Available: available,
+		})
+	}
+
+	err := m.acct.Snapshots(&persist.Account{ID: a.ID.String()}).SetSnapshot(ctx, snap)
+	if err != nil {
+		return nil, fmt.Errorf("BalanceManager::SnapshotBalances::%w", err)
+	}
+
+	return snap, nil
+}
+
+// GetBalanceHistory returns the daily balance snapshots for the account from the day of
+// from through the day of to
+func (m *BalanceManager) GetBalanceHistory(ctx context.Context, a *Account, from, to time.Time) ([]*persist.BalanceSnapshot, error) {
+
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w; from must not be after to", ErrInvalidHistoryRange)
+	}
+
+	if to.Sub(from) > MaxBalanceHistory {
+		return nil, fmt.Errorf("%w; range must not exceed %d days", ErrInvalidHistoryRange, int(MaxBalanceHistory.Hours()/24))
+	}
+
+	snaps, err := m.acct.Snapshots(&persist.Account{ID: a.ID.String()}).GetSnapshots(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("BalanceManager::GetBalanceHistory::%w", err)
+	}
+
+	return snaps, nil
+}
+
+func NewBalanceSnapshotter(bm *BalanceManager, u persist.AuthorizationRepository) *BalanceSnapshotter {
+	return &BalanceSnapshotter{balances: bm, auth: u}
+}
+
+// BalanceSnapshotter takes the daily balance snapshot for every account
+type BalanceSnapshotter struct {
+	balances *BalanceManager
+	auth     persist.AuthorizationRepository
+}
+
+// SnapshotAll saves a snapshot for the day of t on every account with an authorization
+// and returns the number of accounts saved. Accounts are shared between authorizations
+// so each is only saved once.
+func (s *BalanceSnapshotter) SnapshotAll(ctx context.Context, t time.Time) (int, error) {
+	auths, err := s.auth.GetAuthorizations(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("BalanceSnapshotter::SnapshotAll::%w", err)
+	}
+
+	done := make(map[string]struct{})
+	for _, auth := range auths {
+		for _, acc := range auth.Accounts {
+			if _, ok := done[acc]; ok {
+				continue
+			}
+
+			id, err := uuid.FromString(acc)
+			if err != nil {
+				return len(done), fmt.Errorf("BalanceSnapshotter::SnapshotAll::%w", err)
+			}
+
+			_, err = s.balances.SnapshotBalances(ctx, &Account{ID: id}, t)
+			if err != nil {
+				return len(done), err
+			}
+
+			done[acc] = struct{}{}
+		}
+	}
+
+	return len(done), nil
+}
@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/funding"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/kv"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBalanceSnapshots(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	ur := kv.NewAuthorizationRepository(st)
+	bm := NewBalanceManager(ar, kv.NewLedgerRepository(st), funding.NewMockSource())
+	ctx := context.Background()
+
+	acct := NewAccount()
+	err := ur.SetAuthorization(ctx, &persist.Authorization{ID: "auth", Accounts: []string{acct.ID.String()}})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	day1 := time.Date(2021, 3, 1, 23, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+
+	snapshotter := NewBalanceSnapshotter(bm, ur)
+
+	assert.NoError(t, bm.PostAmtToBalance(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(2)))
+	cnt, err := snapshotter.SnapshotAll(ctx, day1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+
+	_, err = bm.SetHoldOnAccount(ctx, acct, types.SymbolBitcoin, decimal.NewFromFloat(0.5))
+	assert.NoError(t, err)
+	_, err = snapshotter.SnapshotAll(ctx, day2)
+	assert.NoError(t, err)
+
+	// a second run on the same day replaces the first
+	assert.NoError(t, bm.PostAmtToBalance(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(1)))
+	_, err = snapshotter.SnapshotAll(ctx, day2)
+	assert.NoError(t, err)
+
+	history, err := bm.GetBalanceHistory(ctx, acct, day1, day2)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, "2021-03-01", history[0].Date)
+		assert.Equal(t, "2021-03-02", history[1].Date)
+
+		btc := func(s *persist.BalanceSnapshot) persist.SnapshotBalance {
+			for _, b := range s.Balances {
+				if b.Symbol == types.SymbolBitcoin {
+					return b
+				}
+			}
+			return persist.SnapshotBalance{}
+		}
+
+		assert.True(t, btc(history[0]).Posted.Equal(decimal.NewFromInt(2)))
+		assert.True(t, btc(history[0]).Available.Equal(decimal.NewFromInt(2)))
+		assert.True(t, btc(history[1]).Posted.Equal(decimal.NewFromInt(3)))
+		assert.True(t, btc(history[1]).Available.Equal(decimal.NewFromFloat(2.5)))
+	}
+
+	history, err = bm.GetBalanceHistory(ctx, acct, day2, day2)
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	_, err = bm.GetBalanceHistory(ctx, acct, day2, day1)
+	assert.True(t, errors.Is(err, ErrInvalidHistoryRange))
+}
@@ -0,0 +1,408 @@
+package domain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidStatementPeriod = errors.New("invalid statement period")
+	ErrUnknownStatementFormat = errors.New("unknown statement format")
+)
+
+type StatementFormat string
+
+const (
+	CSVStatementFormat StatementFormat = "csv"
+	OFXStatementFormat StatementFormat = "ofx"
+)
+
+// ContentType returns the media type of a rendered statement
+func (f StatementFormat) ContentType() string {
+	switch f {
+	case OFXStatementFormat:
+		return "application/x-ofx"
+	default:
+		return "text/csv"
+	}
+}
+
+// Statement is the list of account transactions for a period along with the
+// posted balance of each symbol at the start and end of the period
+type Statement struct {
+	Account      string
+	From         time.Time
+	To           time.Time
+	Symbols      []types.Symbol
+	Opening      map[types.Symbol]decimal.Decimal
+	Closing      map[types.Symbol]decimal.Decimal
+	Transactions []*persist.Transaction
+}
+
+// GetStatement builds the statement for transactions from the start of the period up
+// to, but not including, the end. Balances are worked backward from the current posted
+// balance so that funds posted without a transaction record are still included.
+func (m *BalanceManager) GetStatement(ctx context.Context, a *Account, from, to time.Time) (*Statement, error) {
+
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w; from must be before to", ErrInvalidStatementPeriod)
+	}
+
+	list, err := m.acct.Transactions(&persist.Account{ID: a.ID.String()}).GetTransactions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("BalanceManager::GetStatement::%w", err)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Timestamp.Value() < list[j].Timestamp.Value()
+	})
+
+	st := &Statement{
+		Account: a.ID.String(),
+		From:    from,
+		To:      to,
+		Symbols: a.ActiveSymbols(),
+		Opening: make(map[types.Symbol]decimal.Decimal),
+		Closing: make(map[types.Symbol]decimal.Decimal),
+	}
+
+	for _, s := range st.Symbols {
+		st.Closing[s], err = m.GetPostedBalance(ctx, a, s)
+		if err != nil {
+			return nil, fmt.Errorf("BalanceManager::GetStatement::%w", err)
+		}
+	}
+
+	// remove activity after the period from the closing balance and activity in
+	// the period from the opening balance
+	period := []*persist.Transaction{}
+	for _, t := range list {
+		ts := time.Time(t.Timestamp)
+		if ts.Before(from) {
+			continue
+		}
+
+		changes, err := transactionChanges(t)
+		if err != nil {
+			return nil, fmt.Errorf("BalanceManager::GetStatement::%w", err)
+		}
+
+		for s, amt := range changes {
+			if !ts.Before(to) {
+				st.Closing[s] = st.Closing[s].Sub(amt)
+			} else {
+				st.Opening[s] = st.Opening[s].Sub(amt)
+			}
+		}
+
+		if ts.Before(to) {
+			period = append(period, t)
+		}
+	}
+
+	for _, s := range st.Symbols {
+		st.Opening[s] = st.Opening[s].Add(st.Closing[s])
+	}
+	st.Transactions = period
+
+	return st, nil
+}
+
+// Write renders the statement in the requested format
+func (st *Statement) Write(w io.Writer, f StatementFormat) error {
+	switch f {
+	case CSVStatementFormat:
+		return st.WriteCSV(w)
+	case OFXStatementFormat:
+		return st.WriteOFX(w)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownStatementFormat, f)
+	}
+}
+
+// WriteCSV renders the statement as CSV with one row per transaction. Opening
+// balances are listed before the transactions and closing balances after them.
+func (st *Statement) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	rows := [][]string{{"date", "type", "symbol", "quantity", "fee", "fee_symbol", "order_id", "transaction_hash", "address"}}
+
+	for _, s := range st.Symbols {
+		rows = append(rows, []string{st.From.UTC().Format(time.RFC3339), "opening_balance", s.String(), st.Opening[s].StringFixedBank(s.RoundingPlace()), "", "", "", "", ""})
+	}
+
+	for _, t := range st.Transactions {
+		var feeSymbol string
+		if t.Fee != "" {
+			feeSymbol = types.SymbolCipherMtn.String()
+		}
+
+		rows = append(rows, []string{
+			time.Time(t.Timestamp).UTC().Format(time.RFC3339),
+			string(t.Type),
+			t.Symbol,
+			t.Quantity,
+			t.Fee,
+			feeSymbol,
+			t.OrderID,
+			t.TransactionHash,
+			t.AddressHash,
+		})
+	}
+
+	for _, s := range st.Symbols {
+		rows = append(rows, []string{st.To.UTC().Format(time.RFC3339), "closing_balance", s.String(), st.Closing[s].StringFixedBank(s.RoundingPlace()), "", "", "", "", ""})
+	}
+
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+
+	return cw.Error()
+}
+
+const ofxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+`
+
+// WriteOFX renders the statement as an OFX 2.2 bank statement with one statement
+// per symbol. Trade fees are listed as separate fee transactions on the fee symbol.
+func (st *Statement) WriteOFX(w io.Writer) error {
+	now := ofxTime(time.Now())
+
+	doc := ofxDocument{
+		SignOn: ofxSignOn{
+			Status:   ofxStatus{Code: 0, Severity: "INFO"},
+			DTServer: now,
+			Language: "ENG",
+		},
+	}
+
+	lines := make(map[types.Symbol][]ofxTransaction)
+	for _, t := range st.Transactions {
+		s, err := types.FromString(t.Symbol)
+		if err != nil {
+			return err
+		}
+
+		amt, err := decimal.NewFromString(t.Quantity)
+		if err != nil {
+			return err
+		}
+
+		lines[s] = append(lines[s], ofxTransaction{
+			Type:     ofxTransactionType(t.Type, amt),
+			DTPosted: ofxTime(time.Time(t.Timestamp)),
+			Amount:   t.Quantity,
+			FITID:    ofxID(st.Account, t, ""),
+			Name:     string(t.Type),
+			Memo:     ofxMemo(t),
+		})
+
+		fee, err := transactionFee(t)
+		if err != nil {
+			return err
+		}
+
+		if fee.GreaterThan(decimal.Zero) {
+			fs := types.SymbolCipherMtn
+			lines[fs] = append(lines[fs], ofxTransaction{
+				Type:     "FEE",
+				DTPosted: ofxTime(time.Time(t.Timestamp)),
+				Amount:   fee.Neg().StringFixedBank(fs.RoundingPlace()),
+				FITID:    ofxID(st.Account, t, "fee"),
+				Name:     "fee",
+				Memo:     ofxMemo(t),
+			})
+		}
+	}
+
+	for _, s := range st.Symbols {
+		doc.Bank = append(doc.Bank, ofxStatementResponse{
+			TRNUID: fmt.Sprintf("%s-%s", st.Account, s),
+			Status: ofxStatus{Code: 0, Severity: "INFO"},
+			Statement: ofxStatement{
+				Currency: s.String(),
+				Account: ofxAccount{
+					BankID: "SPEW",
+					ID:     fmt.Sprintf("%s-%s", st.Account, s),
+					Type:   "CHECKING",
+				},
+				Transactions: ofxTransactionList{
+					DTStart: ofxTime(st.From),
+					DTEnd:   ofxTime(st.To),
+					Lines:   lines[s],
+				},
+				LedgerBalance: ofxBalance{
+					Amount: st.Closing[s].StringFixedBank(s.RoundingPlace()),
+					DTAsOf: ofxTime(st.To),
+				},
+				Balances: []ofxNamedBalance{{
+					Name:   "OPENING",
+					Desc:   "Opening balance",
+					Type:   "DOLLAR",
+					Value:  st.Opening[s].StringFixedBank(s.RoundingPlace()),
+					DTAsOf: ofxTime(st.From),
+				}},
+			},
+		})
+	}
+
+	if _, err := io.WriteString(w, ofxHeader); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
+
+// transactionChanges returns the change in posted balance for each symbol caused by the transaction
+func transactionChanges(t *persist.Transaction) (map[types.Symbol]decimal.Decimal, error) {
+	changes := make(map[types.Symbol]decimal.Decimal)
+
+	s, err := types.FromString(t.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	amt, err := decimal.NewFromString(t.Quantity)
+	if err != nil {
+		return nil, err
+	}
+	changes[s] = amt
+
+	fee, err := transactionFee(t)
+	if err != nil {
+		return nil, err
+	}
+
+	if fee.GreaterThan(decimal.Zero) {
+		changes[types.SymbolCipherMtn] = changes[types.SymbolCipherMtn].Sub(fee)
+	}
+
+	return changes, nil
+}
+
+// transactionFee returns the flat trade fee charged with the transaction
+func transactionFee(t *persist.Transaction) (decimal.Decimal, error) {
+	if t.Fee == "" {
+		return decimal.Zero, nil
+	}
+	return decimal.NewFromString(t.Fee)
+}
+
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405") + "[0:GMT]"
+}
+
+func ofxTransactionType(t persist.TransactionType, amt decimal.Decimal) string {
+	switch t {
+	case persist.DepositTransactionType:
+		return "DEP"
+	case persist.TransferTransactionType, persist.InternalTransactionType:
+		return "XFER"
+	case persist.FeeTransactionType:
+		return "SRVCHG"
+	}
+
+	if amt.IsNegative() {
+		return "DEBIT"
+	}
+	return "CREDIT"
+}
+
+// ofxID builds a stable transaction id so that importing the same statement twice
+// does not duplicate transactions
+func ofxID(account string, t *persist.Transaction, suffix string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%s|%s|%s|%s|%s", account, t.Timestamp.Value(), t.Type, t.Symbol, t.Quantity, t.OrderID, suffix)))
+	return hex.EncodeToString(sum[:16])
+}
+
+func ofxMemo(t *persist.Transaction) string {
+	switch {
+	case t.OrderID != "":
+		return fmt.Sprintf("order %s", t.OrderID)
+	case t.TransactionHash != "":
+		return fmt.Sprintf("transaction %s", t.TransactionHash)
+	default:
+		return ""
+	}
+}
+
+type ofxDocument struct {
+	XMLName xml.Name               `xml:"OFX"`
+	SignOn  ofxSignOn              `xml:"SIGNONMSGSRSV1>SONRS"`
+	Bank    []ofxStatementResponse `xml:"BANKMSGSRSV1>STMTTRNRS"`
+}
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxSignOn struct {
+	Status   ofxStatus `xml:"STATUS"`
+	DTServer string    `xml:"DTSERVER"`
+	Language string    `xml:"LANGUAGE"`
+}
+
+type ofxStatementResponse struct {
+	TRNUID    string       `xml:"TRNUID"`
+	Status    ofxStatus    `xml:"STATUS"`
+	Statement ofxStatement `xml:"STMTRS"`
+}
+
+type ofxStatement struct {
+	Currency      string             `xml:"CURDEF"`
+	Account       ofxAccount         `xml:"BANKACCTFROM"`
+	Transactions  ofxTransactionList `xml:"BANKTRANLIST"`
+	LedgerBalance ofxBalance         `xml:"LEDGERBAL"`
+	Balances      []ofxNamedBalance  `xml:"BALLIST>BAL"`
+}
+
+type ofxAccount struct {
+	BankID string `xml:"BANKID"`
+	ID     string `xml:"ACCTID"`
+	Type   string `xml:"ACCTTYPE"`
+}
+
+type ofxTransactionList struct {
+	DTStart string           `xml:"DTSTART"`
+	DTEnd   string           `xml:"DTEND"`
+	Lines   []ofxTransaction `xml:"STMTTRN"`
+}
+
+type ofxTransaction struct {
+	Type     string `xml:"TRNTYPE"`
+	DTPosted string `xml:"DTPOSTED"`
+	Amount   string `xml:"TRNAMT"`
+	FITID    string `xml:"FITID"`
+	Name     string `xml:"NAME"`
+	Memo     string `xml:"MEMO,omitempty"`
+}
+
+type ofxBalance struct {
+	Amount string `xml:"BALAMT"`
+	DTAsOf string `xml:"DTASOF"`
+}
+
+type ofxNamedBalance struct {
+	Name   string `xml:"NAME"`
+	Desc   string `xml:"DESC"`
+	Type   string `xml:"BALTYPE"`
+	Value  string `xml:"VALUE"`
+	DTAsOf string `xml:"DTASOF"`
+}
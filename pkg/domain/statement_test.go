@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/funding"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/kv"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStatement(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	bm := NewBalanceManager(ar, kv.NewLedgerRepository(st), funding.NewMockSource())
+	ctx := context.Background()
+
+	acct := NewAccount()
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	records := []*persist.Transaction{
+		{Type: persist.DepositTransactionType, Symbol: "BTC", Quantity: "2.00000000", Timestamp: persist.NanoTime(from.Add(-time.Hour))},
+		{Type: persist.DepositTransactionType, Symbol: "CMTN", Quantity: "500", Timestamp: persist.NanoTime(from.Add(-time.Minute))},
+		{Type: persist.OrderTransactionType, OrderID: "order", Symbol: "ETH", Quantity: "10.000000000000000000", Fee: "100", Timestamp: persist.NanoTime(from.Add(time.Hour))},
+		{Type: persist.OrderTransactionType, OrderID: "order", Symbol: "BTC", Quantity: "-0.50000000", Timestamp: persist.NanoTime(from.Add(time.Hour + time.Millisecond))},
+		{Type: persist.TransferTransactionType, TransactionHash: "hash", Symbol: "BTC", Quantity: "-0.25000000", Timestamp: persist.NanoTime(to.Add(time.Hour))},
+	}
+
+	trepo := ar.Transactions(&persist.Account{ID: acct.ID.String()})
+	for _, r := range records {
+		assert.NoError(t, trepo.SetTransaction(ctx, r))
+
+		changes, err := transactionChanges(r)
+		assert.NoError(t, err)
+		for s, amt := range changes {
+			assert.NoError(t, bm.PostAmtToBalance(ctx, acct, s, amt))
+		}
+	}
+
+	statement, err := bm.GetStatement(ctx, acct, from, to)
+	assert.NoError(t, err)
+	assert.Len(t, statement.Transactions, 2)
+
+	expected := map[types.Symbol][2]string{
+		types.SymbolBitcoin:   {"2", "1.5"},
+		types.SymbolEthereum:  {"0", "10"},
+		types.SymbolCipherMtn: {"500", "400"},
+	}
+
+	for s, e := range expected {
+		assert.Equal(t, e[0], statement.Opening[s].String(), "opening %s", s)
+		assert.Equal(t, e[1], statement.Closing[s].String(), "closing %s", s)
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, statement.Write(&buf, CSVStatementFormat))
+
+		rows, err := csv.NewReader(&buf).ReadAll()
+		assert.NoError(t, err)
+
+		// header, opening and closing balances for each symbol, and two transactions
+		assert.Len(t, rows, 1+2*len(statement.Symbols)+2)
+		assert.Equal(t, "opening_balance", rows[1][1])
+		assert.Equal(t, "closing_balance", rows[len(rows)-1][1])
+	})
+
+	t.Run("OFX", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, statement.Write(&buf, OFXStatementFormat))
+		assert.True(t, strings.HasPrefix(buf.String(), "<?xml"))
+
+		var doc ofxDocument
+		assert.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+		assert.Len(t, doc.Bank, len(statement.Symbols))
+
+		for _, b := range doc.Bank {
+			if b.Statement.Currency == "CMTN" {
+				if assert.Len(t, b.Statement.Transactions.Lines, 1) {
+					assert.Equal(t, "FEE", b.Statement.Transactions.Lines[0].Type)
+					assert.Equal(t, "-100", b.Statement.Transactions.Lines[0].Amount)
+				}
+				assert.Equal(t, "400", b.Statement.LedgerBalance.Amount)
+			}
+		}
+	})
+
+	_, err = bm.GetStatement(ctx, acct, to, from)
+	assert.ErrorIs(t, err, ErrInvalidStatementPeriod)
+
+	assert.ErrorIs(t, statement.Write(&bytes.Buffer{}, "pdf"), ErrUnknownStatementFormat)
+}
@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/easterthebunny/spew-order/internal/persist"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// MaxAccountsPerAuthorization limits the number of accounts, archived or not,
+	// a single authorization can hold
+	MaxAccountsPerAuthorization = 20
+	// MaxAccountLabelLength is the longest label that can be set on an account
+	MaxAccountLabelLength = 64
+
+	ErrAccountLimit       = errors.New("account limit reached")
+	ErrAccountNotHeld     = errors.New("account not held by this authorization")
+	ErrAccountArchived    = errors.New("account is archived")
+	ErrAccountNotEmpty    = errors.New("account has balances or open orders")
+	ErrLastActiveAccount  = errors.New("cannot archive the last active account")
+	ErrAccountLabelLength = fmt.Errorf("account label limited to %d characters", MaxAccountLabelLength)
+)
+
+// CreateSubAccount creates a new account and adds it to the authorization. Sub-accounts
+// have their own balances, orders and funding addresses but are not funded on creation.
+func (m *BalanceManager) CreateSubAccount(ctx context.Context, u persist.AuthorizationRepository, authz *persist.Authorization, label string) (*Account, error) {
+
+	if len(label) > MaxAccountLabelLength {
+		return nil, ErrAccountLabelLength
+	}
+
+	if len(authz.Accounts) >= MaxAccountsPerAuthorization {
+		return nil, fmt.Errorf("%w; limited to %d accounts", ErrAccountLimit, MaxAccountsPerAuthorization)
+	}
+
+	a := NewAccount()
+	a.Label = label
+
+	// saving the account first prevents GetAccount from treating it as new
+	err := m.acct.Save(ctx, &persist.Account{ID: a.ID.String(), Label: label, Owner: authz.ID})
+	if err != nil {
+		return nil, fmt.Errorf("BalanceManager::CreateSubAccount::%w", err)
+	}
+
+	authz.Accounts = append(authz.Accounts, a.ID.String())
+	err = u.SetAuthorization(ctx, authz)
+	if err != nil {
+		return nil, fmt.Errorf("BalanceManager::CreateSubAccount::%w", err)
+	}
+
+	for _, s := range a.ActiveSymbols() {
+		a.Balances[s] = decimal.NewFromInt(0)
+	}
+
+	return a, nil
+}
+
+// LabelAccount sets the display label of an account
+func (m *BalanceManager) LabelAccount(ctx context.Context, a *Account, label string) error {
+
+	if len(label) > MaxAccountLabelLength {
+		return ErrAccountLabelLength
+	}
+
+	return m.updateAccount(ctx, a, func(p *persist.Account) error {
+		p.Label = label
+		return nil
+	})
+}
+
+// ArchiveAccount marks an account archived so it can no longer trade or receive funds. Only
+// accounts without balances or open orders can be archived and an authorization must keep
+// at least one active account.
+func (m *BalanceManager) ArchiveAccount(ctx context.Context, authz *persist.Authorization, a *Account) error {
+
+	if a.Archived {
+		return nil
+	}
+
+	var active int
+	for _, id := range authz.Accounts {
+		if id == a.ID.String() {
+			continue
+		}
+
+		uid, err := uuid.FromString(id)
+		if err != nil {
+			continue
+		}
+
+		p, err := m.acct.Find(ctx, uid)
+		if err != nil && !errors.Is(err, persist.ErrObjectNotExist) {
+			return fmt.Errorf("BalanceManager::ArchiveAccount::%w", err)
+		}
+
+		if p == nil || !p.Archived {
+			active++
+		}
+	}
+
+	if active == 0 {
+		return ErrLastActiveAccount
+	}
+
+	for _, s := range a.ActiveSymbols() {
+		bal, err := m.GetPostedBalance(ctx, a, s)
+		if err != nil {
+			return fmt.Errorf("BalanceManager::ArchiveAccount::%w", err)
+		}
+
+		if !bal.IsZero() {
+			return ErrAccountNotEmpty
+		}
+	}
+
+	orders, err := m.GetOrdersByStatus(ctx, a, persist.StatusOpen, persist.StatusPartial)
+	if err != nil {
+		return fmt.Errorf("BalanceManager::ArchiveAccount::%w", err)
+	}
+
+	if len(orders) > 0 {
+		return ErrAccountNotEmpty
+	}
+
+	return m.updateAccount(ctx, a, func(p *persist.Account) error {
+		p.Archived = true
+		return nil
+	})
+}
+
+// RestoreAccount returns an archived account to active use
+func (m *BalanceManager) RestoreAccount(ctx context.Context, a *Account) error {
+	return m.updateAccount(ctx, a, func(p *persist.Account) error {
+		p.Archived = false
+		return nil
+	})
+}
+
+func (m *BalanceManager) updateAccount(ctx context.Context, a *Account, fn func(*persist.Account) error) error {
+
+	p, err := m.acct.Find(ctx, a.ID)
+	if err != nil {
+		return fmt.Errorf("BalanceManager::updateAccount::%w", err)
+	}
+
+	if err := fn(p); err != nil {
+		return err
+	}
+
+	err = m.acct.Save(ctx, p)
+	if err != nil {
+		return fmt.Errorf("BalanceManager::updateAccount::%w", err)
+	}
+
+	a.Label = p.Label
+	a.Archived = p.Archived
+	a.Tier = AccountTier(p.Tier)
+	a.setAllowlist(p)
+
+	return nil
+}
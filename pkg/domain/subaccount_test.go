@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/easterthebunny/spew-order/internal/funding"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/kv"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubAccounts(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	ur := kv.NewAuthorizationRepository(st)
+	bm := NewBalanceManager(ar, kv.NewLedgerRepository(st), funding.NewMockSource())
+	ctx := context.Background()
+
+	main, err := bm.GetAccount(ctx, NewAccount().ID.String())
+	assert.NoError(t, err)
+
+	id := uuid.NewV4()
+	authz := &persist.Authorization{ID: id.String(), Accounts: []string{main.ID.String()}}
+	assert.NoError(t, ur.SetAuthorization(ctx, authz))
+
+	sub, err := bm.CreateSubAccount(ctx, ur, authz, "savings")
+	assert.NoError(t, err)
+	assert.Equal(t, "savings", sub.Label)
+
+	saved, err := ur.GetAuthorization(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{main.ID.String(), sub.ID.String()}, saved.Accounts)
+
+	// sub-accounts start empty
+	sub, err = bm.GetAccount(ctx, sub.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "savings", sub.Label)
+	bal, err := bm.GetPostedBalance(ctx, sub, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, bal.IsZero(), bal.String())
+
+	_, err = bm.CreateSubAccount(ctx, ur, authz, strings.Repeat("a", MaxAccountLabelLength+1))
+	assert.ErrorIs(t, err, ErrAccountLabelLength)
+
+	assert.NoError(t, bm.LabelAccount(ctx, sub, "trading"))
+	sub, err = bm.GetAccount(ctx, sub.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "trading", sub.Label)
+
+	// funded accounts cannot be archived
+	assert.NoError(t, bm.PostAmtToBalance(ctx, sub, types.SymbolBitcoin, decimal.NewFromInt(1)))
+	assert.ErrorIs(t, bm.ArchiveAccount(ctx, authz, sub), ErrAccountNotEmpty)
+	assert.NoError(t, bm.PostAmtToBalance(ctx, sub, types.SymbolBitcoin, decimal.NewFromInt(-1)))
+
+	assert.NoError(t, bm.ArchiveAccount(ctx, authz, sub))
+	sub, err = bm.GetAccount(ctx, sub.ID.String())
+	assert.NoError(t, err)
+	assert.True(t, sub.Archived)
+
+	// the main account is the only active account left
+	main, err = bm.GetAccount(ctx, main.ID.String())
+	assert.NoError(t, err)
+	for _, s := range main.ActiveSymbols() {
+		bal, err := bm.GetPostedBalance(ctx, main, s)
+		assert.NoError(t, err)
+		assert.NoError(t, bm.PostAmtToBalance(ctx, main, s, bal.Neg()))
+	}
+	assert.ErrorIs(t, bm.ArchiveAccount(ctx, authz, main), ErrLastActiveAccount)
+
+	assert.NoError(t, bm.RestoreAccount(ctx, sub))
+	assert.False(t, sub.Archived)
+
+	authz.Accounts = make([]string, MaxAccountsPerAuthorization)
+	_, err = bm.CreateSubAccount(ctx, ur, authz, "")
+	assert.ErrorIs(t, err, ErrAccountLimit)
+}
@@ -0,0 +1,135 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrSameAccountTransfer = errors.New("cannot transfer funds to the same account")
+)
+
+// TransferInternal moves funds from one account to another in the same symbol without
+// an on-chain withdrawal. Both accounts receive a transaction record sharing the same
+// transfer id. If the receiving account cannot be credited, the sender is credited
+// back and the hold is released. When a non-empty key is provided, a repeated transfer
+// with the same key inside the idempotency window returns the original debit record.
+func (m *BalanceManager) TransferInternal(ctx context.Context, from *Account, to *Account, s types.Symbol, amt decimal.Decimal, key string) (t *persist.Transaction, err error) {
+
+	if uuid.Equal(from.ID, to.ID) {
+		return nil, ErrSameAccountTransfer
+	}
+
+	if key != "" {
+		var rec *persist.IdempotencyRecord
+		rec, err = m.reserveKey(ctx, from, persist.InternalIdempotencyType, key)
+		if err != nil {
+			return
+		}
+
+		if rec != nil {
+			return m.internalTransferForKey(ctx, from, rec)
+		}
+	}
+
+	// the key can only be reused if no funds were moved
+	var moved bool
+	defer func() {
+		if key != "" && err != nil && !moved {
+			m.ReleaseKey(ctx, from, key)
+		}
+	}()
+
+	hid, err := m.SetHoldOnAccount(ctx, from, s, amt)
+	if err != nil {
+		return
+	}
+
+	err = m.PostAmtToBalance(ctx, from, s, amt.Neg())
+	if err != nil {
+		m.RemoveHoldOnAccount(ctx, from, s, ky(hid))
+		return
+	}
+	moved = true
+
+	err = m.PostAmtToBalance(ctx, to, s, amt)
+	if err != nil {
+		// return the funds to the sender so they are not lost with the failed credit
+		if rerr := m.PostAmtToBalance(ctx, from, s, amt); rerr != nil {
+			err = fmt.Errorf("TransferInternal::%s::%w", err, rerr)
+			return
+		}
+		moved = false
+
+		m.RemoveHoldOnAccount(ctx, from, s, ky(hid))
+		return
+	}
+
+	err = m.RemoveHoldOnAccount(ctx, from, s, ky(hid))
+	if err != nil {
+		return
+	}
+
+	id := uuid.NewV4().String()
+	tm := persist.NanoTime(time.Now())
+	t = &persist.Transaction{
+		Type:            persist.InternalTransactionType,
+		TransactionHash: id,
+		AddressHash:     to.ID.String(),
+		Symbol:          s.String(),
+		Quantity:        amt.Neg().StringFixedBank(s.RoundingPlace()),
+		Timestamp:       tm,
+	}
+
+	err = m.acct.Transactions(&persist.Account{ID: from.ID.String()}).SetTransaction(ctx, t)
+	if err != nil {
+		return
+	}
+
+	err = m.acct.Transactions(&persist.Account{ID: to.ID.String()}).SetTransaction(ctx, &persist.Transaction{
+		Type:            persist.InternalTransactionType,
+		TransactionHash: id,
+		AddressHash:     from.ID.String(),
+		Symbol:          s.String(),
+		Quantity:        amt.StringFixedBank(s.RoundingPlace()),
+		Timestamp:       tm,
+	})
+	if err != nil {
+		return
+	}
+
+	err = m.ledger.RecordInternalTransfer(ctx, id, from.ID.String(), to.ID.String(), s, amt)
+	if err != nil {
+		return
+	}
+
+	if key != "" {
+		err = m.completeKey(ctx, from, persist.InternalIdempotencyType, key, tm.String())
+	}
+
+	return
+}
+
+func (m *BalanceManager) internalTransferForKey(ctx context.Context, a *Account, rec *persist.IdempotencyRecord) (*persist.Transaction, error) {
+
+	rep := m.acct.Transactions(&persist.Account{ID: a.ID.String()})
+	list, err := rep.GetTransactions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tr := range list {
+		if tr.Type == persist.InternalTransactionType && tr.Timestamp.String() == rec.Reference {
+			return tr, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: internal transfer for key '%s'", persist.ErrObjectNotExist, rec.Key)
+}
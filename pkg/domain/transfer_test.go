@@ -0,0 +1,168 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/funding"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/kv"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransferInternal(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	lr := kv.NewLedgerRepository(st)
+	bm := NewBalanceManager(ar, lr, funding.NewMockSource())
+	ctx := context.Background()
+
+	from := NewAccount()
+	to := NewAccount()
+	assert.NoError(t, bm.PostAmtToBalance(ctx, from, types.SymbolBitcoin, decimal.NewFromInt(2)))
+
+	amt := decimal.NewFromFloat(0.75)
+	tr, err := bm.TransferInternal(ctx, from, to, types.SymbolBitcoin, amt, "transfer-key")
+	assert.NoError(t, err)
+	if assert.NotNil(t, tr) {
+		assert.Equal(t, persist.TransactionType(persist.InternalTransactionType), tr.Type)
+		assert.Equal(t, to.ID.String(), tr.AddressHash)
+		assert.Equal(t, "-0.75000000", tr.Quantity)
+	}
+
+	// a repeated request with the same key does not move funds again
+	again, err := bm.TransferInternal(ctx, from, to, types.SymbolBitcoin, amt, "transfer-key")
+	assert.NoError(t, err)
+	assert.Equal(t, tr.TransactionHash, again.TransactionHash)
+
+	bal, err := bm.GetAvailableBalance(ctx, from, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, bal.Equal(decimal.NewFromFloat(1.25)), bal.String())
+
+	bal, err = bm.GetAvailableBalance(ctx, to, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, bal.Equal(amt), bal.String())
+
+	received, err := ar.Transactions(&persist.Account{ID: to.ID.String()}).GetTransactions(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, received, 1) {
+		assert.Equal(t, tr.TransactionHash, received[0].TransactionHash)
+		assert.Equal(t, from.ID.String(), received[0].AddressHash)
+		assert.Equal(t, "0.75000000", received[0].Quantity)
+	}
+
+	// the transfer moves liabilities between customers without touching transfers
+	tb, err := lr.GetTrialBalance(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, tb.Unbalanced(), 0)
+	assert.True(t, tb.Net(persist.Transfers)[types.SymbolBitcoin].IsZero())
+	assert.True(t, tb.Net(persist.CustomerLiabilities)[types.SymbolBitcoin].IsZero())
+
+	_, err = bm.TransferInternal(ctx, from, to, types.SymbolBitcoin, decimal.NewFromInt(5), "")
+	assert.ErrorIs(t, err, ErrInsufficientBalanceForHold)
+
+	_, err = bm.TransferInternal(ctx, from, from, types.SymbolBitcoin, amt, "")
+	assert.ErrorIs(t, err, ErrSameAccountTransfer)
+}
+
+// slowKVStore delays reads so concurrent requests overlap
+type slowKVStore struct {
+	*persist.MockKVStore
+}
+
+func (s slowKVStore) Get(k string) ([]byte, error) {
+	b, err := s.MockKVStore.Get(k)
+	<-time.After(time.Millisecond)
+	return b, err
+}
+
+func TestTransferInternal_ConcurrentKey(t *testing.T) {
+	st := slowKVStore{persist.NewMockKVStore()}
+	ar := kv.NewAccountRepository(st)
+	bm := NewBalanceManager(ar, kv.NewLedgerRepository(st), funding.NewMockSource())
+	ctx := context.Background()
+
+	from := NewAccount()
+	to := NewAccount()
+	assert.NoError(t, bm.PostAmtToBalance(ctx, from, types.SymbolBitcoin, decimal.NewFromInt(2)))
+
+	// concurrent retries with the same key only move funds once
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = bm.TransferInternal(ctx, from, to, types.SymbolBitcoin, decimal.NewFromFloat(0.5), "transfer-key")
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			assert.ErrorIs(t, err, ErrIdempotentRequestInProgress)
+		}
+	}
+
+	bal, err := bm.GetAvailableBalance(ctx, to, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, bal.Equal(decimal.NewFromFloat(0.5)), bal.String())
+
+	received, err := ar.Transactions(&persist.Account{ID: to.ID.String()}).GetTransactions(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, received, 1)
+}
+
+// creditFailingAccounts fails every balance post to a single account
+type creditFailingAccounts struct {
+	persist.AccountRepository
+	account string
+}
+
+func (a creditFailingAccounts) Balances(acct *persist.Account, s types.Symbol) persist.BalanceRepository {
+	r := a.AccountRepository.Balances(acct, s)
+	if acct.ID == a.account {
+		return creditFailingBalance{r}
+	}
+	return r
+}
+
+type creditFailingBalance struct {
+	persist.BalanceRepository
+}
+
+func (b creditFailingBalance) AddToBalance(ctx context.Context, amt decimal.Decimal) error {
+	return errors.New("balance unavailable")
+}
+
+func TestTransferInternal_CreditFailure(t *testing.T) {
+	st := persist.NewMockKVStore()
+	to := NewAccount()
+	ar := creditFailingAccounts{AccountRepository: kv.NewAccountRepository(st), account: to.ID.String()}
+	bm := NewBalanceManager(ar, kv.NewLedgerRepository(st), funding.NewMockSource())
+	ctx := context.Background()
+
+	from := NewAccount()
+	assert.NoError(t, bm.PostAmtToBalance(ctx, from, types.SymbolBitcoin, decimal.NewFromInt(2)))
+
+	_, err := bm.TransferInternal(ctx, from, to, types.SymbolBitcoin, decimal.NewFromFloat(0.5), "transfer-key")
+	assert.Error(t, err)
+
+	// the sender is credited back and the hold released
+	bal, err := bm.GetAvailableBalance(ctx, from, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, bal.Equal(decimal.NewFromInt(2)), bal.String())
+
+	holds, err := ar.Balances(&persist.Account{ID: from.ID.String()}, types.SymbolBitcoin).FindHolds(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, holds, 0)
+
+	// no funds moved so the key can be used again
+	_, err = bm.TransferInternal(ctx, from, to, types.SymbolBitcoin, decimal.NewFromFloat(0.5), "transfer-key")
+	assert.NotErrorIs(t, err, ErrIdempotentRequestInProgress)
+}
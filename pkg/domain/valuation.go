@@ -0,0 +1,289 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ErrNoPriceRoute indicates no priced markets connect a symbol to the quote symbol
+	ErrNoPriceRoute = errors.New("no price route")
+
+	// PriceHistoryLookback is how far before a balance history range closing prices are
+	// read so markets that did not trade early in the range still have a price
+	PriceHistoryLookback = 30 * 24 * time.Hour
+)
+
+// Markets returns every market that can be traded
+func Markets() []types.SwapPair {
+	valid := make(map[string]struct{})
+	for _, p := range types.ValidPairs {
+		valid[p] = struct{}{}
+	}
+
+	var markets []types.SwapPair
+	for _, b := range types.Symbols {
+		for _, t := range types.Symbols {
+			m := types.SwapPair{Base: b, Target: t}
+			if _, ok := valid[m.String()]; ok {
+				markets = append(markets, m)
+			}
+		}
+	}
+
+	return markets
+}
+
+// MarketPrices is the price of each market as the amount of base paid for one target
+type MarketPrices map[types.SwapPair]decimal.Decimal
+
+// Rate returns the amount of quote one unit of s is worth. When no market trades s
+// against quote directly, the route through the fewest intermediate markets is used.
+func (p MarketPrices) Rate(s, quote types.Symbol) (decimal.Decimal, error) {
+	one := decimal.NewFromInt(1)
+	if s == quote {
+		return one, nil
+	}
+
+	type edge struct {
+		to   types.Symbol
+		rate decimal.Decimal
+	}
+
+	// markets are walked in a fixed order so equal length routes resolve the same way
+	// on every call
+	var markets []types.SwapPair
+	for m, price := range p {
+		if price.IsPositive() {
+			markets = append(markets, m)
+		}
+	}
+	sort.Slice(markets, func(i, j int) bool {
+		return markets[i].String() < markets[j].String()
+	})
+
+	edges := make(map[types.Symbol][]edge)
+	for _, m := range markets {
+		price := p[m]
+		edges[m.Target] = append(edges[m.Target], edge{to: m.Base, rate: price})
+		edges[m.Base] = append(edges[m.Base], edge{to: m.Target, rate: one.Div(price)})
+	}
+
+	rates := map[types.Symbol]decimal.Decimal{s: one}
+	queue := []types.Symbol{s}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, e := range edges[current] {
+			if _, seen := rates[e.to]; seen {
+				continue
+			}
+
+			rates[e.to] = rates[current].Mul(e.rate)
+			if e.to == quote {
+				return rates[e.to], nil
+			}
+			queue = append(queue, e.to)
+		}
+	}
+
+	return decimal.Zero, fmt.Errorf("%w from %s to %s", ErrNoPriceRoute, s, quote)
+}
+
+// Value prices each balance in the quote symbol. Balances that cannot be priced are
+// listed as unpriced and left out of the total.
+func (p MarketPrices) Value(balances map[types.Symbol]decimal.Decimal, quote types.Symbol) *Valuation {
+	v := &Valuation{
+		Quote: quote,
+		Total: decimal.NewFromInt(0),
+	}
+
+	for _, s := range types.Symbols {
+		qty, ok := balances[s]
+		if !ok || qty.IsZero() {
+			continue
+		}
+
+		rate, err := p.Rate(s, quote)
+		if err != nil {
+			v.Unpriced = append(v.Unpriced, s)
+			continue
+		}
+
+		item := ValuationItem{
+			Symbol:   s,
+			Quantity: qty,
+			Price:    rate,
+			Value:    qty.Mul(rate),
+		}
+
+		v.Items = append(v.Items, item)
+		v.Total = v.Total.Add(item.Value)
+	}
+
+	return v
+}
+
+// Valuation is the worth of a set of balances in a single quote symbol
+type Valuation struct {
+	Quote    types.Symbol
+	Total    decimal.Decimal
+	Items    []ValuationItem
+	Unpriced []types.Symbol
+}
+
+// ValuationItem is a single balance and its worth in the quote symbol
+type ValuationItem struct {
+	Symbol   types.Symbol
+	Quantity decimal.Decimal
+	Price    decimal.Decimal
+	Value    decimal.Decimal
+}
+
+func NewValuer(br persist.BookRepository, mr persist.MarketRepository) *Valuer {
+	return &Valuer{book: br, markets: mr}
+}
+
+// Valuer prices balances from the trades and resting orders on each market
+type Valuer struct {
+	book    persist.BookRepository
+	markets persist.MarketRepository
+}
+
+// Prices returns the current price of every market. A market is priced at its last
+// trade or, when it has never traded, at the mid price of its best bid and ask.
+// Markets with neither are left out.
+func (v *Valuer) Prices(ctx context.Context) (MarketPrices, error) {
+	prices := make(MarketPrices)
+	for _, m := range Markets() {
+		t, err := v.markets.GetLastTrade(ctx, m.Base, m.Target)
+		if err == nil {
+			prices[m] = t.Price
+			continue
+		}
+
+		if !errors.Is(err, persist.ErrObjectNotExist) {
+			return nil, fmt.Errorf("Valuer::Prices::%w", err)
+		}
+
+		mid, ok, err := v.midPrice(ctx, m)
+		if err != nil {
+			return nil, fmt.Errorf("Valuer::Prices::%w", err)
+		}
+
+		if ok {
+			prices[m] = mid
+		}
+	}
+
+	return prices, nil
+}
+
+// Value prices the balances in the quote symbol at current market prices
+func (v *Valuer) Value(ctx context.Context, balances map[types.Symbol]decimal.Decimal, quote types.Symbol) (*Valuation, error) {
+	prices, err := v.Prices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Valuer::Value::%w", err)
+	}
+
+	return prices.Value(balances, quote), nil
+}
+
+// ValueHistory values the posted balances of each snapshot at the closing prices of its
+// day. A market that did not trade on a day keeps its most recent earlier closing price.
+// Snapshots must be in date order.
+func (v *Valuer) ValueHistory(ctx context.Context, snaps []*persist.BalanceSnapshot, quote types.Symbol) ([]*Valuation, error) {
+	if len(snaps) == 0 {
+		return []*Valuation{}, nil
+	}
+
+	from, err := time.Parse(persist.SnapshotDateFormat, snaps[0].Date)
+	if err != nil {
+		return nil, fmt.Errorf("Valuer::ValueHistory::%w", err)
+	}
+
+	to, err := time.Parse(persist.SnapshotDateFormat, snaps[len(snaps)-1].Date)
+	if err != nil {
+		return nil, fmt.Errorf("Valuer::ValueHistory::%w", err)
+	}
+
+	closes := make(map[types.SwapPair][]*persist.Trade)
+	for _, m := range Markets() {
+		trades, err := v.markets.GetClosingTrades(ctx, m.Base, m.Target, from.Add(-PriceHistoryLookback), to)
+		if err != nil {
+			return nil, fmt.Errorf("Valuer::ValueHistory::%w", err)
+		}
+		closes[m] = trades
+	}
+
+	prices := make(MarketPrices)
+	next := make(map[types.SwapPair]int)
+	valuations := make([]*Valuation, len(snaps))
+	for i, snap := range snaps {
+		// move each market forward to its last close on or before the snapshot day
+		for m, trades := range closes {
+			for next[m] < len(trades) && trades[next[m]].Date() <= snap.Date {
+				prices[m] = trades[next[m]].Price
+				next[m]++
+			}
+		}
+
+		balances := make(map[types.Symbol]decimal.Decimal)
+		for _, b := range snap.Balances {
+			balances[b.Symbol] = b.Posted
+		}
+
+		valuations[i] = prices.Value(balances, quote)
+	}
+
+	return valuations, nil
+}
+
+// midPrice is halfway between the highest bid and the lowest ask. It is only available
+// when both sides of the book have a limit order.
+func (v *Valuer) midPrice(ctx context.Context, m types.SwapPair) (decimal.Decimal, bool, error) {
+	bid, ok, err := v.bestPrice(ctx, m, types.ActionTypeBuy)
+	if err != nil || !ok {
+		return decimal.Zero, false, err
+	}
+
+	ask, ok, err := v.bestPrice(ctx, m, types.ActionTypeSell)
+	if err != nil || !ok {
+		return decimal.Zero, false, err
+	}
+
+	return bid.Add(ask).Div(decimal.NewFromInt(2)), true, nil
+}
+
+// bestPrice returns the price of the first limit order at the head of one side of the book
+func (v *Valuer) bestPrice(ctx context.Context, m types.SwapPair, side types.ActionType) (decimal.Decimal, bool, error) {
+	item := &persist.BookItem{
+		Order:      types.Order{OrderRequest: types.OrderRequest{Base: m.Base, Target: m.Target}},
+		ActionType: side,
+	}
+
+	batch, err := v.book.GetHeadBatch(ctx, item, 10, nil)
+	if err != nil {
+		return decimal.Zero, false, err
+	}
+
+	for _, b := range batch {
+		if b.Order.Base != m.Base || b.Order.Target != m.Target || b.Order.Action != side {
+			continue
+		}
+
+		if lt, ok := b.Order.Type.(*types.LimitOrderType); ok {
+			return lt.Price, true, nil
+		}
+	}
+
+	return decimal.Zero, false, nil
+}
@@ -0,0 +1,158 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/kv"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarketPricesRate(t *testing.T) {
+	btc := types.SymbolBitcoin
+	eth := types.SymbolEthereum
+	ada := types.SymbolCardano
+	doge := types.SymbolDogecoin
+
+	prices := MarketPrices{
+		{Base: btc, Target: eth}:  decimal.NewFromFloat(0.05),
+		{Base: ada, Target: doge}: decimal.NewFromInt(4),
+		{Base: ada, Target: btc}:  decimal.NewFromInt(20000),
+	}
+
+	tests := []struct {
+		name  string
+		from  types.Symbol
+		quote types.Symbol
+		rate  decimal.Decimal
+	}{
+		{name: "same symbol", from: btc, quote: btc, rate: decimal.NewFromInt(1)},
+		{name: "target in base", from: eth, quote: btc, rate: decimal.NewFromFloat(0.05)},
+		{name: "base in target", from: btc, quote: eth, rate: decimal.NewFromInt(20)},
+		{name: "through one market", from: doge, quote: btc, rate: decimal.NewFromFloat(0.0002)},
+		{name: "through two markets", from: doge, quote: eth, rate: decimal.NewFromFloat(0.004)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rate, err := prices.Rate(test.from, test.quote)
+			assert.NoError(t, err)
+			assert.True(t, test.rate.Equal(rate), "expected %s; got %s", test.rate, rate)
+		})
+	}
+
+	_, err := prices.Rate(types.SymbolUniswap, btc)
+	assert.True(t, errors.Is(err, ErrNoPriceRoute))
+}
+
+func TestMarketPricesValue(t *testing.T) {
+	prices := MarketPrices{
+		{Base: types.SymbolBitcoin, Target: types.SymbolEthereum}: decimal.NewFromFloat(0.05),
+	}
+
+	v := prices.Value(map[types.Symbol]decimal.Decimal{
+		types.SymbolBitcoin:  decimal.NewFromInt(1),
+		types.SymbolEthereum: decimal.NewFromInt(10),
+		types.SymbolUniswap:  decimal.NewFromInt(3),
+		types.SymbolDogecoin: decimal.Zero,
+	}, types.SymbolBitcoin)
+
+	assert.Equal(t, types.SymbolBitcoin, v.Quote)
+	assert.True(t, decimal.NewFromFloat(1.5).Equal(v.Total), "total: %s", v.Total)
+	assert.Len(t, v.Items, 2)
+	assert.Equal(t, []types.Symbol{types.SymbolUniswap}, v.Unpriced, "balances without a route are not counted")
+}
+
+func TestValuerPrices(t *testing.T) {
+	st := persist.NewMockKVStore()
+	br := kv.NewBookRepository(st)
+	mr := kv.NewMarketRepository(st)
+	v := NewValuer(br, mr)
+	ctx := context.Background()
+
+	// without trades the market is priced from the middle of the book
+	for _, o := range []types.Order{
+		newLimitBookOrder(1, 0.04, 1, types.ActionTypeBuy),
+		newLimitBookOrder(2, 0.045, 1, types.ActionTypeBuy),
+		newLimitBookOrder(3, 0.055, 1, types.ActionTypeSell),
+		newLimitBookOrder(4, 0.06, 1, types.ActionTypeSell),
+	} {
+		item := persist.NewBookItem(o)
+		assert.NoError(t, br.SetBookItem(ctx, &item))
+	}
+
+	market := types.SwapPair{Base: types.SymbolBitcoin, Target: types.SymbolEthereum}
+	prices, err := v.Prices(ctx)
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromFloat(0.05).Equal(prices[market]), "mid price: %s", prices[market])
+
+	_, ok := prices[types.SwapPair{Base: types.SymbolBitcoin, Target: types.SymbolDogecoin}]
+	assert.False(t, ok, "markets without trades or orders are not priced")
+
+	// the last trade is used once the market has traded
+	err = mr.SetLastTrade(ctx, &persist.Trade{
+		Base:      types.SymbolBitcoin,
+		Target:    types.SymbolEthereum,
+		Price:     decimal.NewFromFloat(0.052),
+		Quantity:  decimal.NewFromInt(1),
+		Timestamp: persist.NanoTime(time.Now()),
+	})
+	assert.NoError(t, err)
+
+	prices, err = v.Prices(ctx)
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromFloat(0.052).Equal(prices[market]), "last trade: %s", prices[market])
+}
+
+func TestValuerValueHistory(t *testing.T) {
+	st := persist.NewMockKVStore()
+	mr := kv.NewMarketRepository(st)
+	v := NewValuer(kv.NewBookRepository(st), mr)
+	ctx := context.Background()
+
+	day1 := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	day3 := day1.AddDate(0, 0, 2)
+
+	for _, tr := range []struct {
+		at    time.Time
+		price float64
+	}{
+		{at: day1.Add(-time.Hour), price: 0.04},
+		{at: day1, price: 0.05},
+		{at: day3, price: 0.1},
+	} {
+		err := mr.SetLastTrade(ctx, &persist.Trade{
+			Base:      types.SymbolBitcoin,
+			Target:    types.SymbolEthereum,
+			Price:     decimal.NewFromFloat(tr.price),
+			Quantity:  decimal.NewFromInt(1),
+			Timestamp: persist.NanoTime(tr.at),
+		})
+		assert.NoError(t, err)
+	}
+
+	var snaps []*persist.BalanceSnapshot
+	for _, d := range []time.Time{day1, day2, day3} {
+		s := persist.NewBalanceSnapshot(d)
+		s.Balances = []persist.SnapshotBalance{
+			{Symbol: types.SymbolBitcoin, Posted: decimal.NewFromInt(1), Available: decimal.NewFromInt(1)},
+			{Symbol: types.SymbolEthereum, Posted: decimal.NewFromInt(10), Available: decimal.NewFromInt(5)},
+		}
+		snaps = append(snaps, s)
+	}
+
+	valuations, err := v.ValueHistory(ctx, snaps, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	if assert.Len(t, valuations, 3) {
+		// the last trade of the day is the closing price and carries over days without trades
+		assert.True(t, decimal.NewFromFloat(1.5).Equal(valuations[0].Total), "day 1: %s", valuations[0].Total)
+		assert.True(t, decimal.NewFromFloat(1.5).Equal(valuations[1].Total), "day 2: %s", valuations[1].Total)
+		assert.True(t, decimal.NewFromInt(2).Equal(valuations[2].Total), "day 3: %s", valuations[2].Total)
+	}
+}
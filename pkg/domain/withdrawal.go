@@ -0,0 +1,520 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/funding"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// WithdrawalBroadcastTimeout is how long a withdrawal can stay broadcasting before it
+	// is reported as stuck. The funding source may or may not have sent the funds so stuck
+	// withdrawals are never retried automatically.
+	WithdrawalBroadcastTimeout = 10 * time.Minute
+
+	ErrUnsupportedWithdrawal = errors.New("unsupported withdrawal")
+)
+
+// WithdrawFunds requests a withdrawal from the account to an external address. The
+// withdrawal fee is quoted, the amount and fee are placed on hold and the withdrawal is
+// saved as requested; the WithdrawalProcessor sends the funds. Withdrawals over a
+// withdrawal limit are saved for manual review instead. When a non-empty key is
+// provided, a repeated withdrawal with the same key inside the idempotency window
+// returns the original withdrawal.
+func (m *BalanceManager) WithdrawFunds(ctx context.Context, a *Account, s types.Symbol, amt decimal.Decimal, hash string, key string) (w *persist.Withdrawal, err error) {
+
+	switch s {
+	case types.SymbolCipherMtn:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedWithdrawal, s)
+	}
+
+	if m.fundingSource(s) == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedWithdrawal, s)
+	}
+
+	if key != "" {
+		var rec *persist.IdempotencyRecord
+		rec, err = m.reserveKey(ctx, a, persist.WithdrawalIdempotencyType, key)
+		if err != nil {
+			return
+		}
+
+		if rec != nil {
+			return m.withdrawalForKey(ctx, a, rec)
+		}
+	}
+
+	// the key can only be reused if the withdrawal was never saved
+	var saved bool
+	defer func() {
+		if key != "" && err != nil && !saved {
+			m.ReleaseKey(ctx, a, key)
+		}
+	}()
+
+	err = m.checkAllowlist(ctx, a, s, hash)
+	if err != nil {
+		return
+	}
+
+	q, err := m.EstimateWithdrawalFee(s, amt, hash)
+	if err != nil {
+		return
+	}
+
+	hid, err := m.SetHoldOnAccount(ctx, a, s, q.Total)
+	if err != nil {
+		return
+	}
+
+	w = persist.NewWithdrawal(a.ID.String(), s, q.Amount, hash)
+	w.Fee = q.Fee
+	w.HoldID = hid
+
+	reason, err := m.CheckWithdrawalLimits(ctx, a, w)
+	if err != nil {
+		m.RemoveHoldOnAccount(ctx, a, s, ky(hid))
+		return nil, err
+	}
+
+	if reason != "" {
+		w.Status = persist.WithdrawalReview
+		w.Reason = reason
+	}
+
+	err = m.acct.Withdrawals(&persist.Account{ID: a.ID.String()}).SetWithdrawal(ctx, w)
+	if err != nil {
+		m.RemoveHoldOnAccount(ctx, a, s, ky(hid))
+		return nil, fmt.Errorf("BalanceManager::WithdrawFunds::%w", err)
+	}
+	saved = true
+
+	if key != "" {
+		err = m.completeKey(ctx, a, persist.WithdrawalIdempotencyType, key, w.ID)
+	}
+
+	return
+}
+
+// GetWithdrawal returns a single withdrawal for the account
+func (m *BalanceManager) GetWithdrawal(ctx context.Context, a *Account, id persist.Key) (*persist.Withdrawal, error) {
+	w, err := m.acct.Withdrawals(&persist.Account{ID: a.ID.String()}).GetWithdrawal(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("BalanceManager::GetWithdrawal::%w", err)
+	}
+
+	return w, nil
+}
+
+// GetWithdrawals returns the withdrawals for the account, oldest first
+func (m *BalanceManager) GetWithdrawals(ctx context.Context, a *Account, status ...persist.WithdrawalStatus) ([]*persist.Withdrawal, error) {
+	list, err := m.acct.Withdrawals(&persist.Account{ID: a.ID.String()}).GetWithdrawals(ctx, status...)
+	if err != nil {
+		return nil, fmt.Errorf("BalanceManager::GetWithdrawals::%w", err)
+	}
+
+	return list, nil
+}
+
+// AdvanceWithdrawal moves a withdrawal to its next stage and saves it. Broadcast
+// withdrawals wait for confirmation from the funding source while broadcasting and
+// review withdrawals are left for manual resolution, so the status may not change. The hold
+// on the withdrawn funds is only released when the withdrawal reaches a final status.
+func (m *BalanceManager) AdvanceWithdrawal(ctx context.Context, w *persist.Withdrawal) error {
+
+	id, err := uuid.FromString(w.Account)
+	if err != nil {
+		return fmt.Errorf("BalanceManager::AdvanceWithdrawal::%w", err)
+	}
+	a := &Account{ID: id}
+
+	switch w.Status {
+	case persist.WithdrawalRequested:
+		err = m.approveWithdrawal(ctx, a, w)
+	case persist.WithdrawalApproved:
+		err = m.broadcastWithdrawal(ctx, w)
+	case persist.WithdrawalBroadcast:
+		err = m.confirmWithdrawal(ctx, a, w)
+	case persist.WithdrawalFailed:
+		err = m.refundWithdrawal(ctx, a, w)
+	default:
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("BalanceManager::AdvanceWithdrawal::%w", err)
+	}
+
+	return nil
+}
+
+func (m *BalanceManager) approveWithdrawal(ctx context.Context, a *Account, w *persist.Withdrawal) error {
+	if m.fundingSource(w.Symbol) == nil {
+		return m.failWithdrawal(ctx, w, fmt.Sprintf("funding source not available for %s", w.Symbol))
+	}
+
+	// limits are checked again because concurrent requests can each pass the check
+	// made when the withdrawal was requested
+	acct, err := m.GetAccount(ctx, a.ID.String())
+	if err != nil {
+		return err
+	}
+
+	reason, err := m.CheckWithdrawalLimits(ctx, acct, w)
+	if err != nil {
+		return err
+	}
+
+	if reason != "" {
+		w.Reason = reason
+		return m.setWithdrawalStatus(ctx, w, persist.WithdrawalReview)
+	}
+
+	return m.setWithdrawalStatus(ctx, w, persist.WithdrawalApproved)
+}
+
+func (m *BalanceManager) broadcastWithdrawal(ctx context.Context, w *persist.Withdrawal) error {
+	src := m.fundingSource(w.Symbol)
+	if src == nil {
+		return m.failWithdrawal(ctx, w, fmt.Sprintf("funding source not available for %s", w.Symbol))
+	}
+
+	// saved before sending so that a crash while sending leaves a record that the
+	// funds may have left. Only one processor can move the withdrawal out of approved
+	// so a withdrawal is never sent twice.
+	ok, err := m.updateWithdrawal(ctx, w, func(next *persist.Withdrawal) {
+		next.Status = persist.WithdrawalBroadcasting
+	})
+	if err != nil || !ok {
+		return err
+	}
+
+	tr := withdrawalTransaction(w)
+	hash, err := src.Withdraw(&tr)
+	if err != nil {
+		if errors.Is(err, funding.ErrWithdrawalNotSent) || errors.Is(err, funding.ErrNotImplemented) {
+			return m.failWithdrawal(ctx, w, err.Error())
+		}
+
+		// the funds may have left so the withdrawal stays broadcasting for manual review
+		w.Reason = err.Error()
+		serr := m.setWithdrawalStatus(ctx, w, persist.WithdrawalBroadcasting)
+		if serr != nil {
+			return serr
+		}
+
+		return fmt.Errorf("withdrawal %s left broadcasting: %w", w.ID, err)
+	}
+
+	w.TransactionHash = hash
+	return m.setWithdrawalStatus(ctx, w, persist.WithdrawalBroadcast)
+}
+
+// confirmWithdrawal posts a withdrawal confirmed by the funding source. The account
+// debit is claimed on the withdrawal before it is made so it is never made twice, and
+// the records written after it are replaced rather than duplicated when retried.
+func (m *BalanceManager) confirmWithdrawal(ctx context.Context, a *Account, w *persist.Withdrawal) error {
+	switch w.Settlement {
+	case persist.WithdrawalDebiting:
+		return fmt.Errorf("withdrawal %s may already be debited; left for manual review", w.ID)
+	case "":
+		if t, ok := m.fundingSource(w.Symbol).(funding.WithdrawalTracker); ok {
+			tr := withdrawalTransaction(w)
+			confirmed, err := t.WithdrawalConfirmed(&tr)
+			if err != nil || !confirmed {
+				return err
+			}
+		}
+
+		ok, err := m.updateWithdrawal(ctx, w, func(next *persist.Withdrawal) {
+			settled := persist.NanoTime(time.Now())
+			next.Settlement = persist.WithdrawalDebiting
+			next.Settled = &settled
+		})
+		if err != nil || !ok {
+			return err
+		}
+
+		err = m.PostAmtToBalance(ctx, a, w.Symbol, w.Amount.Add(w.Fee).Neg())
+		if err != nil {
+			return err
+		}
+
+		err = m.setWithdrawalSettlement(ctx, w, persist.WithdrawalDebited)
+		if err != nil {
+			return err
+		}
+	}
+
+	if w.Settlement == persist.WithdrawalDebited {
+		err := m.recordWithdrawal(ctx, a, w)
+		if err != nil {
+			return err
+		}
+
+		err = m.setWithdrawalSettlement(ctx, w, persist.WithdrawalRecorded)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := m.releaseWithdrawalHold(ctx, a, w)
+	if err != nil {
+		return err
+	}
+
+	return m.setWithdrawalStatus(ctx, w, persist.WithdrawalConfirmed)
+}
+
+// recordWithdrawal writes the account transaction records and ledger entries for a
+// debited withdrawal. Transaction records already written are skipped.
+func (m *BalanceManager) recordWithdrawal(ctx context.Context, a *Account, w *persist.Withdrawal) error {
+	trepo := m.acct.Transactions(&persist.Account{ID: a.ID.String()})
+	existing, err := trepo.GetTransactions(ctx)
+	if err != nil {
+		return err
+	}
+
+	written := make(map[persist.TransactionType]bool)
+	for _, t := range existing {
+		if t.TransactionHash == w.TransactionHash && t.Timestamp.Value() == w.Settled.Value() {
+			written[t.Type] = true
+		}
+	}
+
+	records := []*persist.Transaction{{
+		Type:     persist.TransferTransactionType,
+		Quantity: w.Amount.Neg().StringFixedBank(w.Symbol.RoundingPlace()),
+	}}
+
+	if w.Fee.IsPositive() {
+		records = append(records, &persist.Transaction{
+			Type:     persist.FeeTransactionType,
+			Quantity: w.Fee.Neg().StringFixedBank(w.Symbol.RoundingPlace()),
+		})
+	}
+
+	for _, t := range records {
+		if written[t.Type] {
+			continue
+		}
+
+		t.TransactionHash = w.TransactionHash
+		t.AddressHash = w.Address
+		t.Symbol = w.Symbol.String()
+		t.Timestamp = *w.Settled
+
+		err = trepo.SetTransaction(ctx, t)
+		if err != nil {
+			return err
+		}
+	}
+
+	return m.ledger.RecordWithdrawal(ctx, w)
+}
+
+func (m *BalanceManager) refundWithdrawal(ctx context.Context, a *Account, w *persist.Withdrawal) error {
+	err := m.releaseWithdrawalHold(ctx, a, w)
+	if err != nil {
+		return err
+	}
+
+	return m.setWithdrawalStatus(ctx, w, persist.WithdrawalRefunded)
+}
+
+func (m *BalanceManager) failWithdrawal(ctx context.Context, w *persist.Withdrawal, reason string) error {
+	w.Reason = reason
+	return m.setWithdrawalStatus(ctx, w, persist.WithdrawalFailed)
+}
+
+func (m *BalanceManager) releaseWithdrawalHold(ctx context.Context, a *Account, w *persist.Withdrawal) error {
+	err := m.RemoveHoldOnAccount(ctx, a, w.Symbol, ky(w.HoldID))
+	if err != nil && !errors.Is(err, persist.ErrObjectNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (m *BalanceManager) setWithdrawalSettlement(ctx context.Context, w *persist.Withdrawal, s persist.WithdrawalSettlement) error {
+	w.Settlement = s
+	w.Updated = persist.NanoTime(time.Now())
+
+	return m.acct.Withdrawals(&persist.Account{ID: w.Account}).SetWithdrawal(ctx, w)
+}
+
+func (m *BalanceManager) setWithdrawalStatus(ctx context.Context, w *persist.Withdrawal, s persist.WithdrawalStatus) error {
+	w.Status = s
+	w.Updated = persist.NanoTime(time.Now())
+
+	return m.acct.Withdrawals(&persist.Account{ID: w.Account}).SetWithdrawal(ctx, w)
+}
+
+// updateWithdrawal applies the change and saves the withdrawal only while the stored
+// withdrawal is unchanged since it was read. False is returned and the withdrawal is
+// left as it was when another writer updated it first.
+func (m *BalanceManager) updateWithdrawal(ctx context.Context, w *persist.Withdrawal, change func(*persist.Withdrawal)) (bool, error) {
+	next := *w
+	change(&next)
+	next.Updated = persist.NanoTime(time.Now())
+
+	err := m.acct.Withdrawals(&persist.Account{ID: w.Account}).UpdateWithdrawal(ctx, &next, w.Updated)
+	if err != nil {
+		if errors.Is(err, persist.ErrPreconditionFailed) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	*w = next
+	return true, nil
+}
+
+func (m *BalanceManager) fundingSource(s types.Symbol) funding.Source {
+	for _, src := range m.funding {
+		if src.Supports(s) {
+			return src
+		}
+	}
+
+	return nil
+}
+
+func (m *BalanceManager) withdrawalForKey(ctx context.Context, a *Account, rec *persist.IdempotencyRecord) (*persist.Withdrawal, error) {
+	return m.acct.Withdrawals(&persist.Account{ID: a.ID.String()}).GetWithdrawal(ctx, ky(rec.Reference))
+}
+
+func withdrawalTransaction(w *persist.Withdrawal) funding.Transaction {
+	return funding.Transaction{
+		Symbol:          w.Symbol,
+		TransactionHash: w.TransactionHash,
+		Address:         w.Address,
+		Amount:          w.Amount,
+	}
+}
+
+func NewWithdrawalProcessor(bm *BalanceManager, u persist.AuthorizationRepository) *WithdrawalProcessor {
+	return &WithdrawalProcessor{balances: bm, auth: u}
+}
+
+// WithdrawalProcessor drives withdrawals on every account through the withdrawal
+// workflow. Only one processor should run at a time.
+type WithdrawalProcessor struct {
+	balances *BalanceManager
+	auth     persist.AuthorizationRepository
+}
+
+// WithdrawalRun summarizes a single pass of the withdrawal processor
+type WithdrawalRun struct {
+	// Completed are withdrawals that reached a final status
+	Completed []*persist.Withdrawal
+	// Pending are withdrawals waiting for confirmation from the funding source
+	Pending []*persist.Withdrawal
+	// Review are withdrawals over a withdrawal limit waiting for manual review
+	Review []*persist.Withdrawal
+	// Stuck are withdrawals broadcasting for longer than WithdrawalBroadcastTimeout
+	Stuck []*persist.Withdrawal
+}
+
+// ProcessAll advances every open withdrawal as far as it can go. A withdrawal that
+// fails to advance is logged and left for the next run.
+func (p *WithdrawalProcessor) ProcessAll(ctx context.Context) (*WithdrawalRun, error) {
+	list, err := p.withdrawals(ctx,
+		persist.WithdrawalRequested,
+		persist.WithdrawalReview,
+		persist.WithdrawalApproved,
+		persist.WithdrawalBroadcasting,
+		persist.WithdrawalBroadcast,
+		persist.WithdrawalFailed)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &WithdrawalRun{}
+	cutoff := time.Now().Add(-WithdrawalBroadcastTimeout)
+	for _, w := range list {
+		err = p.process(ctx, w)
+		if err != nil {
+			log.Printf("withdrawal %s on account %s: %s", w.ID, w.Account, err)
+		}
+
+		switch {
+		case w.Status.Final():
+			run.Completed = append(run.Completed, w)
+		case w.Status == persist.WithdrawalReview:
+			run.Review = append(run.Review, w)
+		case w.Status == persist.WithdrawalBroadcasting:
+			if time.Time(w.Updated).Before(cutoff) {
+				run.Stuck = append(run.Stuck, w)
+			}
+		default:
+			run.Pending = append(run.Pending, w)
+		}
+	}
+
+	return run, nil
+}
+
+// FindReview returns every withdrawal waiting for manual review
+func (p *WithdrawalProcessor) FindReview(ctx context.Context) ([]*persist.Withdrawal, error) {
+	return p.withdrawals(ctx, persist.WithdrawalReview)
+}
+
+// withdrawals returns the withdrawals in the provided statuses across all accounts.
+// Accounts are shared between authorizations so each is only read once.
+func (p *WithdrawalProcessor) withdrawals(ctx context.Context, status ...persist.WithdrawalStatus) ([]*persist.Withdrawal, error) {
+	auths, err := p.auth.GetAuthorizations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("WithdrawalProcessor::withdrawals::%w", err)
+	}
+
+	out := []*persist.Withdrawal{}
+	done := make(map[string]struct{})
+	for _, auth := range auths {
+		for _, acc := range auth.Accounts {
+			if _, ok := done[acc]; ok {
+				continue
+			}
+			done[acc] = struct{}{}
+
+			id, err := uuid.FromString(acc)
+			if err != nil {
+				return nil, fmt.Errorf("WithdrawalProcessor::withdrawals::%w", err)
+			}
+
+			list, err := p.balances.GetWithdrawals(ctx, &Account{ID: id}, status...)
+			if err != nil {
+				return nil, err
+			}
+
+			out = append(out, list...)
+		}
+	}
+
+	return out, nil
+}
+
+// process advances the withdrawal until it reaches a final status or stops changing
+func (p *WithdrawalProcessor) process(ctx context.Context, w *persist.Withdrawal) error {
+	for !w.Status.Final() {
+		last := w.Status
+		err := p.balances.AdvanceWithdrawal(ctx, w)
+		if err != nil {
+			return err
+		}
+
+		if w.Status == last {
+			break
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,229 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/easterthebunny/spew-order/internal/funding"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/kv"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+type withdrawalTestSource struct {
+	funding.Source
+	err       error
+	confirmed bool
+	sent      int
+}
+
+func (s *withdrawalTestSource) Withdraw(*funding.Transaction) (string, error) {
+	s.sent++
+	if s.err != nil {
+		return "", s.err
+	}
+	return "hash", nil
+}
+
+func (s *withdrawalTestSource) WithdrawalConfirmed(*funding.Transaction) (bool, error) {
+	return s.confirmed, nil
+}
+
+func TestWithdrawalProcessor(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	lr := kv.NewLedgerRepository(st)
+	ur := kv.NewAuthorizationRepository(st)
+	src := &withdrawalTestSource{Source: funding.NewMockSource()}
+	bm := NewBalanceManager(ar, lr, src)
+	p := NewWithdrawalProcessor(bm, ur)
+	ctx := context.Background()
+
+	acct := NewAccount()
+	assert.NoError(t, ur.SetAuthorization(ctx, &persist.Authorization{ID: uuid.NewV4().String(), Accounts: []string{acct.ID.String()}}))
+	assert.NoError(t, bm.PostAmtToBalance(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(2)))
+
+	w, err := bm.WithdrawFunds(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(1), "address", "")
+	assert.NoError(t, err)
+	assert.Equal(t, persist.WithdrawalRequested, w.Status)
+
+	posted, err := bm.GetPostedBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, posted.Equal(decimal.NewFromInt(2)), posted.String())
+
+	// broadcast withdrawals wait for confirmation with funds still on hold
+	run, err := p.ProcessAll(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, run.Pending, 1)
+
+	w, err = bm.GetWithdrawal(ctx, acct, ky(w.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, persist.WithdrawalBroadcast, w.Status)
+	assert.Equal(t, "hash", w.TransactionHash)
+
+	avail, err := bm.GetAvailableBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, avail.Equal(decimal.NewFromInt(1)), avail.String())
+
+	src.confirmed = true
+	run, err = p.ProcessAll(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, run.Completed, 1)
+
+	w, err = bm.GetWithdrawal(ctx, acct, ky(w.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, persist.WithdrawalConfirmed, w.Status)
+
+	posted, err = bm.GetPostedBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, posted.Equal(decimal.NewFromInt(1)), posted.String())
+
+	avail, err = bm.GetAvailableBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, avail.Equal(decimal.NewFromInt(1)), avail.String())
+
+	// a second pass does not debit the account again
+	run, err = p.ProcessAll(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, run.Completed, 0)
+
+	// withdrawals the source rejects before sending are refunded
+	src.err = fmt.Errorf("%w: rejected", funding.ErrWithdrawalNotSent)
+	w, err = bm.WithdrawFunds(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(1), "address", "")
+	assert.NoError(t, err)
+
+	run, err = p.ProcessAll(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, run.Completed, 1)
+
+	w, err = bm.GetWithdrawal(ctx, acct, ky(w.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, persist.WithdrawalRefunded, w.Status)
+	assert.Equal(t, src.err.Error(), w.Reason)
+
+	avail, err = bm.GetAvailableBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, avail.Equal(decimal.NewFromInt(1)), avail.String())
+
+	// withdrawals that may have been sent stay broadcasting with the funds on hold
+	src.err = errors.New("timeout")
+	w, err = bm.WithdrawFunds(ctx, acct, types.SymbolBitcoin, decimal.NewFromFloat(0.5), "address", "")
+	assert.NoError(t, err)
+
+	_, err = p.ProcessAll(ctx)
+	assert.NoError(t, err)
+
+	w, err = bm.GetWithdrawal(ctx, acct, ky(w.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, persist.WithdrawalBroadcasting, w.Status)
+	assert.Equal(t, "timeout", w.Reason)
+
+	avail, err = bm.GetAvailableBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, avail.Equal(decimal.NewFromFloat(0.5)), avail.String())
+
+	// only the confirmed withdrawal reaches the ledger
+	tb, err := lr.GetTrialBalance(ctx)
+	assert.NoError(t, err)
+	net := tb.Net(persist.Transfers)[types.SymbolBitcoin]
+	assert.True(t, net.Abs().Equal(decimal.NewFromInt(1)), net.String())
+}
+
+func TestBroadcastWithdrawal_AlreadyClaimed(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	src := &withdrawalTestSource{Source: funding.NewMockSource()}
+	bm := NewBalanceManager(ar, kv.NewLedgerRepository(st), src)
+	ctx := context.Background()
+
+	acct := NewAccount()
+	assert.NoError(t, bm.PostAmtToBalance(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(2)))
+
+	w, err := bm.WithdrawFunds(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(1), "address", "")
+	assert.NoError(t, err)
+	assert.NoError(t, bm.AdvanceWithdrawal(ctx, w))
+	assert.Equal(t, persist.WithdrawalApproved, w.Status)
+
+	// another processor reads the same approved withdrawal and sends it first
+	stale := *w
+	assert.NoError(t, bm.AdvanceWithdrawal(ctx, w))
+	assert.Equal(t, 1, src.sent)
+
+	assert.NoError(t, bm.AdvanceWithdrawal(ctx, &stale))
+	assert.Equal(t, 1, src.sent)
+	assert.Equal(t, persist.WithdrawalApproved, stale.Status)
+
+	w, err = bm.GetWithdrawal(ctx, acct, ky(w.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, persist.WithdrawalBroadcast, w.Status)
+}
+
+func TestConfirmWithdrawal_Retry(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	lr := kv.NewLedgerRepository(st)
+	src := &withdrawalTestSource{Source: funding.NewMockSource(), confirmed: true}
+	bm := NewBalanceManager(ar, lr, src)
+	ctx := context.Background()
+
+	acct := NewAccount()
+	assert.NoError(t, bm.PostAmtToBalance(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(2)))
+
+	w, err := bm.WithdrawFunds(ctx, acct, types.SymbolBitcoin, decimal.NewFromInt(1), "address", "")
+	assert.NoError(t, err)
+	for w.Status != persist.WithdrawalBroadcast {
+		assert.NoError(t, bm.AdvanceWithdrawal(ctx, w))
+	}
+
+	// a second processor holding the same broadcast withdrawal does not debit it again
+	stale := *w
+	assert.NoError(t, bm.AdvanceWithdrawal(ctx, w))
+	assert.Equal(t, persist.WithdrawalConfirmed, w.Status)
+	assert.NoError(t, bm.AdvanceWithdrawal(ctx, &stale))
+
+	posted, err := bm.GetPostedBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	expected := decimal.NewFromInt(1).Sub(w.Fee)
+	assert.True(t, posted.Equal(expected), posted.String())
+
+	tb, err := lr.GetTrialBalance(ctx)
+	assert.NoError(t, err)
+
+	trepo := ar.Transactions(&persist.Account{ID: acct.ID.String()})
+	records, err := trepo.GetTransactions(ctx)
+	assert.NoError(t, err)
+
+	// a confirmation interrupted after writing its records writes them again in place
+	w.Status = persist.WithdrawalBroadcast
+	w.Settlement = persist.WithdrawalDebited
+	assert.NoError(t, bm.setWithdrawalSettlement(ctx, w, persist.WithdrawalDebited))
+	assert.NoError(t, bm.AdvanceWithdrawal(ctx, w))
+	assert.Equal(t, persist.WithdrawalConfirmed, w.Status)
+
+	again, err := lr.GetTrialBalance(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, tb, again)
+
+	after, err := trepo.GetTransactions(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, after, len(records))
+
+	posted, err = bm.GetPostedBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, posted.Equal(expected), posted.String())
+
+	// a confirmation interrupted while debiting is left for manual review
+	w.Status = persist.WithdrawalBroadcast
+	assert.NoError(t, bm.setWithdrawalSettlement(ctx, w, persist.WithdrawalDebiting))
+	assert.Error(t, bm.AdvanceWithdrawal(ctx, w))
+	assert.Equal(t, persist.WithdrawalBroadcast, w.Status)
+
+	posted, err = bm.GetPostedBalance(ctx, acct, types.SymbolBitcoin)
+	assert.NoError(t, err)
+	assert.True(t, posted.Equal(expected), posted.String())
+}
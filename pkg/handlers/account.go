@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -35,36 +36,185 @@ func (h *AccountHandler) GetAccounts() func(w http.ResponseWriter, r *http.Reque
 
 		var res []render.Renderer
 		for _, acct := range authz.Accounts {
-			res = append(res, &api.Account{
+			a := &api.Account{
 				Id: acct,
-			})
+			}
+
+			if id, err := uuid.FromString(acct); err == nil {
+				p, err := h.repo.Find(r.Context(), id)
+				if err != nil && !errors.Is(err, persist.ErrObjectNotExist) {
+					render.Render(w, r, HTTPInternalServerError(err))
+					return
+				}
+
+				if p != nil {
+					a.Label = accountLabel(p.Label)
+					a.Archived = &p.Archived
+				}
+			}
+
+			res = append(res, a)
 		}
 
 		render.Render(w, r, HTTPNewOKListResponse(res))
 	}
 }
 
-func (h *AccountHandler) GetAccount() func(w http.ResponseWriter, r *http.Request) {
+// PostAccount provides an http handler that creates a sub-account under the current
+// authorization.
+func (h *AccountHandler) PostAccount(b *domain.BalanceManager, u persist.AuthorizationRepository) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		authz := contexts.GetAuthorization(ctx)
+
+		var in api.AccountRequest
+		err := json.NewDecoder(r.Body).Decode(&in)
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
+		}
+
+		var label string
+		if in.Label != nil {
+			label = strings.TrimSpace(*in.Label)
+		}
+
+		acct, err := b.CreateSubAccount(ctx, u, authz, label)
+		if err != nil {
+			if errors.Is(err, domain.ErrAccountLimit) || errors.Is(err, domain.ErrAccountLabelLength) {
+				render.Render(w, r, HTTPBadRequest(err))
+				return
+			}
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		render.Render(w, r, HTTPNewOKResponse(accountResponse(acct)))
+	}
+}
+
+// PatchAccount provides an http handler that updates the account label, archives and
+// restores the account, and turns the withdrawal allowlist on or off.
+func (h *AccountHandler) PatchAccount(b *domain.BalanceManager, au persist.AuditRepository) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		acct := contexts.GetAccount(ctx)
+		authz := contexts.GetAuthorization(ctx)
+
+		if acct == nil {
+			render.Render(w, r, HTTPInternalServerError(errors.New("incorrect route structure")))
+			return
+		}
+
+		var in api.AccountUpdateRequest
+		err := json.NewDecoder(r.Body).Decode(&in)
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
+		}
+
+		if in.Label != nil {
+			err = b.LabelAccount(ctx, acct, strings.TrimSpace(*in.Label))
+			if err != nil {
+				if errors.Is(err, domain.ErrAccountLabelLength) {
+					render.Render(w, r, HTTPBadRequest(err))
+					return
+				}
+				render.Render(w, r, HTTPInternalServerError(err))
+				return
+			}
+		}
+
+		if in.Archived != nil {
+			if *in.Archived {
+				err = b.ArchiveAccount(ctx, authz, acct)
+			} else {
+				err = b.RestoreAccount(ctx, acct)
+			}
+
+			if err != nil {
+				if errors.Is(err, domain.ErrAccountNotEmpty) || errors.Is(err, domain.ErrLastActiveAccount) {
+					render.Render(w, r, HTTPConflict(err))
+					return
+				}
+				render.Render(w, r, HTTPInternalServerError(err))
+				return
+			}
+		}
+
+		if in.Allowlist != nil {
+			if *in.Allowlist {
+				err = b.EnableAllowlist(ctx, au, acct)
+			} else {
+				err = b.DisableAllowlist(ctx, au, acct)
+			}
+
+			if err != nil {
+				render.Render(w, r, HTTPInternalServerError(err))
+				return
+			}
+		}
+
+		render.Render(w, r, HTTPNewOKResponse(accountResponse(acct)))
+	}
+}
+
+// DefaultValuationQuote is the symbol balances are valued in when no quote is requested
+var DefaultValuationQuote = types.SymbolBitcoin
+
+// GetAccount returns the account with its balances. Balances are valued in the requested
+// quote symbol when a valuer is provided.
+func (h *AccountHandler) GetAccount(v *domain.Valuer) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		acct := contexts.GetAccount(r.Context())
+		ctx := r.Context()
+		acct := contexts.GetAccount(ctx)
+
+		quote, err := quoteSymbol(r)
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
+		}
 
-		res := api.Account{
-			Id: acct.ID.String(),
+		var valuation *domain.Valuation
+		if v != nil {
+			valuation, err = v.Value(ctx, acct.Balances, quote)
+			if err != nil {
+				render.Render(w, r, HTTPInternalServerError(err))
+				return
+			}
 		}
 
+		res := accountResponse(acct)
+
 		items := []api.BalanceItem{}
 		for _, s := range acct.ActiveSymbols() {
 			i := api.BalanceItem{}
 			if bal, ok := acct.Balances[s]; ok {
 				i.Quantity = api.CurrencyValue(bal.StringFixedBank(s.RoundingPlace()))
 			}
+			if pending, ok := acct.Pending[s]; ok {
+				v := api.CurrencyValue(pending.StringFixedBank(s.RoundingPlace()))
+				i.Pending = &v
+			}
 			i.Symbol = api.SymbolType(s.String())
+			if valuation != nil {
+				for _, vi := range valuation.Items {
+					if vi.Symbol == s {
+						val := api.CurrencyValue(vi.Value.StringFixedBank(quote.RoundingPlace()))
+						i.Value = &val
+					}
+				}
+			}
 			items = append(items, i)
 		}
 		bl := api.BalanceList(items)
 		res.Balances = &bl
 
-		render.Render(w, r, HTTPNewOKResponse(&res))
+		if valuation != nil {
+			res.Valuation = valuationResponse(valuation)
+		}
+
+		render.Render(w, r, HTTPNewOKResponse(res))
 	}
 }
 
@@ -76,6 +226,7 @@ func (h *AccountHandler) GetAccountOrder() func(w http.ResponseWriter, r *http.R
 			Guid:   ord.Base.ID.String(),
 			Order:  api.BuildOrderRequest(ord.Base.OrderRequest),
 			Status: api.StringOrderStatus(ord.Status),
+			Reason: api.OrderRejectReason(ord),
 		}
 
 		render.Render(w, r, HTTPNewOKResponse(&res))
@@ -88,7 +239,7 @@ func (h *AccountHandler) GetAccountOrders() func(w http.ResponseWriter, r *http.
 		acct := contexts.GetAccount(ctx)
 		or := h.repo.Orders(&persist.Account{ID: acct.ID.String()})
 
-		list, err := or.GetOrdersByStatus(ctx, persist.StatusOpen, persist.StatusPartial, persist.StatusFilled, persist.StatusCanceled)
+		list, err := or.GetOrdersByStatus(ctx, persist.StatusOpen, persist.StatusPartial, persist.StatusFilled, persist.StatusCanceled, persist.StatusRejected, persist.StatusPartialCanceled)
 		if err != nil {
 			render.Render(w, r, HTTPInternalServerError(err))
 			return
@@ -100,6 +251,7 @@ func (h *AccountHandler) GetAccountOrders() func(w http.ResponseWriter, r *http.
 				Guid:   ord.Base.ID.String(),
 				Order:  api.BuildOrderRequest(ord.Base.OrderRequest),
 				Status: api.StringOrderStatus(ord.Status),
+				Reason: api.OrderRejectReason(ord),
 			}
 			out = append(out, &o)
 		}
@@ -108,7 +260,7 @@ func (h *AccountHandler) GetAccountOrders() func(w http.ResponseWriter, r *http.
 	}
 }
 
-// PostTransaction provides an http handler that initiates a funds withdrawal. Limited to permitted
+// PostTransaction provides an http handler that requests a funds withdrawal. Limited to permitted
 // currencies.
 func (h *AccountHandler) PostTransaction(b *domain.BalanceManager) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -163,27 +315,419 @@ func (h *AccountHandler) PostTransaction(b *domain.BalanceManager) func(w http.R
 			return
 		}
 
-		tr, err := b.WithdrawFunds(ctx, acct, smb, amt, in.Address)
+		key := r.Header.Get(api.IdempotencyKeyHeaderName)
+		if len(key) > maxIdempotencyKeyLength {
+			render.Render(w, r, HTTPBadRequest(fmt.Errorf("idempotency key limited to %d characters", maxIdempotencyKeyLength)))
+			return
+		}
+
+		wd, err := b.WithdrawFunds(ctx, acct, smb, amt, in.Address, key)
 		if err != nil {
-			if errors.Is(domain.ErrInsufficientBalanceForHold, err) {
+			if errors.Is(err, domain.ErrInsufficientBalanceForHold) || isIdempotencyConflict(err) {
 				render.Render(w, r, HTTPConflict(err))
 				return
 			}
+			if errors.Is(err, domain.ErrUnsupportedWithdrawal) || errors.Is(err, domain.ErrFeeExceedsAmount) {
+				render.Render(w, r, HTTPBadRequest(err))
+				return
+			}
+			if errors.Is(err, domain.ErrAddressNotAllowed) {
+				render.Render(w, r, HTTPStatusError(http.StatusForbidden, err))
+				return
+			}
 			render.Render(w, r, HTTPInternalServerError(err))
 			return
 		}
 
-		if tr == nil {
+		if wd == nil {
 			render.Render(w, r, HTTPInternalServerError(errors.New("unexpected state")))
 			return
 		}
 
+		render.Render(w, r, HTTPNewOKResponse(api.WithdrawalFromRecord(wd)))
+	}
+}
+
+// GetWithdrawals provides an http handler that lists the withdrawals on an account
+// with their current status.
+func (h *AccountHandler) GetWithdrawals(b *domain.BalanceManager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		acct := contexts.GetAccount(ctx)
+
+		list, err := b.GetWithdrawals(ctx, acct)
+		if err != nil {
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		var out []render.Renderer
+		for _, wd := range list {
+			out = append(out, api.WithdrawalFromRecord(wd))
+		}
+
+		render.Render(w, r, HTTPNewOKListResponse(out))
+	}
+}
+
+// GetDeposits provides an http handler that lists the deposits received by an account
+// with their confirmation status.
+func (h *AccountHandler) GetDeposits(b *domain.BalanceManager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		acct := contexts.GetAccount(ctx)
+
+		list, err := b.GetDeposits(ctx, acct)
+		if err != nil {
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		var out []render.Renderer
+		for _, d := range list {
+			out = append(out, api.DepositFromRecord(d, domain.RequiredConfirmations[d.Symbol]))
+		}
+
+		render.Render(w, r, HTTPNewOKListResponse(out))
+	}
+}
+
+// GetWithdrawalFee provides an http handler that quotes the fee for a withdrawal of the
+// requested quantity without placing a hold.
+func (h *AccountHandler) GetWithdrawalFee(b *domain.BalanceManager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		var smb types.Symbol
+		err := json.Unmarshal([]byte(fmt.Sprintf(`"%s"`, query.Get(api.SymbolQueryParamName))), &smb)
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
+		}
+
+		allowWithdrawal := false
+		for _, s := range types.PermittedWithdrawal {
+			if s == smb {
+				allowWithdrawal = true
+				break
+			}
+		}
+
+		if !allowWithdrawal {
+			render.Render(w, r, HTTPBadRequest(errors.New("symbol not available for withdrawal")))
+			return
+		}
+
+		amt, err := decimal.NewFromString(query.Get(api.QuantityQueryParamName))
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
+		}
+
+		if amt.LessThanOrEqual(decimal.NewFromInt(0)) {
+			render.Render(w, r, HTTPBadRequest(errors.New("quantity must be greater than 0")))
+			return
+		}
+
+		q, err := b.EstimateWithdrawalFee(smb, amt, query.Get(api.AddressQueryParamName))
+		if err != nil {
+			if errors.Is(err, domain.ErrFeeExceedsAmount) {
+				render.Render(w, r, HTTPBadRequest(err))
+				return
+			}
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		place := smb.RoundingPlace()
+		render.Render(w, r, HTTPNewOKResponse(&api.FeeEstimate{
+			Symbol:   api.SymbolType(smb.String()),
+			Quantity: api.CurrencyValue(q.Amount.StringFixedBank(place)),
+			Fee:      api.CurrencyValue(q.Fee.StringFixedBank(place)),
+			Total:    api.CurrencyValue(q.Total.StringFixedBank(place)),
+		}))
+	}
+}
+
+// GetWithdrawal provides an http handler that returns a single withdrawal
+func (h *AccountHandler) GetWithdrawal(b *domain.BalanceManager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		acct := contexts.GetAccount(ctx)
+
+		id, err := uuid.FromString(h.urlParam(r, api.WithdrawalPathParamName))
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(errors.New("invalid withdrawal id")))
+			return
+		}
+
+		wd, err := b.GetWithdrawal(ctx, acct, id)
+		if err != nil {
+			if errors.Is(err, persist.ErrObjectNotExist) {
+				render.Render(w, r, HTTPNotFound(errors.New("withdrawal not found")))
+				return
+			}
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		render.Render(w, r, HTTPNewOKResponse(api.WithdrawalFromRecord(wd)))
+	}
+}
+
+// GetAllowlist provides an http handler that lists the withdrawal addresses on the
+// account allowlist.
+func (h *AccountHandler) GetAllowlist(b *domain.BalanceManager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		acct := contexts.GetAccount(ctx)
+
+		list, err := b.GetAllowlist(ctx, acct)
+		if err != nil {
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		now := time.Now()
+		var out []render.Renderer
+		for _, e := range list {
+			out = append(out, api.AllowlistEntryFromRecord(e, now))
+		}
+
+		render.Render(w, r, HTTPNewOKListResponse(out))
+	}
+}
+
+// PostAllowlistEntry provides an http handler that adds a withdrawal address to the
+// account allowlist. The address is usable once the cooldown has passed.
+func (h *AccountHandler) PostAllowlistEntry(b *domain.BalanceManager, au persist.AuditRepository) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		acct := contexts.GetAccount(ctx)
+
+		var in api.AllowlistEntryRequest
+		err := json.NewDecoder(r.Body).Decode(&in)
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
+		}
+
+		var smb types.Symbol
+		err = json.Unmarshal([]byte(fmt.Sprintf(`"%s"`, string(in.Symbol))), &smb)
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
+		}
+
+		var label string
+		if in.Label != nil {
+			label = strings.TrimSpace(*in.Label)
+		}
+
+		e, err := b.AddAllowlistEntry(ctx, au, acct, smb, in.Address, label)
+		if err != nil {
+			if errors.Is(err, domain.ErrInvalidAddress) || errors.Is(err, domain.ErrAllowlistLabelLength) {
+				render.Render(w, r, HTTPBadRequest(err))
+				return
+			}
+			if errors.Is(err, domain.ErrAllowlistEntryExists) {
+				render.Render(w, r, HTTPConflict(err))
+				return
+			}
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		render.Render(w, r, HTTPNewOKResponse(api.AllowlistEntryFromRecord(e, time.Now())))
+	}
+}
+
+// PatchAllowlistEntry provides an http handler that updates the label of an allowlist
+// address.
+func (h *AccountHandler) PatchAllowlistEntry(b *domain.BalanceManager, au persist.AuditRepository) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		acct := contexts.GetAccount(ctx)
+
+		id, err := uuid.FromString(h.urlParam(r, api.AllowlistEntryPathParamName))
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(errors.New("invalid allowlist entry id")))
+			return
+		}
+
+		var in api.AllowlistEntryUpdateRequest
+		err = json.NewDecoder(r.Body).Decode(&in)
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
+		}
+
+		e, err := b.LabelAllowlistEntry(ctx, au, acct, id, strings.TrimSpace(in.Label))
+		if err != nil {
+			if errors.Is(err, domain.ErrAllowlistEntryNotFound) {
+				render.Render(w, r, HTTPNotFound(err))
+				return
+			}
+			if errors.Is(err, domain.ErrAllowlistLabelLength) {
+				render.Render(w, r, HTTPBadRequest(err))
+				return
+			}
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		render.Render(w, r, HTTPNewOKResponse(api.AllowlistEntryFromRecord(e, time.Now())))
+	}
+}
+
+// DeleteAllowlistEntry provides an http handler that removes an address from the
+// account allowlist.
+func (h *AccountHandler) DeleteAllowlistEntry(b *domain.BalanceManager, au persist.AuditRepository) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		acct := contexts.GetAccount(ctx)
+
+		id, err := uuid.FromString(h.urlParam(r, api.AllowlistEntryPathParamName))
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(errors.New("invalid allowlist entry id")))
+			return
+		}
+
+		err = b.RemoveAllowlistEntry(ctx, au, acct, id)
+		if err != nil {
+			if errors.Is(err, domain.ErrAllowlistEntryNotFound) {
+				render.Render(w, r, HTTPNotFound(err))
+				return
+			}
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		render.Render(w, r, HTTPNoContentResponse())
+	}
+}
+
+// GetAllowlistHistory provides an http handler that lists the changes made to the
+// account allowlist.
+func (h *AccountHandler) GetAllowlistHistory(b *domain.BalanceManager, au persist.AuditRepository) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		acct := contexts.GetAccount(ctx)
+
+		history, err := b.GetAllowlistHistory(ctx, au, acct)
+		if err != nil {
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		var out []render.Renderer
+		for _, e := range history {
+			out = append(out, api.AllowlistEventFromAudit(e))
+		}
+
+		render.Render(w, r, HTTPNewOKListResponse(out))
+	}
+}
+
+// PostTransfer moves funds from the account to another account held by the same
+// authorization
+func (h *AccountHandler) PostTransfer(b *domain.BalanceManager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		acct := contexts.GetAccount(ctx)
+
+		if acct == nil {
+			render.Render(w, r, HTTPInternalServerError(errors.New("incorrect route structure")))
+			return
+		}
+
+		var in api.InternalTransferRequest
+		err := json.NewDecoder(r.Body).Decode(&in)
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
+		}
+
+		amt, err := decimal.NewFromString(string(in.Quantity))
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
+		}
+
+		if amt.LessThanOrEqual(decimal.NewFromInt(0)) {
+			render.Render(w, r, HTTPBadRequest(errors.New("quantity must be greater than 0")))
+			return
+		}
+
+		var smb types.Symbol
+		err = json.Unmarshal([]byte(fmt.Sprintf(`"%s"`, string(in.Symbol))), &smb)
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
+		}
+
+		if !amt.Equal(amt.Round(smb.RoundingPlace())) {
+			render.Render(w, r, HTTPBadRequest(fmt.Errorf("quantity limited to %d decimal places", smb.RoundingPlace())))
+			return
+		}
+
+		to, err := uuid.FromString(in.Account)
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(errors.New("invalid destination account")))
+			return
+		}
+
+		var owned bool
+		authz := contexts.GetAuthorization(ctx)
+		for _, id := range authz.Accounts {
+			if id == to.String() {
+				owned = true
+				break
+			}
+		}
+
+		if !owned {
+			render.Render(w, r, HTTPBadRequest(errors.New("destination account not held by this authorization")))
+			return
+		}
+
+		key := r.Header.Get(api.IdempotencyKeyHeaderName)
+		if len(key) > maxIdempotencyKeyLength {
+			render.Render(w, r, HTTPBadRequest(fmt.Errorf("idempotency key limited to %d characters", maxIdempotencyKeyLength)))
+			return
+		}
+
+		dest, err := b.GetAccount(ctx, to.String())
+		if err != nil {
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		if dest.Archived {
+			render.Render(w, r, HTTPBadRequest(fmt.Errorf("destination %w", domain.ErrAccountArchived)))
+			return
+		}
+
+		tr, err := b.TransferInternal(ctx, acct, dest, smb, amt, key)
+		if err != nil {
+			if errors.Is(err, domain.ErrSameAccountTransfer) {
+				render.Render(w, r, HTTPBadRequest(err))
+				return
+			}
+			if errors.Is(err, domain.ErrInsufficientBalanceForHold) || isIdempotencyConflict(err) {
+				render.Render(w, r, HTTPConflict(err))
+				return
+			}
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		other := tr.AddressHash
 		o := api.Transaction{
-			Type:            api.TransactionTypeTRANSFER,
+			Type:            api.TransactionTypeINTERNAL,
+			Account:         &other,
 			Symbol:          api.SymbolType(tr.Symbol),
 			Quantity:        api.CurrencyValue(tr.Quantity),
-			Fee:             "",
-			Orderid:         "",
 			Timestamp:       time.Time(tr.Timestamp).Format(time.RFC3339),
 			TransactionHash: tr.TransactionHash,
 		}
@@ -191,6 +735,62 @@ func (h *AccountHandler) PostTransaction(b *domain.BalanceManager) func(w http.R
 	}
 }
 
+// PostReferral records the account that referred the account in the route. Accounts
+// held by the same authorization cannot refer each other.
+func (h *AccountHandler) PostReferral(b *domain.BalanceManager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		acct := contexts.GetAccount(ctx)
+
+		if acct == nil {
+			render.Render(w, r, HTTPInternalServerError(errors.New("incorrect route structure")))
+			return
+		}
+
+		var in api.ReferralRequest
+		err := json.NewDecoder(r.Body).Decode(&in)
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
+		}
+
+		referrer, err := uuid.FromString(in.Referrer)
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(errors.New("invalid referring account")))
+			return
+		}
+
+		if authz := contexts.GetAuthorization(ctx); authz != nil {
+			for _, id := range authz.Accounts {
+				if id == referrer.String() {
+					render.Render(w, r, HTTPBadRequest(domain.ErrSelfReferral))
+					return
+				}
+			}
+		}
+
+		err = b.SetReferrer(ctx, acct, referrer)
+		if err != nil {
+			if errors.Is(err, domain.ErrSelfReferral) || errors.Is(err, domain.ErrReferrerNotFound) {
+				render.Render(w, r, HTTPBadRequest(err))
+				return
+			}
+			if errors.Is(err, domain.ErrAlreadyReferred) || errors.Is(err, domain.ErrReferralClosed) {
+				render.Render(w, r, HTTPConflict(err))
+				return
+			}
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		render.Render(w, r, HTTPNewOKResponse(&api.Referral{
+			Account:   acct.ID.String(),
+			Referrer:  referrer.String(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		}))
+	}
+}
+
 func (h *AccountHandler) GetAccountTransactions() func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -214,6 +814,10 @@ func (h *AccountHandler) GetAccountTransactions() func(w http.ResponseWriter, r
 				Timestamp:       time.Time(trans.Timestamp).Format(time.RFC3339),
 				TransactionHash: trans.TransactionHash,
 			}
+			if trans.Type == persist.InternalTransactionType {
+				other := trans.AddressHash
+				t.Account = &other
+			}
 			out = append(out, &t)
 		}
 
@@ -221,6 +825,125 @@ func (h *AccountHandler) GetAccountTransactions() func(w http.ResponseWriter, r
 	}
 }
 
+// DefaultBalanceHistoryDays is the number of days of balance history returned when no
+// start date is provided
+var DefaultBalanceHistoryDays = 30
+
+// GetBalanceHistory returns the daily balance snapshots for the account over an
+// inclusive date range. Each snapshot is valued in the requested quote symbol when a
+// valuer is provided.
+func (h *AccountHandler) GetBalanceHistory(b *domain.BalanceManager, v *domain.Valuer) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		acct := contexts.GetAccount(ctx)
+
+		to := time.Now().UTC()
+		if v := r.URL.Query().Get(api.ToQueryParamName); v != "" {
+			t, err := time.Parse(persist.SnapshotDateFormat, v)
+			if err != nil {
+				render.Render(w, r, HTTPBadRequest(fmt.Errorf("invalid to date '%s'; expected YYYY-MM-DD", v)))
+				return
+			}
+			to = t
+		}
+
+		from := to.AddDate(0, 0, 1-DefaultBalanceHistoryDays)
+		if v := r.URL.Query().Get(api.FromQueryParamName); v != "" {
+			t, err := time.Parse(persist.SnapshotDateFormat, v)
+			if err != nil {
+				render.Render(w, r, HTTPBadRequest(fmt.Errorf("invalid from date '%s'; expected YYYY-MM-DD", v)))
+				return
+			}
+			from = t
+		}
+
+		quote, err := quoteSymbol(r)
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
+		}
+
+		snaps, err := b.GetBalanceHistory(ctx, acct, from, to)
+		if err != nil {
+			if errors.Is(err, domain.ErrInvalidHistoryRange) {
+				render.Render(w, r, HTTPBadRequest(err))
+				return
+			}
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		history := api.BalanceHistoryFromSnapshots(snaps)
+		if v != nil {
+			valuations, err := v.ValueHistory(ctx, snaps, quote)
+			if err != nil {
+				render.Render(w, r, HTTPInternalServerError(err))
+				return
+			}
+
+			for i := range history {
+				history[i].Valuation = valuationResponse(valuations[i])
+			}
+		}
+
+		render.Render(w, r, HTTPNewOKResponse(&history))
+	}
+}
+
+// GetStatement renders the account transactions and opening and closing balances for an
+// inclusive date range as a CSV or OFX file. The range defaults to the current month.
+func (h *AccountHandler) GetStatement(b *domain.BalanceManager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		acct := contexts.GetAccount(ctx)
+		query := r.URL.Query()
+
+		now := time.Now().UTC()
+		from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+		for name, t := range map[string]*time.Time{api.FromQueryParamName: &from, api.ToQueryParamName: &to} {
+			if v := query.Get(name); v != "" {
+				d, err := time.Parse(persist.SnapshotDateFormat, v)
+				if err != nil {
+					render.Render(w, r, HTTPBadRequest(fmt.Errorf("invalid %s date '%s'; expected YYYY-MM-DD", name, v)))
+					return
+				}
+				*t = d
+			}
+		}
+
+		format := domain.CSVStatementFormat
+		if v := query.Get(api.FormatQueryParamName); v != "" {
+			format = domain.StatementFormat(strings.ToLower(v))
+			if format != domain.CSVStatementFormat && format != domain.OFXStatementFormat {
+				render.Render(w, r, HTTPBadRequest(fmt.Errorf("%w: %s", domain.ErrUnknownStatementFormat, v)))
+				return
+			}
+		}
+
+		// the end date is inclusive
+		st, err := b.GetStatement(ctx, acct, from, to.AddDate(0, 0, 1))
+		if err != nil {
+			if errors.Is(err, domain.ErrInvalidStatementPeriod) {
+				render.Render(w, r, HTTPBadRequest(err))
+				return
+			}
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		name := fmt.Sprintf("statement-%s-%s-%s.%s", acct.ID, from.Format(persist.SnapshotDateFormat), to.Format(persist.SnapshotDateFormat), format)
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", name))
+		w.WriteHeader(http.StatusOK)
+
+		if err := st.Write(w, format); err != nil {
+			log.Printf("GetStatement: %s", err)
+		}
+	}
+}
+
 func (h *AccountHandler) GetFundingAddress() func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -322,24 +1045,27 @@ func (h *AccountHandler) AccountCtx(bm *domain.BalanceManager, paramFunc func(*h
 
 			authz := contexts.GetAuthorization(r.Context())
 
-			var availableID string
-			for _, j := range authz.Accounts {
-				availableID = j
-			}
-
-			if availableID == "" {
+			if len(authz.Accounts) == 0 {
 				render.Render(w, r, HTTPUnauthorized(errors.New("no accounts authorized")))
 				return
 			}
 
 			if accountID := paramFunc(r, api.AccountPathParamName); accountID != "" {
-				if accountID != availableID {
+				var held bool
+				for _, j := range authz.Accounts {
+					if j == accountID {
+						held = true
+						break
+					}
+				}
+
+				if !held {
 					render.Render(w, r, HTTPUnauthorized(errors.New("invalid authorization to access this account")))
 					return
 				}
 
 				// look for the account in storage and create the account if it doesn't exist
-				ctxAccount, err = bm.GetAccount(r.Context(), accountID)
+				ctxAccount, err = bm.GetOwnedAccount(r.Context(), authz, accountID)
 				if err != nil {
 					render.Render(w, r, HTTPInternalServerError(fmt.Errorf("AccountCtx::%w", err)))
 					return
@@ -354,3 +1080,81 @@ func (h *AccountHandler) AccountCtx(bm *domain.BalanceManager, paramFunc func(*h
 		})
 	}
 }
+
+// ActiveAccountCtx rejects requests on archived accounts. Archived accounts can still be
+// read and updated but cannot trade, move funds or receive deposits.
+func (h *AccountHandler) ActiveAccountCtx() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			acct := contexts.GetAccount(r.Context())
+			if acct == nil {
+				render.Render(w, r, HTTPInternalServerError(errors.New("incorrect route structure")))
+				return
+			}
+
+			if acct.Archived {
+				render.Render(w, r, HTTPConflict(domain.ErrAccountArchived))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func accountResponse(a *domain.Account) *api.Account {
+	archived := a.Archived
+	allowlist := a.AllowlistEnforced(time.Now())
+	out := &api.Account{
+		Id:        a.ID.String(),
+		Label:     accountLabel(a.Label),
+		Archived:  &archived,
+		Allowlist: &allowlist,
+	}
+
+	if allowlist && !a.AllowlistDisableAt.IsZero() {
+		at := a.AllowlistDisableAt.Format(time.RFC3339)
+		out.AllowlistDisableAt = &at
+	}
+
+	return out
+}
+
+// quoteSymbol reads the quote query parameter or falls back to the default quote
+func quoteSymbol(r *http.Request) (types.Symbol, error) {
+	v := r.URL.Query().Get(api.QuoteQueryParamName)
+	if v == "" {
+		return DefaultValuationQuote, nil
+	}
+
+	s, err := types.FromString(v)
+	if err != nil {
+		return s, fmt.Errorf("invalid quote symbol '%s'", v)
+	}
+
+	return s, nil
+}
+
+func valuationResponse(v *domain.Valuation) *api.Valuation {
+	out := &api.Valuation{
+		Quote: api.SymbolType(v.Quote.String()),
+		Total: api.CurrencyValue(v.Total.StringFixedBank(v.Quote.RoundingPlace())),
+	}
+
+	if len(v.Unpriced) > 0 {
+		unpriced := make([]api.SymbolType, len(v.Unpriced))
+		for i, s := range v.Unpriced {
+			unpriced[i] = api.SymbolType(s.String())
+		}
+		out.Unpriced = &unpriced
+	}
+
+	return out
+}
+
+func accountLabel(l string) *string {
+	if l == "" {
+		return nil
+	}
+	return &l
+}
@@ -2,16 +2,20 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"log"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/easterthebunny/spew-order/internal/contexts"
 	"github.com/easterthebunny/spew-order/internal/persist"
 	"github.com/easterthebunny/spew-order/internal/persist/kv"
 	"github.com/easterthebunny/spew-order/pkg/api"
 	"github.com/easterthebunny/spew-order/pkg/domain"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,7 +34,7 @@ func TestGetAccount(t *testing.T) {
 	// create a response recorder for later inspection of the response
 	w := httptest.NewRecorder()
 
-	h := NewAccountHandler(repo).GetAccount()
+	h := NewAccountHandler(repo).GetAccount(nil)
 
 	h(w, r)
 
@@ -52,6 +56,101 @@ func TestGetAccount(t *testing.T) {
 	assert.Equal(t, acct.ID.String(), responseAccount.Id)
 }
 
+func TestGetAccountValuation(t *testing.T) {
+	st := persist.NewMockKVStore()
+	mr := kv.NewMarketRepository(st)
+	v := domain.NewValuer(kv.NewBookRepository(st), mr)
+
+	err := mr.SetLastTrade(context.Background(), &persist.Trade{
+		Base:      types.SymbolBitcoin,
+		Target:    types.SymbolEthereum,
+		Price:     decimal.NewFromFloat(0.05),
+		Quantity:  decimal.NewFromInt(1),
+		Timestamp: persist.NanoTime(time.Now()),
+	})
+	assert.NoError(t, err)
+
+	acct := domain.NewAccount()
+	acct.Balances[types.SymbolBitcoin] = decimal.NewFromInt(1)
+	acct.Balances[types.SymbolEthereum] = decimal.NewFromInt(10)
+
+	tests := []struct {
+		quote  string
+		code   int
+		symbol api.SymbolType
+		total  api.CurrencyValue
+	}{
+		{quote: "", code: 200, symbol: "BTC", total: "1.50000000"},
+		{quote: "ETH", code: 200, symbol: "ETH", total: "30.000000000000000000"},
+		{quote: "XYZ", code: 400},
+	}
+
+	for _, test := range tests {
+		r := NewGet(t, "/?quote="+test.quote)
+		r = r.WithContext(contexts.AttachAccount(r.Context(), *acct))
+		w := httptest.NewRecorder()
+
+		NewAccountHandler(kv.NewAccountRepository(st)).GetAccount(v)(w, r)
+
+		assert.Equal(t, test.code, w.Code, "quote '%s'", test.quote)
+		if test.code != 200 {
+			continue
+		}
+
+		var res struct {
+			Data api.Account `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+		if assert.NotNil(t, res.Data.Valuation) {
+			assert.Equal(t, test.symbol, res.Data.Valuation.Quote)
+			assert.Equal(t, test.total, res.Data.Valuation.Total)
+		}
+	}
+}
+
 func TestOrderContext(t *testing.T) {
 
 }
+
+func TestGetBalanceHistory(t *testing.T) {
+
+	acct := domain.NewAccount()
+	store := persist.NewMockKVStore()
+	repo := kv.NewAccountRepository(store)
+	bm := domain.NewBalanceManager(repo, kv.NewLedgerRepository(store))
+
+	tests := []struct {
+		path string
+		code int
+		days int
+	}{
+		{path: "/", code: 200, days: 0},
+		{path: "/?from=2021-03-01&to=2021-03-02", code: 200, days: 2},
+		{path: "/?from=2021-03-02&to=2021-03-01", code: 400},
+		{path: "/?from=03-01-2021", code: 400},
+	}
+
+	_, err := bm.SnapshotBalances(context.Background(), acct, time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	_, err = bm.SnapshotBalances(context.Background(), acct, time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+
+	for _, test := range tests {
+		r := NewGet(t, test.path)
+		r = r.WithContext(contexts.AttachAccount(r.Context(), *acct))
+		w := httptest.NewRecorder()
+
+		NewAccountHandler(repo).GetBalanceHistory(bm, nil)(w, r)
+
+		assert.Equal(t, test.code, w.Code, test.path)
+		if test.code != 200 {
+			continue
+		}
+
+		var res struct {
+			Data api.BalanceHistory `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+		assert.Len(t, res.Data, test.days, test.path)
+	}
+}
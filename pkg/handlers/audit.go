@@ -6,161 +6,235 @@ import (
 	"fmt"
 	"net/http"
 	"sort"
+	"time"
 
 	"github.com/easterthebunny/render"
 	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/api"
+	"github.com/easterthebunny/spew-order/pkg/domain"
 	"github.com/easterthebunny/spew-order/pkg/types"
+	"github.com/go-chi/chi"
+	uuid "github.com/satori/go.uuid"
 	"github.com/shopspring/decimal"
 )
 
 type AuditHandler struct {
-	accounts persist.AccountRepository
-	auths    persist.AuthorizationRepository
-	ledger   persist.LedgerRepository
-	book     persist.BookRepository
+	accounts   persist.AccountRepository
+	auths      persist.AuthorizationRepository
+	ledger     persist.LedgerRepository
+	book       persist.BookRepository
+	runs       persist.AuditRunRepository
+	reconciler *domain.Reconciler
 }
 
-func NewAuditHandler(a persist.AccountRepository, t persist.AuthorizationRepository, l persist.LedgerRepository, b persist.BookRepository) *AuditHandler {
+func NewAuditHandler(a persist.AccountRepository, t persist.AuthorizationRepository, l persist.LedgerRepository, b persist.BookRepository, ar persist.AuditRunRepository, rc *domain.Reconciler) *AuditHandler {
 	return &AuditHandler{
-		accounts: a,
-		auths:    t,
-		ledger:   l,
-		book:     b}
+		accounts:   a,
+		auths:      t,
+		ledger:     l,
+		book:       b,
+		runs:       ar,
+		reconciler: rc}
 }
 
+// AuditBalances checks account balances, transaction history and the ledger against
+// each other for every symbol and stores the result as an audit run. With as_of, user
+// balances are rebuilt from transactions and the ledger from entries posted before that
+// time, and the checks against current balances and the order book are skipped.
 func (h *AuditHandler) AuditBalances() func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var err error
-
-		response := AuditResponse{
-			UserAccounts:   make(map[string]string),
-			LedgerAccounts: make(map[string]map[string]string), // map[symbol][account]balance
-			Balance:        make(map[string]string),
-			Throughput:     make(map[string]string),
-			Errors:         []string{},
+		asOf, err := timeFromQuery(r, api.AsOfQueryParamName)
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
 		}
 
 		ctx := r.Context()
-		symbols := []types.Symbol{
-			types.SymbolBitcoin,
-			types.SymbolEthereum,
-			types.SymbolCipherMtn}
-		accountBalances, orderBalances, msgs, err := h.getAccountBalances(ctx, symbols)
-		if err != nil {
-			render.Render(w, r, HTTPInternalServerError(err))
-			return
+		now := time.Now()
+		run := &persist.AuditRun{
+			ID:        uuid.NewV4().String(),
+			Timestamp: persist.NanoTime(now),
+			AsOf:      persist.NanoTime(now),
+			Errors:    []string{},
 		}
-		response.Errors = append(response.Errors, msgs...)
 
-		aBal := make(map[types.Symbol]decimal.Decimal)
-		lBal := make(map[types.Symbol]decimal.Decimal)
+		if !asOf.IsZero() {
+			run.AsOf = persist.NanoTime(asOf)
+		}
 
-		transferAssets, err := h.ledger.GetAssetBalance(ctx, persist.Transfers)
+		accountBalances, orderBalances, msgs, err := h.getAccountBalances(ctx, types.Symbols, asOf)
 		if err != nil {
 			render.Render(w, r, HTTPInternalServerError(err))
 			return
 		}
-		payableLiabilities, err := h.ledger.GetLiabilityBalance(ctx, persist.TransfersPayable)
+		run.Errors = append(run.Errors, msgs...)
+
+		// trades are journaled against customer liability sub-accounts and
+		// should leave the ledger balanced for every symbol
+		trial, err := h.ledger.GetTrialBalanceAt(ctx, asOf)
 		if err != nil {
 			render.Render(w, r, HTTPInternalServerError(err))
 			return
 		}
 
-		for k, x := range accountBalances {
-			response.UserAccounts[k.String()] = x.StringFixedBank(k.RoundingPlace())
+		for _, k := range trial.Unbalanced() {
+			run.Errors = append(run.Errors, fmt.Sprintf("trial balance debit/credit discrepancy: %s", k))
+		}
 
-			y, ok := transferAssets[k]
-			if !ok {
-				y = decimal.NewFromInt(0)
-			}
+		transferAssets := trial.Net(persist.Transfers)
+		payableLiabilities := trial.Net(persist.TransfersPayable)
+		cashAssets := trial.Net(persist.Cash)
+		salesLiabilities := trial.Net(persist.Sales)
+		customerLiabilities := trial.Net(persist.CustomerLiabilities)
+		promotionAssets := trial.Net(persist.Promotions)
 
-			response.LedgerAccounts[k.String()] = map[string]string{
-				persist.Transfers.String(): y.StringFixedBank(k.RoundingPlace()),
-			}
+		for _, k := range types.Symbols {
+			x := accountBalances[k]
+			run.Set(k, persist.AuditUserAccounts, x)
+			run.Set(k, persist.AuditThroughput, orderBalances[k])
+
+			// asset accounts carry a debit balance
+			y := transferAssets[k].Neg()
+			run.Set(k, persist.Transfers.String(), y)
 
-			if !x.Equal(y) {
+			// promotional grants are paid into account balances without a transfer
+			promo := promotionAssets[k].Neg()
+			run.Set(k, persist.Promotions.String(), promo)
+
+			if !x.Equal(y.Add(promo)) {
 				msg := fmt.Sprintf("incorrect %s balance check for account balance and transfer: %s", k, y.StringFixedBank(k.RoundingPlace()))
-				response.Errors = append(response.Errors, msg)
+				run.Errors = append(run.Errors, msg)
 			}
 
-			a, ok := aBal[k]
-			if !ok {
-				aBal[k] = y
-			} else {
-				aBal[k] = a.Add(y)
-			}
+			z := payableLiabilities[k]
+			run.Set(k, persist.TransfersPayable.String(), z)
 
-			z, ok := payableLiabilities[k]
-			if !ok {
-				z = decimal.NewFromInt(0)
+			if !x.Equal(z.Add(promo)) {
+				msg := fmt.Sprintf("incorrect balance check for account balance and payable: %s", k)
+				run.Errors = append(run.Errors, msg)
 			}
 
-			response.LedgerAccounts[k.String()][persist.TransfersPayable.String()] = z.StringFixedBank(k.RoundingPlace())
+			cash := cashAssets[k].Neg()
+			sales := salesLiabilities[k]
+			run.Set(k, persist.Cash.String(), cash)
+			run.Set(k, persist.Sales.String(), sales)
 
-			if !x.Equal(z) {
-				msg := fmt.Sprintf("incorrect balance check for account balance and payable: %s", k)
-				response.Errors = append(response.Errors, msg)
+			if !cash.Equal(sales) {
+				run.Errors = append(run.Errors, fmt.Sprintf("sales/cash discrepancy: %s", k))
 			}
 
-			l, ok := lBal[k]
-			if !ok {
-				lBal[k] = z
-			} else {
-				lBal[k] = l.Add(z)
+			c := customerLiabilities[k]
+			run.Set(k, persist.CustomerLiabilities.String(), c)
+
+			// trades move balances between customers so the sum of all
+			// customer balances is the payable amount plus net trade liabilities
+			if !x.Equal(z.Add(c)) {
+				run.Errors = append(run.Errors, fmt.Sprintf("trial balance does not reconcile with account balances: %s", k))
 			}
-		}
 
-		for k, x := range orderBalances {
-			response.Throughput[k.String()] = x.StringFixedBank(k.RoundingPlace())
+			assets := y.Add(cash)
+			liabilities := z.Add(sales)
+			run.Set(k, persist.AuditBalance, liabilities.Sub(assets))
+
+			if !liabilities.Equal(assets) {
+				run.Errors = append(run.Errors, fmt.Sprintf("liability/asset discrepancy: %s", k))
+			}
 		}
 
-		cashAssets, err := h.ledger.GetAssetBalance(ctx, persist.Cash)
+		err = h.runs.SetAuditRun(ctx, run)
 		if err != nil {
 			render.Render(w, r, HTTPInternalServerError(err))
 			return
 		}
-		salesLiabilities, err := h.ledger.GetLiabilityBalance(ctx, persist.Sales)
+
+		response := auditResponse(run)
+		response.TrialBalance = trial
+
+		render.Render(w, r, HTTPNewOKResponse(response))
+	}
+}
+
+// GetAuditRuns returns the history of audit runs, oldest first
+func (h *AuditHandler) GetAuditRuns() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		runs, err := h.runs.GetAuditRuns(r.Context())
 		if err != nil {
 			render.Render(w, r, HTTPInternalServerError(err))
 			return
 		}
 
-		for k, x := range cashAssets {
-			a, ok := aBal[k]
-			if !ok {
-				aBal[k] = x
-			} else {
-				aBal[k] = a.Add(x)
-			}
+		var out []render.Renderer
+		for _, run := range runs {
+			out = append(out, auditResponse(run))
+		}
 
-			response.LedgerAccounts[k.String()][persist.Cash.String()] = x.StringFixedBank(k.RoundingPlace())
+		render.Render(w, r, HTTPNewOKListResponse(out))
+	}
+}
 
-			z, ok := salesLiabilities[k]
-			if !ok || !x.Equal(z) {
-				response.Errors = append(response.Errors, fmt.Sprintf("sales/cash discrepancy: %s", k))
+// GetAuditRun returns a single stored audit run
+func (h *AuditHandler) GetAuditRun() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.FromString(chi.URLParam(r, api.AuditRunPathParamName))
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(errors.New("invalid audit run id")))
+			return
+		}
+
+		run, err := h.runs.GetAuditRun(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, persist.ErrObjectNotExist) {
+				render.Render(w, r, HTTPNotFound(errors.New("audit run not found")))
+				return
 			}
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
 
-			response.LedgerAccounts[k.String()][persist.Sales.String()] = z.StringFixedBank(k.RoundingPlace())
+		render.Render(w, r, HTTPNewOKResponse(auditResponse(run)))
+	}
+}
 
-			l, ok := lBal[k]
-			if !ok {
-				lBal[k] = z
-			} else {
-				lBal[k] = l.Add(z)
+// GetAuditDiff returns the values and errors that changed between two audit runs
+func (h *AuditHandler) GetAuditDiff() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var runs []*persist.AuditRun
+		for _, name := range []string{api.FromQueryParamName, api.ToQueryParamName} {
+			id, err := uuid.FromString(r.URL.Query().Get(name))
+			if err != nil {
+				render.Render(w, r, HTTPBadRequest(fmt.Errorf("invalid %s audit run id", name)))
+				return
 			}
-		}
 
-		for k, a := range aBal {
-			l, ok := lBal[k]
-			if !ok {
-				render.Render(w, r, HTTPInternalServerError(errors.New("missing balance")))
+			run, err := h.runs.GetAuditRun(r.Context(), id)
+			if err != nil {
+				if errors.Is(err, persist.ErrObjectNotExist) {
+					render.Render(w, r, HTTPNotFound(fmt.Errorf("audit run '%s' not found", id)))
+					return
+				}
+				render.Render(w, r, HTTPInternalServerError(err))
 				return
 			}
+			runs = append(runs, run)
+		}
 
-			response.Balance[k.String()] = l.Sub(a).StringFixedBank(k.RoundingPlace())
+		diff := runs[0].Diff(*runs[1])
+		response := AuditDiffResponse{
+			From:           diff.From,
+			To:             diff.To,
+			Changes:        make([]AuditChangeResponse, len(diff.Changes)),
+			NewErrors:      diff.NewErrors,
+			ResolvedErrors: diff.ResolvedErrors,
+		}
 
-			if !l.Equal(a) {
-				response.Errors = append(response.Errors, fmt.Sprintf("liability/asset discrepancy: %s", k))
+		for i, c := range diff.Changes {
+			place := c.Symbol.RoundingPlace()
+			response.Changes[i] = AuditChangeResponse{
+				Symbol: c.Symbol.String(),
+				Name:   c.Name,
+				From:   c.From.StringFixedBank(place),
+				To:     c.To.StringFixedBank(place),
+				Change: c.To.Sub(c.From).StringFixedBank(place),
 			}
 		}
 
@@ -168,7 +242,50 @@ func (h *AuditHandler) AuditBalances() func(w http.ResponseWriter, r *http.Reque
 	}
 }
 
-func (h *AuditHandler) getAccountBalances(ctx context.Context, symbols []types.Symbol) (map[types.Symbol]decimal.Decimal, map[types.Symbol]decimal.Decimal, []string, error) {
+func auditResponse(run *persist.AuditRun) *AuditResponse {
+	res := &AuditResponse{
+		ID:             run.ID,
+		Timestamp:      time.Time(run.Timestamp).UTC().Format(time.RFC3339),
+		AsOf:           time.Time(run.AsOf).UTC().Format(time.RFC3339),
+		UserAccounts:   make(map[string]string),
+		LedgerAccounts: make(map[string]map[string]string),
+		Balance:        make(map[string]string),
+		Throughput:     make(map[string]string),
+		Errors:         run.Errors,
+	}
+
+	if res.Errors == nil {
+		res.Errors = []string{}
+	}
+
+	for s, vals := range run.Values {
+		place := s.RoundingPlace()
+		for name, amt := range vals {
+			v := amt.StringFixedBank(place)
+			switch name {
+			case persist.AuditUserAccounts:
+				res.UserAccounts[s.String()] = v
+			case persist.AuditThroughput:
+				res.Throughput[s.String()] = v
+			case persist.AuditBalance:
+				res.Balance[s.String()] = v
+			default:
+				if _, ok := res.LedgerAccounts[s.String()]; !ok {
+					res.LedgerAccounts[s.String()] = make(map[string]string)
+				}
+				res.LedgerAccounts[s.String()][name] = v
+			}
+		}
+	}
+
+	return res
+}
+
+// getAccountBalances totals the balance of every account and the amount held for orders
+// for each symbol. With a zero asOf, stored balances are checked against transaction
+// history and orders against the book. Otherwise balances are rebuilt from transactions
+// before asOf and only orders placed before asOf are counted.
+func (h *AuditHandler) getAccountBalances(ctx context.Context, symbols []types.Symbol, asOf time.Time) (map[types.Symbol]decimal.Decimal, map[types.Symbol]decimal.Decimal, []string, error) {
 	var err error
 	var msgs []string
 	var auths []*persist.Authorization
@@ -186,14 +303,19 @@ func (h *AuditHandler) getAccountBalances(ctx context.Context, symbols []types.S
 	}
 
 	// calculate totals for all symbols for all accounts
+	done := make(map[string]struct{})
 	for _, auth := range auths {
 		for _, acc := range auth.Accounts {
+			if _, ok := done[acc]; ok {
+				continue
+			}
+			done[acc] = struct{}{}
+
 			a := &persist.Account{ID: acc}
 
-			trbals := map[types.Symbol]decimal.Decimal{
-				types.SymbolBitcoin:   decimal.NewFromInt(0),
-				types.SymbolEthereum:  decimal.NewFromInt(0),
-				types.SymbolCipherMtn: decimal.NewFromInt(0),
+			trbals := make(map[types.Symbol]decimal.Decimal)
+			for _, s := range symbols {
+				trbals[s] = decimal.NewFromInt(0)
 			}
 
 			trepo := h.accounts.Transactions(a)
@@ -207,14 +329,14 @@ func (h *AuditHandler) getAccountBalances(ctx context.Context, symbols []types.S
 			})
 
 			for _, tr := range transactions {
-				var sym types.Symbol
-				switch tr.Symbol {
-				case "BTC":
-					sym = types.SymbolBitcoin
-				case "ETH":
-					sym = types.SymbolEthereum
-				case "CMTN":
-					sym = types.SymbolCipherMtn
+				if !asOf.IsZero() && !time.Time(tr.Timestamp).Before(asOf) {
+					break
+				}
+
+				sym, err := types.FromString(tr.Symbol)
+				if err != nil {
+					msgs = append(msgs, fmt.Sprintf("account %s has a transaction with unknown symbol '%s'", acc, tr.Symbol))
+					continue
 				}
 
 				qty, _ := decimal.NewFromString(tr.Quantity)
@@ -227,12 +349,21 @@ func (h *AuditHandler) getAccountBalances(ctx context.Context, symbols []types.S
 			}
 
 			orepo := h.accounts.Orders(a)
-			orders, err := orepo.GetOrdersByStatus(ctx, persist.StatusFilled, persist.StatusOpen, persist.StatusPartial, persist.StatusCanceled)
+			orders, err := orepo.GetOrdersByStatus(ctx, persist.StatusFilled, persist.StatusOpen, persist.StatusPartial, persist.StatusCanceled, persist.StatusRejected, persist.StatusPartialCanceled)
 			if err != nil {
 				return accountBalances, orderBalance, msgs, err
 			}
 
 			for _, order := range orders {
+				if !asOf.IsZero() {
+					// the book only reflects the current state of orders
+					if order.Base.Timestamp.Before(asOf) {
+						s, a := order.Base.Type.HoldAmount(order.Base.Action, order.Base.Base, order.Base.Target)
+						orderBalance[s] = orderBalance[s].Add(a)
+					}
+					continue
+				}
+
 				bi := persist.NewBookItem(order.Base)
 				exists, err := h.book.BookItemExists(ctx, &bi)
 
@@ -244,8 +375,16 @@ func (h *AuditHandler) getAccountBalances(ctx context.Context, symbols []types.S
 				if err != nil {
 					return accountBalances, orderBalance, msgs, err
 				}
+				if _, ok := order.Base.Type.(*types.MarketOrderType); ok {
+					// market orders never rest on the book; unfilled remainders are canceled
+					if exists {
+						msgs = append(msgs, fmt.Sprintf("account %s order %s is a market order and is on the order book with key %s", acc, order.Base.ID, key))
+					}
+					continue
+				}
+
 				switch order.Status {
-				case persist.StatusCanceled, persist.StatusFilled:
+				case persist.StatusCanceled, persist.StatusFilled, persist.StatusRejected, persist.StatusPartialCanceled:
 					// order should not be on the book
 					if exists {
 						msgs = append(msgs, fmt.Sprintf("account %s order %s exists on order book with key %s", acc, order.Base.ID, key))
@@ -256,16 +395,13 @@ func (h *AuditHandler) getAccountBalances(ctx context.Context, symbols []types.S
 						msgs = append(msgs, fmt.Sprintf("account %s order %s does not exist on order book", acc, order.Base.ID))
 					}
 				}
+			}
 
-				/* TODO: market orders are allowed on the order book if there are no matching orders on the opposite book
-				switch order.Base.Type.(type) {
-				case *types.MarketOrderType:
-					// market orders should not appear on the book
-					if exists {
-						msgs = append(msgs, fmt.Sprintf("account %s order %s is a market order and is on the order book with key %s", acc, order.Base.ID, key))
-					}
+			if !asOf.IsZero() {
+				for _, s := range symbols {
+					accountBalances[s] = accountBalances[s].Add(trbals[s])
 				}
-				*/
+				continue
 			}
 
 			for _, s := range symbols {
@@ -294,10 +430,14 @@ func (h *AuditHandler) getAccountBalances(ctx context.Context, symbols []types.S
 }
 
 type AuditResponse struct {
+	ID             string                       `json:"id"`
+	Timestamp      string                       `json:"timestamp"`
+	AsOf           string                       `json:"as_of"`
 	UserAccounts   map[string]string            `json:"user_accounts"`
-	LedgerAccounts map[string]map[string]string `json:"ledger_accounts"`
+	LedgerAccounts map[string]map[string]string `json:"ledger_accounts"` // map[symbol][account]balance
 	Balance        map[string]string            `json:"balance"`
 	Throughput     map[string]string            `json:"order_throughput"`
+	TrialBalance   *persist.TrialBalance        `json:"trial_balance,omitempty"`
 	Errors         []string                     `json:"errors"`
 }
 
@@ -305,3 +445,24 @@ type AuditResponse struct {
 func (a *AuditResponse) Render(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
+
+type AuditChangeResponse struct {
+	Symbol string `json:"symbol"`
+	Name   string `json:"name"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Change string `json:"change"`
+}
+
+type AuditDiffResponse struct {
+	From           string                `json:"from"`
+	To             string                `json:"to"`
+	Changes        []AuditChangeResponse `json:"changes"`
+	NewErrors      []string              `json:"new_errors"`
+	ResolvedErrors []string              `json:"resolved_errors"`
+}
+
+// Render implements the render.Renderer interface for use with chi-router
+func (a *AuditDiffResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
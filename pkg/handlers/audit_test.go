@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/funding"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/kv"
+	"github.com/easterthebunny/spew-order/pkg/domain"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditBalances(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	ur := kv.NewAuthorizationRepository(st)
+	lr := kv.NewLedgerRepository(st)
+	runs := kv.NewAuditRunRepository(st)
+	bm := domain.NewBalanceManager(ar, lr, funding.NewMockSource())
+	h := NewAuditHandler(ar, ur, lr, kv.NewBookRepository(st), runs, nil)
+	ctx := context.Background()
+
+	acct := domain.NewAccount()
+	assert.NoError(t, ur.SetAuthorization(ctx, &persist.Authorization{ID: uuid.NewV4().String(), Accounts: []string{acct.ID.String()}}))
+	assert.NoError(t, ar.Save(ctx, &persist.Account{ID: acct.ID.String()}))
+	assert.NoError(t, bm.FundAccountByID(ctx, acct.ID, types.SymbolBitcoin, decimal.NewFromInt(1)))
+
+	before := time.Now().Add(time.Second)
+	time.Sleep(time.Second)
+	assert.NoError(t, bm.FundAccountByID(ctx, acct.ID, types.SymbolCardano, decimal.NewFromInt(5)))
+
+	audit := func(path string) *AuditResponse {
+		w := httptest.NewRecorder()
+		h.AuditBalances()(w, NewGet(t, path))
+		assert.Equal(t, 200, w.Code, w.Body.String())
+
+		var res struct {
+			Data AuditResponse `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+		return &res.Data
+	}
+
+	// every symbol is audited, not only the ones with trading pairs
+	current := audit("/")
+	assert.Len(t, current.Errors, 0, current.Errors)
+	assert.Len(t, current.UserAccounts, len(types.Symbols))
+	assert.Equal(t, "5.000000", current.UserAccounts[types.SymbolCardano.String()])
+	assert.Equal(t, "5.000000", current.LedgerAccounts[types.SymbolCardano.String()][persist.TransfersPayable.String()])
+
+	// balances are rebuilt as of an earlier time
+	past := audit(fmt.Sprintf("/?as_of=%s", url.QueryEscape(before.UTC().Format(time.RFC3339))))
+	assert.Len(t, past.Errors, 0, past.Errors)
+	assert.Equal(t, "0.000000", past.UserAccounts[types.SymbolCardano.String()])
+	assert.Equal(t, "1.00000000", past.UserAccounts[types.SymbolBitcoin.String()])
+
+	list, err := runs.GetAuditRuns(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	w := httptest.NewRecorder()
+	h.GetAuditDiff()(w, NewGet(t, fmt.Sprintf("/?from=%s&to=%s", past.ID, current.ID)))
+	assert.Equal(t, 200, w.Code, w.Body.String())
+
+	var res struct {
+		Data AuditDiffResponse `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+	assert.Len(t, res.Data.Changes, 3)
+	for _, c := range res.Data.Changes {
+		assert.Equal(t, types.SymbolCardano.String(), c.Symbol)
+		assert.Equal(t, "5.000000", c.Change)
+	}
+
+	w = httptest.NewRecorder()
+	h.GetAuditDiff()(w, NewGet(t, fmt.Sprintf("/?from=%s&to=%s", past.ID, uuid.NewV4())))
+	assert.Equal(t, 404, w.Code)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/easterthebunny/render"
+	"github.com/easterthebunny/spew-order/internal/persist"
+)
+
+// GetCampaigns returns every campaign with the amount granted so far, oldest first
+func (h *AuditHandler) GetCampaigns() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		campaigns, err := h.accounts.Campaigns().GetCampaigns(r.Context())
+		if err != nil {
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		var out []render.Renderer
+		for _, c := range campaigns {
+			out = append(out, campaignResponse(c))
+		}
+
+		render.Render(w, r, HTTPNewOKListResponse(out))
+	}
+}
+
+func campaignResponse(c *persist.Campaign) *CampaignResponse {
+	place := c.Symbol.RoundingPlace()
+	return &CampaignResponse{
+		ID:              c.ID,
+		Name:            c.Name,
+		Rule:            string(c.Rule),
+		Symbol:          c.Symbol.String(),
+		Amount:          c.Amount.StringFixedBank(place),
+		MatchRate:       c.MatchRate.String(),
+		Budget:          c.Budget.StringFixedBank(place),
+		Granted:         c.Granted.StringFixedBank(place),
+		Remaining:       c.Remaining().StringFixedBank(place),
+		PerAccountLimit: c.PerAccountLimit,
+		Start:           time.Time(c.Start).UTC().Format(time.RFC3339),
+		End:             time.Time(c.End).UTC().Format(time.RFC3339),
+		Active:          c.Active(time.Now()),
+	}
+}
+
+type CampaignResponse struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Rule            string `json:"rule"`
+	Symbol          string `json:"symbol"`
+	Amount          string `json:"amount"`
+	MatchRate       string `json:"match_rate"`
+	Budget          string `json:"budget"`
+	Granted         string `json:"granted"`
+	Remaining       string `json:"remaining"`
+	PerAccountLimit int    `json:"per_account_limit"`
+	Start           string `json:"start"`
+	End             string `json:"end"`
+	Active          bool   `json:"active"`
+}
+
+// Render implements the render.Renderer interface for use with chi-router
+func (cr *CampaignResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/internal/persist/kv"
+	"github.com/easterthebunny/spew-order/pkg/domain"
+	"github.com/easterthebunny/spew-order/pkg/types"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCampaignRoutes(t *testing.T) {
+	st := persist.NewMockKVStore()
+	ar := kv.NewAccountRepository(st)
+	lr := kv.NewLedgerRepository(st)
+	bm := domain.NewBalanceManager(ar, lr)
+	ur := kv.NewAuthorizationRepository(st)
+	h := NewAuditHandler(ar, ur, lr, kv.NewBookRepository(st), kv.NewAuditRunRepository(st), nil)
+	routes := (&AuditRouter{Audit: h}).Routes()
+	ctx := context.Background()
+
+	// campaigns are created with the campaigns tool; the audit api only reads them
+	c := persist.NewCampaign("welcome", persist.SignUpBonus, types.SymbolCipherMtn, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	c.Amount = decimal.NewFromInt(5000)
+	c.Budget = decimal.NewFromInt(50000)
+	assert.NoError(t, domain.ValidateCampaign(c))
+	assert.NoError(t, ar.Campaigns().CreateCampaign(ctx, c))
+
+	acct := domain.NewAccount()
+	assert.NoError(t, ur.SetAuthorization(ctx, &persist.Authorization{ID: uuid.NewV4().String(), Accounts: []string{acct.ID.String()}}))
+	_, err := bm.GetAccount(ctx, acct.ID.String())
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	routes.ServeHTTP(w, NewGet(t, "/campaigns"))
+	assert.Equal(t, 200, w.Code)
+
+	var list struct {
+		Data []CampaignResponse `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	if assert.Len(t, list.Data, 1) {
+		assert.Equal(t, "5000", list.Data[0].Granted)
+		assert.Equal(t, "45000", list.Data[0].Remaining)
+	}
+
+	// campaign management and grant listings are not served by the audit api
+	w = httptest.NewRecorder()
+	routes.ServeHTTP(w, httptest.NewRequest("POST", "/campaigns", strings.NewReader("{}")))
+	assert.Equal(t, 405, w.Code)
+
+	w = httptest.NewRecorder()
+	routes.ServeHTTP(w, NewGet(t, fmt.Sprintf("/campaigns/%s/grants", c.ID)))
+	assert.Equal(t, 404, w.Code)
+
+	// grants are balances without a transfer and still reconcile in the audit
+	w = httptest.NewRecorder()
+	routes.ServeHTTP(w, NewGet(t, "/audit"))
+	assert.Equal(t, 200, w.Code)
+
+	var audit struct {
+		Data AuditResponse `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&audit))
+	assert.Len(t, audit.Data.Errors, 0, audit.Data.Errors)
+	assert.Equal(t, "5000", audit.Data.LedgerAccounts[types.SymbolCipherMtn.String()][persist.Promotions.String()])
+}
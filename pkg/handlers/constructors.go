@@ -17,7 +17,7 @@ func NewGoogleOrderBook(client *firestore.Client, f ...funding.Source) *domain.O
 	a := firebase.NewAccountRepository(client)
 	l := firebase.NewLedgerRepository(client)
 	bs := domain.NewBalanceManager(a, l, f...)
-	return domain.NewOrderBook(br, bs)
+	return domain.NewOrderBook(br, bs, firebase.NewMarketRepository(client))
 }
 
 func NewGoogleKVStore(bucket *string) (persist.KVStore, error) {
@@ -61,12 +61,17 @@ func NewDefaultRouter(client *firestore.Client, ps queue.PubSub, pr middleware.A
 	l := firebase.NewLedgerRepository(client)
 	bs := domain.NewBalanceManager(a, l, f...)
 
+	u := firebase.NewAuthorizationRepository(client)
+
 	r := Router{
-		AuthStore: firebase.NewAuthorizationRepository(client),
-		Balance:   bs,
-		AuthProv:  pr,
-		Orders:    NewOrderHandler(queue.NewOrderQueue(ps, bs)),
-		Accounts:  NewAccountHandler(a),
+		AuthStore:   u,
+		Balance:     bs,
+		AuthProv:    pr,
+		Orders:      NewOrderHandler(queue.NewOrderQueue(ps, bs)),
+		Accounts:    NewAccountHandler(a),
+		Audit:       firebase.NewAuditRepository(client),
+		Liabilities: domain.NewLiabilityProver(bs, u, firebase.NewLiabilityRepository(client)),
+		Valuer:      domain.NewValuer(firebase.NewBookRepository(client), firebase.NewMarketRepository(client)),
 	}
 
 	return &r, nil
@@ -81,11 +86,66 @@ func NewWebhookRouter(client *firestore.Client, f funding.Source, d funding.Sour
 		Airdrop: NewFundingHandler(a, l, d)}
 }
 
-func NewAuditRouter(client *firestore.Client) *AuditRouter {
+func NewAuditRouter(client *firestore.Client, f ...funding.Source) *AuditRouter {
 	a := firebase.NewAccountRepository(client)
 	u := firebase.NewAuthorizationRepository(client)
 	l := firebase.NewLedgerRepository(client)
 	b := firebase.NewBookRepository(client)
+	bs := domain.NewBalanceManager(a, l, f...)
+	rc := domain.NewReconciler(bs, u, firebase.NewReconciliationRepository(client))
+
+	return &AuditRouter{
+		Audit: NewAuditHandler(a, u, l, b, firebase.NewAuditRunRepository(client), rc)}
+}
+
+func NewGoogleReconciler(client *firestore.Client, f ...funding.Source) *domain.Reconciler {
+	a := firebase.NewAccountRepository(client)
+	l := firebase.NewLedgerRepository(client)
+	u := firebase.NewAuthorizationRepository(client)
+
+	return domain.NewReconciler(domain.NewBalanceManager(a, l, f...), u, firebase.NewReconciliationRepository(client))
+}
+
+func NewGoogleHoldAuditor(client *firestore.Client) *domain.HoldAuditor {
+	a := firebase.NewAccountRepository(client)
+	u := firebase.NewAuthorizationRepository(client)
+	au := firebase.NewAuditRepository(client)
+
+	return domain.NewHoldAuditor(a, u, au)
+}
+
+func NewGoogleBalanceSnapshotter(client *firestore.Client) *domain.BalanceSnapshotter {
+	a := firebase.NewAccountRepository(client)
+	l := firebase.NewLedgerRepository(client)
+	u := firebase.NewAuthorizationRepository(client)
+
+	return domain.NewBalanceSnapshotter(domain.NewBalanceManager(a, l), u)
+}
+
+func NewGoogleWithdrawalProcessor(client *firestore.Client, f ...funding.Source) *domain.WithdrawalProcessor {
+	a := firebase.NewAccountRepository(client)
+	l := firebase.NewLedgerRepository(client)
+	u := firebase.NewAuthorizationRepository(client)
+
+	return domain.NewWithdrawalProcessor(domain.NewBalanceManager(a, l, f...), u)
+}
+
+func NewGoogleDepositProcessor(client *firestore.Client, f ...funding.Source) *domain.DepositProcessor {
+	a := firebase.NewAccountRepository(client)
+	l := firebase.NewLedgerRepository(client)
+	u := firebase.NewAuthorizationRepository(client)
+
+	return domain.NewDepositProcessor(domain.NewBalanceManager(a, l, f...), u)
+}
+
+func NewGoogleLiabilityProver(client *firestore.Client) *domain.LiabilityProver {
+	a := firebase.NewAccountRepository(client)
+	l := firebase.NewLedgerRepository(client)
+	u := firebase.NewAuthorizationRepository(client)
+
+	return domain.NewLiabilityProver(domain.NewBalanceManager(a, l), u, firebase.NewLiabilityRepository(client))
+}
 
-	return &AuditRouter{Audit: NewAuditHandler(a, u, l, b)}
+func NewGooglePostCompactor(client *firestore.Client) persist.PostCompactor {
+	return firebase.NewPostCompactor(client)
 }
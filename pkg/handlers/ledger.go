@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/easterthebunny/render"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/api"
+	"github.com/easterthebunny/spew-order/pkg/types"
+)
+
+// MaxLedgerPageSize is the largest page of ledger entries a single request can return
+var MaxLedgerPageSize = 1000
+
+// GetLedgerEntries returns a page of ledger entries. Entries can be filtered by ledger
+// account, symbol and time range and are paged with the cursor from the previous response.
+func (h *AuditHandler) GetLedgerEntries() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := ledgerQueryFromRequest(r)
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
+		}
+
+		page, err := h.ledger.GetEntries(r.Context(), q)
+		if err != nil {
+			if errors.Is(err, persist.ErrInvalidCursor) {
+				render.Render(w, r, HTTPBadRequest(err))
+				return
+			}
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		response := LedgerEntriesResponse{
+			Entries: make([]LedgerEntryResponse, len(page.Entries)),
+			Cursor:  page.Cursor,
+		}
+
+		for i, e := range page.Entries {
+			response.Entries[i] = LedgerEntryResponse{
+				Account:    e.Account.String(),
+				SubAccount: e.SubAccount,
+				Entry:      e.Entry.String(),
+				Symbol:     e.Symbol.String(),
+				Amount:     e.Amount.StringFixedBank(e.Symbol.RoundingPlace()),
+				Timestamp:  time.Time(e.Timestamp).UTC().Format(time.RFC3339Nano),
+			}
+		}
+
+		render.Render(w, r, HTTPNewOKResponse(&response))
+	}
+}
+
+// GetLedgerBalances returns the balance of every ledger account for each symbol at the
+// end of a period. The period end defaults to the current time.
+func (h *AuditHandler) GetLedgerBalances() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		asOf, err := timeFromQuery(r, api.AsOfQueryParamName)
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
+		}
+
+		trial, err := h.ledger.GetTrialBalanceAt(r.Context(), asOf)
+		if err != nil {
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		response := LedgerBalancesResponse{
+			AsOf:     periodEnd(asOf),
+			Balances: make(map[string]map[string]string),
+		}
+
+		for _, l := range trial.Lines {
+			if _, ok := response.Balances[l.Symbol.String()]; !ok {
+				response.Balances[l.Symbol.String()] = make(map[string]string)
+			}
+			response.Balances[l.Symbol.String()][l.Account.String()] = l.Balance().StringFixedBank(l.Symbol.RoundingPlace())
+		}
+
+		render.Render(w, r, HTTPNewOKResponse(&response))
+	}
+}
+
+// GetTrialBalance returns the debit and credit totals for every ledger account and
+// symbol at the end of a period along with whether the ledger balances.
+func (h *AuditHandler) GetTrialBalance() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		asOf, err := timeFromQuery(r, api.AsOfQueryParamName)
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
+		}
+
+		trial, err := h.ledger.GetTrialBalanceAt(r.Context(), asOf)
+		if err != nil {
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		response := TrialBalanceResponse{
+			AsOf:     periodEnd(asOf),
+			Lines:    make([]TrialBalanceLineResponse, len(trial.Lines)),
+			Debits:   make(map[string]string),
+			Credits:  make(map[string]string),
+			Balanced: len(trial.Unbalanced()) == 0,
+		}
+
+		for i, l := range trial.Lines {
+			response.Lines[i] = TrialBalanceLineResponse{
+				Account: l.Account.String(),
+				Symbol:  l.Symbol.String(),
+				Debits:  l.Debits.StringFixedBank(l.Symbol.RoundingPlace()),
+				Credits: l.Credits.StringFixedBank(l.Symbol.RoundingPlace()),
+			}
+		}
+
+		debits, credits := trial.Totals()
+		for s, d := range debits {
+			response.Debits[s.String()] = d.StringFixedBank(s.RoundingPlace())
+		}
+		for s, c := range credits {
+			response.Credits[s.String()] = c.StringFixedBank(s.RoundingPlace())
+		}
+
+		render.Render(w, r, HTTPNewOKResponse(&response))
+	}
+}
+
+func ledgerQueryFromRequest(r *http.Request) (q persist.LedgerQuery, err error) {
+	query := r.URL.Query()
+
+	if v := query.Get(api.AccountQueryParamName); v != "" {
+		var a persist.LedgerAccount
+		a.FromString(v)
+		if a == persist.DefaultAccount {
+			err = fmt.Errorf("unknown ledger account '%s'", v)
+			return
+		}
+		q.Account = &a
+	}
+
+	if v := query.Get(api.SymbolQueryParamName); v != "" {
+		var s types.Symbol
+		s, err = types.FromString(v)
+		if err != nil {
+			return
+		}
+		q.Symbol = &s
+	}
+
+	q.From, err = timeFromQuery(r, api.FromQueryParamName)
+	if err != nil {
+		return
+	}
+
+	q.To, err = timeFromQuery(r, api.ToQueryParamName)
+	if err != nil {
+		return
+	}
+
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		err = errors.New("from must be before to")
+		return
+	}
+
+	q.Cursor = query.Get(api.CursorQueryParamName)
+
+	if v := query.Get(api.LimitQueryParamName); v != "" {
+		q.Limit, err = strconv.Atoi(v)
+		if err != nil || q.Limit <= 0 || q.Limit > MaxLedgerPageSize {
+			err = fmt.Errorf("limit must be between 1 and %d", MaxLedgerPageSize)
+			return
+		}
+	}
+
+	return
+}
+
+// timeFromQuery parses an RFC 3339 time from the named query parameter. A missing
+// parameter returns the zero time.
+func timeFromQuery(r *http.Request, name string) (time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return t, fmt.Errorf("invalid %s time '%s'; expected RFC 3339", name, v)
+	}
+
+	return t, nil
+}
+
+func periodEnd(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+type LedgerEntryResponse struct {
+	Account    string `json:"account"`
+	SubAccount string `json:"sub_account,omitempty"`
+	Entry      string `json:"entry"`
+	Symbol     string `json:"symbol"`
+	Amount     string `json:"amount"`
+	Timestamp  string `json:"timestamp"`
+}
+
+type LedgerEntriesResponse struct {
+	Entries []LedgerEntryResponse `json:"entries"`
+	Cursor  string                `json:"cursor,omitempty"`
+}
+
+// Render implements the render.Renderer interface for use with chi-router
+func (l *LedgerEntriesResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+type LedgerBalancesResponse struct {
+	AsOf     string                       `json:"as_of"`
+	Balances map[string]map[string]string `json:"balances"` // map[symbol][account]balance
+}
+
+// Render implements the render.Renderer interface for use with chi-router
+func (l *LedgerBalancesResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+type TrialBalanceLineResponse struct {
+	Account string `json:"account"`
+	Symbol  string `json:"symbol"`
+	Debits  string `json:"debits"`
+	Credits string `json:"credits"`
+}
+
+type TrialBalanceResponse struct {
+	AsOf     string                     `json:"as_of"`
+	Lines    []TrialBalanceLineResponse `json:"lines"`
+	Debits   map[string]string          `json:"debits"`
+	Credits  map[string]string          `json:"credits"`
+	Balanced bool                       `json:"balanced"`
+}
+
+// Render implements the render.Renderer interface for use with chi-router
+func (t *TrialBalanceResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/easterthebunny/render"
+	"github.com/easterthebunny/spew-order/internal/contexts"
+	"github.com/easterthebunny/spew-order/internal/persist"
+	"github.com/easterthebunny/spew-order/pkg/api"
+	"github.com/easterthebunny/spew-order/pkg/domain"
+)
+
+// GetLiabilityRoot provides an http handler that returns the root and per symbol totals
+// of the latest proof of liabilities tree. It does not require authorization.
+func (h *AccountHandler) GetLiabilityRoot(p *domain.LiabilityProver) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, err := p.GetLatestTree(r.Context())
+		if err != nil {
+			if errors.Is(err, persist.ErrObjectNotExist) {
+				render.Render(w, r, HTTPNotFound(errors.New("no proof of liabilities has been published")))
+				return
+			}
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		render.Render(w, r, HTTPNewOKResponse(api.LiabilityRootFromRecord(t)))
+	}
+}
+
+// GetLiabilityProof provides an http handler that returns the inclusion proof of the
+// account in the latest proof of liabilities tree.
+func (h *AccountHandler) GetLiabilityProof(p *domain.LiabilityProver) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		acct := contexts.GetAccount(ctx)
+
+		t, proof, err := p.GetProof(ctx, acct)
+		if err != nil {
+			if errors.Is(err, persist.ErrObjectNotExist) {
+				render.Render(w, r, HTTPNotFound(errors.New("no proof of liabilities for account")))
+				return
+			}
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		render.Render(w, r, HTTPNewOKResponse(api.LiabilityProofFromRecord(t, proof)))
+	}
+}
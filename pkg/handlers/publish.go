@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 
 	"github.com/easterthebunny/render"
 	"github.com/easterthebunny/spew-order/internal/contexts"
@@ -19,6 +20,12 @@ import (
 
 var (
 	ErrNoAccountIDFound = errors.New("no account identifier found in request")
+	// MaxBatchOrders is the largest number of orders accepted in a single batch request
+	MaxBatchOrders = 20
+)
+
+const (
+	maxIdempotencyKeyLength = 255
 )
 
 type OrderHandler struct {
@@ -73,6 +80,16 @@ func (h *OrderHandler) CancelOrder() func(w http.ResponseWriter, r *http.Request
 			return
 		}
 
+		if order.Status == persist.StatusRejected {
+			render.Render(w, r, HTTPBadRequest(errors.New("order was rejected")))
+			return
+		}
+
+		if order.Status == persist.StatusPartialCanceled {
+			render.Render(w, r, HTTPBadRequest(errors.New("order remainder already cancelled")))
+			return
+		}
+
 		err = h.queue.CancelOrder(r.Context(), order.Base)
 		if err != nil {
 			render.Render(w, r, HTTPInternalServerError(err))
@@ -88,6 +105,101 @@ func (h *OrderHandler) CancelOrder() func(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// CancelOrders publishes cancellations for all open and partially filled orders on
+// the account. The optional market and side query parameters narrow the orders
+// affected.
+func (h *OrderHandler) CancelOrders() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		acct := contexts.GetAccount(ctx)
+		if acct == nil {
+			render.Render(w, r, HTTPInternalServerError(errors.New("incorrect route structure")))
+			return
+		}
+
+		filter, err := orderFilterFromQuery(r)
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
+		}
+
+		canceled, failed, err := h.queue.CancelOrders(ctx, acct, filter)
+		if err != nil {
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		out := api.OrderCancelSummary{
+			Count:    len(canceled) + len(failed),
+			Canceled: api.BookOrderList{},
+			Failed:   api.BookOrderList{},
+		}
+
+		for _, order := range canceled {
+			out.Canceled = append(out.Canceled, api.BookOrder{
+				Guid:   order.ID.String(),
+				Order:  api.BuildOrderRequest(order.OrderRequest),
+				Status: api.StringOrderStatus(persist.StatusCanceled),
+			})
+		}
+
+		for _, order := range failed {
+			out.Failed = append(out.Failed, api.BookOrder{
+				Guid:   order.ID.String(),
+				Order:  api.BuildOrderRequest(order.OrderRequest),
+				Status: api.StringOrderStatus(persist.StatusOpen),
+			})
+		}
+
+		render.Render(w, r, HTTPNewOKResponse(&out))
+	}
+}
+
+// orderFilterFromQuery builds an order filter from the market and side query
+// parameters; a nil filter is returned when neither is present
+func orderFilterFromQuery(r *http.Request) (func(types.Order) bool, error) {
+	query := r.URL.Query()
+	market := query.Get(api.MarketQueryParamName)
+	side := query.Get(api.SideQueryParamName)
+
+	if market == "" && side == "" {
+		return nil, nil
+	}
+
+	var base, target types.Symbol
+	if market != "" {
+		var err error
+		base, target, err = api.MarketFromString(market)
+		if err != nil {
+			return nil, err
+		}
+
+		if !validPair(base, target) {
+			return nil, errors.New("invalid trade pair")
+		}
+	}
+
+	var action types.ActionType
+	if side != "" {
+		err := json.Unmarshal([]byte(fmt.Sprintf(`"%s"`, strings.ToUpper(side))), &action)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func(o types.Order) bool {
+		if market != "" && (o.Base != base || o.Target != target) {
+			return false
+		}
+
+		if side != "" && o.Action != action {
+			return false
+		}
+
+		return true
+	}, nil
+}
+
 // PostOrder publishes a message to Pub/Sub. PublishMessage only works
 // with topics that already exist.
 func (h *OrderHandler) PostOrder() func(w http.ResponseWriter, r *http.Request) {
@@ -116,45 +228,42 @@ func (h *OrderHandler) PostOrder() func(w http.ResponseWriter, r *http.Request)
 		or.Account = acct.ID
 		or.Owner = authz.ID
 
-		if !validPair(or.Base, or.Target) {
-			render.Render(w, r, HTTPBadRequest(errors.New("invalid trade pair")))
-			return
+		if or.ClientOrderID == "" {
+			or.ClientOrderID = r.Header.Get(api.IdempotencyKeyHeaderName)
 		}
 
-		switch t := or.Type.(type) {
-		case *types.MarketOrderType:
-			if (or.Action == types.ActionTypeBuy && t.Base != or.Base) || (or.Action == types.ActionTypeSell && t.Base != or.Target) {
-				render.Render(w, r, HTTPBadRequest(errors.New("quantity based market orders not supported")))
-				return
-			}
+		if err := validateOrderRequest(or); err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
+		}
 
-			if t.Quantity.LessThanOrEqual(decimal.NewFromInt(0)) {
-				render.Render(w, r, HTTPBadRequest(errors.New("quantity must be greater than 0")))
-				return
-			}
-		case *types.LimitOrderType:
-			if t.Base != or.Base {
-				render.Render(w, r, HTTPBadRequest(errors.New("incorrect base value for limit order")))
-				return
-			}
+		// a repeated request returns the order created by the original
+		if or.ClientOrderID != "" {
+			existing, err := h.queue.FindOrderByKey(ctx, acct, or.ClientOrderID)
+			if err != nil {
+				if isIdempotencyConflict(err) {
+					render.Render(w, r, HTTPConflict(err))
+					return
+				}
 
-			if t.Price.LessThanOrEqual(decimal.NewFromInt(0)) {
-				render.Render(w, r, HTTPBadRequest(errors.New("price must be greater than 0")))
+				render.Render(w, r, HTTPInternalServerError(err))
 				return
 			}
 
-			if t.Quantity.LessThanOrEqual(decimal.NewFromInt(0)) {
-				render.Render(w, r, HTTPBadRequest(errors.New("quantity must be greater than 0")))
+			if existing != nil {
+				o := api.BookOrder{
+					Guid:   existing.Base.ID.String(),
+					Order:  api.BuildOrderRequest(existing.Base.OrderRequest),
+					Status: api.StringOrderStatus(existing.Status),
+				}
+				render.Render(w, r, HTTPNewOKResponse(&o))
 				return
 			}
-		default:
-			render.Render(w, r, HTTPBadRequest(errors.New("incorrect order type")))
-			return
 		}
 
 		order, err := h.queue.PublishOrderRequest(ctx, or)
 		if err != nil {
-			if errors.Is(domain.ErrInsufficientBalanceForHold, err) {
+			if errors.Is(err, domain.ErrInsufficientBalanceForHold) || isIdempotencyConflict(err) {
 				render.Render(w, r, HTTPConflict(err))
 				return
 			}
@@ -172,6 +281,176 @@ func (h *OrderHandler) PostOrder() func(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// PostOrders publishes a batch of orders. Every order in the batch is validated before
+// any holds are placed; the batch mode determines whether a failure to place one
+// order prevents the others from being placed.
+func (h *OrderHandler) PostOrders() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		acct := contexts.GetAccount(ctx)
+		authz := contexts.GetAuthorization(ctx)
+		if acct == nil {
+			render.Render(w, r, HTTPInternalServerError(errors.New("incorrect route structure")))
+			return
+		}
+		ctx = contexts.AttachAccountID(ctx, acct.ID.String())
+
+		var in api.BatchOrderRequest
+		err := json.NewDecoder(r.Body).Decode(&in)
+		if err != nil {
+			render.Render(w, r, HTTPBadRequest(err))
+			return
+		}
+
+		if len(in.Orders) == 0 {
+			render.Render(w, r, HTTPBadRequest(errors.New("no orders in batch")))
+			return
+		}
+
+		if len(in.Orders) > MaxBatchOrders {
+			render.Render(w, r, HTTPBadRequest(fmt.Errorf("batch limited to %d orders", MaxBatchOrders)))
+			return
+		}
+
+		var atomic bool
+		switch in.Mode {
+		case api.BatchModeALLORNOTHING:
+			atomic = true
+		case api.BatchModeBESTEFFORT:
+			atomic = false
+		default:
+			render.Render(w, r, HTTPBadRequest(fmt.Errorf("unknown batch mode '%s'", in.Mode)))
+			return
+		}
+
+		res := api.BatchOrderResponse{
+			Mode:    in.Mode,
+			Results: make(api.BatchOrderResultList, len(in.Orders)),
+		}
+
+		// validate every order before placing any of them
+		var invalid bool
+		reqs := make([]types.OrderRequest, len(in.Orders))
+		for i, o := range in.Orders {
+			res.Results[i].Index = i
+
+			or, err := api.OrderRequestFromAPI(o)
+			if err == nil {
+				err = validateOrderRequest(or)
+			}
+
+			if err != nil {
+				res.Results[i].Error = &api.ResponseError{Detail: err.Error()}
+				invalid = true
+				continue
+			}
+
+			or.Account = acct.ID
+			or.Owner = authz.ID
+			reqs[i] = or
+		}
+
+		if invalid {
+			render.Render(w, r, &APIResponse{
+				HTTPStatusCode: http.StatusBadRequest,
+				StatusText:     "Bad Request",
+				Data:           &res,
+				Error:          NewErrorResponseSet(errors.New("invalid order in batch"))})
+			return
+		}
+
+		results, err := h.queue.PublishOrderRequests(ctx, reqs, atomic)
+		if err != nil {
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		var failed error
+		var placed int
+		for i, result := range results {
+			if result.Err != nil {
+				res.Results[i].Error = &api.ResponseError{Detail: result.Err.Error()}
+				if failed == nil && !errors.Is(result.Err, queue.ErrBatchAborted) {
+					failed = result.Err
+				}
+				continue
+			}
+
+			placed++
+			res.Results[i].Order = &api.BookOrder{
+				Guid:   result.Order.ID.String(),
+				Order:  api.BuildOrderRequest(result.Order.OrderRequest),
+				Status: api.StringOrderStatus(persist.StatusOpen),
+			}
+		}
+
+		// an all or nothing batch that failed without placing any orders is reported
+		// as a failure of the request as a whole; once an order is placed the
+		// results report which orders went out
+		if atomic && failed != nil && placed == 0 {
+			stat := http.StatusInternalServerError
+			if errors.Is(failed, domain.ErrInsufficientBalanceForHold) {
+				stat = http.StatusConflict
+			}
+
+			render.Render(w, r, &APIResponse{
+				HTTPStatusCode: stat,
+				StatusText:     http.StatusText(stat),
+				Data:           &res,
+				Error:          NewErrorResponseSet(failed)})
+			return
+		}
+
+		render.Render(w, r, HTTPNewOKResponse(&res))
+	}
+}
+
+func validateOrderRequest(or types.OrderRequest) error {
+
+	if !validPair(or.Base, or.Target) {
+		return errors.New("invalid trade pair")
+	}
+
+	if len(or.ClientOrderID) > maxIdempotencyKeyLength {
+		return fmt.Errorf("client order id limited to %d characters", maxIdempotencyKeyLength)
+	}
+
+	switch t := or.Type.(type) {
+	case *types.MarketOrderType:
+		if (or.Action == types.ActionTypeBuy && t.Base != or.Base) || (or.Action == types.ActionTypeSell && t.Base != or.Target) {
+			return errors.New("quantity based market orders not supported")
+		}
+
+		if t.Quantity.LessThanOrEqual(decimal.NewFromInt(0)) {
+			return errors.New("quantity must be greater than 0")
+		}
+
+		if t.MaxDeviation.IsNegative() {
+			return errors.New("max deviation cannot be negative")
+		}
+	case *types.LimitOrderType:
+		if t.Base != or.Base {
+			return errors.New("incorrect base value for limit order")
+		}
+
+		if t.Price.LessThanOrEqual(decimal.NewFromInt(0)) {
+			return errors.New("price must be greater than 0")
+		}
+
+		if t.Quantity.LessThanOrEqual(decimal.NewFromInt(0)) {
+			return errors.New("quantity must be greater than 0")
+		}
+	default:
+		return errors.New("incorrect order type")
+	}
+
+	return nil
+}
+
+func isIdempotencyConflict(err error) bool {
+	return errors.Is(err, domain.ErrIdempotencyKeyReused) || errors.Is(err, domain.ErrIdempotentRequestInProgress)
+}
+
 func validPair(a, b types.Symbol) bool {
 
 	pair := fmt.Sprintf("%s%s", a, b)
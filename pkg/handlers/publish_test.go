@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -17,6 +18,7 @@ import (
 	"github.com/easterthebunny/spew-order/internal/persist"
 	"github.com/easterthebunny/spew-order/internal/persist/kv"
 	"github.com/easterthebunny/spew-order/internal/queue"
+	"github.com/easterthebunny/spew-order/pkg/api"
 	"github.com/easterthebunny/spew-order/pkg/domain"
 	"github.com/easterthebunny/spew-order/pkg/types"
 	"github.com/shopspring/decimal"
@@ -106,6 +108,91 @@ func TestPostOrder(t *testing.T) {
 	})
 }
 
+func TestCancelOrders(t *testing.T) {
+
+	// set up the mocked pub sub and drain the subscription to the topic
+	subscription := make(chan domain.PubSubMessage)
+	mps := queue.NewMockPubSub()
+	mps.Subscribe(queue.OrderTopic, subscription)
+	go func() {
+		for range subscription {
+		}
+	}()
+
+	dmnAcct := domain.NewAccount()
+	store := persist.NewMockKVStore()
+	repo := kv.NewAccountRepository(store)
+	l := kv.NewLedgerRepository(store)
+	err := repo.Save(context.Background(), &persist.Account{ID: dmnAcct.ID.String()})
+	if err != nil {
+		t.FailNow()
+	}
+	svc := domain.NewBalanceManager(repo, l, funding.NewMockSource())
+	svc.PostAmtToBalance(context.Background(), dmnAcct, types.SymbolBitcoin, decimal.NewFromFloat(5.5))
+	svc.PostAmtToBalance(context.Background(), dmnAcct, types.SymbolCipherMtn, decimal.NewFromFloat(1000))
+
+	oq := queue.NewOrderQueue(mps, svc)
+	handler := NewOrderHandler(oq)
+
+	ctx := contexts.AttachAccountID(context.Background(), dmnAcct.ID.String())
+	for _, pair := range [][]types.Symbol{
+		{types.SymbolBitcoin, types.SymbolEthereum},
+		{types.SymbolBitcoin, types.SymbolEthereum},
+		{types.SymbolBitcoin, types.SymbolDogecoin}} {
+
+		_, err := oq.PublishOrderRequest(ctx, types.OrderRequest{
+			Base:   pair[0],
+			Target: pair[1],
+			Action: types.ActionTypeBuy,
+			Type: &types.LimitOrderType{
+				Base:     pair[0],
+				Price:    decimal.NewFromFloat(0.25),
+				Quantity: decimal.NewFromFloat(1.0)}})
+		if err != nil {
+			t.Fatalf("error: %s", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		code     int
+		expected int
+	}{
+		{name: "NoMatchingSide", query: "?side=SELL", code: 200, expected: 0},
+		{name: "InvalidMarket", query: "?market=BTC", code: 400, expected: 0},
+		{name: "SingleMarket", query: "?market=BTC-ETH&side=BUY", code: 200, expected: 2},
+		{name: "AllMarkets", query: "", code: 200, expected: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodDelete, "/"+test.query, nil)
+			if err != nil {
+				t.Fatalf("request error: %s", err)
+			}
+			r = r.WithContext(contexts.AttachAccount(r.Context(), *dmnAcct))
+
+			handler.CancelOrders()(w, r)
+
+			assert.Equal(t, test.code, w.Code)
+			if test.code != 200 {
+				return
+			}
+
+			var res struct {
+				Data api.OrderCancelSummary `json:"data"`
+			}
+			err = json.NewDecoder(w.Body).Decode(&res)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, res.Data.Count)
+			assert.Len(t, res.Data.Canceled, test.expected)
+		})
+	}
+}
+
 func NewPost(cont string) string {
 	post :=
 		`POST / HTTP/1.1
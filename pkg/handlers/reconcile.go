@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/easterthebunny/render"
+	"github.com/easterthebunny/spew-order/internal/persist"
+)
+
+// GetReconciliations returns the history of custodian reconciliation runs, oldest first
+func (h *AuditHandler) GetReconciliations() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		runs, err := h.reconciler.GetReconciliations(r.Context())
+		if err != nil {
+			render.Render(w, r, HTTPInternalServerError(err))
+			return
+		}
+
+		var out []render.Renderer
+		for _, run := range runs {
+			out = append(out, reconciliationResponse(run))
+		}
+
+		render.Render(w, r, HTTPNewOKListResponse(out))
+	}
+}
+
+func reconciliationResponse(run *persist.Reconciliation) *ReconciliationResponse {
+	res := &ReconciliationResponse{
+		ID:        run.ID,
+		Timestamp: time.Time(run.Timestamp).UTC().Format(time.RFC3339),
+		Items:     []ReconciliationItemResponse{},
+		Errors:    run.Errors,
+	}
+
+	if res.Errors == nil {
+		res.Errors = []string{}
+	}
+
+	for _, item := range run.Items {
+		place := item.Symbol.RoundingPlace()
+		i := ReconciliationItemResponse{
+			Symbol:            item.Symbol.String(),
+			Holdings:          make(map[string]string),
+			Total:             item.Total.StringFixedBank(place),
+			Liabilities:       item.Liabilities.StringFixedBank(place),
+			Payable:           item.Payable.StringFixedBank(place),
+			Difference:        item.Difference().StringFixedBank(place),
+			PayableDifference: item.PayableDifference().StringFixedBank(place),
+			Threshold:         item.Threshold.StringFixedBank(place),
+			Discrepant:        item.Discrepant,
+		}
+
+		for src, amt := range item.Holdings {
+			i.Holdings[src] = amt.StringFixedBank(place)
+		}
+
+		res.Items = append(res.Items, i)
+	}
+
+	return res
+}
+
+type ReconciliationItemResponse struct {
+	Symbol            string            `json:"symbol"`
+	Holdings          map[string]string `json:"holdings"` // map[source]balance
+	Total             string            `json:"total"`
+	Liabilities       string            `json:"liabilities"`
+	Payable           string            `json:"payable"`
+	Difference        string            `json:"difference"`
+	PayableDifference string            `json:"payable_difference"`
+	Threshold         string            `json:"threshold"`
+	Discrepant        bool              `json:"discrepant"`
+}
+
+type ReconciliationResponse struct {
+	ID        string                       `json:"id"`
+	Timestamp string                       `json:"timestamp"`
+	Items     []ReconciliationItemResponse `json:"items"`
+	Errors    []string                     `json:"errors"`
+}
+
+// Render implements the render.Renderer interface for use with chi-router
+func (rr *ReconciliationResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -12,11 +12,14 @@ import (
 )
 
 type Router struct {
-	AuthStore persist.AuthorizationRepository
-	Balance   *domain.BalanceManager
-	AuthProv  middleware.AuthenticationProvider
-	Orders    *OrderHandler
-	Accounts  *AccountHandler
+	AuthStore   persist.AuthorizationRepository
+	Balance     *domain.BalanceManager
+	AuthProv    middleware.AuthenticationProvider
+	Orders      *OrderHandler
+	Accounts    *AccountHandler
+	Audit       persist.AuditRepository
+	Liabilities *domain.LiabilityProver
+	Valuer      *domain.Valuer
 }
 
 // Router ...
@@ -26,6 +29,9 @@ func (d *Router) Routes() http.Handler {
 	// set CORS headers early and short circuit the response loop
 	r.Use(middleware.SetCORSHeaders)
 
+	// published proof of liabilities root is public
+	r.Get("/liabilities", d.Accounts.GetLiabilityRoot(d.Liabilities))
+
 	// set up routes that require authorization
 	r.Route("/", d.AuthorizedRoutes())
 
@@ -49,6 +55,7 @@ func (d *Router) AuthorizedRoutes() func(r chi.Router) {
 func (d *Router) AccountRoutes() func(r chi.Router) {
 	return func(r chi.Router) {
 		r.Get("/", d.Accounts.GetAccounts())
+		r.Post("/", d.Accounts.PostAccount(d.Balance, d.AuthStore))
 		r.Route(fmt.Sprintf("/{%s}", api.AccountPathParamName), d.AccountSubRoutes())
 	}
 }
@@ -56,10 +63,19 @@ func (d *Router) AccountRoutes() func(r chi.Router) {
 func (d *Router) AccountSubRoutes() func(r chi.Router) {
 	return func(r chi.Router) {
 		r.Use(d.Accounts.AccountCtx(d.Balance, chi.URLParam))
-		r.Get("/", d.Accounts.GetAccount())
+		r.Get("/", d.Accounts.GetAccount(d.Valuer))
+		r.Patch("/", d.Accounts.PatchAccount(d.Balance, d.Audit))
 		r.Route("/orders", d.OrderRoutes())
 		r.Route("/transactions", d.TransactionRoutes())
+		r.Route("/withdrawals", d.WithdrawalRoutes())
+		r.Get("/deposits", d.Accounts.GetDeposits(d.Balance))
+		r.Get("/liabilities/proof", d.Accounts.GetLiabilityProof(d.Liabilities))
+		r.Route("/allowlist", d.AllowlistRoutes())
+		r.With(d.Accounts.ActiveAccountCtx()).Post("/transfers", d.Accounts.PostTransfer(d.Balance))
+		r.Post("/referral", d.Accounts.PostReferral(d.Balance))
 		r.Route("/addresses", d.AddressRoutes())
+		r.Get("/balances/history", d.Accounts.GetBalanceHistory(d.Balance, d.Valuer))
+		r.Get("/statement", d.Accounts.GetStatement(d.Balance))
 	}
 }
 
@@ -71,6 +87,7 @@ func (d *Router) AddressRoutes() func(r chi.Router) {
 
 func (d *Router) AddressSubRoutes() func(r chi.Router) {
 	return func(r chi.Router) {
+		r.Use(d.Accounts.ActiveAccountCtx())
 		r.Use(d.Accounts.AddressCtx(d.Balance))
 		r.Get("/", d.Accounts.GetFundingAddress())
 	}
@@ -78,15 +95,38 @@ func (d *Router) AddressSubRoutes() func(r chi.Router) {
 
 func (d *Router) TransactionRoutes() func(r chi.Router) {
 	return func(r chi.Router) {
-		r.Post("/", d.Accounts.PostTransaction(d.Balance))
+		r.With(d.Accounts.ActiveAccountCtx()).Post("/", d.Accounts.PostTransaction(d.Balance))
 		r.Get("/", d.Accounts.GetAccountTransactions())
 	}
 }
 
+func (d *Router) WithdrawalRoutes() func(r chi.Router) {
+	return func(r chi.Router) {
+		r.Get("/", d.Accounts.GetWithdrawals(d.Balance))
+		r.Get("/fee", d.Accounts.GetWithdrawalFee(d.Balance))
+		r.Get(fmt.Sprintf("/{%s}", api.WithdrawalPathParamName), d.Accounts.GetWithdrawal(d.Balance))
+	}
+}
+
+func (d *Router) AllowlistRoutes() func(r chi.Router) {
+	return func(r chi.Router) {
+		r.Get("/", d.Accounts.GetAllowlist(d.Balance))
+		r.With(d.Accounts.ActiveAccountCtx()).Post("/", d.Accounts.PostAllowlistEntry(d.Balance, d.Audit))
+		r.Get("/history", d.Accounts.GetAllowlistHistory(d.Balance, d.Audit))
+		r.Route(fmt.Sprintf("/{%s}", api.AllowlistEntryPathParamName), func(r chi.Router) {
+			r.Use(d.Accounts.ActiveAccountCtx())
+			r.Patch("/", d.Accounts.PatchAllowlistEntry(d.Balance, d.Audit))
+			r.Delete("/", d.Accounts.DeleteAllowlistEntry(d.Balance, d.Audit))
+		})
+	}
+}
+
 func (d *Router) OrderRoutes() func(r chi.Router) {
 	return func(r chi.Router) {
-		r.Post("/", d.Orders.PostOrder())
+		r.With(d.Accounts.ActiveAccountCtx()).Post("/", d.Orders.PostOrder())
 		r.Get("/", d.Accounts.GetAccountOrders())
+		r.Delete("/", d.Orders.CancelOrders())
+		r.With(d.Accounts.ActiveAccountCtx()).Post("/batch", d.Orders.PostOrders())
 		r.Route(fmt.Sprintf("/{%s}", api.OrderPathParamName), d.OrderSubRoutes())
 	}
 }
@@ -135,7 +175,25 @@ func (ar *AuditRouter) Routes() http.Handler {
 	// set CORS headers early and short circuit the response loop
 	r.Use(middleware.SetCORSHeaders)
 
-	r.Get("/audit", ar.Audit.AuditBalances())
+	r.Route("/audit", func(r chi.Router) {
+		r.Get("/", ar.Audit.AuditBalances())
+		r.Get("/diff", ar.Audit.GetAuditDiff())
+
+		r.Route("/runs", func(r chi.Router) {
+			r.Get("/", ar.Audit.GetAuditRuns())
+			r.Get(fmt.Sprintf("/{%s}", api.AuditRunPathParamName), ar.Audit.GetAuditRun())
+		})
+	})
+
+	r.Get("/reconciliations", ar.Audit.GetReconciliations())
+
+	r.Get("/campaigns", ar.Audit.GetCampaigns())
+
+	r.Route("/ledger", func(r chi.Router) {
+		r.Get("/entries", ar.Audit.GetLedgerEntries())
+		r.Get("/balances", ar.Audit.GetLedgerBalances())
+		r.Get("/trial-balance", ar.Audit.GetTrialBalance())
+	})
 
 	return r
 }
@@ -44,6 +44,9 @@ func (o Order) MarshalJSON() ([]byte, error) {
 	or["owner"] = o.Owner
 	or["holdID"] = o.HoldID
 	or["feeHoldID"] = o.FeeHoldID
+	if o.ClientOrderID != "" {
+		or["clientOrderID"] = o.ClientOrderID
+	}
 	or["account"] = o.OrderRequest.Account.String()
 	or["timestamp"] = o.Timestamp.UnixNano()
 
@@ -66,6 +69,7 @@ func (o *Order) UnmarshalJSON(b []byte) error {
 		Owner     string    `json:"owner"`
 		HoldID    string    `json:"holdID"`
 		FeeHoldID string    `json:"feeHoldID"`
+		ClientID  string    `json:"clientOrderID"`
 		Account   uuid.UUID `json:"account"`
 		Timestamp int64     `json:"timestamp"`
 	}{}
@@ -78,6 +82,7 @@ func (o *Order) UnmarshalJSON(b []byte) error {
 	o.OrderRequest.Owner = tp.Owner
 	o.OrderRequest.HoldID = tp.HoldID
 	o.OrderRequest.FeeHoldID = tp.FeeHoldID
+	o.OrderRequest.ClientOrderID = tp.ClientID
 	o.ID = tp.ID
 	o.Timestamp = time.Unix(0, tp.Timestamp)
 
@@ -175,6 +180,9 @@ type OrderType interface {
 type MarketOrderType struct {
 	Base     Symbol          `json:"base"`
 	Quantity decimal.Decimal `json:"quantity"`
+	// MaxDeviation optionally caps slippage as a fraction of the first
+	// matched price; zero means no cap
+	MaxDeviation decimal.Decimal `json:"maxDeviation"`
 }
 
 func (m MarketOrderType) String() string {
@@ -354,6 +362,9 @@ func (m MarketOrderType) MarshalJSON() ([]byte, error) {
 	data["base"] = m.Base
 	data["quantity"] = m.Quantity
 	data["name"] = m.Name()
+	if !m.MaxDeviation.IsZero() {
+		data["maxDeviation"] = m.MaxDeviation
+	}
 
 	return json.Marshal(data)
 }
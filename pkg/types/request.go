@@ -22,7 +22,10 @@ type OrderRequest struct {
 	FeePaid   bool       `json:"feePaid"`
 	Owner     string     `json:"owner"`
 	Account   uuid.UUID  `json:"account"`
-	Type      OrderType  `json:"-"`
+	// ClientOrderID is an optional client supplied identifier used to detect
+	// duplicate submissions of the same order
+	ClientOrderID string    `json:"clientOrderID"`
+	Type          OrderType `json:"-"`
 }
 
 func (r OrderRequest) MarshalMap() map[string]interface{} {
@@ -84,15 +87,17 @@ func (r *OrderRequest) UnmarshalJSON(b []byte) error {
 		}
 	case "MARKET":
 		order := struct {
-			Base     Symbol          `json:"base"`
-			Quantity decimal.Decimal `json:"quantity"`
+			Base         Symbol          `json:"base"`
+			Quantity     decimal.Decimal `json:"quantity"`
+			MaxDeviation decimal.Decimal `json:"maxDeviation"`
 		}{}
 		if err := json.Unmarshal(tp.Type, &order); err != nil {
 			return err
 		}
 		r.Type = &MarketOrderType{
-			Base:     order.Base,
-			Quantity: order.Quantity,
+			Base:         order.Base,
+			Quantity:     order.Quantity,
+			MaxDeviation: order.MaxDeviation,
 		}
 	}
 
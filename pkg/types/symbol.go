@@ -93,6 +93,16 @@ var (
 		SymbolEthereum,
 		SymbolUniswap,
 	}
+	// Symbols lists every symbol provided by this package
+	Symbols = []Symbol{
+		SymbolBitcoin,
+		SymbolEthereum,
+		SymbolBitcoinCash,
+		SymbolDogecoin,
+		SymbolUniswap,
+		SymbolCipherMtn,
+		SymbolCardano,
+	}
 )
 
 // String provides a string representation to an Symbol value. Defaults to